@@ -28,7 +28,9 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
@@ -261,6 +263,29 @@ type EndpointSpec struct {
 	WorkflowImportPath string `yaml:"workflowImportPath"`
 	// Config additional configs for the endpoint
 	Config map[string]interface{} `yaml:"config,omitempty"`
+	// Timeout in milliseconds for the whole request, overrides the
+	// http.endpointTimeout default of the gateway.
+	Timeout int `yaml:"timeout,omitempty"`
+	// MaxBodyBytes caps the request body size, larger bodies are rejected
+	// with a 413. Overrides the http.maxBodyBytes default of the gateway.
+	MaxBodyBytes int `yaml:"maxBodyBytes,omitempty"`
+	// RateLimit overrides the inbound rate limits of the gateway.
+	RateLimit *RateLimitSpec `yaml:"rateLimit,omitempty"`
+	// QPSLevel ranks the endpoint for load shedding unless it has a
+	// Criticality, the highest levels are shed first.
+	QPSLevel int `yaml:"qpsLevel,omitempty"`
+	// Criticality is the name of the zanzibar.Criticality constant of the
+	// "criticality" field, one of critical, high, normal, low or sheddable.
+	Criticality string `yaml:"-"`
+	// CORS overrides the cross-origin policy of the gateway, an endpoint
+	// with a policy sets CORS headers even when the gateway has none.
+	CORS *CORSSpec `yaml:"cors,omitempty"`
+	// Match restricts the endpoint to requests with a host or header
+	// values, so that several endpoints can serve the same method and path.
+	Match *MatchSpec `yaml:"match,omitempty"`
+	// APIVersions are the versions of the API the endpoint serves, each
+	// version is registered on its own route.
+	APIVersions []*APIVersionSpec `yaml:"apiVersions,omitempty"`
 	// if "httpClient", which client to call.
 	ClientID string `yaml:"clientId,omitempty"`
 	// if "httpClient", which client method to call.
@@ -410,6 +435,39 @@ func NewEndpointSpec(
 		config = endpointConfigObj["config"].(map[string]interface{})
 	}
 
+	timeout, err := positiveIntField(endpointConfigObj, "timeout", yamlFile)
+	if err != nil {
+		return nil, err
+	}
+	maxBodyBytes, err := positiveIntField(endpointConfigObj, "maxBodyBytes", yamlFile)
+	if err != nil {
+		return nil, err
+	}
+	rateLimit, err := newRateLimitSpec(endpointConfigObj, yamlFile)
+	if err != nil {
+		return nil, err
+	}
+	qpsLevel, err := positiveIntField(endpointConfigObj, "qpsLevel", yamlFile)
+	if err != nil {
+		return nil, err
+	}
+	criticality, err := endpointCriticality(endpointConfigObj, yamlFile)
+	if err != nil {
+		return nil, err
+	}
+	cors, err := newCORSSpec(endpointConfigObj, yamlFile)
+	if err != nil {
+		return nil, err
+	}
+	match, err := newMatchSpec(endpointConfigObj, yamlFile)
+	if err != nil {
+		return nil, err
+	}
+	apiVersions, err := newAPIVersionSpecs(endpointConfigObj, yamlFile)
+	if err != nil {
+		return nil, err
+	}
+
 	espec := &EndpointSpec{
 		ModuleSpec:           mspec,
 		YAMLFile:             yamlFile,
@@ -429,6 +487,14 @@ func NewEndpointSpec(
 		ClientMethod:         clientMethod,
 		DefaultHeaders:       h.defaultHeaders,
 		Config:               config,
+		Timeout:              timeout,
+		MaxBodyBytes:         maxBodyBytes,
+		RateLimit:            rateLimit,
+		QPSLevel:             qpsLevel,
+		Criticality:          criticality,
+		CORS:                 cors,
+		Match:                match,
+		APIVersions:          apiVersions,
 	}
 
 	defaultMidSpecs, err := getOrderedDefaultMiddlewareSpecs(
@@ -444,6 +510,388 @@ func NewEndpointSpec(
 	return augmentEndpointSpec(espec, endpointConfigObj, midSpecs, defaultMidSpecs)
 }
 
+// positiveIntField reads an optional positive integer field of an endpoint
+// such as the timeout in milliseconds.
+func positiveIntField(config map[string]interface{}, field, yamlFile string) (int, error) {
+	value, ok := config[field]
+	if !ok {
+		return 0, nil
+	}
+	n, ok := value.(float64)
+	if !ok || n <= 0 || n != float64(int(n)) {
+		return 0, errors.Errorf(
+			"endpoint config %q must have a positive integer %s, got %v", yamlFile, field, value,
+		)
+	}
+	return int(n), nil
+}
+
+// criticalities maps the "criticality" field of endpoints to the constants
+// of the runtime.
+var criticalities = map[string]string{
+	"critical":  "CriticalityCritical",
+	"high":      "CriticalityHigh",
+	"normal":    "CriticalityNormal",
+	"low":       "CriticalityLow",
+	"sheddable": "CriticalitySheddable",
+}
+
+func endpointCriticality(config map[string]interface{}, yamlFile string) (string, error) {
+	value, ok := config["criticality"]
+	if !ok {
+		return "", nil
+	}
+	name, _ := value.(string)
+	criticality, ok := criticalities[name]
+	if !ok {
+		return "", errors.Errorf(
+			"endpoint config %q has unknown criticality %v, expected critical, high, normal, low or sheddable",
+			yamlFile, value,
+		)
+	}
+	return criticality, nil
+}
+
+// RateLimitSpec is the "rateLimit" field of an endpoint, it overrides the
+// "rateLimit.*" defaults of the gateway, rates are in requests per second.
+type RateLimitSpec struct {
+	RPS         float64 `yaml:"rps,omitempty" json:"rps,omitempty"`
+	Burst       int     `yaml:"burst,omitempty" json:"burst,omitempty"`
+	CallerRPS   float64 `yaml:"callerRps,omitempty" json:"callerRps,omitempty"`
+	CallerBurst int     `yaml:"callerBurst,omitempty" json:"callerBurst,omitempty"`
+	KeyHeader   string  `yaml:"keyHeader,omitempty" json:"keyHeader,omitempty"`
+	KeyRPS      float64 `yaml:"keyRps,omitempty" json:"keyRps,omitempty"`
+	KeyBurst    int     `yaml:"keyBurst,omitempty" json:"keyBurst,omitempty"`
+	Concurrency bool    `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+}
+
+func newRateLimitSpec(config map[string]interface{}, yamlFile string) (*RateLimitSpec, error) {
+	value, ok := config["rateLimit"]
+	if !ok {
+		return nil, nil
+	}
+	bytes, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read rateLimit of endpoint config %q", yamlFile)
+	}
+	spec := &RateLimitSpec{}
+	if err := yaml.Unmarshal(bytes, spec); err != nil {
+		return nil, errors.Wrapf(err, "could not read rateLimit of endpoint config %q", yamlFile)
+	}
+	if spec.RPS < 0 || spec.Burst < 0 || spec.CallerRPS < 0 || spec.CallerBurst < 0 ||
+		spec.KeyRPS < 0 || spec.KeyBurst < 0 {
+		return nil, errors.Errorf("endpoint config %q must not have negative rate limits", yamlFile)
+	}
+	if (spec.KeyRPS > 0) != (spec.KeyHeader != "") {
+		return nil, errors.Errorf("endpoint config %q must set both keyHeader and keyRps", yamlFile)
+	}
+	return spec, nil
+}
+
+// Fields returns the set fields of the spec as Go literals keyed by the
+// field names of zanzibar.RateLimitOptions.
+func (r *RateLimitSpec) Fields() map[string]string {
+	fields := map[string]string{}
+	floats := map[string]float64{"RPS": r.RPS, "CallerRPS": r.CallerRPS, "KeyRPS": r.KeyRPS}
+	for name, value := range floats {
+		if value != 0 {
+			fields[name] = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	ints := map[string]int{"Burst": r.Burst, "CallerBurst": r.CallerBurst, "KeyBurst": r.KeyBurst}
+	for name, value := range ints {
+		if value != 0 {
+			fields[name] = strconv.Itoa(value)
+		}
+	}
+	if r.KeyHeader != "" {
+		fields["KeyHeader"] = strconv.Quote(r.KeyHeader)
+	}
+	if r.Concurrency {
+		fields["Concurrency"] = "true"
+	}
+	return fields
+}
+
+// CORSSpec is the "cors" field of an endpoint, it overrides the "http.cors.*"
+// defaults of the gateway, maxAge is in milliseconds.
+type CORSSpec struct {
+	AllowedOrigins   []string `yaml:"allowedOrigins,omitempty" json:"allowedOrigins,omitempty"`
+	AllowedHeaders   []string `yaml:"allowedHeaders,omitempty" json:"allowedHeaders,omitempty"`
+	AllowCredentials bool     `yaml:"allowCredentials,omitempty" json:"allowCredentials,omitempty"`
+	MaxAge           int      `yaml:"maxAge,omitempty" json:"maxAge,omitempty"`
+}
+
+func newCORSSpec(config map[string]interface{}, yamlFile string) (*CORSSpec, error) {
+	value, ok := config["cors"]
+	if !ok {
+		return nil, nil
+	}
+	bytes, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read cors of endpoint config %q", yamlFile)
+	}
+	spec := &CORSSpec{}
+	if err := yaml.Unmarshal(bytes, spec); err != nil {
+		return nil, errors.Wrapf(err, "could not read cors of endpoint config %q", yamlFile)
+	}
+	for _, origin := range spec.AllowedOrigins {
+		if origin == "" || strings.Count(origin, "*") > 1 {
+			return nil, errors.Errorf(
+				"endpoint config %q has invalid cors origin %q, expected an origin with at most one \"*\"",
+				yamlFile, origin,
+			)
+		}
+		if origin == "*" && spec.AllowCredentials {
+			return nil, errors.Errorf(
+				"endpoint config %q cannot allow cors credentials for the \"*\" origin, list the allowed origins instead",
+				yamlFile,
+			)
+		}
+	}
+	if spec.MaxAge < 0 {
+		return nil, errors.Errorf("endpoint config %q must not have a negative cors maxAge", yamlFile)
+	}
+	return spec, nil
+}
+
+// Fields returns the set fields of the spec as Go literals keyed by the
+// field names of zanzibar.CORSOptions.
+func (c *CORSSpec) Fields() map[string]string {
+	fields := map[string]string{}
+	lists := map[string][]string{"AllowedOrigins": c.AllowedOrigins, "AllowedHeaders": c.AllowedHeaders}
+	for name, values := range lists {
+		if len(values) == 0 {
+			continue
+		}
+		quoted := make([]string, len(values))
+		for i, value := range values {
+			quoted[i] = strconv.Quote(value)
+		}
+		fields[name] = "[]string{" + strings.Join(quoted, ", ") + "}"
+	}
+	if c.AllowCredentials {
+		fields["AllowCredentials"] = "true"
+	}
+	if c.MaxAge != 0 {
+		fields["MaxAge"] = strconv.Itoa(c.MaxAge) + " * time.Millisecond"
+	}
+	return fields
+}
+
+// MatchSpec is the "match" field of an endpoint. Host is an exact host or a
+// wildcard such as "*.example.com" and Headers maps header names to the value
+// the requests must have, "*" only requires the header.
+type MatchSpec struct {
+	Host    string            `yaml:"host,omitempty" json:"host,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+func newMatchSpec(config map[string]interface{}, yamlFile string) (*MatchSpec, error) {
+	value, ok := config["match"]
+	if !ok {
+		return nil, nil
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("endpoint config %q must have a map match, got %v", yamlFile, value)
+	}
+	spec := &MatchSpec{}
+	for key, field := range fields {
+		switch key {
+		case "host":
+			host, _ := field.(string)
+			if host == "" || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+				return nil, errors.Errorf(
+					"endpoint config %q has invalid match host %v, expected a host optionally prefixed with \"*.\"",
+					yamlFile, field,
+				)
+			}
+			spec.Host = host
+		case "headers":
+			headers, err := headerValues(field, yamlFile, "match")
+			if err != nil {
+				return nil, err
+			}
+			spec.Headers = headers
+		default:
+			return nil, errors.Errorf("endpoint config %q has unknown match field %q, expected host or headers", yamlFile, key)
+		}
+	}
+	if spec.Host == "" && len(spec.Headers) == 0 {
+		return nil, errors.Errorf("endpoint config %q must match a host or headers", yamlFile)
+	}
+	return spec, nil
+}
+
+// headerValues reads a non empty map of header names to values.
+func headerValues(field interface{}, yamlFile, what string) (map[string]string, error) {
+	values, ok := field.(map[string]interface{})
+	if !ok || len(values) == 0 {
+		return nil, errors.Errorf("endpoint config %q must have a non empty map of %s headers", yamlFile, what)
+	}
+	headers := make(map[string]string, len(values))
+	for name, value := range values {
+		switch value.(type) {
+		case string, float64, bool:
+			// yaml reads x-api-version: 2 as a number
+			headers[name] = fmt.Sprint(value)
+		default:
+			return nil, errors.Errorf(
+				"endpoint config %q has invalid value %v for %s header %q", yamlFile, value, what, name,
+			)
+		}
+	}
+	return headers, nil
+}
+
+// APIVersionSpec is an entry of the "apiVersions" field of an endpoint. A
+// version is selected by a path prefix such as "/v1", by header values or,
+// for at most one version, by neither. Dates are either dates such as
+// "2027-06-30" or RFC 3339 timestamps and are only allowed on deprecated
+// versions.
+type APIVersionSpec struct {
+	Name            string            `yaml:"name" json:"name"`
+	PathPrefix      string            `yaml:"pathPrefix,omitempty" json:"pathPrefix,omitempty"`
+	Headers         map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Deprecated      bool              `yaml:"deprecated,omitempty" json:"deprecated,omitempty"`
+	DeprecatedSince string            `yaml:"deprecatedSince,omitempty" json:"deprecatedSince,omitempty"`
+	Sunset          string            `yaml:"sunset,omitempty" json:"sunset,omitempty"`
+
+	deprecatedSince time.Time
+	sunset          time.Time
+}
+
+func newAPIVersionSpecs(config map[string]interface{}, yamlFile string) ([]*APIVersionSpec, error) {
+	value, ok := config["apiVersions"]
+	if !ok {
+		return nil, nil
+	}
+	entries, ok := value.([]interface{})
+	if !ok || len(entries) == 0 {
+		return nil, errors.Errorf("endpoint config %q must have a non empty list of apiVersions", yamlFile)
+	}
+	specs := make([]*APIVersionSpec, 0, len(entries))
+	names := map[string]bool{}
+	unselected := 0
+	for _, entry := range entries {
+		spec, err := newAPIVersionSpec(entry, yamlFile)
+		if err != nil {
+			return nil, err
+		}
+		if names[spec.Name] {
+			return nil, errors.Errorf("endpoint config %q has duplicate api version %q", yamlFile, spec.Name)
+		}
+		names[spec.Name] = true
+		if spec.PathPrefix == "" && len(spec.Headers) == 0 {
+			unselected++
+		}
+		specs = append(specs, spec)
+	}
+	if unselected > 1 {
+		return nil, errors.Errorf(
+			"endpoint config %q must select all api versions but one by pathPrefix or headers", yamlFile,
+		)
+	}
+	return specs, nil
+}
+
+func newAPIVersionSpec(entry interface{}, yamlFile string) (*APIVersionSpec, error) {
+	fields, ok := entry.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("endpoint config %q must have a map api version, got %v", yamlFile, entry)
+	}
+	spec := &APIVersionSpec{}
+	spec.Name, _ = fields["name"].(string)
+	if spec.Name == "" {
+		return nil, errors.Errorf("endpoint config %q must name its api versions", yamlFile)
+	}
+	for key, field := range fields {
+		var err error
+		switch key {
+		case "name":
+		case "pathPrefix":
+			spec.PathPrefix, _ = field.(string)
+			if !strings.HasPrefix(spec.PathPrefix, "/") || strings.HasSuffix(spec.PathPrefix, "/") {
+				err = errors.Errorf("pathPrefix %v must start with \"/\" and have no trailing slash", field)
+			}
+		case "headers":
+			spec.Headers, err = headerValues(field, yamlFile, "api version")
+		case "deprecated":
+			spec.Deprecated, ok = field.(bool)
+			if !ok {
+				err = errors.Errorf("deprecated must be a boolean, got %v", field)
+			}
+		case "deprecatedSince":
+			spec.DeprecatedSince, spec.deprecatedSince, err = apiVersionDate(field)
+		case "sunset":
+			spec.Sunset, spec.sunset, err = apiVersionDate(field)
+		default:
+			err = errors.Errorf(
+				"unknown field %q, expected name, pathPrefix, headers, deprecated, deprecatedSince or sunset", key,
+			)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "endpoint config %q has invalid api version %q", yamlFile, spec.Name)
+		}
+	}
+	if !spec.Deprecated && (spec.DeprecatedSince != "" || spec.Sunset != "") {
+		return nil, errors.Errorf(
+			"endpoint config %q must set deprecated on api version %q to set its deprecatedSince or sunset",
+			yamlFile, spec.Name,
+		)
+	}
+	return spec, nil
+}
+
+// apiVersionDate parses a date such as "2027-06-30" or an RFC 3339 timestamp.
+func apiVersionDate(field interface{}) (string, time.Time, error) {
+	value, _ := field.(string)
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return value, t.UTC(), nil
+		}
+	}
+	return "", time.Time{}, errors.Errorf("%v is neither a date such as 2027-06-30 nor an RFC 3339 timestamp", field)
+}
+
+// Fields returns the set fields of the spec as Go literals keyed by the
+// field names of zanzibar.APIVersion.
+func (v *APIVersionSpec) Fields() map[string]string {
+	fields := map[string]string{"Name": strconv.Quote(v.Name)}
+	if v.PathPrefix != "" {
+		fields["PathPrefix"] = strconv.Quote(v.PathPrefix)
+	}
+	if len(v.Headers) > 0 {
+		names := make([]string, 0, len(v.Headers))
+		for name := range v.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pairs := make([]string, len(names))
+		for i, name := range names {
+			pairs[i] = strconv.Quote(name) + ": " + strconv.Quote(v.Headers[name])
+		}
+		fields["Headers"] = "map[string]string{" + strings.Join(pairs, ", ") + "}"
+	}
+	if v.Deprecated {
+		fields["Deprecated"] = "true"
+	}
+	if !v.deprecatedSince.IsZero() {
+		fields["DeprecatedSince"] = timeLiteral(v.deprecatedSince)
+	}
+	if !v.sunset.IsZero() {
+		fields["Sunset"] = timeLiteral(v.sunset)
+	}
+	return fields
+}
+
+func timeLiteral(t time.Time) string {
+	return fmt.Sprintf("time.Date(%d, %d, %d, %d, %d, %d, 0, time.UTC)",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+	)
+}
+
 func getOrderedDefaultMiddlewareSpecs(
 	cfgDir string,
 	middlewareSpecs map[string]*MiddlewareSpec,
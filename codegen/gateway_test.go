@@ -92,3 +92,190 @@ func TestGetModuleConfigNameWhenConfigFileIsJSON(t *testing.T) {
 	}
 	assert.Equal(t, expectedFileName, getModuleConfigFileName(instance))
 }
+
+func TestPositiveIntField(t *testing.T) {
+	cases := []struct {
+		cfg     string
+		timeout int
+		valid   bool
+	}{
+		{"{}", 0, true},
+		{"timeout: 250", 250, true},
+		{"timeout: 0", 0, false},
+		{"timeout: 1.5", 0, false},
+		{"timeout: fast", 0, false},
+		{"timeout: -10", 0, false},
+	}
+	for _, c := range cases {
+		endpointObj := make(map[string]interface{})
+		assert.NoError(t, yaml.Unmarshal([]byte(c.cfg), &endpointObj))
+		timeout, err := positiveIntField(endpointObj, "timeout", "endpoint.yaml")
+		assert.Equal(t, c.valid, err == nil, c.cfg)
+		assert.Equal(t, c.timeout, timeout, c.cfg)
+	}
+}
+
+func TestNewRateLimitSpec(t *testing.T) {
+	cases := []struct {
+		cfg    string
+		fields map[string]string
+		valid  bool
+	}{
+		{"{}", nil, true},
+		{"rateLimit: {rps: 2.5, burst: 5}", map[string]string{"RPS": "2.5", "Burst": "5"}, true},
+		{"rateLimit: {keyHeader: x-user, keyRps: 1, concurrency: true}", map[string]string{
+			"KeyHeader":   `"x-user"`,
+			"KeyRPS":      "1",
+			"Concurrency": "true",
+		}, true},
+		{"rateLimit: {rps: -1}", nil, false},
+		{"rateLimit: {keyRps: 1}", nil, false},
+		{"rateLimit: {rps: fast}", nil, false},
+	}
+	for _, c := range cases {
+		endpointObj := make(map[string]interface{})
+		assert.NoError(t, yaml.Unmarshal([]byte(c.cfg), &endpointObj))
+		spec, err := newRateLimitSpec(endpointObj, "endpoint.yaml")
+		assert.Equal(t, c.valid, err == nil, c.cfg)
+		if c.fields == nil {
+			assert.Nil(t, spec, c.cfg)
+			continue
+		}
+		assert.Equal(t, c.fields, spec.Fields(), c.cfg)
+	}
+}
+
+func TestEndpointCriticality(t *testing.T) {
+	cases := []struct {
+		cfg         string
+		criticality string
+		valid       bool
+	}{
+		{"{}", "", true},
+		{"criticality: critical", "CriticalityCritical", true},
+		{"criticality: sheddable", "CriticalitySheddable", true},
+		{"criticality: urgent", "", false},
+		{"criticality: 1", "", false},
+	}
+	for _, c := range cases {
+		endpointObj := make(map[string]interface{})
+		assert.NoError(t, yaml.Unmarshal([]byte(c.cfg), &endpointObj))
+		criticality, err := endpointCriticality(endpointObj, "endpoint.yaml")
+		assert.Equal(t, c.valid, err == nil, c.cfg)
+		assert.Equal(t, c.criticality, criticality, c.cfg)
+	}
+}
+
+func TestNewCORSSpec(t *testing.T) {
+	cases := []struct {
+		cfg    string
+		fields map[string]string
+		valid  bool
+	}{
+		{"{}", nil, true},
+		{"cors: {allowedOrigins: ['https://*.example.com', 'https://example.org'], maxAge: 60000}", map[string]string{
+			"AllowedOrigins": `[]string{"https://*.example.com", "https://example.org"}`,
+			"MaxAge":         "60000 * time.Millisecond",
+		}, true},
+		{"cors: {allowedHeaders: [x-token], allowCredentials: true}", map[string]string{
+			"AllowedHeaders":   `[]string{"x-token"}`,
+			"AllowCredentials": "true",
+		}, true},
+		{"cors: {allowedOrigins: ['https://*.*.com']}", nil, false},
+		{"cors: {allowedOrigins: ['']}", nil, false},
+		{"cors: {allowedOrigins: ['*'], allowCredentials: true}", nil, false},
+		{"cors: {maxAge: -1}", nil, false},
+		{"cors: {maxAge: long}", nil, false},
+	}
+	for _, c := range cases {
+		endpointObj := make(map[string]interface{})
+		assert.NoError(t, yaml.Unmarshal([]byte(c.cfg), &endpointObj))
+		spec, err := newCORSSpec(endpointObj, "endpoint.yaml")
+		assert.Equal(t, c.valid, err == nil, c.cfg)
+		if c.fields == nil {
+			assert.Nil(t, spec, c.cfg)
+			continue
+		}
+		assert.Equal(t, c.fields, spec.Fields(), c.cfg)
+	}
+}
+
+func TestNewMatchSpec(t *testing.T) {
+	cases := []struct {
+		cfg   string
+		spec  *MatchSpec
+		valid bool
+	}{
+		{"{}", nil, true},
+		{"match: {host: '*.example.com'}", &MatchSpec{Host: "*.example.com"}, true},
+		{"match: {headers: {x-api-version: 2, x-beta: '*'}}", &MatchSpec{
+			Headers: map[string]string{"x-api-version": "2", "x-beta": "*"},
+		}, true},
+		{"match: {host: api.example.com, headers: {x-api-version: v2}}", &MatchSpec{
+			Host:    "api.example.com",
+			Headers: map[string]string{"x-api-version": "v2"},
+		}, true},
+		{"match: {}", nil, false},
+		{"match: api.example.com", nil, false},
+		{"match: {host: 'api.*.com'}", nil, false},
+		{"match: {headers: {}}", nil, false},
+		{"match: {headers: {x-api-version: [1, 2]}}", nil, false},
+		{"match: {path: /a}", nil, false},
+	}
+	for _, c := range cases {
+		endpointObj := make(map[string]interface{})
+		assert.NoError(t, yaml.Unmarshal([]byte(c.cfg), &endpointObj))
+		spec, err := newMatchSpec(endpointObj, "endpoint.yaml")
+		assert.Equal(t, c.valid, err == nil, c.cfg)
+		assert.Equal(t, c.spec, spec, c.cfg)
+	}
+}
+
+func TestNewAPIVersionSpecs(t *testing.T) {
+	cases := []struct {
+		cfg    string
+		fields []map[string]string
+		valid  bool
+	}{
+		{"{}", nil, true},
+		{`apiVersions:
+- {name: v1, pathPrefix: /v1, deprecated: true, deprecatedSince: 2026-01-15, sunset: '2027-06-30T12:00:00Z'}
+- {name: v2, headers: {x-api-version: 2}}
+- {name: v3}`, []map[string]string{
+			{
+				"Name":            `"v1"`,
+				"PathPrefix":      `"/v1"`,
+				"Deprecated":      "true",
+				"DeprecatedSince": "time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)",
+				"Sunset":          "time.Date(2027, 6, 30, 12, 0, 0, 0, time.UTC)",
+			},
+			{"Name": `"v2"`, "Headers": `map[string]string{"x-api-version": "2"}`},
+			{"Name": `"v3"`},
+		}, true},
+		{"apiVersions: []", nil, false},
+		{"apiVersions: [{pathPrefix: /v1}]", nil, false},
+		{"apiVersions: [{name: v1, pathPrefix: v1}]", nil, false},
+		{"apiVersions: [{name: v1, pathPrefix: /v1/}]", nil, false},
+		{"apiVersions: [{name: v1, pathPrefix: /v1}, {name: v1, pathPrefix: /v2}]", nil, false},
+		{"apiVersions: [{name: v1}, {name: v2}]", nil, false},
+		{"apiVersions: [{name: v1, sunset: 2027-06-30}]", nil, false},
+		{"apiVersions: [{name: v1, deprecated: true, sunset: soon}]", nil, false},
+		{"apiVersions: [{name: v1, deprecated: yes please}]", nil, false},
+		{"apiVersions: [{name: v1, prefix: /v1}]", nil, false},
+	}
+	for _, c := range cases {
+		endpointObj := make(map[string]interface{})
+		assert.NoError(t, yaml.Unmarshal([]byte(c.cfg), &endpointObj))
+		specs, err := newAPIVersionSpecs(endpointObj, "endpoint.yaml")
+		assert.Equal(t, c.valid, err == nil, c.cfg)
+		if c.fields == nil {
+			assert.Nil(t, specs, c.cfg)
+			continue
+		}
+		fields := make([]map[string]string, len(specs))
+		for i, spec := range specs {
+			fields[i] = spec.Fields()
+		}
+		assert.Equal(t, c.fields, fields, c.cfg)
+	}
+}
@@ -39,6 +39,7 @@ const (
 	antHTTPRef        = "%s.http.ref"
 	antMeta           = "%s.meta"
 	antHandler        = "%s.handler"
+	antHTTPIdempotent = "%s.http.idempotent"
 
 	// AntHTTPReqDefBoxed annotates a method so that the genereted method takes
 	// generated argument directly instead of a struct that warps the argument.
@@ -57,6 +58,13 @@ type PathSegment struct {
 	BodyIdentifier string
 	ParamName      string
 	Required       bool
+	// Constraint restricts the values of a param in the route, it is "int"
+	// for integer fields unless the path sets one, e.g. "{id:[0-9]{4}}"
+	Constraint string
+	// IntBits is the size of an integer param, 0 for a string param
+	IntBits int
+
+	typeSpec compile.TypeSpec
 }
 
 // ExceptionSpec contains information about thrift exceptions
@@ -81,6 +89,9 @@ type MethodSpec struct {
 	// Used by edge gateway to generate endpoint.
 	EndpointName string
 	HTTPPath     string
+	// RoutePath is the path registered with the router, with the
+	// constraints of the params
+	RoutePath    string
 	PathSegments []PathSegment
 	annotations  annotations
 	IsEndpoint   bool
@@ -106,6 +117,9 @@ type MethodSpec struct {
 	ReqHeaders []string
 	// ResHeaders needed, generated from "zanzibar.http.resHeaders"
 	ResHeaders []string
+	// Idempotent is whether the method can be retried, generated from
+	// "zanzibar.http.idempotent" or else from the HTTP method
+	Idempotent bool
 
 	RequestType            string
 	ShortRequestType       string
@@ -165,6 +179,7 @@ type annotations struct {
 	Handler         string
 	HTTPReqDefBoxed string
 	HTTPResNoBody   string
+	HTTPIdempotent  string
 }
 
 // StructSpec specifies a Go struct to be generated.
@@ -211,6 +226,7 @@ func NewMethod(
 		Handler:         fmt.Sprintf(antHandler, ant),
 		HTTPReqDefBoxed: fmt.Sprintf(AntHTTPReqDefBoxed, ant),
 		HTTPResNoBody:   fmt.Sprintf(antHTTPResNoBody, ant),
+		HTTPIdempotent:  fmt.Sprintf(antHTTPIdempotent, ant),
 	}
 
 	method.GenCodePkgName, err = packageHelper.TypePackageName(thriftFile)
@@ -246,6 +262,11 @@ func NewMethod(
 
 	method.EndpointName = funcSpec.Annotations[method.annotations.Handler]
 
+	err = method.setIdempotent(funcSpec.Annotations[method.annotations.HTTPIdempotent])
+	if err != nil {
+		return nil, err
+	}
+
 	err = method.setOKStatusCode(funcSpec.Annotations[method.annotations.HTTPStatus])
 	if err != nil {
 		return nil, err
@@ -294,6 +315,27 @@ func NewMethod(
 	return method, nil
 }
 
+// setIdempotent sets whether the method is idempotent from the annotation,
+// defaulting to the idempotency of its HTTP method.
+func (ms *MethodSpec) setIdempotent(annotation string) error {
+	if annotation == "" {
+		switch ms.HTTPMethod {
+		case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+			ms.Idempotent = true
+		}
+		return nil
+	}
+	idempotent, err := strconv.ParseBool(annotation)
+	if err != nil {
+		return errors.Wrapf(
+			err, "Could not parse annotation '%s' for method %s",
+			ms.annotations.HTTPIdempotent, ms.Name,
+		)
+	}
+	ms.Idempotent = idempotent
+	return nil
+}
+
 // setRequestType sets the request type of the method specification. If the
 // "zanzibar.http.req.def.boxed" is true, then the first parameter will be used as
 // the request body; otherwise a new struct is generated to bundle the request
@@ -471,16 +513,16 @@ func (ms *MethodSpec) setExceptions(
 
 func (ms *MethodSpec) findParamsAnnotation(
 	fields compile.FieldGroup, paramName string,
-) (string, bool, bool) {
+) (string, *compile.FieldSpec, bool) {
 	var identifier string
-	var required bool
+	var paramField *compile.FieldSpec
 	visitor := func(
 		goPrefix string, thriftPrefix string, field *compile.FieldSpec,
 	) bool {
 		if param, ok := field.Annotations[ms.annotations.HTTPRef]; ok {
-			if param == "params."+paramName[1:] {
+			if param == "params."+paramName {
 				identifier = goPrefix + "." + PascalCase(field.Name)
-				required = field.Required
+				paramField = field
 				return true
 			}
 		}
@@ -490,10 +532,10 @@ func (ms *MethodSpec) findParamsAnnotation(
 	walkFieldGroups(fields, visitor)
 
 	if identifier == "" {
-		return "", required, false
+		return "", nil, false
 	}
 
-	return identifier, required, true
+	return identifier, paramField, true
 }
 
 func (ms *MethodSpec) setRequestParamFields(
@@ -523,7 +565,28 @@ func (ms *MethodSpec) setRequestParamFields(
 			}
 		}
 
-		if segment.Required {
+		if segment.IntBits > 0 {
+			typeName, err := GoType(packageHelper, segment.typeSpec)
+			if err != nil {
+				return err
+			}
+			statements.append("{")
+			statements.appendf("\tvalue, ok := req.GetParamInt(%q, %d)",
+				segment.ParamName, segment.IntBits,
+			)
+			statements.append("\tif !ok {")
+			statements.append("\t\treturn ctx")
+			statements.append("\t}")
+			if segment.Required {
+				statements.appendf("\trequestBody%s = %s(value)",
+					segment.BodyIdentifier, typeName,
+				)
+			} else {
+				statements.appendf("\ttyped := %s(value)", typeName)
+				statements.appendf("\trequestBody%s = &typed", segment.BodyIdentifier)
+			}
+			statements.append("}")
+		} else if segment.Required {
 			statements.appendf("requestBody%s = req.Params.Get(%q)",
 				segment.BodyIdentifier, segment.ParamName,
 			)
@@ -837,31 +900,78 @@ func (ms *MethodSpec) setHTTPPath(httpPath string, funcSpec *compile.FunctionSpe
 	ms.HTTPPath = httpPath
 
 	segments := strings.Split(httpPath[1:], "/")
+	routeSegments := make([]string, len(segments))
 	ms.PathSegments = make([]PathSegment, len(segments))
 	for i := 0; i < len(segments); i++ {
 		segment := segments[i]
+		routeSegments[i] = segment
 
-		if segment == "" || segment[0] != ':' {
+		paramName, constraint, isParam := parsePathParam(segment)
+		if !isParam {
 			ms.PathSegments[i].Type = "static"
 			ms.PathSegments[i].Text = segment
 		} else {
 			ms.PathSegments[i].Type = "param"
 
-			var fieldSelect string
-			var required bool
-			var ok bool
-
-			fieldSelect, required, ok = ms.findParamsAnnotation(
-				compile.FieldGroup(funcSpec.ArgsSpec), segment,
+			fieldSelect, field, ok := ms.findParamsAnnotation(
+				compile.FieldGroup(funcSpec.ArgsSpec), paramName,
 			)
 
 			if !ok {
 				panic(fmt.Sprintf("cannot find params: %s for http path %s", segment, httpPath))
 			}
 			ms.PathSegments[i].BodyIdentifier = fieldSelect
-			ms.PathSegments[i].ParamName = segment[1:]
-			ms.PathSegments[i].Required = required
+			ms.PathSegments[i].ParamName = paramName
+			ms.PathSegments[i].Required = field.Required
+			ms.PathSegments[i].typeSpec = field.Type
+
+			// integer fields only match integers in the route
+			if bits := intBits(field.Type); bits > 0 {
+				ms.PathSegments[i].IntBits = bits
+				if constraint == "" {
+					constraint = "int"
+				}
+			}
+			ms.PathSegments[i].Constraint = constraint
+			if constraint == "" {
+				routeSegments[i] = ":" + paramName
+			} else {
+				routeSegments[i] = "{" + paramName + ":" + constraint + "}"
+			}
+		}
+	}
+	ms.RoutePath = "/" + strings.Join(routeSegments, "/")
+}
+
+// parsePathParam returns the name and constraint of a ":name" or
+// "{name:constraint}" path segment, the constraint is optional.
+func parsePathParam(segment string) (string, string, bool) {
+	if strings.HasPrefix(segment, ":") {
+		return segment[1:], "", true
+	}
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		inner := segment[1 : len(segment)-1]
+		if idx := strings.Index(inner, ":"); idx >= 0 {
+			return inner[:idx], inner[idx+1:], true
 		}
+		return inner, "", true
+	}
+	return "", "", false
+}
+
+// intBits returns the size of an integer type, or 0 for other types.
+func intBits(typeSpec compile.TypeSpec) int {
+	switch compile.RootTypeSpec(typeSpec).(type) {
+	case *compile.I8Spec:
+		return 8
+	case *compile.I16Spec:
+		return 16
+	case *compile.I32Spec:
+		return 32
+	case *compile.I64Spec:
+		return 64
+	default:
+		return 0
 	}
 }
 
@@ -1599,9 +1599,32 @@ func (generator *GatewayServiceGenerator) ComputeSpec(
 }
 
 // Generate returns the gateway build result, which contains the service and
-// service test main files, and no spec
+// service test main files and the routes.json of the service, and no spec
 func (generator *GatewayServiceGenerator) Generate(instance *ModuleInstance) (*BuildResult, error) {
+	// route conflicts would otherwise only surface when the gateway registers
+	// its endpoints at bootstrap
+	routes, err := NewRouteTable(
+		readEndpointDependencySpecs(instance),
+		generator.packageHelper.WhitelistedPaths(),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"Error building the route table for %s",
+			instance.InstanceName,
+		)
+	}
+	routeTable, err := json.MarshalIndent(routes, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"Error generating service routes.json for %s",
+			instance.InstanceName,
+		)
+	}
+
 	var fileMap sync.Map
+	fileMap.Store("routes.json", append(routeTable, '\n'))
 	workCount := 5
 	runner := parallelize.NewUnboundedRunner(workCount)
 
@@ -1814,6 +1837,22 @@ func readClientDependencySpecs(instance *ModuleInstance) []*ClientSpec {
 	return clients
 }
 
+// readEndpointDependencySpecs returns the specs of the endpoints a module
+// depends on, in the order the module registers them.
+func readEndpointDependencySpecs(instance *ModuleInstance) []*EndpointSpec {
+	var endpoints []*EndpointSpec
+	for _, endpointDep := range instance.ResolvedDependencies["endpoint"] {
+		specs, _ := endpointDep.GeneratedSpec().([]*EndpointSpec)
+		sorted := make([]*EndpointSpec, len(specs))
+		copy(sorted, specs)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].HandleID < sorted[j].HandleID
+		})
+		endpoints = append(endpoints, sorted...)
+	}
+	return endpoints
+}
+
 // GenerateDependencyStruct generates a module struct with placeholders for the
 // instance module based on the defined dependency configuration
 func GenerateDependencyStruct(
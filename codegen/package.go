@@ -74,6 +74,8 @@ type PackageHelper struct {
 	moduleSearchPaths map[string][]string
 	// defaultDependencies is a dictionary of glob patterns for default dependencies
 	defaultDependencies map[string][]string
+	// whitelistedPaths are the router paths that can be registered next to param paths
+	whitelistedPaths []string
 }
 
 // NewDefaultPackageHelperOptions returns a new default PackageHelperOptions, all optional fields are set as default.
@@ -116,6 +118,9 @@ type PackageHelperOptions struct {
 	ModuleSearchPaths map[string][]string
 	// DefaultDependencies is a dictionary of glob patterns for folders that contain default dependencies
 	DefaultDependencies map[string][]string
+	// WhitelistedPaths are the router paths that can be registered next to param paths such as /a and /:b,
+	// they should match the router.whitelistedPaths of the runtime config
+	WhitelistedPaths []string
 
 	// key to read qps levels or not in the endpoint levels
 	QPSLevelsEnabled bool
@@ -244,6 +249,7 @@ func NewPackageHelper(
 		moduleSearchPaths:      options.ModuleSearchPaths,
 		defaultDependencies:    options.DefaultDependencies,
 		defaultHeaders:         options.DefaultHeaders,
+		whitelistedPaths:       options.WhitelistedPaths,
 		moduleIdlSubDir:        moduleIdlSubDir,
 	}
 	return p, nil
@@ -418,3 +424,9 @@ func (p PackageHelper) DeputyReqHeader() string {
 func (p PackageHelper) DefaultHeaders() []string {
 	return p.defaultHeaders
 }
+
+// WhitelistedPaths returns the router paths that can be registered next to
+// param paths, the route table of a service is built with them.
+func (p PackageHelper) WhitelistedPaths() []string {
+	return p.whitelistedPaths
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package codegen
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	zrouter "github.com/uber/zanzibar/runtime/router"
+)
+
+// RouteSpec is a route the HTTP endpoints of a gateway service register with
+// its router, the routes of a service are written to its routes.json.
+type RouteSpec struct {
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Host       string            `json:"host,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	APIVersion string            `json:"apiVersion,omitempty"`
+	Deprecated bool              `json:"deprecated,omitempty"`
+	Sunset     string            `json:"sunset,omitempty"`
+	EndpointID string            `json:"endpointId"`
+	HandlerID  string            `json:"handlerId"`
+	// YAMLFile is the config file of the endpoint
+	YAMLFile string `json:"-"`
+}
+
+// builtinRoutes are the routes the gateway registers before the endpoints of
+// its services: the health checks of runtime/gateway.go and the endpoints
+// of runtime/admin.go, which are registered when admin.enabled is set.
+var builtinRoutes = []*RouteSpec{
+	{Method: "GET", Path: "/health", EndpointID: "health", HandlerID: "health"},
+	{Method: "GET", Path: "/health/live", EndpointID: "health", HandlerID: "live"},
+	{Method: "GET", Path: "/health/ready", EndpointID: "health", HandlerID: "ready"},
+	{Method: "GET", Path: "/admin/config", EndpointID: "admin", HandlerID: "config"},
+	{Method: "GET", Path: "/admin/routes", EndpointID: "admin", HandlerID: "routes"},
+	{Method: "GET", Path: "/admin/tchannel", EndpointID: "admin", HandlerID: "tchannel"},
+	{Method: "GET", Path: "/admin/middlewares", EndpointID: "admin", HandlerID: "middlewares"},
+	{Method: "GET", Path: "/admin/clients", EndpointID: "admin", HandlerID: "clients"},
+	{Method: "GET", Path: "/admin/loglevel", EndpointID: "admin", HandlerID: "loglevel"},
+	{Method: "PUT", Path: "/admin/loglevel", EndpointID: "admin", HandlerID: "setLoglevel"},
+}
+
+func (r *RouteSpec) match() zrouter.Match {
+	return zrouter.Match{Host: r.Host, Headers: r.Headers}
+}
+
+// String returns the route and the endpoint that registers it.
+func (r *RouteSpec) String() string {
+	route := r.Method + " " + r.Path
+	if m := r.match().String(); m != "" {
+		route += " [" + m + "]"
+	}
+	if r.YAMLFile == "" {
+		return fmt.Sprintf("%s (%s.%s built into the gateway)", route, r.EndpointID, r.HandlerID)
+	}
+	return fmt.Sprintf("%s (%s.%s in %s)", route, r.EndpointID, r.HandlerID, r.YAMLFile)
+}
+
+// RouteConflict is a route the router rejects, or a route that is
+// ambiguous with a route registered before it.
+type RouteConflict struct {
+	Route *RouteSpec
+	// With is the route registered before Route that it conflicts with, it
+	// is nil when no single route is the cause.
+	With *RouteSpec
+	// Ambiguous is true when the router accepts both routes but a request
+	// can satisfy both of their matches.
+	Ambiguous bool
+	// Err is the error of the router.
+	Err error
+	// Whitelist is a path that lets the router register both routes once it
+	// is added to router.whitelistedPaths.
+	Whitelist string
+}
+
+// RouteConflictError reports the conflicting and ambiguous routes of a
+// route table.
+type RouteConflictError struct {
+	Conflicts []*RouteConflict
+}
+
+// Error returns a report with a few lines per conflict.
+func (e *RouteConflictError) Error() string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "%d conflicting or ambiguous routes:", len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(&report, "\n  %s", c.Route)
+		switch {
+		case c.Ambiguous:
+			fmt.Fprintf(&report, "\n    is ambiguous with %s: a request can satisfy both matches", c.With)
+		case c.With != nil:
+			fmt.Fprintf(&report, "\n    conflicts with %s", c.With)
+		default:
+			fmt.Fprintf(&report, "\n    cannot be registered: %s", c.Err)
+		}
+		if c.Whitelist != "" {
+			fmt.Fprintf(&report, "\n    adding %q to router.whitelistedPaths lets both routes be registered", c.Whitelist)
+		}
+	}
+	return report.String()
+}
+
+// NewRouteTable registers the routes of the HTTP endpoints the way the
+// gateway does at bootstrap, after the built-in health and admin routes and
+// with endpoints in order, and returns them sorted by path, method and
+// match. The built-in routes are not returned. It returns a
+// *RouteConflictError when routes conflict or are ambiguous.
+func NewRouteTable(endpoints []*EndpointSpec, whitelistedPaths []string) ([]*RouteSpec, error) {
+	router := &zrouter.Router{WhitelistedPaths: whitelistedPaths}
+	for _, route := range builtinRoutes {
+		err := router.HandleMatch(route.Method, route.Path, route.match(), http.NotFoundHandler())
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not register %s", route)
+		}
+	}
+	// registered are the routes conflicts are looked for among
+	registered := append([]*RouteSpec(nil), builtinRoutes...)
+	routes := []*RouteSpec{}
+	var conflicts []*RouteConflict
+	for _, e := range endpoints {
+		endpointRoutes, err := newEndpointRoutes(e)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range endpointRoutes {
+			err := router.HandleMatch(route.Method, route.Path, route.match(), http.NotFoundHandler())
+			if err != nil {
+				conflicts = append(conflicts, findRouteConflict(route, registered, whitelistedPaths, err))
+				continue
+			}
+			for _, other := range registered {
+				if other.Method == route.Method && samePath(other.Path, route.Path) &&
+					zrouter.Ambiguous(other.match(), route.match()) {
+					conflicts = append(conflicts, &RouteConflict{Route: route, With: other, Ambiguous: true})
+				}
+			}
+			registered = append(registered, route)
+			routes = append(routes, route)
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, &RouteConflictError{Conflicts: conflicts}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		if routes[i].Method != routes[j].Method {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].match().String() < routes[j].match().String()
+	})
+	return routes, nil
+}
+
+// newEndpointRoutes returns the routes of an endpoint, one per API version.
+func newEndpointRoutes(e *EndpointSpec) ([]*RouteSpec, error) {
+	if e.EndpointType != "http" || len(e.ModuleSpec.Services) == 0 {
+		return nil, nil
+	}
+	method := findMethod(e.ModuleSpec, e.ThriftServiceName, e.ThriftMethodName)
+	if method == nil {
+		return nil, errors.Errorf(
+			"endpoint config %q: could not find thriftServiceName %q + methodName %q in module",
+			e.YAMLFile, e.ThriftServiceName, e.ThriftMethodName,
+		)
+	}
+
+	route := RouteSpec{
+		Method:     method.HTTPMethod,
+		Path:       method.RoutePath,
+		EndpointID: e.EndpointID,
+		HandlerID:  e.HandleID,
+		YAMLFile:   e.YAMLFile,
+	}
+	if e.Match != nil {
+		route.Host = e.Match.Host
+		route.Headers = e.Match.Headers
+	}
+	if len(e.APIVersions) == 0 {
+		return []*RouteSpec{&route}, nil
+	}
+
+	routes := make([]*RouteSpec, 0, len(e.APIVersions))
+	for _, version := range e.APIVersions {
+		versioned := route
+		versioned.Path = version.PathPrefix + route.Path
+		versioned.APIVersion = version.Name
+		versioned.Deprecated = version.Deprecated
+		versioned.Sunset = version.Sunset
+		if len(version.Headers) > 0 {
+			versioned.Headers = make(map[string]string, len(route.Headers)+len(version.Headers))
+			for name, value := range route.Headers {
+				versioned.Headers[name] = value
+			}
+			for name, value := range version.Headers {
+				versioned.Headers[name] = value
+			}
+		}
+		routes = append(routes, &versioned)
+	}
+	return routes, nil
+}
+
+// findRouteConflict registers the route next to each route registered
+// before it to find the one it conflicts with. When the paths of the two
+// routes differ it also looks for a path to whitelist.
+func findRouteConflict(route *RouteSpec, routes []*RouteSpec, whitelistedPaths []string, err error) *RouteConflict {
+	conflict := &RouteConflict{Route: route, Err: err}
+	for _, other := range routes {
+		if other.Method != route.Method || registerRoutes(whitelistedPaths, other, route) == nil {
+			continue
+		}
+		conflict.With = other
+		if samePath(other.Path, route.Path) {
+			return conflict
+		}
+		for _, path := range []string{route.Path, other.Path} {
+			whitelisted := append(whitelistedPaths[:len(whitelistedPaths):len(whitelistedPaths)], path)
+			if registerRoutes(whitelisted, other, route) == nil {
+				conflict.Whitelist = path
+				break
+			}
+		}
+		return conflict
+	}
+	return conflict
+}
+
+// registerRoutes registers the routes in order with a new router.
+func registerRoutes(whitelistedPaths []string, routes ...*RouteSpec) error {
+	router := &zrouter.Router{WhitelistedPaths: whitelistedPaths}
+	for _, route := range routes {
+		err := router.HandleMatch(route.Method, route.Path, route.match(), http.NotFoundHandler())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// samePath reports whether the router registers both paths as the same path,
+// it ignores the leading and trailing slashes.
+func samePath(a, b string) bool {
+	return strings.Trim(a, "/") == strings.Trim(b, "/")
+}
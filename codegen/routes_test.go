@@ -0,0 +1,158 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package codegen
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func routeEndpoint(handleID, httpMethod, path string) *EndpointSpec {
+	return &EndpointSpec{
+		EndpointType:      "http",
+		EndpointID:        "bar",
+		HandleID:          handleID,
+		ThriftServiceName: "Bar",
+		ThriftMethodName:  handleID,
+		YAMLFile:          handleID + ".yaml",
+		ModuleSpec: &ModuleSpec{
+			Services: []*ServiceSpec{{
+				Name: "Bar",
+				Methods: []*MethodSpec{{
+					Name:       handleID,
+					HTTPMethod: httpMethod,
+					RoutePath:  path,
+				}},
+			}},
+		},
+	}
+}
+
+func TestNewRouteTable(t *testing.T) {
+	hello := routeEndpoint("hello", "GET", "/bar/hello")
+	hello.Match = &MatchSpec{Host: "*.example.com"}
+	hello.APIVersions = []*APIVersionSpec{
+		{Name: "v1", PathPrefix: "/v1", Deprecated: true, Sunset: "2027-06-30"},
+		{Name: "v2", Headers: map[string]string{"x-api-version": "2"}},
+	}
+	tchannel := routeEndpoint("echo", "", "")
+	tchannel.EndpointType = "tchannel"
+
+	routes, err := NewRouteTable([]*EndpointSpec{
+		routeEndpoint("user", "GET", "/bar/users/{id:int}"),
+		hello,
+		routeEndpoint("create", "POST", "/bar/users"),
+		tchannel,
+	}, nil)
+	assert.NoError(t, err)
+
+	table, err := json.Marshal(routes)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"method": "GET", "path": "/bar/hello", "host": "*.example.com",
+			"headers": {"x-api-version": "2"}, "apiVersion": "v2", "endpointId": "bar", "handlerId": "hello"},
+		{"method": "POST", "path": "/bar/users", "endpointId": "bar", "handlerId": "create"},
+		{"method": "GET", "path": "/bar/users/{id:int}", "endpointId": "bar", "handlerId": "user"},
+		{"method": "GET", "path": "/v1/bar/hello", "host": "*.example.com", "apiVersion": "v1",
+			"deprecated": true, "sunset": "2027-06-30", "endpointId": "bar", "handlerId": "hello"}
+	]`, string(table))
+}
+
+func TestNewRouteTableConflicts(t *testing.T) {
+	beta := routeEndpoint("beta", "GET", "/bar/hello")
+	beta.Match = &MatchSpec{Headers: map[string]string{"x-beta": "*"}}
+	v2 := routeEndpoint("v2", "GET", "/bar/hello")
+	v2.Match = &MatchSpec{Headers: map[string]string{"x-api-version": "2"}}
+
+	_, err := NewRouteTable([]*EndpointSpec{
+		routeEndpoint("user", "GET", "/bar/users/:id"),
+		routeEndpoint("me", "GET", "/bar/users/me"),
+		routeEndpoint("hello", "GET", "/bar/hello"),
+		routeEndpoint("helloAgain", "GET", "/bar/hello/"),
+		routeEndpoint("create", "POST", "/bar/users/me"),
+		beta,
+		v2,
+	}, nil)
+	conflictErr, ok := err.(*RouteConflictError)
+	if !assert.True(t, ok, "expected a route conflict error, got %v", err) {
+		return
+	}
+	assert.Len(t, conflictErr.Conflicts, 3)
+
+	me := conflictErr.Conflicts[0]
+	assert.Equal(t, "me", me.Route.HandlerID)
+	assert.Equal(t, "user", me.With.HandlerID)
+	assert.False(t, me.Ambiguous)
+	assert.Equal(t, "/bar/users/me", me.Whitelist)
+
+	helloAgain := conflictErr.Conflicts[1]
+	assert.Equal(t, "helloAgain", helloAgain.Route.HandlerID)
+	assert.Equal(t, "hello", helloAgain.With.HandlerID)
+	assert.Empty(t, helloAgain.Whitelist)
+
+	ambiguous := conflictErr.Conflicts[2]
+	assert.Equal(t, "v2", ambiguous.Route.HandlerID)
+	assert.Equal(t, "beta", ambiguous.With.HandlerID)
+	assert.True(t, ambiguous.Ambiguous)
+
+	assert.Equal(t, `3 conflicting or ambiguous routes:
+  GET /bar/users/me (bar.me in me.yaml)
+    conflicts with GET /bar/users/:id (bar.user in user.yaml)
+    adding "/bar/users/me" to router.whitelistedPaths lets both routes be registered
+  GET /bar/hello/ (bar.helloAgain in helloAgain.yaml)
+    conflicts with GET /bar/hello (bar.hello in hello.yaml)
+  GET /bar/hello [X-Api-Version=2] (bar.v2 in v2.yaml)
+    is ambiguous with GET /bar/hello [X-Beta=*] (bar.beta in beta.yaml): a request can satisfy both matches`,
+		err.Error())
+}
+
+func TestNewRouteTableBuiltinRoutes(t *testing.T) {
+	_, err := NewRouteTable([]*EndpointSpec{
+		routeEndpoint("health", "GET", "/health/"),
+		routeEndpoint("admin", "GET", "/admin/:name"),
+		routeEndpoint("live", "POST", "/health/live"),
+	}, nil)
+	assert.EqualError(t, err, `2 conflicting or ambiguous routes:
+  GET /health/ (bar.health in health.yaml)
+    conflicts with GET /health (health.health built into the gateway)
+  GET /admin/:name (bar.admin in admin.yaml)
+    conflicts with GET /admin/config (admin.config built into the gateway)
+    adding "/admin/:name" to router.whitelistedPaths lets both routes be registered`)
+}
+
+func TestNewRouteTableWhitelistedPaths(t *testing.T) {
+	endpoints := []*EndpointSpec{
+		routeEndpoint("me", "GET", "/bar/users/me"),
+		routeEndpoint("user", "GET", "/bar/users/:id"),
+	}
+
+	// a whitelisted static path can only be registered after the param path
+	_, err := NewRouteTable(endpoints, []string{"/bar/users/me"})
+	if conflictErr, ok := err.(*RouteConflictError); assert.True(t, ok) {
+		assert.Equal(t, "/bar/users/:id", conflictErr.Conflicts[0].Whitelist)
+	}
+
+	routes, err := NewRouteTable(endpoints, []string{"/bar/users/:id"})
+	assert.NoError(t, err)
+	assert.Len(t, routes, 2)
+}
@@ -55,6 +55,27 @@ func checkError(err error, message string) {
 	}
 }
 
+// readWhitelistedPaths returns the router.whitelistedPaths of the runtime
+// config set by "runtimeConfig", or of "./config/production.yaml" when it
+// exists, so that route tables are checked the way the gateway routes.
+func readWhitelistedPaths(config *zanzibar.StaticConfig, configRoot string) []string {
+	whitelistedPaths := make([]string, 0)
+	runtimeConfigFile := filepath.Join(configRoot, "config", "production.yaml")
+	if config.ContainsKey("runtimeConfig") {
+		runtimeConfigFile = filepath.Join(configRoot, config.MustGetString("runtimeConfig"))
+	} else if _, err := os.Stat(runtimeConfigFile); os.IsNotExist(err) {
+		return whitelistedPaths
+	}
+
+	runtimeConfig := zanzibar.NewStaticConfigOrDie([]*zanzibar.ConfigOption{
+		zanzibar.ConfigFilePath(runtimeConfigFile),
+	}, nil)
+	if runtimeConfig.ContainsKey("router.whitelistedPaths") {
+		runtimeConfig.MustGetStruct("router.whitelistedPaths", &whitelistedPaths)
+	}
+	return whitelistedPaths
+}
+
 func main() {
 	configFile := flag.String("config", "", "the config file path")
 	moduleName := flag.String("instance", "", "")
@@ -122,6 +143,8 @@ func main() {
 		config.MustGetStruct("defaultHeaders", &defaultHeaders)
 	}
 
+	whitelistedPaths := readWhitelistedPaths(config, configRoot)
+
 	moduleIdlSubDir := map[string]string{}
 	config.MustGetStruct("moduleIdlSubDir", &moduleIdlSubDir)
 	genCodePackage := map[string]string{}
@@ -141,6 +164,7 @@ func main() {
 		ModuleSearchPaths:             searchPaths,
 		DefaultDependencies:           defaultDependencies,
 		DefaultHeaders:                defaultHeaders,
+		WhitelistedPaths:              whitelistedPaths,
 	}
 
 	options.QPSLevelsEnabled = true
@@ -439,6 +439,14 @@ package {{$instance.PackageInfo.PackageName}}
 {{- $endpointId := .Spec.EndpointID }}
 {{- $handleId := .Spec.HandleID }}
 {{- $middlewares := .Spec.Middlewares }}
+{{- $timeout := .Spec.Timeout }}
+{{- $maxBodyBytes := .Spec.MaxBodyBytes }}
+{{- $rateLimit := .Spec.RateLimit }}
+{{- $qpsLevel := .Spec.QPSLevel }}
+{{- $criticality := .Spec.Criticality }}
+{{- $cors := .Spec.CORS }}
+{{- $match := .Spec.Match }}
+{{- $apiVersions := .Spec.APIVersions }}
 {{- $workflowPkg := .WorkflowPkg }}
 {{- $workflowInterface := printf "%sWorkflow" $serviceMethod }}
 {{- $traceKey := .TraceKey }}
@@ -448,7 +456,6 @@ import (
 	"runtime/debug"
 	"encoding/json"
 	"io/ioutil"
-	"net/http"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
@@ -490,10 +497,10 @@ func New{{$handlerName}}(deps *module.Dependencies) *{{$handlerName}} {
 	handler := &{{$handlerName}}{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	{{ if len $middlewares | ne 0 -}}
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"{{$endpointId}}", "{{$handleId}}",
-		{{ if len $middlewares | ne 0 -}}
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
 			{{range $idx, $middleware := $middlewares -}}
 			deps.Middleware.{{$middleware.Name | pascal}}.NewMiddlewareHandle(
@@ -504,21 +511,84 @@ func New{{$handlerName}}(deps *module.Dependencies) *{{$handlerName}} {
 				},
 			),
 			{{end -}}
-		}, handler.HandleRequest).Handle,
-		{{- else -}}
+		}, handler.HandleRequest),
+	)
+	{{- else -}}
+	handler.endpoint = zanzibar.NewRouterEndpoint(
+		deps.Default.ContextExtractor, deps.Default,
+		"{{$endpointId}}", "{{$handleId}}",
 		handler.HandleRequest,
-		{{- end}}
 	)
+	{{- end}}
+	{{- if $timeout}}
+	handler.endpoint.Timeout = {{$timeout}} * time.Millisecond
+	{{- end}}
+	{{- if $maxBodyBytes}}
+	handler.endpoint.MaxBodyBytes = {{$maxBodyBytes}}
+	{{- end}}
+	{{- with $rateLimit}}
+	handler.endpoint.RateLimit = zanzibar.NewRateLimitOptions(deps.Default.Config).With(zanzibar.RateLimitOptions{
+		{{- range $key, $value := .Fields}}
+		{{$key}}: {{$value}},
+		{{- end}}
+	})
+	{{- end}}
+	{{- if $qpsLevel}}
+	handler.endpoint.QPSLevel = {{$qpsLevel}}
+	{{- end}}
+	{{- if $criticality}}
+	handler.endpoint.Criticality = zanzibar.{{$criticality}}
+	{{- end}}
+	{{- with $cors}}
+	handler.endpoint.CORS = zanzibar.NewCORSOptions(deps.Default.Config).With(zanzibar.CORSOptions{
+		{{- range $key, $value := .Fields}}
+		{{$key}}: {{$value}},
+		{{- end}}
+	})
+	{{- end}}
+	{{- with $match}}
+	{{- if .Host}}
+	handler.endpoint.MatchHost = {{printf "%q" .Host}}
+	{{- end}}
+	{{- if .Headers}}
+	handler.endpoint.MatchHeaders = map[string]string{
+		{{- range $name, $value := .Headers}}
+		{{printf "%q" $name}}: {{printf "%q" $value}},
+		{{- end}}
+	}
+	{{- end}}
+	{{- end}}
 
 	return handler
 }
 
 // Register adds the http handler to the gateway's http router
 func (h *{{$handlerName}}) Register(g *zanzibar.Gateway) error {
+	{{- if $apiVersions}}
+	for _, version := range []zanzibar.APIVersion{
+		{{- range $apiVersions}}
+		{
+			{{- range $key, $value := .Fields}}
+			{{$key}}: {{$value}},
+			{{- end}}
+		},
+		{{- end}}
+	} {
+		err := g.HTTPRouter.Handle(
+			"{{.HTTPMethod}}", version.Path("{{.RoutePath}}"),
+			h.endpoint.WithAPIVersion(version),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+	{{- else}}
 	return g.HTTPRouter.Handle(
-		"{{.HTTPMethod}}", "{{.HTTPPath}}",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		"{{.HTTPMethod}}", "{{.RoutePath}}",
+		h.endpoint,
 	)
+	{{- end}}
 }
 
 // HandleRequest handles "{{.HTTPPath}}".
@@ -697,7 +767,7 @@ func endpointTmpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "endpoint.tmpl", size: 7798, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
+	info := bindataFileInfo{name: "endpoint.tmpl", size: 9643, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -1221,7 +1291,6 @@ package {{$instance.PackageInfo.PackageName}}
 import (
 	"context"
 
-	"github.com/afex/hystrix-go/hystrix"
 	"go.uber.org/yarpc"
 
 	module "{{$instance.PackageInfo.ModulePackagePath}}"
@@ -1238,7 +1307,10 @@ import (
 {{- $exportName := .ExportName}}
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 // Client defines {{$clientID}} client interface.
 type Client interface {
@@ -1263,6 +1335,7 @@ type {{$clientName}} struct {
 	{{camel $s.Name}}Client gen.{{pascal $s.Name}}YARPCClient
 	{{ end -}}
 	opts   *zanzibar.GRPCClientOpts
+	circuitBreaker zanzibar.CircuitBreaker
 }
 
 // NewClient returns a new gRPC client for service {{$clientID}}
@@ -1300,6 +1373,7 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.circuitBreakerDisabled") {
 		circuitBreakerDisabled = deps.Default.Config.MustGetBoolean("clients.{{$clientID}}.circuitBreakerDisabled")
 	}
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "{{$clientID}}", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for _, methodName := range methodNames {
 			circuitBreakerName := "{{$clientID}}"  + "-" + methodName
@@ -1307,11 +1381,17 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, timeoutInMS, circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "{{$clientID}}", qpsLevel, timeoutInMS))
 		}
 	}
 
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterGRPCClient("{{$clientID}}", oc)
+		deps.Default.Gateway.RegisterCircuitBreaker("{{$clientID}}", circuitBreaker)
+	}
+
 	return &{{$clientName}}{
+		circuitBreaker: circuitBreaker,
 		{{range $i, $s := $services -}}
 		{{camel $s.Name}}Client: gen.New{{pascal $s.Name}}YARPCClient(oc),
 		{{ end -}}
@@ -1329,67 +1409,6 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 	}
 }
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
-			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
-			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
-			}
-		}
-	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-		MaxConcurrentRequests:  maxConcurrentRequests,
-		ErrorPercentThreshold:  errorPercentThreshold,
-		SleepWindow:            sleepWindowInMilliseconds,
-		RequestVolumeThreshold: requestVolumeThreshold,
-		Timeout:                timeoutVal,
-	})
-}
-
 {{range $i, $svc := .ProtoServices -}}
 {{range $j, $method := $svc.RPC -}}
 {{$serviceMethod := printf "%s::%s" $svc.Name .Name -}}
@@ -1426,10 +1445,10 @@ func (e *{{$clientName}}) {{$methodName}}(
 		result, err = runFunc(ctxWithTimeout, request, opts...)
 	} else {
 		circuitBreakerName := "{{$clientID}}" + "-" + "{{$methodName}}"
-		err = hystrix.DoC(ctxWithTimeout, circuitBreakerName, func(ctx context.Context) error {
+		err = e.circuitBreaker.Do(ctxWithTimeout, circuitBreakerName, func(ctx context.Context) error {
 			result, err = runFunc(ctx, request, opts...)
 			return err
-		}, nil)
+		})
 	}
 	callHelper.Finish(ctx, err)
 
@@ -1450,7 +1469,7 @@ func grpc_clientTmpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "grpc_client.tmpl", size: 8644, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
+	info := bindataFileInfo{name: "grpc_client.tmpl", size: 6074, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -1463,7 +1482,6 @@ import (
 	"context"
 	"fmt"
 	"net/textproto"
-	"github.com/afex/hystrix-go/hystrix"
 	"strconv"
 	"time"
 
@@ -1486,7 +1504,10 @@ import (
 {{- $sidecarRouter := .SidecarRouter}}
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 // Client defines {{$clientID}} client interface.
 type Client interface {
@@ -1514,6 +1535,7 @@ type {{$clientName}} struct {
 	httpClient   *zanzibar.HTTPClient
 	jsonWrapper   jsonwrapper.JSONWrapper
 	circuitBreakerDisabled bool
+	circuitBreaker zanzibar.CircuitBreaker
 	requestUUIDHeaderKey string
 	requestProcedureHeaderKey string
 
@@ -1541,11 +1563,26 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 		deps.Default.Config.MustGetStruct("clients.{{$clientID}}.alternates", &altServiceDetail)
 	}
 
+	baseURL := fmt.Sprintf("http://%s:%d", ip, port)
 	{{else -}}
-	ip := deps.Default.Config.MustGetString("clients.{{$clientID}}.ip")
-	port := deps.Default.Config.MustGetInt("clients.{{$clientID}}.port")
+	peerProvider := zanzibar.NewPeerProvider(deps.Default.Config, "{{$clientID}}", deps.Default.Logger, deps.Default.Scope)
+	// the gateway starts the peer provider, without one no peers would be discovered
+	if peerProvider != nil && deps.Default.Gateway == nil {
+		panic(errors.New("client {{$clientID}} has a peer provider but no gateway to start it"))
+	}
+	tlsConfig := zanzibar.NewHTTPClientTLSConfig(deps.Default.Config, "{{$clientID}}", deps.Default.Logger, deps.Default.Scope)
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	// with a host list or a peer provider the host of each request is picked by the host pool
+	baseURL := scheme + "://{{$clientID}}"
+	if peerProvider == nil && !deps.Default.Config.ContainsKey("clients.{{$clientID}}.hostList") {
+		ip := deps.Default.Config.MustGetString("clients.{{$clientID}}.ip")
+		port := deps.Default.Config.MustGetInt("clients.{{$clientID}}.port")
+		baseURL = fmt.Sprintf("%s://%s:%d", scheme, ip, port)
+	}
 	{{end -}}
-	baseURL := fmt.Sprintf("http://%s:%d", ip, port)
 	timeoutVal := int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.timeout"))
 	timeout := time.Millisecond * time.Duration(
 		timeoutVal,
@@ -1598,6 +1635,7 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 				"{{$methodName}}": "{{$qpsLevel}}",
 				{{end}}
 	}
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "{{$clientID}}", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for methodName, methodTimeout := range clientMethodTimeoutMapping{
 			circuitBreakerName := "{{$clientID}}" + "-" + methodName
@@ -1605,11 +1643,11 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, int(methodTimeout), circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "{{$clientID}}", qpsLevel, int(methodTimeout)))
 		}
 	}
 
-	return &{{$clientName}}{
+	client := &{{$clientName}}{
 		clientID: "{{$clientID}}",
 		{{if $sidecarRouter -}}
 		callerHeader: callerHeader,
@@ -1628,9 +1666,77 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 			followRedirect,
 		),
 		circuitBreakerDisabled: circuitBreakerDisabled,
+		circuitBreaker: circuitBreaker,
 		requestUUIDHeaderKey: requestUUIDHeaderKey,
 		requestProcedureHeaderKey: requestProcedureHeaderKey,
 	}
+	client.httpClient.Hedging = zanzibar.NewHedgingPolicy(deps.Default.Config, "{{$clientID}}")
+	idempotentMethods := map[string]bool{
+		{{range $svc := .Services -}}
+		{{range .Methods -}}
+		{{$serviceMethod := printf "%s::%s" $svc.Name .Name -}}
+		{{$methodName := (title (index $exposedMethods $serviceMethod)) -}}
+		{{if $methodName -}}
+		"{{$methodName}}": {{.Idempotent}},
+		{{end -}}
+		{{end -}}
+		{{end -}}
+	}
+	client.httpClient.RetryPolicy = zanzibar.NewRetryPolicy(deps.Default.Config, "{{$clientID}}", idempotentMethods)
+	transportOptions := zanzibar.NewHTTPTransportOptions(deps.Default.Config, "{{$clientID}}")
+	{{if not $sidecarRouter -}}
+	transportOptions.TLSConfig = tlsConfig
+	{{end -}}
+	client.httpClient.SetTransport(transportOptions.NewTransport())
+	{{if not $sidecarRouter -}}
+	client.httpClient.Hosts = zanzibar.NewHTTPHostPool(deps.Default.Config, "{{$clientID}}", deps.Default.Logger, deps.Default.Scope)
+	if peerProvider != nil {
+		peerProvider.Subscribe(client.httpClient.Hosts.SetHosts)
+	}
+	{{end -}}
+	client.watchDynamicConfig(deps, methodNames, qpsLevels)
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterHTTPClient("{{$clientID}}", client.httpClient)
+		deps.Default.Gateway.RegisterCircuitBreaker("{{$clientID}}", circuitBreaker)
+		{{if not $sidecarRouter -}}
+		deps.Default.Gateway.RegisterPeerProvider("{{$clientID}}", peerProvider)
+		{{end -}}
+	}
+	return client
+}
+
+// watchDynamicConfig re-applies the timeout and circuit breaker settings
+// when they change in the gateway's dynamic config.
+func (c *{{$clientName}}) watchDynamicConfig(deps *module.Dependencies, methodNames map[string]string, qpsLevels map[string]string) {
+	if deps.Default.Gateway == nil || deps.Default.Gateway.DynamicConfig == nil {
+		return
+	}
+	dynamicConfig := deps.Default.Gateway.DynamicConfig
+	reconfigure := func(change zanzibar.ConfigChange) {
+		timeoutVal := int(dynamicConfig.MustGetInt("clients.{{$clientID}}.timeout"))
+		c.httpClient.SetTimeout(time.Millisecond * time.Duration(timeoutVal))
+		if c.circuitBreakerDisabled {
+			return
+		}
+		clientMethodTimeoutMapping := make(map[string]int64)
+		if dynamicConfig.ContainsKey("clients.{{$clientID}}.methodTimeoutMapping") {
+			dynamicConfig.MustGetStruct("clients.{{$clientID}}.methodTimeoutMapping", &clientMethodTimeoutMapping)
+		} else {
+			for methodName := range methodNames {
+				clientMethodTimeoutMapping[methodName] = int64(timeoutVal)
+			}
+		}
+		for methodName, methodTimeout := range clientMethodTimeoutMapping {
+			circuitBreakerName := "{{$clientID}}" + "-" + methodName
+			qpsLevel := "default"
+			if level, ok := qpsLevels[circuitBreakerName]; ok {
+				qpsLevel = level
+			}
+			c.circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(dynamicConfig, "{{$clientID}}", qpsLevel, int(methodTimeout)))
+		}
+	}
+	dynamicConfig.Subscribe("clients.{{$clientID}}.", reconfigure)
+	dynamicConfig.Subscribe(CircuitBreakerConfigKey, reconfigure)
 }
 
 {{if $sidecarRouter -}}
@@ -1648,67 +1754,6 @@ func initializeAltRoutingMap(altServiceDetail config.AlternateServiceDetail) map
 }
 {{end -}}
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
-			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
-			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
-			}
-		}
-	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-			MaxConcurrentRequests:  maxConcurrentRequests,
-			ErrorPercentThreshold:  errorPercentThreshold,
-			SleepWindow:            sleepWindowInMilliseconds,
-			RequestVolumeThreshold: requestVolumeThreshold,
-			Timeout:                timeoutVal,
-		})
-}
-
 // HTTPClient returns the underlying HTTP client, should only be
 // used for internal testing.
 func (c *{{$clientName}}) HTTPClient() *zanzibar.HTTPClient {
@@ -1777,6 +1822,7 @@ func (c *{{$clientName}}) {{$methodName}}(
 	fullURL := c.httpClient.BaseURL
 	{{- range $k, $segment := .PathSegments -}}
 	{{- if eq $segment.Type "static" -}}+"/{{$segment.Text}}"
+	{{- else if $segment.IntBits -}}+"/"+strconv.FormatInt(int64({{- if not $segment.Required }} * {{- end -}}r{{$segment.BodyIdentifier | title}}), 10)
 	{{- else -}}+"/"+string({{- if not $segment.Required }} * {{- end -}}r{{$segment.BodyIdentifier | title}})
 	{{- end -}}
 	{{- end}}
@@ -1813,17 +1859,17 @@ func (c *{{$clientName}}) {{$methodName}}(
 	if (c.circuitBreakerDisabled) {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "{{$clientID}}" + "-" + "{{$methodName}}"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2026,7 +2072,7 @@ func http_clientTmpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "http_client.tmpl", size: 19491, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
+	info := bindataFileInfo{name: "http_client.tmpl", size: 20668, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -3567,7 +3613,6 @@ import (
 	"time"
 	"net/textproto"
 
-	"github.com/afex/hystrix-go/hystrix"
 	"github.com/uber/tchannel-go"
 	zanzibar "github.com/uber/zanzibar/runtime"
 	"github.com/uber/tchannel-go"
@@ -3591,7 +3636,10 @@ import (
 {{- $sidecarRouter := .SidecarRouter}}
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 var logFieldErrLocation = zanzibar.LogFieldErrorLocation("client::{{$instance.InstanceName}}")
 
@@ -3627,28 +3675,37 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 		requestUUIDHeaderKey = deps.Default.Config.MustGetString("tchannel.clients.requestUUIDHeaderKey")
 	}
 
-	{{if $sidecarRouter -}}
-		ip := deps.Default.Config.MustGetString("sidecarRouter.{{$sidecarRouter}}.tchannel.ip")
-		port := deps.Default.Config.MustGetInt("sidecarRouter.{{$sidecarRouter}}.tchannel.port")
-	{{else -}}
-		ip := deps.Default.Config.MustGetString("clients.{{$clientID}}.ip")
-		port := deps.Default.Config.MustGetInt("clients.{{$clientID}}.port")
-	{{end -}}
-
 	gateway := deps.Default.Gateway
 	var channel *tchannel.Channel
+	var peers *tchannel.PeerList
 
 	// If dedicated.tchannel.client : true, each tchannel client will create a
 	// dedicated connection with local sidecar, else it will use a shared connection
 	if deps.Default.Config.ContainsKey("dedicated.tchannel.client") &&
 		deps.Default.Config.MustGetBoolean("dedicated.tchannel.client") {
 		channel = gateway.SetupClientTChannel(deps.Default.Config, serviceName)
-		channel.Peers().Add(ip + ":" + strconv.Itoa(int(port)))
+		peers = channel.Peers()
 	} else {
 		channel = gateway.ServerTChannel
-		channel.GetSubChannel(serviceName, tchannel.Isolated).Peers().Add(ip + ":" + strconv.Itoa(int(port)))
+		peers = channel.GetSubChannel(serviceName, tchannel.Isolated).Peers()
 	}
 
+	{{if $sidecarRouter -}}
+		ip := deps.Default.Config.MustGetString("sidecarRouter.{{$sidecarRouter}}.tchannel.ip")
+		port := deps.Default.Config.MustGetInt("sidecarRouter.{{$sidecarRouter}}.tchannel.port")
+		peers.Add(ip + ":" + strconv.Itoa(int(port)))
+	{{else -}}
+		// a peer provider keeps the peers up to date as they change
+		if peerProvider := zanzibar.NewPeerProvider(deps.Default.Config, "{{$clientID}}", deps.Default.Logger, deps.Default.Scope); peerProvider != nil {
+			peerProvider.Subscribe(zanzibar.TChannelPeersUpdater(peers))
+			gateway.RegisterPeerProvider("{{$clientID}}", peerProvider)
+		} else {
+			ip := deps.Default.Config.MustGetString("clients.{{$clientID}}.ip")
+			port := deps.Default.Config.MustGetInt("clients.{{$clientID}}.port")
+			peers.Add(ip + ":" + strconv.Itoa(int(port)))
+		}
+	{{end -}}
+
 	/*Ex:
 	{
 		"clients.rider-presentation.alternates": {
@@ -3723,6 +3780,7 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 		circuitBreakerDisabled = deps.Default.Config.MustGetBoolean("clients.{{$clientID}}.circuitBreakerDisabled")
 	}
 
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "{{$clientID}}", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for methodName, methodTimeoutVal := range clientMethodTimeoutMapping{
 			circuitBreakerName := "{{$clientID}}" + "-" + methodName
@@ -3730,10 +3788,13 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, int(methodTimeoutVal), circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "{{$clientID}}", qpsLevel, int(methodTimeoutVal)))
 		}
 	}
 
+	hedging := zanzibar.NewHedgingPolicy(deps.Default.Config, "{{$clientID}}")
+	retryPolicy := zanzibar.NewBackoffRetryPolicy(deps.Default.Config, "{{$clientID}}", nil)
+
 	var client *zanzibar.TChannelClient
 
 	if  deps.Default.Config.ContainsKey("tchannelclients.retryCount.feature.enabled") && deps.Default.Config.MustGetBoolean("tchannelclients.retryCount.feature.enabled") && deps.Default.Config.ContainsKey("clients.{{$clientID}}.retryCount") && int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.retryCount")) > 0{
@@ -3755,6 +3816,8 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 					RequestUUIDHeaderKey: requestUUIDHeaderKey,
 					AltChannelMap:        altChannelMap,
 					MaxAttempts:          maxAttempts,
+					Hedging:              hedging,
+					RetryPolicy:          retryPolicy,
 				},
 			)
 	}else{
@@ -3774,15 +3837,70 @@ func {{$exportName}}(deps *module.Dependencies) Client {
 					HeaderPatterns:       headerPatterns,
 					RequestUUIDHeaderKey: requestUUIDHeaderKey,
 					AltChannelMap:        altChannelMap,
+					Hedging:              hedging,
+					RetryPolicy:          retryPolicy,
 				},
 			)
 	}
 
-	return &{{$clientName}}{
+	tchannelClient := &{{$clientName}}{
 		client: client,
 		circuitBreakerDisabled: circuitBreakerDisabled,
+		circuitBreaker: circuitBreaker,
 		defaultDeps:            deps.Default,
 	}
+	tchannelClient.watchDynamicConfig(deps, methodNames, qpsLevels)
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterTChannelClient("{{$clientID}}", client)
+		deps.Default.Gateway.RegisterCircuitBreaker("{{$clientID}}", circuitBreaker)
+	}
+	return tchannelClient
+}
+
+// watchDynamicConfig re-applies the timeout, retry and circuit breaker settings
+// when they change in the gateway's dynamic config.
+func (c *{{$clientName}}) watchDynamicConfig(deps *module.Dependencies, methodNames map[string]string, qpsLevels map[string]string) {
+	if deps.Default.Gateway == nil || deps.Default.Gateway.DynamicConfig == nil {
+		return
+	}
+	dynamicConfig := deps.Default.Gateway.DynamicConfig
+	reconfigure := func(change zanzibar.ConfigChange) {
+		timeoutVal := int(dynamicConfig.MustGetInt("clients.{{$clientID}}.timeout"))
+		c.client.SetTimeout(
+			time.Millisecond*time.Duration(timeoutVal),
+			time.Millisecond*time.Duration(dynamicConfig.MustGetInt("clients.{{$clientID}}.timeoutPerAttempt")),
+		)
+		maxAttempts := 0
+		if dynamicConfig.ContainsKey("tchannelclients.retryCount.feature.enabled") && dynamicConfig.MustGetBoolean("tchannelclients.retryCount.feature.enabled") && dynamicConfig.ContainsKey("clients.{{$clientID}}.retryCount") {
+			maxAttempts = int(dynamicConfig.MustGetInt("clients.{{$clientID}}.retryCount"))
+		}
+		if maxAttempts < 0 {
+			maxAttempts = 0
+		}
+		c.client.SetMaxAttempts(maxAttempts)
+		if c.circuitBreakerDisabled {
+			return
+		}
+		clientMethodTimeoutMapping := make(map[string]int64)
+		if dynamicConfig.ContainsKey("clients.{{$clientID}}.methodTimeoutMapping") {
+			dynamicConfig.MustGetStruct("clients.{{$clientID}}.methodTimeoutMapping", &clientMethodTimeoutMapping)
+		} else {
+			for _, methodName := range methodNames {
+				clientMethodTimeoutMapping[methodName] = int64(timeoutVal)
+			}
+		}
+		for methodName, methodTimeoutVal := range clientMethodTimeoutMapping {
+			circuitBreakerName := "{{$clientID}}" + "-" + methodName
+			qpsLevel := "default"
+			if level, ok := qpsLevels[circuitBreakerName]; ok {
+				qpsLevel = level
+			}
+			c.circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(dynamicConfig, "{{$clientID}}", qpsLevel, int(methodTimeoutVal)))
+		}
+	}
+	dynamicConfig.Subscribe("clients.{{$clientID}}.", reconfigure)
+	dynamicConfig.Subscribe("tchannelclients.retryCount.feature.enabled", reconfigure)
+	dynamicConfig.Subscribe(CircuitBreakerConfigKey, reconfigure)
 }
 
 func initializeDynamicChannel(channel *tchannel.Channel, deps *module.Dependencies, headerPatterns []string, altChannelMap map[string]*tchannel.SubChannel, re ruleengine.RuleEngine) ([]string, ruleengine.RuleEngine) {
@@ -3816,71 +3934,11 @@ func initializeDynamicChannel(channel *tchannel.Channel, deps *module.Dependenci
 	return headerPatterns, re
 }
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
-			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
-			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
-			}
-		}
-	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.{{$clientID}}.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.{{$clientID}}.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-			MaxConcurrentRequests:  maxConcurrentRequests,
-			ErrorPercentThreshold:  errorPercentThreshold,
-			SleepWindow:            sleepWindowInMilliseconds,
-			RequestVolumeThreshold: requestVolumeThreshold,
-			Timeout:                timeoutVal,
-		})
-}
-
 // {{$clientName}} is the TChannel client for downstream service.
 type {{$clientName}} struct {
 	client *zanzibar.TChannelClient
 	circuitBreakerDisabled bool
+	circuitBreaker zanzibar.CircuitBreaker
 	defaultDeps  *zanzibar.DefaultDependencies
 }
 
@@ -3914,7 +3972,7 @@ type {{$clientName}} struct {
 			success, respHeaders, err = c.client.Call(
 				ctx, "{{$svc.Name}}", "{{.Name}}", reqHeaders, args, &result)
 		} else {
-			// We want hystrix ckt-breaker to count errors only for system issues
+			// We want the circuit breaker to count errors only for system issues
 			var clientErr error
 			scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client" : "{{$clientID}}",
@@ -3922,7 +3980,7 @@ type {{$clientName}} struct {
 			})
 			start := time.Now()
 			circuitBreakerName := "{{$clientID}}" + "-" + "{{$methodName}}"
-			err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+			err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 				elapsed := time.Now().Sub(start)
 				scope.Timer("hystrix-timer").Record(elapsed)
 				success, respHeaders, clientErr = c.client.Call(
@@ -3932,7 +3990,7 @@ type {{$clientName}} struct {
 					return nil
 				}
 				return clientErr
-			}, nil)
+			})
 			if err == nil {
 				// ckt-breaker was ok, bubble up client error if set
 				err = clientErr
@@ -3994,7 +4052,7 @@ func tchannel_clientTmpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "tchannel_client.tmpl", size: 16209, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
+	info := bindataFileInfo{name: "tchannel_client.tmpl", size: 16635, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -4124,6 +4182,9 @@ var _tchannel_endpointTmpl = []byte(`{{- /* template to render edge gateway tcha
 package {{$instance.PackageInfo.PackageName}}
 
 {{- $middlewares := .Spec.Middlewares }}
+{{- $rateLimit := .Spec.RateLimit }}
+{{- $qpsLevel := .Spec.QPSLevel }}
+{{- $criticality := .Spec.Criticality }}
 import (
 	"context"
 	"runtime/debug"
@@ -4182,6 +4243,19 @@ func New{{$handlerName}}(deps *module.Dependencies) *{{$handlerName}} {
 			handler,
 		{{- end}}
 	)
+	{{- with $rateLimit}}
+	handler.endpoint.RateLimit = zanzibar.NewRateLimitOptions(deps.Default.Config).With(zanzibar.RateLimitOptions{
+		{{- range $key, $value := .Fields}}
+		{{$key}}: {{$value}},
+		{{- end}}
+	})
+	{{- end}}
+	{{- if $qpsLevel}}
+	handler.endpoint.QPSLevel = {{$qpsLevel}}
+	{{- end}}
+	{{- if $criticality}}
+	handler.endpoint.Criticality = zanzibar.{{$criticality}}
+	{{- end}}
 
 	return handler
 }
@@ -4408,7 +4482,7 @@ func tchannel_endpointTmpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "tchannel_endpoint.tmpl", size: 9383, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
+	info := bindataFileInfo{name: "tchannel_endpoint.tmpl", size: 9886, mode: os.FileMode(420), modTime: time.Unix(1, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
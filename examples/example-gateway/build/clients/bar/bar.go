@@ -30,8 +30,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/afex/hystrix-go/hystrix"
-
 	"github.com/pkg/errors"
 	zanzibar "github.com/uber/zanzibar/runtime"
 	"github.com/uber/zanzibar/runtime/jsonwrapper"
@@ -42,7 +40,10 @@ import (
 )
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 // Client defines bar client interface.
 type Client interface {
@@ -228,15 +229,30 @@ type barClient struct {
 	httpClient                *zanzibar.HTTPClient
 	jsonWrapper               jsonwrapper.JSONWrapper
 	circuitBreakerDisabled    bool
+	circuitBreaker            zanzibar.CircuitBreaker
 	requestUUIDHeaderKey      string
 	requestProcedureHeaderKey string
 }
 
 // NewClient returns a new http client.
 func NewClient(deps *module.Dependencies) Client {
-	ip := deps.Default.Config.MustGetString("clients.bar.ip")
-	port := deps.Default.Config.MustGetInt("clients.bar.port")
-	baseURL := fmt.Sprintf("http://%s:%d", ip, port)
+	peerProvider := zanzibar.NewPeerProvider(deps.Default.Config, "bar", deps.Default.Logger, deps.Default.Scope)
+	// the gateway starts the peer provider, without one no peers would be discovered
+	if peerProvider != nil && deps.Default.Gateway == nil {
+		panic(errors.New("client bar has a peer provider but no gateway to start it"))
+	}
+	tlsConfig := zanzibar.NewHTTPClientTLSConfig(deps.Default.Config, "bar", deps.Default.Logger, deps.Default.Scope)
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	// with a host list or a peer provider the host of each request is picked by the host pool
+	baseURL := scheme + "://bar"
+	if peerProvider == nil && !deps.Default.Config.ContainsKey("clients.bar.hostList") {
+		ip := deps.Default.Config.MustGetString("clients.bar.ip")
+		port := deps.Default.Config.MustGetInt("clients.bar.port")
+		baseURL = fmt.Sprintf("%s://%s:%d", scheme, ip, port)
+	}
 	timeoutVal := int(deps.Default.Config.MustGetInt("clients.bar.timeout"))
 	timeout := time.Millisecond * time.Duration(
 		timeoutVal,
@@ -353,6 +369,7 @@ func NewClient(deps *module.Dependencies) Client {
 		"bar-NormalRecur":                     "default",
 		"bar-TooManyArgs":                     "3",
 	}
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "bar", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for methodName, methodTimeout := range clientMethodTimeoutMapping {
 			circuitBreakerName := "bar" + "-" + methodName
@@ -360,11 +377,11 @@ func NewClient(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, int(methodTimeout), circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "bar", qpsLevel, int(methodTimeout)))
 		}
 	}
 
-	return &barClient{
+	client := &barClient{
 		clientID: "bar",
 		httpClient: zanzibar.NewHTTPClientContext(
 			deps.Default.ContextLogger, deps.Default.ContextMetrics, deps.Default.JSONWrapper,
@@ -376,70 +393,97 @@ func NewClient(deps *module.Dependencies) Client {
 			followRedirect,
 		),
 		circuitBreakerDisabled:    circuitBreakerDisabled,
+		circuitBreaker:            circuitBreaker,
 		requestUUIDHeaderKey:      requestUUIDHeaderKey,
 		requestProcedureHeaderKey: requestProcedureHeaderKey,
 	}
+	client.httpClient.Hedging = zanzibar.NewHedgingPolicy(deps.Default.Config, "bar")
+	idempotentMethods := map[string]bool{
+		"ArgNotStruct":                    false,
+		"ArgWithHeaders":                  false,
+		"ArgWithManyQueryParams":          true,
+		"ArgWithNearDupQueryParams":       true,
+		"ArgWithNestedQueryParams":        true,
+		"ArgWithParams":                   true,
+		"ArgWithParamsAndDuplicateFields": false,
+		"ArgWithQueryHeader":              false,
+		"ArgWithQueryParams":              true,
+		"DeleteFoo":                       true,
+		"DeleteWithBody":                  true,
+		"DeleteWithQueryParams":           true,
+		"Hello":                           true,
+		"ListAndEnum":                     true,
+		"MissingArg":                      true,
+		"NoRequest":                       true,
+		"Normal":                          false,
+		"NormalRecur":                     false,
+		"TooManyArgs":                     false,
+		"EchoBinary":                      false,
+		"EchoBool":                        false,
+		"EchoDouble":                      false,
+		"EchoEnum":                        false,
+		"EchoI16":                         false,
+		"EchoI32":                         false,
+		"EchoI32Map":                      false,
+		"EchoI64":                         false,
+		"EchoI8":                          false,
+		"EchoString":                      false,
+		"EchoStringList":                  false,
+		"EchoStringMap":                   false,
+		"EchoStringSet":                   false,
+		"EchoStructList":                  false,
+		"EchoStructSet":                   false,
+		"EchoTypedef":                     false,
+	}
+	client.httpClient.RetryPolicy = zanzibar.NewRetryPolicy(deps.Default.Config, "bar", idempotentMethods)
+	transportOptions := zanzibar.NewHTTPTransportOptions(deps.Default.Config, "bar")
+	transportOptions.TLSConfig = tlsConfig
+	client.httpClient.SetTransport(transportOptions.NewTransport())
+	client.httpClient.Hosts = zanzibar.NewHTTPHostPool(deps.Default.Config, "bar", deps.Default.Logger, deps.Default.Scope)
+	if peerProvider != nil {
+		peerProvider.Subscribe(client.httpClient.Hosts.SetHosts)
+	}
+	client.watchDynamicConfig(deps, methodNames, qpsLevels)
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterHTTPClient("bar", client.httpClient)
+		deps.Default.Gateway.RegisterCircuitBreaker("bar", circuitBreaker)
+		deps.Default.Gateway.RegisterPeerProvider("bar", peerProvider)
+	}
+	return client
 }
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
+// watchDynamicConfig re-applies the timeout and circuit breaker settings
+// when they change in the gateway's dynamic config.
+func (c *barClient) watchDynamicConfig(deps *module.Dependencies, methodNames map[string]string, qpsLevels map[string]string) {
+	if deps.Default.Gateway == nil || deps.Default.Gateway.DynamicConfig == nil {
+		return
+	}
+	dynamicConfig := deps.Default.Gateway.DynamicConfig
+	reconfigure := func(change zanzibar.ConfigChange) {
+		timeoutVal := int(dynamicConfig.MustGetInt("clients.bar.timeout"))
+		c.httpClient.SetTimeout(time.Millisecond * time.Duration(timeoutVal))
+		if c.circuitBreakerDisabled {
+			return
+		}
+		clientMethodTimeoutMapping := make(map[string]int64)
+		if dynamicConfig.ContainsKey("clients.bar.methodTimeoutMapping") {
+			dynamicConfig.MustGetStruct("clients.bar.methodTimeoutMapping", &clientMethodTimeoutMapping)
+		} else {
+			for methodName := range methodNames {
+				clientMethodTimeoutMapping[methodName] = int64(timeoutVal)
 			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
-			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
+		}
+		for methodName, methodTimeout := range clientMethodTimeoutMapping {
+			circuitBreakerName := "bar" + "-" + methodName
+			qpsLevel := "default"
+			if level, ok := qpsLevels[circuitBreakerName]; ok {
+				qpsLevel = level
 			}
+			c.circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(dynamicConfig, "bar", qpsLevel, int(methodTimeout)))
 		}
 	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.bar.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.bar.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.bar.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.bar.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.bar.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.bar.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.bar.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.bar.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-		MaxConcurrentRequests:  maxConcurrentRequests,
-		ErrorPercentThreshold:  errorPercentThreshold,
-		SleepWindow:            sleepWindowInMilliseconds,
-		RequestVolumeThreshold: requestVolumeThreshold,
-		Timeout:                timeoutVal,
-	})
+	dynamicConfig.Subscribe("clients.bar.", reconfigure)
+	dynamicConfig.Subscribe(CircuitBreakerConfigKey, reconfigure)
 }
 
 // HTTPClient returns the underlying HTTP client, should only be
@@ -479,17 +523,17 @@ func (c *barClient) ArgNotStruct(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "ArgNotStruct"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -589,17 +633,17 @@ func (c *barClient) ArgWithHeaders(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "ArgWithHeaders"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -774,17 +818,17 @@ func (c *barClient) ArgWithManyQueryParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "ArgWithManyQueryParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -881,17 +925,17 @@ func (c *barClient) ArgWithNearDupQueryParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "ArgWithNearDupQueryParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1012,17 +1056,17 @@ func (c *barClient) ArgWithNestedQueryParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "ArgWithNestedQueryParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1102,17 +1146,17 @@ func (c *barClient) ArgWithParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "ArgWithParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1192,17 +1236,17 @@ func (c *barClient) ArgWithParamsAndDuplicateFields(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "ArgWithParamsAndDuplicateFields"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1282,17 +1326,17 @@ func (c *barClient) ArgWithQueryHeader(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "ArgWithQueryHeader"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1389,17 +1433,17 @@ func (c *barClient) ArgWithQueryParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "ArgWithQueryParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1485,17 +1529,17 @@ func (c *barClient) DeleteFoo(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "DeleteFoo"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1567,17 +1611,17 @@ func (c *barClient) DeleteWithBody(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "DeleteWithBody"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1658,17 +1702,17 @@ func (c *barClient) DeleteWithQueryParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "DeleteWithQueryParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1740,17 +1784,17 @@ func (c *barClient) Hello(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "Hello"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1857,17 +1901,17 @@ func (c *barClient) ListAndEnum(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "ListAndEnum"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1956,17 +2000,17 @@ func (c *barClient) MissingArg(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "MissingArg"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2056,17 +2100,17 @@ func (c *barClient) NoRequest(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "NoRequest"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2157,17 +2201,17 @@ func (c *barClient) Normal(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "Normal"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2258,17 +2302,17 @@ func (c *barClient) NormalRecur(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "NormalRecur"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2358,17 +2402,17 @@ func (c *barClient) TooManyArgs(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "TooManyArgs"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2473,17 +2517,17 @@ func (c *barClient) EchoBinary(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoBinary"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2561,17 +2605,17 @@ func (c *barClient) EchoBool(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoBool"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2655,17 +2699,17 @@ func (c *barClient) EchoDouble(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoDouble"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2749,17 +2793,17 @@ func (c *barClient) EchoEnum(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoEnum"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2843,17 +2887,17 @@ func (c *barClient) EchoI16(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoI16"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2937,17 +2981,17 @@ func (c *barClient) EchoI32(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoI32"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3031,17 +3075,17 @@ func (c *barClient) EchoI32Map(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoI32Map"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3125,17 +3169,17 @@ func (c *barClient) EchoI64(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoI64"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3219,17 +3263,17 @@ func (c *barClient) EchoI8(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoI8"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3313,17 +3357,17 @@ func (c *barClient) EchoString(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoString"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3407,17 +3451,17 @@ func (c *barClient) EchoStringList(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoStringList"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3501,17 +3545,17 @@ func (c *barClient) EchoStringMap(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoStringMap"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3595,17 +3639,17 @@ func (c *barClient) EchoStringSet(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoStringSet"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3689,17 +3733,17 @@ func (c *barClient) EchoStructList(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoStructList"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3783,17 +3827,17 @@ func (c *barClient) EchoStructSet(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoStructSet"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3877,17 +3921,17 @@ func (c *barClient) EchoTypedef(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "bar" + "-" + "EchoTypedef"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
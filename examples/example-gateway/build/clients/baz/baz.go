@@ -32,7 +32,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/afex/hystrix-go/hystrix"
 	"github.com/uber/tchannel-go"
 	"github.com/uber/zanzibar/config"
 	zanzibar "github.com/uber/zanzibar/runtime"
@@ -46,7 +45,10 @@ import (
 )
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 var logFieldErrLocation = zanzibar.LogFieldErrorLocation("client::baz")
 
@@ -199,20 +201,29 @@ func NewClient(deps *module.Dependencies) Client {
 		requestUUIDHeaderKey = deps.Default.Config.MustGetString("tchannel.clients.requestUUIDHeaderKey")
 	}
 
-	ip := deps.Default.Config.MustGetString("clients.baz.ip")
-	port := deps.Default.Config.MustGetInt("clients.baz.port")
 	gateway := deps.Default.Gateway
 	var channel *tchannel.Channel
+	var peers *tchannel.PeerList
 
 	// If dedicated.tchannel.client : true, each tchannel client will create a
 	// dedicated connection with local sidecar, else it will use a shared connection
 	if deps.Default.Config.ContainsKey("dedicated.tchannel.client") &&
 		deps.Default.Config.MustGetBoolean("dedicated.tchannel.client") {
 		channel = gateway.SetupClientTChannel(deps.Default.Config, serviceName)
-		channel.Peers().Add(ip + ":" + strconv.Itoa(int(port)))
+		peers = channel.Peers()
 	} else {
 		channel = gateway.ServerTChannel
-		channel.GetSubChannel(serviceName, tchannel.Isolated).Peers().Add(ip + ":" + strconv.Itoa(int(port)))
+		peers = channel.GetSubChannel(serviceName, tchannel.Isolated).Peers()
+	}
+
+	// a peer provider keeps the peers up to date as they change
+	if peerProvider := zanzibar.NewPeerProvider(deps.Default.Config, "baz", deps.Default.Logger, deps.Default.Scope); peerProvider != nil {
+		peerProvider.Subscribe(zanzibar.TChannelPeersUpdater(peers))
+		gateway.RegisterPeerProvider("baz", peerProvider)
+	} else {
+		ip := deps.Default.Config.MustGetString("clients.baz.ip")
+		port := deps.Default.Config.MustGetInt("clients.baz.port")
+		peers.Add(ip + ":" + strconv.Itoa(int(port)))
 	}
 
 	/*Ex:
@@ -331,6 +342,7 @@ func NewClient(deps *module.Dependencies) Client {
 		circuitBreakerDisabled = deps.Default.Config.MustGetBoolean("clients.baz.circuitBreakerDisabled")
 	}
 
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "baz", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for methodName, methodTimeoutVal := range clientMethodTimeoutMapping {
 			circuitBreakerName := "baz" + "-" + methodName
@@ -338,10 +350,13 @@ func NewClient(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, int(methodTimeoutVal), circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "baz", qpsLevel, int(methodTimeoutVal)))
 		}
 	}
 
+	hedging := zanzibar.NewHedgingPolicy(deps.Default.Config, "baz")
+	retryPolicy := zanzibar.NewBackoffRetryPolicy(deps.Default.Config, "baz", nil)
+
 	var client *zanzibar.TChannelClient
 
 	if deps.Default.Config.ContainsKey("tchannelclients.retryCount.feature.enabled") && deps.Default.Config.MustGetBoolean("tchannelclients.retryCount.feature.enabled") && deps.Default.Config.ContainsKey("clients.baz.retryCount") && int(deps.Default.Config.MustGetInt("clients.baz.retryCount")) > 0 {
@@ -363,6 +378,8 @@ func NewClient(deps *module.Dependencies) Client {
 				RequestUUIDHeaderKey: requestUUIDHeaderKey,
 				AltChannelMap:        altChannelMap,
 				MaxAttempts:          maxAttempts,
+				Hedging:              hedging,
+				RetryPolicy:          retryPolicy,
 			},
 		)
 	} else {
@@ -382,15 +399,70 @@ func NewClient(deps *module.Dependencies) Client {
 				HeaderPatterns:       headerPatterns,
 				RequestUUIDHeaderKey: requestUUIDHeaderKey,
 				AltChannelMap:        altChannelMap,
+				Hedging:              hedging,
+				RetryPolicy:          retryPolicy,
 			},
 		)
 	}
 
-	return &bazClient{
+	tchannelClient := &bazClient{
 		client:                 client,
 		circuitBreakerDisabled: circuitBreakerDisabled,
+		circuitBreaker:         circuitBreaker,
 		defaultDeps:            deps.Default,
 	}
+	tchannelClient.watchDynamicConfig(deps, methodNames, qpsLevels)
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterTChannelClient("baz", client)
+		deps.Default.Gateway.RegisterCircuitBreaker("baz", circuitBreaker)
+	}
+	return tchannelClient
+}
+
+// watchDynamicConfig re-applies the timeout, retry and circuit breaker settings
+// when they change in the gateway's dynamic config.
+func (c *bazClient) watchDynamicConfig(deps *module.Dependencies, methodNames map[string]string, qpsLevels map[string]string) {
+	if deps.Default.Gateway == nil || deps.Default.Gateway.DynamicConfig == nil {
+		return
+	}
+	dynamicConfig := deps.Default.Gateway.DynamicConfig
+	reconfigure := func(change zanzibar.ConfigChange) {
+		timeoutVal := int(dynamicConfig.MustGetInt("clients.baz.timeout"))
+		c.client.SetTimeout(
+			time.Millisecond*time.Duration(timeoutVal),
+			time.Millisecond*time.Duration(dynamicConfig.MustGetInt("clients.baz.timeoutPerAttempt")),
+		)
+		maxAttempts := 0
+		if dynamicConfig.ContainsKey("tchannelclients.retryCount.feature.enabled") && dynamicConfig.MustGetBoolean("tchannelclients.retryCount.feature.enabled") && dynamicConfig.ContainsKey("clients.baz.retryCount") {
+			maxAttempts = int(dynamicConfig.MustGetInt("clients.baz.retryCount"))
+		}
+		if maxAttempts < 0 {
+			maxAttempts = 0
+		}
+		c.client.SetMaxAttempts(maxAttempts)
+		if c.circuitBreakerDisabled {
+			return
+		}
+		clientMethodTimeoutMapping := make(map[string]int64)
+		if dynamicConfig.ContainsKey("clients.baz.methodTimeoutMapping") {
+			dynamicConfig.MustGetStruct("clients.baz.methodTimeoutMapping", &clientMethodTimeoutMapping)
+		} else {
+			for _, methodName := range methodNames {
+				clientMethodTimeoutMapping[methodName] = int64(timeoutVal)
+			}
+		}
+		for methodName, methodTimeoutVal := range clientMethodTimeoutMapping {
+			circuitBreakerName := "baz" + "-" + methodName
+			qpsLevel := "default"
+			if level, ok := qpsLevels[circuitBreakerName]; ok {
+				qpsLevel = level
+			}
+			c.circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(dynamicConfig, "baz", qpsLevel, int(methodTimeoutVal)))
+		}
+	}
+	dynamicConfig.Subscribe("clients.baz.", reconfigure)
+	dynamicConfig.Subscribe("tchannelclients.retryCount.feature.enabled", reconfigure)
+	dynamicConfig.Subscribe(CircuitBreakerConfigKey, reconfigure)
 }
 
 func initializeDynamicChannel(channel *tchannel.Channel, deps *module.Dependencies, headerPatterns []string, altChannelMap map[string]*tchannel.SubChannel, re ruleengine.RuleEngine) ([]string, ruleengine.RuleEngine) {
@@ -424,71 +496,11 @@ func initializeDynamicChannel(channel *tchannel.Channel, deps *module.Dependenci
 	return headerPatterns, re
 }
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
-			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
-			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
-			}
-		}
-	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.baz.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.baz.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.baz.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.baz.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.baz.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.baz.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.baz.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.baz.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-		MaxConcurrentRequests:  maxConcurrentRequests,
-		ErrorPercentThreshold:  errorPercentThreshold,
-		SleepWindow:            sleepWindowInMilliseconds,
-		RequestVolumeThreshold: requestVolumeThreshold,
-		Timeout:                timeoutVal,
-	})
-}
-
 // bazClient is the TChannel client for downstream service.
 type bazClient struct {
 	client                 *zanzibar.TChannelClient
 	circuitBreakerDisabled bool
+	circuitBreaker         zanzibar.CircuitBreaker
 	defaultDeps            *zanzibar.DefaultDependencies
 }
 
@@ -510,7 +522,7 @@ func (c *bazClient) EchoBinary(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoBinary", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -518,7 +530,7 @@ func (c *bazClient) EchoBinary(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoBinary"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -528,7 +540,7 @@ func (c *bazClient) EchoBinary(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -579,7 +591,7 @@ func (c *bazClient) EchoBool(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoBool", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -587,7 +599,7 @@ func (c *bazClient) EchoBool(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoBool"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -597,7 +609,7 @@ func (c *bazClient) EchoBool(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -648,7 +660,7 @@ func (c *bazClient) EchoDouble(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoDouble", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -656,7 +668,7 @@ func (c *bazClient) EchoDouble(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoDouble"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -666,7 +678,7 @@ func (c *bazClient) EchoDouble(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -717,7 +729,7 @@ func (c *bazClient) EchoEnum(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoEnum", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -725,7 +737,7 @@ func (c *bazClient) EchoEnum(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoEnum"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -735,7 +747,7 @@ func (c *bazClient) EchoEnum(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -786,7 +798,7 @@ func (c *bazClient) EchoI16(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoI16", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -794,7 +806,7 @@ func (c *bazClient) EchoI16(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoI16"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -804,7 +816,7 @@ func (c *bazClient) EchoI16(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -855,7 +867,7 @@ func (c *bazClient) EchoI32(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoI32", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -863,7 +875,7 @@ func (c *bazClient) EchoI32(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoI32"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -873,7 +885,7 @@ func (c *bazClient) EchoI32(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -924,7 +936,7 @@ func (c *bazClient) EchoI64(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoI64", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -932,7 +944,7 @@ func (c *bazClient) EchoI64(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoI64"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -942,7 +954,7 @@ func (c *bazClient) EchoI64(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -993,7 +1005,7 @@ func (c *bazClient) EchoI8(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoI8", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1001,7 +1013,7 @@ func (c *bazClient) EchoI8(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoI8"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1011,7 +1023,7 @@ func (c *bazClient) EchoI8(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1062,7 +1074,7 @@ func (c *bazClient) EchoString(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoString", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1070,7 +1082,7 @@ func (c *bazClient) EchoString(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoString"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1080,7 +1092,7 @@ func (c *bazClient) EchoString(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1131,7 +1143,7 @@ func (c *bazClient) EchoStringList(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoStringList", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1139,7 +1151,7 @@ func (c *bazClient) EchoStringList(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoStringList"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1149,7 +1161,7 @@ func (c *bazClient) EchoStringList(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1200,7 +1212,7 @@ func (c *bazClient) EchoStringMap(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoStringMap", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1208,7 +1220,7 @@ func (c *bazClient) EchoStringMap(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoStringMap"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1218,7 +1230,7 @@ func (c *bazClient) EchoStringMap(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1269,7 +1281,7 @@ func (c *bazClient) EchoStringSet(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoStringSet", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1277,7 +1289,7 @@ func (c *bazClient) EchoStringSet(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoStringSet"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1287,7 +1299,7 @@ func (c *bazClient) EchoStringSet(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1338,7 +1350,7 @@ func (c *bazClient) EchoStructList(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoStructList", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1346,7 +1358,7 @@ func (c *bazClient) EchoStructList(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoStructList"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1356,7 +1368,7 @@ func (c *bazClient) EchoStructList(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1407,7 +1419,7 @@ func (c *bazClient) EchoStructSet(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoStructSet", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1415,7 +1427,7 @@ func (c *bazClient) EchoStructSet(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoStructSet"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1425,7 +1437,7 @@ func (c *bazClient) EchoStructSet(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1476,7 +1488,7 @@ func (c *bazClient) EchoTypedef(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SecondService", "echoTypedef", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1484,7 +1496,7 @@ func (c *bazClient) EchoTypedef(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "EchoTypedef"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1494,7 +1506,7 @@ func (c *bazClient) EchoTypedef(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1544,7 +1556,7 @@ func (c *bazClient) Call(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "call", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1552,7 +1564,7 @@ func (c *bazClient) Call(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "Call"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1562,7 +1574,7 @@ func (c *bazClient) Call(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1608,7 +1620,7 @@ func (c *bazClient) Compare(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "compare", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1616,7 +1628,7 @@ func (c *bazClient) Compare(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "Compare"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1626,7 +1638,7 @@ func (c *bazClient) Compare(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1683,7 +1695,7 @@ func (c *bazClient) GetProfile(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "getProfile", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1691,7 +1703,7 @@ func (c *bazClient) GetProfile(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "GetProfile"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1701,7 +1713,7 @@ func (c *bazClient) GetProfile(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1755,7 +1767,7 @@ func (c *bazClient) HeaderSchema(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "headerSchema", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1763,7 +1775,7 @@ func (c *bazClient) HeaderSchema(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "HeaderSchema"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1773,7 +1785,7 @@ func (c *bazClient) HeaderSchema(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1830,7 +1842,7 @@ func (c *bazClient) Ping(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "ping", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1838,7 +1850,7 @@ func (c *bazClient) Ping(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "Ping"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1848,7 +1860,7 @@ func (c *bazClient) Ping(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1898,7 +1910,7 @@ func (c *bazClient) DeliberateDiffNoop(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "sillyNoop", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1906,7 +1918,7 @@ func (c *bazClient) DeliberateDiffNoop(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "DeliberateDiffNoop"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1916,7 +1928,7 @@ func (c *bazClient) DeliberateDiffNoop(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1964,7 +1976,7 @@ func (c *bazClient) TestUUID(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "testUuid", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -1972,7 +1984,7 @@ func (c *bazClient) TestUUID(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "TestUUID"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -1982,7 +1994,7 @@ func (c *bazClient) TestUUID(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2025,7 +2037,7 @@ func (c *bazClient) Trans(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "trans", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -2033,7 +2045,7 @@ func (c *bazClient) Trans(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "Trans"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -2043,7 +2055,7 @@ func (c *bazClient) Trans(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2100,7 +2112,7 @@ func (c *bazClient) TransHeaders(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "transHeaders", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -2108,7 +2120,7 @@ func (c *bazClient) TransHeaders(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "TransHeaders"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -2118,7 +2130,7 @@ func (c *bazClient) TransHeaders(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2175,7 +2187,7 @@ func (c *bazClient) TransHeadersNoReq(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "transHeadersNoReq", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -2183,7 +2195,7 @@ func (c *bazClient) TransHeadersNoReq(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "TransHeadersNoReq"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -2193,7 +2205,7 @@ func (c *bazClient) TransHeadersNoReq(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2247,7 +2259,7 @@ func (c *bazClient) TransHeadersType(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "transHeadersType", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -2255,7 +2267,7 @@ func (c *bazClient) TransHeadersType(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "TransHeadersType"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -2265,7 +2277,7 @@ func (c *bazClient) TransHeadersType(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2321,7 +2333,7 @@ func (c *bazClient) URLTest(
 		success, respHeaders, err = c.client.Call(
 			ctx, "SimpleService", "urlTest", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "baz",
@@ -2329,7 +2341,7 @@ func (c *bazClient) URLTest(
 		})
 		start := time.Now()
 		circuitBreakerName := "baz" + "-" + "URLTest"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -2339,7 +2351,7 @@ func (c *bazClient) URLTest(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
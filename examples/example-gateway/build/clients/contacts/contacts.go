@@ -28,8 +28,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/afex/hystrix-go/hystrix"
-
+	"github.com/pkg/errors"
 	zanzibar "github.com/uber/zanzibar/runtime"
 	"github.com/uber/zanzibar/runtime/jsonwrapper"
 
@@ -38,7 +37,10 @@ import (
 )
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 // Client defines contacts client interface.
 type Client interface {
@@ -60,15 +62,30 @@ type contactsClient struct {
 	httpClient                *zanzibar.HTTPClient
 	jsonWrapper               jsonwrapper.JSONWrapper
 	circuitBreakerDisabled    bool
+	circuitBreaker            zanzibar.CircuitBreaker
 	requestUUIDHeaderKey      string
 	requestProcedureHeaderKey string
 }
 
 // NewClient returns a new http client.
 func NewClient(deps *module.Dependencies) Client {
-	ip := deps.Default.Config.MustGetString("clients.contacts.ip")
-	port := deps.Default.Config.MustGetInt("clients.contacts.port")
-	baseURL := fmt.Sprintf("http://%s:%d", ip, port)
+	peerProvider := zanzibar.NewPeerProvider(deps.Default.Config, "contacts", deps.Default.Logger, deps.Default.Scope)
+	// the gateway starts the peer provider, without one no peers would be discovered
+	if peerProvider != nil && deps.Default.Gateway == nil {
+		panic(errors.New("client contacts has a peer provider but no gateway to start it"))
+	}
+	tlsConfig := zanzibar.NewHTTPClientTLSConfig(deps.Default.Config, "contacts", deps.Default.Logger, deps.Default.Scope)
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	// with a host list or a peer provider the host of each request is picked by the host pool
+	baseURL := scheme + "://contacts"
+	if peerProvider == nil && !deps.Default.Config.ContainsKey("clients.contacts.hostList") {
+		ip := deps.Default.Config.MustGetString("clients.contacts.ip")
+		port := deps.Default.Config.MustGetInt("clients.contacts.port")
+		baseURL = fmt.Sprintf("%s://%s:%d", scheme, ip, port)
+	}
 	timeoutVal := int(deps.Default.Config.MustGetInt("clients.contacts.timeout"))
 	timeout := time.Millisecond * time.Duration(
 		timeoutVal,
@@ -119,6 +136,7 @@ func NewClient(deps *module.Dependencies) Client {
 		"contacts-SaveContacts": "default",
 		"contacts-TestURLURL":   "default",
 	}
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "contacts", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for methodName, methodTimeout := range clientMethodTimeoutMapping {
 			circuitBreakerName := "contacts" + "-" + methodName
@@ -126,11 +144,11 @@ func NewClient(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, int(methodTimeout), circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "contacts", qpsLevel, int(methodTimeout)))
 		}
 	}
 
-	return &contactsClient{
+	client := &contactsClient{
 		clientID: "contacts",
 		httpClient: zanzibar.NewHTTPClientContext(
 			deps.Default.ContextLogger, deps.Default.ContextMetrics, deps.Default.JSONWrapper,
@@ -142,70 +160,64 @@ func NewClient(deps *module.Dependencies) Client {
 			followRedirect,
 		),
 		circuitBreakerDisabled:    circuitBreakerDisabled,
+		circuitBreaker:            circuitBreaker,
 		requestUUIDHeaderKey:      requestUUIDHeaderKey,
 		requestProcedureHeaderKey: requestProcedureHeaderKey,
 	}
+	client.httpClient.Hedging = zanzibar.NewHedgingPolicy(deps.Default.Config, "contacts")
+	idempotentMethods := map[string]bool{
+		"SaveContacts": false,
+		"TestURLURL":   true,
+	}
+	client.httpClient.RetryPolicy = zanzibar.NewRetryPolicy(deps.Default.Config, "contacts", idempotentMethods)
+	transportOptions := zanzibar.NewHTTPTransportOptions(deps.Default.Config, "contacts")
+	transportOptions.TLSConfig = tlsConfig
+	client.httpClient.SetTransport(transportOptions.NewTransport())
+	client.httpClient.Hosts = zanzibar.NewHTTPHostPool(deps.Default.Config, "contacts", deps.Default.Logger, deps.Default.Scope)
+	if peerProvider != nil {
+		peerProvider.Subscribe(client.httpClient.Hosts.SetHosts)
+	}
+	client.watchDynamicConfig(deps, methodNames, qpsLevels)
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterHTTPClient("contacts", client.httpClient)
+		deps.Default.Gateway.RegisterCircuitBreaker("contacts", circuitBreaker)
+		deps.Default.Gateway.RegisterPeerProvider("contacts", peerProvider)
+	}
+	return client
 }
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
-			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
+// watchDynamicConfig re-applies the timeout and circuit breaker settings
+// when they change in the gateway's dynamic config.
+func (c *contactsClient) watchDynamicConfig(deps *module.Dependencies, methodNames map[string]string, qpsLevels map[string]string) {
+	if deps.Default.Gateway == nil || deps.Default.Gateway.DynamicConfig == nil {
+		return
+	}
+	dynamicConfig := deps.Default.Gateway.DynamicConfig
+	reconfigure := func(change zanzibar.ConfigChange) {
+		timeoutVal := int(dynamicConfig.MustGetInt("clients.contacts.timeout"))
+		c.httpClient.SetTimeout(time.Millisecond * time.Duration(timeoutVal))
+		if c.circuitBreakerDisabled {
+			return
+		}
+		clientMethodTimeoutMapping := make(map[string]int64)
+		if dynamicConfig.ContainsKey("clients.contacts.methodTimeoutMapping") {
+			dynamicConfig.MustGetStruct("clients.contacts.methodTimeoutMapping", &clientMethodTimeoutMapping)
+		} else {
+			for methodName := range methodNames {
+				clientMethodTimeoutMapping[methodName] = int64(timeoutVal)
 			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
+		}
+		for methodName, methodTimeout := range clientMethodTimeoutMapping {
+			circuitBreakerName := "contacts" + "-" + methodName
+			qpsLevel := "default"
+			if level, ok := qpsLevels[circuitBreakerName]; ok {
+				qpsLevel = level
 			}
+			c.circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(dynamicConfig, "contacts", qpsLevel, int(methodTimeout)))
 		}
 	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.contacts.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.contacts.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.contacts.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.contacts.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.contacts.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.contacts.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.contacts.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.contacts.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-		MaxConcurrentRequests:  maxConcurrentRequests,
-		ErrorPercentThreshold:  errorPercentThreshold,
-		SleepWindow:            sleepWindowInMilliseconds,
-		RequestVolumeThreshold: requestVolumeThreshold,
-		Timeout:                timeoutVal,
-	})
+	dynamicConfig.Subscribe("clients.contacts.", reconfigure)
+	dynamicConfig.Subscribe(CircuitBreakerConfigKey, reconfigure)
 }
 
 // HTTPClient returns the underlying HTTP client, should only be
@@ -246,17 +258,17 @@ func (c *contactsClient) SaveContacts(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "contacts" + "-" + "SaveContacts"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -340,17 +352,17 @@ func (c *contactsClient) TestURLURL(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "contacts" + "-" + "TestURLURL"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
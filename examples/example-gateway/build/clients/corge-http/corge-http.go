@@ -30,8 +30,6 @@ import (
 	"regexp"
 	"time"
 
-	"github.com/afex/hystrix-go/hystrix"
-
 	"github.com/uber/zanzibar/config"
 	zanzibar "github.com/uber/zanzibar/runtime"
 	"github.com/uber/zanzibar/runtime/jsonwrapper"
@@ -41,7 +39,10 @@ import (
 )
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 // Client defines corge-http client interface.
 type Client interface {
@@ -75,6 +76,7 @@ type corgeHTTPClient struct {
 	httpClient                *zanzibar.HTTPClient
 	jsonWrapper               jsonwrapper.JSONWrapper
 	circuitBreakerDisabled    bool
+	circuitBreaker            zanzibar.CircuitBreaker
 	requestUUIDHeaderKey      string
 	requestProcedureHeaderKey string
 
@@ -154,6 +156,7 @@ func NewClient(deps *module.Dependencies) Client {
 		"corge-http-NoContent":                 "default",
 		"corge-http-NoContentNoException":      "default",
 	}
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "corge-http", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for methodName, methodTimeout := range clientMethodTimeoutMapping {
 			circuitBreakerName := "corge-http" + "-" + methodName
@@ -161,11 +164,11 @@ func NewClient(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, int(methodTimeout), circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "corge-http", qpsLevel, int(methodTimeout)))
 		}
 	}
 
-	return &corgeHTTPClient{
+	client := &corgeHTTPClient{
 		clientID:      "corge-http",
 		callerHeader:  callerHeader,
 		calleeHeader:  calleeHeader,
@@ -182,9 +185,60 @@ func NewClient(deps *module.Dependencies) Client {
 			followRedirect,
 		),
 		circuitBreakerDisabled:    circuitBreakerDisabled,
+		circuitBreaker:            circuitBreaker,
 		requestUUIDHeaderKey:      requestUUIDHeaderKey,
 		requestProcedureHeaderKey: requestProcedureHeaderKey,
 	}
+	client.httpClient.Hedging = zanzibar.NewHedgingPolicy(deps.Default.Config, "corge-http")
+	idempotentMethods := map[string]bool{
+		"EchoString":                false,
+		"NoContent":                 false,
+		"NoContentNoException":      false,
+		"CorgeNoContentOnException": false,
+	}
+	client.httpClient.RetryPolicy = zanzibar.NewRetryPolicy(deps.Default.Config, "corge-http", idempotentMethods)
+	transportOptions := zanzibar.NewHTTPTransportOptions(deps.Default.Config, "corge-http")
+	client.httpClient.SetTransport(transportOptions.NewTransport())
+	client.watchDynamicConfig(deps, methodNames, qpsLevels)
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterHTTPClient("corge-http", client.httpClient)
+		deps.Default.Gateway.RegisterCircuitBreaker("corge-http", circuitBreaker)
+	}
+	return client
+}
+
+// watchDynamicConfig re-applies the timeout and circuit breaker settings
+// when they change in the gateway's dynamic config.
+func (c *corgeHTTPClient) watchDynamicConfig(deps *module.Dependencies, methodNames map[string]string, qpsLevels map[string]string) {
+	if deps.Default.Gateway == nil || deps.Default.Gateway.DynamicConfig == nil {
+		return
+	}
+	dynamicConfig := deps.Default.Gateway.DynamicConfig
+	reconfigure := func(change zanzibar.ConfigChange) {
+		timeoutVal := int(dynamicConfig.MustGetInt("clients.corge-http.timeout"))
+		c.httpClient.SetTimeout(time.Millisecond * time.Duration(timeoutVal))
+		if c.circuitBreakerDisabled {
+			return
+		}
+		clientMethodTimeoutMapping := make(map[string]int64)
+		if dynamicConfig.ContainsKey("clients.corge-http.methodTimeoutMapping") {
+			dynamicConfig.MustGetStruct("clients.corge-http.methodTimeoutMapping", &clientMethodTimeoutMapping)
+		} else {
+			for methodName := range methodNames {
+				clientMethodTimeoutMapping[methodName] = int64(timeoutVal)
+			}
+		}
+		for methodName, methodTimeout := range clientMethodTimeoutMapping {
+			circuitBreakerName := "corge-http" + "-" + methodName
+			qpsLevel := "default"
+			if level, ok := qpsLevels[circuitBreakerName]; ok {
+				qpsLevel = level
+			}
+			c.circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(dynamicConfig, "corge-http", qpsLevel, int(methodTimeout)))
+		}
+	}
+	dynamicConfig.Subscribe("clients.corge-http.", reconfigure)
+	dynamicConfig.Subscribe(CircuitBreakerConfigKey, reconfigure)
 }
 
 func initializeAltRoutingMap(altServiceDetail config.AlternateServiceDetail) map[string]map[string]string {
@@ -200,67 +254,6 @@ func initializeAltRoutingMap(altServiceDetail config.AlternateServiceDetail) map
 	return routingMap
 }
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
-			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
-			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
-			}
-		}
-	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.corge-http.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.corge-http.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.corge-http.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.corge-http.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.corge-http.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.corge-http.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.corge-http.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.corge-http.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-		MaxConcurrentRequests:  maxConcurrentRequests,
-		ErrorPercentThreshold:  errorPercentThreshold,
-		SleepWindow:            sleepWindowInMilliseconds,
-		RequestVolumeThreshold: requestVolumeThreshold,
-		Timeout:                timeoutVal,
-	})
-}
-
 // HTTPClient returns the underlying HTTP client, should only be
 // used for internal testing.
 func (c *corgeHTTPClient) HTTPClient() *zanzibar.HTTPClient {
@@ -319,17 +312,17 @@ func (c *corgeHTTPClient) EchoString(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "corge-http" + "-" + "EchoString"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -427,17 +420,17 @@ func (c *corgeHTTPClient) NoContent(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "corge-http" + "-" + "NoContent"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -530,17 +523,17 @@ func (c *corgeHTTPClient) NoContentNoException(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "corge-http" + "-" + "NoContentNoException"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -629,17 +622,17 @@ func (c *corgeHTTPClient) CorgeNoContentOnException(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "corge-http" + "-" + "CorgeNoContentOnException"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -32,7 +32,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/afex/hystrix-go/hystrix"
 	"github.com/uber/tchannel-go"
 	"github.com/uber/zanzibar/config"
 	zanzibar "github.com/uber/zanzibar/runtime"
@@ -45,7 +44,10 @@ import (
 )
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 var logFieldErrLocation = zanzibar.LogFieldErrorLocation("client::corge")
 
@@ -70,22 +72,25 @@ func NewClient(deps *module.Dependencies) Client {
 		requestUUIDHeaderKey = deps.Default.Config.MustGetString("tchannel.clients.requestUUIDHeaderKey")
 	}
 
-	ip := deps.Default.Config.MustGetString("sidecarRouter.default.tchannel.ip")
-	port := deps.Default.Config.MustGetInt("sidecarRouter.default.tchannel.port")
 	gateway := deps.Default.Gateway
 	var channel *tchannel.Channel
+	var peers *tchannel.PeerList
 
 	// If dedicated.tchannel.client : true, each tchannel client will create a
 	// dedicated connection with local sidecar, else it will use a shared connection
 	if deps.Default.Config.ContainsKey("dedicated.tchannel.client") &&
 		deps.Default.Config.MustGetBoolean("dedicated.tchannel.client") {
 		channel = gateway.SetupClientTChannel(deps.Default.Config, serviceName)
-		channel.Peers().Add(ip + ":" + strconv.Itoa(int(port)))
+		peers = channel.Peers()
 	} else {
 		channel = gateway.ServerTChannel
-		channel.GetSubChannel(serviceName, tchannel.Isolated).Peers().Add(ip + ":" + strconv.Itoa(int(port)))
+		peers = channel.GetSubChannel(serviceName, tchannel.Isolated).Peers()
 	}
 
+	ip := deps.Default.Config.MustGetString("sidecarRouter.default.tchannel.ip")
+	port := deps.Default.Config.MustGetInt("sidecarRouter.default.tchannel.port")
+	peers.Add(ip + ":" + strconv.Itoa(int(port)))
+
 	/*Ex:
 	{
 		"clients.rider-presentation.alternates": {
@@ -150,6 +155,7 @@ func NewClient(deps *module.Dependencies) Client {
 		circuitBreakerDisabled = deps.Default.Config.MustGetBoolean("clients.corge.circuitBreakerDisabled")
 	}
 
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "corge", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for methodName, methodTimeoutVal := range clientMethodTimeoutMapping {
 			circuitBreakerName := "corge" + "-" + methodName
@@ -157,10 +163,13 @@ func NewClient(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, int(methodTimeoutVal), circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "corge", qpsLevel, int(methodTimeoutVal)))
 		}
 	}
 
+	hedging := zanzibar.NewHedgingPolicy(deps.Default.Config, "corge")
+	retryPolicy := zanzibar.NewBackoffRetryPolicy(deps.Default.Config, "corge", nil)
+
 	var client *zanzibar.TChannelClient
 
 	if deps.Default.Config.ContainsKey("tchannelclients.retryCount.feature.enabled") && deps.Default.Config.MustGetBoolean("tchannelclients.retryCount.feature.enabled") && deps.Default.Config.ContainsKey("clients.corge.retryCount") && int(deps.Default.Config.MustGetInt("clients.corge.retryCount")) > 0 {
@@ -182,6 +191,8 @@ func NewClient(deps *module.Dependencies) Client {
 				RequestUUIDHeaderKey: requestUUIDHeaderKey,
 				AltChannelMap:        altChannelMap,
 				MaxAttempts:          maxAttempts,
+				Hedging:              hedging,
+				RetryPolicy:          retryPolicy,
 			},
 		)
 	} else {
@@ -201,15 +212,70 @@ func NewClient(deps *module.Dependencies) Client {
 				HeaderPatterns:       headerPatterns,
 				RequestUUIDHeaderKey: requestUUIDHeaderKey,
 				AltChannelMap:        altChannelMap,
+				Hedging:              hedging,
+				RetryPolicy:          retryPolicy,
 			},
 		)
 	}
 
-	return &corgeClient{
+	tchannelClient := &corgeClient{
 		client:                 client,
 		circuitBreakerDisabled: circuitBreakerDisabled,
+		circuitBreaker:         circuitBreaker,
 		defaultDeps:            deps.Default,
 	}
+	tchannelClient.watchDynamicConfig(deps, methodNames, qpsLevels)
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterTChannelClient("corge", client)
+		deps.Default.Gateway.RegisterCircuitBreaker("corge", circuitBreaker)
+	}
+	return tchannelClient
+}
+
+// watchDynamicConfig re-applies the timeout, retry and circuit breaker settings
+// when they change in the gateway's dynamic config.
+func (c *corgeClient) watchDynamicConfig(deps *module.Dependencies, methodNames map[string]string, qpsLevels map[string]string) {
+	if deps.Default.Gateway == nil || deps.Default.Gateway.DynamicConfig == nil {
+		return
+	}
+	dynamicConfig := deps.Default.Gateway.DynamicConfig
+	reconfigure := func(change zanzibar.ConfigChange) {
+		timeoutVal := int(dynamicConfig.MustGetInt("clients.corge.timeout"))
+		c.client.SetTimeout(
+			time.Millisecond*time.Duration(timeoutVal),
+			time.Millisecond*time.Duration(dynamicConfig.MustGetInt("clients.corge.timeoutPerAttempt")),
+		)
+		maxAttempts := 0
+		if dynamicConfig.ContainsKey("tchannelclients.retryCount.feature.enabled") && dynamicConfig.MustGetBoolean("tchannelclients.retryCount.feature.enabled") && dynamicConfig.ContainsKey("clients.corge.retryCount") {
+			maxAttempts = int(dynamicConfig.MustGetInt("clients.corge.retryCount"))
+		}
+		if maxAttempts < 0 {
+			maxAttempts = 0
+		}
+		c.client.SetMaxAttempts(maxAttempts)
+		if c.circuitBreakerDisabled {
+			return
+		}
+		clientMethodTimeoutMapping := make(map[string]int64)
+		if dynamicConfig.ContainsKey("clients.corge.methodTimeoutMapping") {
+			dynamicConfig.MustGetStruct("clients.corge.methodTimeoutMapping", &clientMethodTimeoutMapping)
+		} else {
+			for _, methodName := range methodNames {
+				clientMethodTimeoutMapping[methodName] = int64(timeoutVal)
+			}
+		}
+		for methodName, methodTimeoutVal := range clientMethodTimeoutMapping {
+			circuitBreakerName := "corge" + "-" + methodName
+			qpsLevel := "default"
+			if level, ok := qpsLevels[circuitBreakerName]; ok {
+				qpsLevel = level
+			}
+			c.circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(dynamicConfig, "corge", qpsLevel, int(methodTimeoutVal)))
+		}
+	}
+	dynamicConfig.Subscribe("clients.corge.", reconfigure)
+	dynamicConfig.Subscribe("tchannelclients.retryCount.feature.enabled", reconfigure)
+	dynamicConfig.Subscribe(CircuitBreakerConfigKey, reconfigure)
 }
 
 func initializeDynamicChannel(channel *tchannel.Channel, deps *module.Dependencies, headerPatterns []string, altChannelMap map[string]*tchannel.SubChannel, re ruleengine.RuleEngine) ([]string, ruleengine.RuleEngine) {
@@ -243,71 +309,11 @@ func initializeDynamicChannel(channel *tchannel.Channel, deps *module.Dependenci
 	return headerPatterns, re
 }
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
-			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
-			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
-			}
-		}
-	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.corge.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.corge.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.corge.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.corge.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.corge.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.corge.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.corge.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.corge.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-		MaxConcurrentRequests:  maxConcurrentRequests,
-		ErrorPercentThreshold:  errorPercentThreshold,
-		SleepWindow:            sleepWindowInMilliseconds,
-		RequestVolumeThreshold: requestVolumeThreshold,
-		Timeout:                timeoutVal,
-	})
-}
-
 // corgeClient is the TChannel client for downstream service.
 type corgeClient struct {
 	client                 *zanzibar.TChannelClient
 	circuitBreakerDisabled bool
+	circuitBreaker         zanzibar.CircuitBreaker
 	defaultDeps            *zanzibar.DefaultDependencies
 }
 
@@ -329,7 +335,7 @@ func (c *corgeClient) EchoString(
 		success, respHeaders, err = c.client.Call(
 			ctx, "Corge", "echoString", reqHeaders, args, &result)
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		scope := c.defaultDeps.Scope.Tagged(map[string]string{
 			"client":     "corge",
@@ -337,7 +343,7 @@ func (c *corgeClient) EchoString(
 		})
 		start := time.Now()
 		circuitBreakerName := "corge" + "-" + "EchoString"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			elapsed := time.Now().Sub(start)
 			scope.Timer("hystrix-timer").Record(elapsed)
 			success, respHeaders, clientErr = c.client.Call(
@@ -347,7 +353,7 @@ func (c *corgeClient) EchoString(
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -30,8 +30,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/afex/hystrix-go/hystrix"
-
 	"github.com/pkg/errors"
 	zanzibar "github.com/uber/zanzibar/runtime"
 	"github.com/uber/zanzibar/runtime/jsonwrapper"
@@ -45,7 +43,10 @@ import (
 const CustomTemplateTesting = "test"
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 // Client defines custom-bar client interface.
 type Client interface {
@@ -231,15 +232,30 @@ type customBarClient struct {
 	httpClient                *zanzibar.HTTPClient
 	jsonWrapper               jsonwrapper.JSONWrapper
 	circuitBreakerDisabled    bool
+	circuitBreaker            zanzibar.CircuitBreaker
 	requestUUIDHeaderKey      string
 	requestProcedureHeaderKey string
 }
 
 // NewClient returns a new http client.
 func NewClient(deps *module.Dependencies) Client {
-	ip := deps.Default.Config.MustGetString("clients.custom-bar.ip")
-	port := deps.Default.Config.MustGetInt("clients.custom-bar.port")
-	baseURL := fmt.Sprintf("http://%s:%d", ip, port)
+	peerProvider := zanzibar.NewPeerProvider(deps.Default.Config, "custom-bar", deps.Default.Logger, deps.Default.Scope)
+	// the gateway starts the peer provider, without one no peers would be discovered
+	if peerProvider != nil && deps.Default.Gateway == nil {
+		panic(errors.New("client custom-bar has a peer provider but no gateway to start it"))
+	}
+	tlsConfig := zanzibar.NewHTTPClientTLSConfig(deps.Default.Config, "custom-bar", deps.Default.Logger, deps.Default.Scope)
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	// with a host list or a peer provider the host of each request is picked by the host pool
+	baseURL := scheme + "://custom-bar"
+	if peerProvider == nil && !deps.Default.Config.ContainsKey("clients.custom-bar.hostList") {
+		ip := deps.Default.Config.MustGetString("clients.custom-bar.ip")
+		port := deps.Default.Config.MustGetInt("clients.custom-bar.port")
+		baseURL = fmt.Sprintf("%s://%s:%d", scheme, ip, port)
+	}
 	timeoutVal := int(deps.Default.Config.MustGetInt("clients.custom-bar.timeout"))
 	timeout := time.Millisecond * time.Duration(
 		timeoutVal,
@@ -356,6 +372,7 @@ func NewClient(deps *module.Dependencies) Client {
 		"custom-bar-NormalRecur":                     "default",
 		"custom-bar-TooManyArgs":                     "default",
 	}
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "custom-bar", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for methodName, methodTimeout := range clientMethodTimeoutMapping {
 			circuitBreakerName := "custom-bar" + "-" + methodName
@@ -363,11 +380,11 @@ func NewClient(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, int(methodTimeout), circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "custom-bar", qpsLevel, int(methodTimeout)))
 		}
 	}
 
-	return &customBarClient{
+	client := &customBarClient{
 		clientID: "custom-bar",
 		httpClient: zanzibar.NewHTTPClientContext(
 			deps.Default.ContextLogger, deps.Default.ContextMetrics, deps.Default.JSONWrapper,
@@ -379,70 +396,97 @@ func NewClient(deps *module.Dependencies) Client {
 			followRedirect,
 		),
 		circuitBreakerDisabled:    circuitBreakerDisabled,
+		circuitBreaker:            circuitBreaker,
 		requestUUIDHeaderKey:      requestUUIDHeaderKey,
 		requestProcedureHeaderKey: requestProcedureHeaderKey,
 	}
+	client.httpClient.Hedging = zanzibar.NewHedgingPolicy(deps.Default.Config, "custom-bar")
+	idempotentMethods := map[string]bool{
+		"ArgNotStruct":                    false,
+		"ArgWithHeaders":                  false,
+		"ArgWithManyQueryParams":          true,
+		"ArgWithNearDupQueryParams":       true,
+		"ArgWithNestedQueryParams":        true,
+		"ArgWithParams":                   true,
+		"ArgWithParamsAndDuplicateFields": false,
+		"ArgWithQueryHeader":              false,
+		"ArgWithQueryParams":              true,
+		"DeleteFoo":                       true,
+		"DeleteWithBody":                  true,
+		"DeleteWithQueryParams":           true,
+		"Hello":                           true,
+		"ListAndEnum":                     true,
+		"MissingArg":                      true,
+		"NoRequest":                       true,
+		"Normal":                          false,
+		"NormalRecur":                     false,
+		"TooManyArgs":                     false,
+		"EchoBinary":                      false,
+		"EchoBool":                        false,
+		"EchoDouble":                      false,
+		"EchoEnum":                        false,
+		"EchoI16":                         false,
+		"EchoI32":                         false,
+		"EchoI32Map":                      false,
+		"EchoI64":                         false,
+		"EchoI8":                          false,
+		"EchoString":                      false,
+		"EchoStringList":                  false,
+		"EchoStringMap":                   false,
+		"EchoStringSet":                   false,
+		"EchoStructList":                  false,
+		"EchoStructSet":                   false,
+		"EchoTypedef":                     false,
+	}
+	client.httpClient.RetryPolicy = zanzibar.NewRetryPolicy(deps.Default.Config, "custom-bar", idempotentMethods)
+	transportOptions := zanzibar.NewHTTPTransportOptions(deps.Default.Config, "custom-bar")
+	transportOptions.TLSConfig = tlsConfig
+	client.httpClient.SetTransport(transportOptions.NewTransport())
+	client.httpClient.Hosts = zanzibar.NewHTTPHostPool(deps.Default.Config, "custom-bar", deps.Default.Logger, deps.Default.Scope)
+	if peerProvider != nil {
+		peerProvider.Subscribe(client.httpClient.Hosts.SetHosts)
+	}
+	client.watchDynamicConfig(deps, methodNames, qpsLevels)
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterHTTPClient("custom-bar", client.httpClient)
+		deps.Default.Gateway.RegisterCircuitBreaker("custom-bar", circuitBreaker)
+		deps.Default.Gateway.RegisterPeerProvider("custom-bar", peerProvider)
+	}
+	return client
 }
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
+// watchDynamicConfig re-applies the timeout and circuit breaker settings
+// when they change in the gateway's dynamic config.
+func (c *customBarClient) watchDynamicConfig(deps *module.Dependencies, methodNames map[string]string, qpsLevels map[string]string) {
+	if deps.Default.Gateway == nil || deps.Default.Gateway.DynamicConfig == nil {
+		return
+	}
+	dynamicConfig := deps.Default.Gateway.DynamicConfig
+	reconfigure := func(change zanzibar.ConfigChange) {
+		timeoutVal := int(dynamicConfig.MustGetInt("clients.custom-bar.timeout"))
+		c.httpClient.SetTimeout(time.Millisecond * time.Duration(timeoutVal))
+		if c.circuitBreakerDisabled {
+			return
+		}
+		clientMethodTimeoutMapping := make(map[string]int64)
+		if dynamicConfig.ContainsKey("clients.custom-bar.methodTimeoutMapping") {
+			dynamicConfig.MustGetStruct("clients.custom-bar.methodTimeoutMapping", &clientMethodTimeoutMapping)
+		} else {
+			for methodName := range methodNames {
+				clientMethodTimeoutMapping[methodName] = int64(timeoutVal)
 			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
-			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
+		}
+		for methodName, methodTimeout := range clientMethodTimeoutMapping {
+			circuitBreakerName := "custom-bar" + "-" + methodName
+			qpsLevel := "default"
+			if level, ok := qpsLevels[circuitBreakerName]; ok {
+				qpsLevel = level
 			}
+			c.circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(dynamicConfig, "custom-bar", qpsLevel, int(methodTimeout)))
 		}
 	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.custom-bar.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.custom-bar.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.custom-bar.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.custom-bar.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.custom-bar.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.custom-bar.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.custom-bar.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.custom-bar.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-		MaxConcurrentRequests:  maxConcurrentRequests,
-		ErrorPercentThreshold:  errorPercentThreshold,
-		SleepWindow:            sleepWindowInMilliseconds,
-		RequestVolumeThreshold: requestVolumeThreshold,
-		Timeout:                timeoutVal,
-	})
+	dynamicConfig.Subscribe("clients.custom-bar.", reconfigure)
+	dynamicConfig.Subscribe(CircuitBreakerConfigKey, reconfigure)
 }
 
 // HTTPClient returns the underlying HTTP client, should only be
@@ -482,17 +526,17 @@ func (c *customBarClient) ArgNotStruct(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "ArgNotStruct"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -592,17 +636,17 @@ func (c *customBarClient) ArgWithHeaders(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "ArgWithHeaders"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -777,17 +821,17 @@ func (c *customBarClient) ArgWithManyQueryParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "ArgWithManyQueryParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -884,17 +928,17 @@ func (c *customBarClient) ArgWithNearDupQueryParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "ArgWithNearDupQueryParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1015,17 +1059,17 @@ func (c *customBarClient) ArgWithNestedQueryParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "ArgWithNestedQueryParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1105,17 +1149,17 @@ func (c *customBarClient) ArgWithParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "ArgWithParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1195,17 +1239,17 @@ func (c *customBarClient) ArgWithParamsAndDuplicateFields(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "ArgWithParamsAndDuplicateFields"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1285,17 +1329,17 @@ func (c *customBarClient) ArgWithQueryHeader(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "ArgWithQueryHeader"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1392,17 +1436,17 @@ func (c *customBarClient) ArgWithQueryParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "ArgWithQueryParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1488,17 +1532,17 @@ func (c *customBarClient) DeleteFoo(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "DeleteFoo"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1570,17 +1614,17 @@ func (c *customBarClient) DeleteWithBody(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "DeleteWithBody"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1661,17 +1705,17 @@ func (c *customBarClient) DeleteWithQueryParams(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "DeleteWithQueryParams"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1743,17 +1787,17 @@ func (c *customBarClient) Hello(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "Hello"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1860,17 +1904,17 @@ func (c *customBarClient) ListAndEnum(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "ListAndEnum"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -1959,17 +2003,17 @@ func (c *customBarClient) MissingArg(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "MissingArg"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2059,17 +2103,17 @@ func (c *customBarClient) NoRequest(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "NoRequest"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2160,17 +2204,17 @@ func (c *customBarClient) Normal(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "Normal"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2261,17 +2305,17 @@ func (c *customBarClient) NormalRecur(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "NormalRecur"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2361,17 +2405,17 @@ func (c *customBarClient) TooManyArgs(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "TooManyArgs"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2476,17 +2520,17 @@ func (c *customBarClient) EchoBinary(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoBinary"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2564,17 +2608,17 @@ func (c *customBarClient) EchoBool(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoBool"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2658,17 +2702,17 @@ func (c *customBarClient) EchoDouble(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoDouble"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2752,17 +2796,17 @@ func (c *customBarClient) EchoEnum(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoEnum"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2846,17 +2890,17 @@ func (c *customBarClient) EchoI16(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoI16"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -2940,17 +2984,17 @@ func (c *customBarClient) EchoI32(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoI32"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3034,17 +3078,17 @@ func (c *customBarClient) EchoI32Map(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoI32Map"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3128,17 +3172,17 @@ func (c *customBarClient) EchoI64(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoI64"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3222,17 +3266,17 @@ func (c *customBarClient) EchoI8(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoI8"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3316,17 +3360,17 @@ func (c *customBarClient) EchoString(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoString"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3410,17 +3454,17 @@ func (c *customBarClient) EchoStringList(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoStringList"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3504,17 +3548,17 @@ func (c *customBarClient) EchoStringMap(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoStringMap"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3598,17 +3642,17 @@ func (c *customBarClient) EchoStringSet(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoStringSet"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3692,17 +3736,17 @@ func (c *customBarClient) EchoStructList(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoStructList"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3786,17 +3830,17 @@ func (c *customBarClient) EchoStructSet(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoStructSet"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
@@ -3880,17 +3924,17 @@ func (c *customBarClient) EchoTypedef(
 	if c.circuitBreakerDisabled {
 		res, err = req.Do()
 	} else {
-		// We want hystrix ckt-breaker to count errors only for system issues
+		// We want the circuit breaker to count errors only for system issues
 		var clientErr error
 		circuitBreakerName := "custom-bar" + "-" + "EchoTypedef"
-		err = hystrix.DoC(ctx, circuitBreakerName, func(ctx context.Context) error {
+		err = c.circuitBreaker.Do(ctx, circuitBreakerName, func(ctx context.Context) error {
 			res, clientErr = req.Do()
 			if res != nil {
 				// This is not a system error/issue. Downstream responded
 				return nil
 			}
 			return clientErr
-		}, nil)
+		})
 		if err == nil {
 			// ckt-breaker was ok, bubble up client error if set
 			err = clientErr
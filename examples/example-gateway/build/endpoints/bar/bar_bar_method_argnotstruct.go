@@ -26,7 +26,6 @@ package barendpoint
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -56,7 +55,7 @@ func NewBarArgNotStructHandler(deps *module.Dependencies) *BarArgNotStructHandle
 	handler := &BarArgNotStructHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "argNotStruct",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -66,8 +65,9 @@ func NewBarArgNotStructHandler(deps *module.Dependencies) *BarArgNotStructHandle
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
@@ -76,7 +76,7 @@ func NewBarArgNotStructHandler(deps *module.Dependencies) *BarArgNotStructHandle
 func (h *BarArgNotStructHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"POST", "/bar/arg-not-struct-path",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
@@ -27,7 +27,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -58,7 +57,7 @@ func NewBarArgWithHeadersHandler(deps *module.Dependencies) *BarArgWithHeadersHa
 	handler := &BarArgWithHeadersHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "argWithHeaders",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -68,8 +67,10 @@ func NewBarArgWithHeadersHandler(deps *module.Dependencies) *BarArgWithHeadersHa
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.MaxBodyBytes = 1024
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
@@ -78,7 +79,7 @@ func NewBarArgWithHeadersHandler(deps *module.Dependencies) *BarArgWithHeadersHa
 func (h *BarArgWithHeadersHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"POST", "/bar/argWithHeaders",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
@@ -27,7 +27,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -58,7 +57,7 @@ func NewBarArgWithManyQueryParamsHandler(deps *module.Dependencies) *BarArgWithM
 	handler := &BarArgWithManyQueryParamsHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "argWithManyQueryParams",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -68,8 +67,9 @@ func NewBarArgWithManyQueryParamsHandler(deps *module.Dependencies) *BarArgWithM
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 1
 
 	return handler
 }
@@ -78,7 +78,7 @@ func NewBarArgWithManyQueryParamsHandler(deps *module.Dependencies) *BarArgWithM
 func (h *BarArgWithManyQueryParamsHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/bar/argWithManyQueryParams",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
@@ -27,7 +27,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -58,7 +57,7 @@ func NewBarArgWithNearDupQueryParamsHandler(deps *module.Dependencies) *BarArgWi
 	handler := &BarArgWithNearDupQueryParamsHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "argWithNearDupQueryParams",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -68,8 +67,9 @@ func NewBarArgWithNearDupQueryParamsHandler(deps *module.Dependencies) *BarArgWi
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 1
 
 	return handler
 }
@@ -78,7 +78,7 @@ func NewBarArgWithNearDupQueryParamsHandler(deps *module.Dependencies) *BarArgWi
 func (h *BarArgWithNearDupQueryParamsHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/bar/argWithNearDupQueryParams",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
@@ -27,7 +27,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -58,7 +57,7 @@ func NewBarArgWithNestedQueryParamsHandler(deps *module.Dependencies) *BarArgWit
 	handler := &BarArgWithNestedQueryParamsHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "argWithNestedQueryParams",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -68,8 +67,9 @@ func NewBarArgWithNestedQueryParamsHandler(deps *module.Dependencies) *BarArgWit
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
@@ -78,7 +78,7 @@ func NewBarArgWithNestedQueryParamsHandler(deps *module.Dependencies) *BarArgWit
 func (h *BarArgWithNestedQueryParamsHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/bar/argWithNestedQueryParams",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
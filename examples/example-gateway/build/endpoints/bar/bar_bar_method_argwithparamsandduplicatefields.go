@@ -27,7 +27,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -57,7 +56,7 @@ func NewBarArgWithParamsAndDuplicateFieldsHandler(deps *module.Dependencies) *Ba
 	handler := &BarArgWithParamsAndDuplicateFieldsHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "argWithParamsAndDuplicateFields",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -67,8 +66,9 @@ func NewBarArgWithParamsAndDuplicateFieldsHandler(deps *module.Dependencies) *Ba
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 1
 
 	return handler
 }
@@ -77,7 +77,7 @@ func NewBarArgWithParamsAndDuplicateFieldsHandler(deps *module.Dependencies) *Ba
 func (h *BarArgWithParamsAndDuplicateFieldsHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"POST", "/bar/argWithParamsAndDuplicateFields/:uuid/segment",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
@@ -26,7 +26,6 @@ package barendpoint
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -56,7 +55,7 @@ func NewBarDeleteWithBodyHandler(deps *module.Dependencies) *BarDeleteWithBodyHa
 	handler := &BarDeleteWithBodyHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "deleteWithBody",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -66,8 +65,9 @@ func NewBarDeleteWithBodyHandler(deps *module.Dependencies) *BarDeleteWithBodyHa
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 3
 
 	return handler
 }
@@ -76,7 +76,7 @@ func NewBarDeleteWithBodyHandler(deps *module.Dependencies) *BarDeleteWithBodyHa
 func (h *BarDeleteWithBodyHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"DELETE", "/bar/withBody",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
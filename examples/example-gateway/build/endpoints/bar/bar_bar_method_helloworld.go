@@ -26,7 +26,6 @@ package barendpoint
 import (
 	"context"
 	"encoding/json"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -56,7 +55,7 @@ func NewBarHelloWorldHandler(deps *module.Dependencies) *BarHelloWorldHandler {
 	handler := &BarHelloWorldHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "helloWorld",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -66,18 +65,39 @@ func NewBarHelloWorldHandler(deps *module.Dependencies) *BarHelloWorldHandler {
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 2
+	handler.endpoint.CORS = zanzibar.NewCORSOptions(deps.Default.Config).With(zanzibar.CORSOptions{
+		AllowedOrigins: []string{"https://*.example.com"},
+		MaxAge:         600000 * time.Millisecond,
+	})
 
 	return handler
 }
 
 // Register adds the http handler to the gateway's http router
 func (h *BarHelloWorldHandler) Register(g *zanzibar.Gateway) error {
-	return g.HTTPRouter.Handle(
-		"GET", "/bar/hello",
-		http.HandlerFunc(h.endpoint.HandleRequest),
-	)
+	for _, version := range []zanzibar.APIVersion{
+		{
+			Deprecated: true,
+			Name:       "v1",
+			PathPrefix: "/v1",
+			Sunset:     time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Name: "v2",
+		},
+	} {
+		err := g.HTTPRouter.Handle(
+			"GET", version.Path("/bar/hello"),
+			h.endpoint.WithAPIVersion(version),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // HandleRequest handles "/bar/hello".
@@ -27,7 +27,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -58,7 +57,7 @@ func NewBarListAndEnumHandler(deps *module.Dependencies) *BarListAndEnumHandler
 	handler := &BarListAndEnumHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "listAndEnum",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -68,8 +67,14 @@ func NewBarListAndEnumHandler(deps *module.Dependencies) *BarListAndEnumHandler
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.Timeout = 2000 * time.Millisecond
+	handler.endpoint.RateLimit = zanzibar.NewRateLimitOptions(deps.Default.Config).With(zanzibar.RateLimitOptions{
+		Burst: 200,
+		RPS:   100,
+	})
+	handler.endpoint.QPSLevel = 1
 
 	return handler
 }
@@ -78,7 +83,7 @@ func NewBarListAndEnumHandler(deps *module.Dependencies) *BarListAndEnumHandler
 func (h *BarListAndEnumHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/bar/list-and-enum",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
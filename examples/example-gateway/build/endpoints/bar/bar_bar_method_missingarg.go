@@ -25,7 +25,6 @@ package barendpoint
 
 import (
 	"context"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -55,7 +54,7 @@ func NewBarMissingArgHandler(deps *module.Dependencies) *BarMissingArgHandler {
 	handler := &BarMissingArgHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "missingArg",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -65,8 +64,9 @@ func NewBarMissingArgHandler(deps *module.Dependencies) *BarMissingArgHandler {
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
@@ -75,7 +75,7 @@ func NewBarMissingArgHandler(deps *module.Dependencies) *BarMissingArgHandler {
 func (h *BarMissingArgHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/bar/missing-arg-path",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
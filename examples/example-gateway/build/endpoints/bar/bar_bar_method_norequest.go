@@ -25,7 +25,6 @@ package barendpoint
 
 import (
 	"context"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -55,7 +54,7 @@ func NewBarNoRequestHandler(deps *module.Dependencies) *BarNoRequestHandler {
 	handler := &BarNoRequestHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "noRequest",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -65,8 +64,9 @@ func NewBarNoRequestHandler(deps *module.Dependencies) *BarNoRequestHandler {
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 3
 
 	return handler
 }
@@ -75,7 +75,7 @@ func NewBarNoRequestHandler(deps *module.Dependencies) *BarNoRequestHandler {
 func (h *BarNoRequestHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/bar/no-request-path",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
@@ -27,7 +27,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -58,7 +57,7 @@ func NewBarNormalHandler(deps *module.Dependencies) *BarNormalHandler {
 	handler := &BarNormalHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"bar", "normal",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -74,8 +73,9 @@ func NewBarNormalHandler(deps *module.Dependencies) *BarNormalHandler {
 					Foo: "test",
 				},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 1
 
 	return handler
 }
@@ -84,7 +84,7 @@ func NewBarNormalHandler(deps *module.Dependencies) *BarNormalHandler {
 func (h *BarNormalHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"POST", "/bar/bar-path",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
@@ -26,7 +26,6 @@ package bazendpoint
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"strconv"
 	"time"
@@ -57,7 +56,7 @@ func NewSimpleServiceCallHandler(deps *module.Dependencies) *SimpleServiceCallHa
 	handler := &SimpleServiceCallHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"baz", "call",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -67,8 +66,9 @@ func NewSimpleServiceCallHandler(deps *module.Dependencies) *SimpleServiceCallHa
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 4
 
 	return handler
 }
@@ -77,7 +77,7 @@ func NewSimpleServiceCallHandler(deps *module.Dependencies) *SimpleServiceCallHa
 func (h *SimpleServiceCallHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"POST", "/baz/call",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
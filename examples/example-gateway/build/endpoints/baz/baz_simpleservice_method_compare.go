@@ -27,7 +27,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -57,7 +56,7 @@ func NewSimpleServiceCompareHandler(deps *module.Dependencies) *SimpleServiceCom
 	handler := &SimpleServiceCompareHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"baz", "compare",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -67,8 +66,9 @@ func NewSimpleServiceCompareHandler(deps *module.Dependencies) *SimpleServiceCom
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
@@ -77,7 +77,7 @@ func NewSimpleServiceCompareHandler(deps *module.Dependencies) *SimpleServiceCom
 func (h *SimpleServiceCompareHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"POST", "/baz/compare",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
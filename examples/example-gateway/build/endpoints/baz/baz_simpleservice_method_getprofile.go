@@ -27,7 +27,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -57,7 +56,7 @@ func NewSimpleServiceGetProfileHandler(deps *module.Dependencies) *SimpleService
 	handler := &SimpleServiceGetProfileHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"baz", "getProfile",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -67,8 +66,9 @@ func NewSimpleServiceGetProfileHandler(deps *module.Dependencies) *SimpleService
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
@@ -77,7 +77,7 @@ func NewSimpleServiceGetProfileHandler(deps *module.Dependencies) *SimpleService
 func (h *SimpleServiceGetProfileHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"POST", "/baz/get-profile",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
@@ -25,7 +25,6 @@ package bazendpoint
 
 import (
 	"context"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -54,7 +53,7 @@ func NewSimpleServicePingHandler(deps *module.Dependencies) *SimpleServicePingHa
 	handler := &SimpleServicePingHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"baz", "ping",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -64,8 +63,10 @@ func NewSimpleServicePingHandler(deps *module.Dependencies) *SimpleServicePingHa
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 1
+	handler.endpoint.Criticality = zanzibar.CriticalityCritical
 
 	return handler
 }
@@ -74,7 +75,7 @@ func NewSimpleServicePingHandler(deps *module.Dependencies) *SimpleServicePingHa
 func (h *SimpleServicePingHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/baz/ping",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
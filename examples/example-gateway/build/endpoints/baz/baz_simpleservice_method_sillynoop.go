@@ -25,7 +25,6 @@ package bazendpoint
 
 import (
 	"context"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -55,7 +54,7 @@ func NewSimpleServiceSillyNoopHandler(deps *module.Dependencies) *SimpleServiceS
 	handler := &SimpleServiceSillyNoopHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"baz", "sillyNoop",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -65,8 +64,9 @@ func NewSimpleServiceSillyNoopHandler(deps *module.Dependencies) *SimpleServiceS
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 3
 
 	return handler
 }
@@ -75,7 +75,7 @@ func NewSimpleServiceSillyNoopHandler(deps *module.Dependencies) *SimpleServiceS
 func (h *SimpleServiceSillyNoopHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/baz/silly-noop",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
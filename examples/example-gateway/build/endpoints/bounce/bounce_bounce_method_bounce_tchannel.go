@@ -56,6 +56,7 @@ func NewBounceBounceHandler(deps *module.Dependencies) *BounceBounceHandler {
 			),
 		}, handler),
 	)
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
@@ -26,7 +26,6 @@ package clientlessendpoint
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -57,7 +56,7 @@ func NewClientlessEmptyclientlessRequestHandler(deps *module.Dependencies) *Clie
 	handler := &ClientlessEmptyclientlessRequestHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"clientless", "emptyclientlessRequest",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -67,8 +66,9 @@ func NewClientlessEmptyclientlessRequestHandler(deps *module.Dependencies) *Clie
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 3
 
 	return handler
 }
@@ -77,7 +77,7 @@ func NewClientlessEmptyclientlessRequestHandler(deps *module.Dependencies) *Clie
 func (h *ClientlessEmptyclientlessRequestHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/clientless/emptyclientlessRequest",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
@@ -26,7 +26,6 @@ package multiendpoint
 import (
 	"context"
 	"encoding/json"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -55,7 +54,7 @@ func NewServiceAFrontHelloHandler(deps *module.Dependencies) *ServiceAFrontHello
 	handler := &ServiceAFrontHelloHandler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"multi", "helloA",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -65,7 +64,7 @@ func NewServiceAFrontHelloHandler(deps *module.Dependencies) *ServiceAFrontHello
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
 
 	return handler
@@ -75,7 +74,7 @@ func NewServiceAFrontHelloHandler(deps *module.Dependencies) *ServiceAFrontHello
 func (h *ServiceAFrontHelloHandler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/multi/serviceA_f/hello",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
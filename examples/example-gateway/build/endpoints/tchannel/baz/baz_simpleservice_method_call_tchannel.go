@@ -63,6 +63,7 @@ func NewSimpleServiceCallHandler(deps *module.Dependencies) *SimpleServiceCallHa
 			),
 		}, handler),
 	)
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
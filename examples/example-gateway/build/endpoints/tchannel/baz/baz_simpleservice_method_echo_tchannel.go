@@ -56,6 +56,11 @@ func NewSimpleServiceEchoHandler(deps *module.Dependencies) *SimpleServiceEchoHa
 			),
 		}, handler),
 	)
+	handler.endpoint.RateLimit = zanzibar.NewRateLimitOptions(deps.Default.Config).With(zanzibar.RateLimitOptions{
+		CallerBurst: 100,
+		CallerRPS:   50,
+	})
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
@@ -56,6 +56,7 @@ func NewEchoEchoHandler(deps *module.Dependencies) *EchoEchoHandler {
 			),
 		}, handler),
 	)
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
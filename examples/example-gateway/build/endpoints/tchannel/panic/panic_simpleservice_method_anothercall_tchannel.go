@@ -57,6 +57,7 @@ func NewSimpleServiceAnotherCallHandler(deps *module.Dependencies) *SimpleServic
 			),
 		}, handler),
 	)
+	handler.endpoint.QPSLevel = 2
 
 	return handler
 }
@@ -56,6 +56,7 @@ func NewSimpleServiceEchoStringHandler(deps *module.Dependencies) *SimpleService
 			),
 		}, handler),
 	)
+	handler.endpoint.QPSLevel = 1
 
 	return handler
 }
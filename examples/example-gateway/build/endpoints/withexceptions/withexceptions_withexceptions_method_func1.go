@@ -25,7 +25,6 @@ package withexceptionsendpoint
 
 import (
 	"context"
-	"net/http"
 	"runtime/debug"
 	"time"
 
@@ -55,7 +54,7 @@ func NewWithExceptionsFunc1Handler(deps *module.Dependencies) *WithExceptionsFun
 	handler := &WithExceptionsFunc1Handler{
 		Dependencies: deps,
 	}
-	handler.endpoint = zanzibar.NewRouterEndpoint(
+	handler.endpoint = zanzibar.NewRouterEndpointWithStack(
 		deps.Default.ContextExtractor, deps.Default,
 		"withexceptions", "Func1",
 		zanzibar.NewStack([]zanzibar.MiddlewareHandle{
@@ -65,8 +64,9 @@ func NewWithExceptionsFunc1Handler(deps *module.Dependencies) *WithExceptionsFun
 			deps.Middleware.DefaultExample.NewMiddlewareHandle(
 				defaultExample.Options{},
 			),
-		}, handler.HandleRequest).Handle,
+		}, handler.HandleRequest),
 	)
+	handler.endpoint.QPSLevel = 1
 
 	return handler
 }
@@ -75,7 +75,7 @@ func NewWithExceptionsFunc1Handler(deps *module.Dependencies) *WithExceptionsFun
 func (h *WithExceptionsFunc1Handler) Register(g *zanzibar.Gateway) error {
 	return g.HTTPRouter.Handle(
 		"GET", "/withexceptions/func1",
-		http.HandlerFunc(h.endpoint.HandleRequest),
+		h.endpoint,
 	)
 }
 
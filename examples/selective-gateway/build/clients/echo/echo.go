@@ -26,7 +26,6 @@ package echoclient
 import (
 	"context"
 
-	"github.com/afex/hystrix-go/hystrix"
 	"go.uber.org/yarpc"
 
 	module "github.com/uber/zanzibar/examples/selective-gateway/build/clients/echo/module"
@@ -36,7 +35,10 @@ import (
 )
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 // Client defines echo client interface.
 type Client interface {
@@ -49,8 +51,9 @@ type Client interface {
 
 // echoClient is the gRPC client for downstream service.
 type echoClient struct {
-	echoClient gen.EchoYARPCClient
-	opts       *zanzibar.GRPCClientOpts
+	echoClient     gen.EchoYARPCClient
+	opts           *zanzibar.GRPCClientOpts
+	circuitBreaker zanzibar.CircuitBreaker
 }
 
 // NewClient returns a new gRPC client for service echo
@@ -78,6 +81,7 @@ func NewClient(deps *module.Dependencies) Client {
 	if deps.Default.Config.ContainsKey("clients.echo.circuitBreakerDisabled") {
 		circuitBreakerDisabled = deps.Default.Config.MustGetBoolean("clients.echo.circuitBreakerDisabled")
 	}
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "echo", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for _, methodName := range methodNames {
 			circuitBreakerName := "echo" + "-" + methodName
@@ -85,12 +89,18 @@ func NewClient(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, timeoutInMS, circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "echo", qpsLevel, timeoutInMS))
 		}
 	}
 
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterGRPCClient("echo", oc)
+		deps.Default.Gateway.RegisterCircuitBreaker("echo", circuitBreaker)
+	}
+
 	return &echoClient{
-		echoClient: gen.NewEchoYARPCClient(oc),
+		circuitBreaker: circuitBreaker,
+		echoClient:     gen.NewEchoYARPCClient(oc),
 		opts: zanzibar.NewGRPCClientOpts(
 			deps.Default.ContextLogger,
 			deps.Default.ContextMetrics,
@@ -105,67 +115,6 @@ func NewClient(deps *module.Dependencies) Client {
 	}
 }
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
-			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
-			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
-			}
-		}
-	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.echo.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.echo.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.echo.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.echo.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.echo.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.echo.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.echo.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.echo.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-		MaxConcurrentRequests:  maxConcurrentRequests,
-		ErrorPercentThreshold:  errorPercentThreshold,
-		SleepWindow:            sleepWindowInMilliseconds,
-		RequestVolumeThreshold: requestVolumeThreshold,
-		Timeout:                timeoutVal,
-	})
-}
-
 // EchoEcho is a client RPC call for method Echo::Echo.
 func (e *echoClient) EchoEcho(
 	ctx context.Context,
@@ -197,10 +146,10 @@ func (e *echoClient) EchoEcho(
 		result, err = runFunc(ctxWithTimeout, request, opts...)
 	} else {
 		circuitBreakerName := "echo" + "-" + "EchoEcho"
-		err = hystrix.DoC(ctxWithTimeout, circuitBreakerName, func(ctx context.Context) error {
+		err = e.circuitBreaker.Do(ctxWithTimeout, circuitBreakerName, func(ctx context.Context) error {
 			result, err = runFunc(ctx, request, opts...)
 			return err
-		}, nil)
+		})
 	}
 	callHelper.Finish(ctx, err)
 
@@ -26,7 +26,6 @@ package mirrorclient
 import (
 	"context"
 
-	"github.com/afex/hystrix-go/hystrix"
 	"go.uber.org/yarpc"
 
 	module "github.com/uber/zanzibar/examples/selective-gateway/build/clients/mirror/module"
@@ -36,7 +35,10 @@ import (
 )
 
 // CircuitBreakerConfigKey is key value for qps level to circuit breaker parameters mapping
-const CircuitBreakerConfigKey = "circuitbreaking-configurations"
+const CircuitBreakerConfigKey = zanzibar.CircuitBreakerConfigKey
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig = zanzibar.CircuitBreakerConfig
 
 // Client defines mirror client interface.
 type Client interface {
@@ -58,6 +60,7 @@ type mirrorClient struct {
 	mirrorClient         gen.MirrorYARPCClient
 	mirrorInternalClient gen.MirrorInternalYARPCClient
 	opts                 *zanzibar.GRPCClientOpts
+	circuitBreaker       zanzibar.CircuitBreaker
 }
 
 // NewClient returns a new gRPC client for service mirror
@@ -87,6 +90,7 @@ func NewClient(deps *module.Dependencies) Client {
 	if deps.Default.Config.ContainsKey("clients.mirror.circuitBreakerDisabled") {
 		circuitBreakerDisabled = deps.Default.Config.MustGetBoolean("clients.mirror.circuitBreakerDisabled")
 	}
+	circuitBreaker := zanzibar.NewCircuitBreaker(deps.Default.Config, "mirror", deps.Default.Logger, deps.Default.Scope)
 	if !circuitBreakerDisabled {
 		for _, methodName := range methodNames {
 			circuitBreakerName := "mirror" + "-" + methodName
@@ -94,11 +98,17 @@ func NewClient(deps *module.Dependencies) Client {
 			if level, ok := qpsLevels[circuitBreakerName]; ok {
 				qpsLevel = level
 			}
-			configureCircuitBreaker(deps, timeoutInMS, circuitBreakerName, qpsLevel)
+			circuitBreaker.Configure(circuitBreakerName, zanzibar.NewCircuitBreakerSettings(deps.Default.Config, "mirror", qpsLevel, timeoutInMS))
 		}
 	}
 
+	if deps.Default.Gateway != nil {
+		deps.Default.Gateway.HealthChecker.RegisterGRPCClient("mirror", oc)
+		deps.Default.Gateway.RegisterCircuitBreaker("mirror", circuitBreaker)
+	}
+
 	return &mirrorClient{
+		circuitBreaker:       circuitBreaker,
 		mirrorClient:         gen.NewMirrorYARPCClient(oc),
 		mirrorInternalClient: gen.NewMirrorInternalYARPCClient(oc),
 		opts: zanzibar.NewGRPCClientOpts(
@@ -115,67 +125,6 @@ func NewClient(deps *module.Dependencies) Client {
 	}
 }
 
-// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
-type CircuitBreakerConfig struct {
-	Parameters map[string]map[string]int
-}
-
-func configureCircuitBreaker(deps *module.Dependencies, timeoutVal int, circuitBreakerName string, qpsLevel string) {
-	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
-	// to reject requests before allowing attempts again to determine if the circuit should again be closed
-	sleepWindowInMilliseconds := 5000
-	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
-	maxConcurrentRequests := 20
-	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
-	errorPercentThreshold := 20
-	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
-	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
-	// the circuit will not trip open even if all 19 failed.
-	requestVolumeThreshold := 20
-	// parses circuit breaker configurations
-	if deps.Default.Config.ContainsKey(CircuitBreakerConfigKey) {
-		var config CircuitBreakerConfig
-		deps.Default.Config.MustGetStruct(CircuitBreakerConfigKey, &config)
-		parameters := config.Parameters
-		// first checks if level exists in configurations then assigns parameters
-		// if "default" qps level assigns default parameters from circuit breaker configurations
-		if settings, ok := parameters[qpsLevel]; ok {
-			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
-				sleepWindowInMilliseconds = sleep
-			}
-			if max, ok := settings["maxConcurrentRequests"]; ok {
-				maxConcurrentRequests = max
-			}
-			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
-				errorPercentThreshold = errorPercent
-			}
-			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
-				requestVolumeThreshold = reqVolThreshold
-			}
-		}
-	}
-	// client settings override parameters
-	if deps.Default.Config.ContainsKey("clients.mirror.sleepWindowInMilliseconds") {
-		sleepWindowInMilliseconds = int(deps.Default.Config.MustGetInt("clients.mirror.sleepWindowInMilliseconds"))
-	}
-	if deps.Default.Config.ContainsKey("clients.mirror.maxConcurrentRequests") {
-		maxConcurrentRequests = int(deps.Default.Config.MustGetInt("clients.mirror.maxConcurrentRequests"))
-	}
-	if deps.Default.Config.ContainsKey("clients.mirror.errorPercentThreshold") {
-		errorPercentThreshold = int(deps.Default.Config.MustGetInt("clients.mirror.errorPercentThreshold"))
-	}
-	if deps.Default.Config.ContainsKey("clients.mirror.requestVolumeThreshold") {
-		requestVolumeThreshold = int(deps.Default.Config.MustGetInt("clients.mirror.requestVolumeThreshold"))
-	}
-	hystrix.ConfigureCommand(circuitBreakerName, hystrix.CommandConfig{
-		MaxConcurrentRequests:  maxConcurrentRequests,
-		ErrorPercentThreshold:  errorPercentThreshold,
-		SleepWindow:            sleepWindowInMilliseconds,
-		RequestVolumeThreshold: requestVolumeThreshold,
-		Timeout:                timeoutVal,
-	})
-}
-
 // MirrorMirror is a client RPC call for method Mirror::Mirror.
 func (e *mirrorClient) MirrorMirror(
 	ctx context.Context,
@@ -207,10 +156,10 @@ func (e *mirrorClient) MirrorMirror(
 		result, err = runFunc(ctxWithTimeout, request, opts...)
 	} else {
 		circuitBreakerName := "mirror" + "-" + "MirrorMirror"
-		err = hystrix.DoC(ctxWithTimeout, circuitBreakerName, func(ctx context.Context) error {
+		err = e.circuitBreaker.Do(ctxWithTimeout, circuitBreakerName, func(ctx context.Context) error {
 			result, err = runFunc(ctx, request, opts...)
 			return err
-		}, nil)
+		})
 	}
 	callHelper.Finish(ctx, err)
 
@@ -248,10 +197,10 @@ func (e *mirrorClient) MirrorInternalMirror(
 		result, err = runFunc(ctxWithTimeout, request, opts...)
 	} else {
 		circuitBreakerName := "mirror" + "-" + "MirrorInternalMirror"
-		err = hystrix.DoC(ctxWithTimeout, circuitBreakerName, func(ctx context.Context) error {
+		err = e.circuitBreaker.Do(ctxWithTimeout, circuitBreakerName, func(ctx context.Context) error {
 			result, err = runFunc(ctx, request, opts...)
 			return err
-		}, nil)
+		})
 	}
 	callHelper.Finish(ctx, err)
 
@@ -0,0 +1,506 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	adminEndpointID      = "admin"
+	adminRequestRejected = "admin.request.rejected"
+	adminRedactedValue   = "<redacted>"
+)
+
+// defaultAdminRedactKeys are the case insensitive key fragments whose values
+// are never served by the admin config endpoint.
+var defaultAdminRedactKeys = []string{
+	"password",
+	"secret",
+	"token",
+	"credential",
+	"apikey",
+	"privatekey",
+}
+
+// defaultAdminAllowlist only allows loopback callers.
+var defaultAdminAllowlist = []string{"127.0.0.1/32", "::1/128"}
+
+// adminHandler serves read only introspection endpoints for the gateway,
+// requests are only served when they arrive on the bind address from an
+// allowed remote address.
+type adminHandler struct {
+	gateway    *Gateway
+	bindIP     net.IP
+	allowlist  []*net.IPNet
+	redactKeys []string
+	// allowUnixSocket serves the requests arriving on a Unix socket, which
+	// have no IP address, the file mode of the socket controls the access
+	allowUnixSocket bool
+}
+
+// adminRoute is an entry of the admin route table.
+type adminRoute struct {
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	Host        string            `json:"host,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	APIVersion  string            `json:"apiVersion,omitempty"`
+	EndpointID  string            `json:"endpointID,omitempty"`
+	HandlerID   string            `json:"handlerID,omitempty"`
+	Middlewares []string          `json:"middlewares,omitempty"`
+}
+
+// adminTChannelMethod is an entry of the admin TChannel method table.
+type adminTChannelMethod struct {
+	Method      string   `json:"method"`
+	EndpointID  string   `json:"endpointID"`
+	HandlerID   string   `json:"handlerID"`
+	Middlewares []string `json:"middlewares,omitempty"`
+}
+
+// adminMiddlewareStack is the middleware stack of an endpoint.
+type adminMiddlewareStack struct {
+	Protocol    string   `json:"protocol"`
+	EndpointID  string   `json:"endpointID"`
+	HandlerID   string   `json:"handlerID"`
+	Middlewares []string `json:"middlewares"`
+}
+
+// adminClient is a configured client with the state of its circuit breakers.
+type adminClient struct {
+	ClientID               string            `json:"clientID"`
+	CircuitBreakerDisabled bool              `json:"circuitBreakerDisabled"`
+	CircuitBreakers        map[string]string `json:"circuitBreakers"`
+}
+
+// setupAdmin reads the admin config, the admin endpoints are only
+// registered when admin.enabled is set. Requests arriving on the Unix
+// sockets of http.socketPath and http.localSocketPath are rejected unless
+// admin.allowUnixSocket is set, which is required when both are set.
+func (gateway *Gateway) setupAdmin(config *StaticConfig) error {
+	if !config.ContainsKey("admin.enabled") || !config.MustGetBoolean("admin.enabled") {
+		return nil
+	}
+
+	bindAddress := localhost
+	if config.ContainsKey("admin.bindAddress") {
+		bindAddress = config.MustGetString("admin.bindAddress")
+	}
+	bindIP := net.ParseIP(bindAddress)
+	if bindIP == nil {
+		return errors.Errorf("invalid admin.bindAddress: %s", bindAddress)
+	}
+
+	allowlist := append([]string{}, defaultAdminAllowlist...)
+	if config.ContainsKey("admin.allowlist") {
+		config.MustGetStruct("admin.allowlist", &allowlist)
+	}
+	nets, err := parseAllowlist(allowlist)
+	if err != nil {
+		return errors.Wrap(err, "invalid admin.allowlist")
+	}
+
+	redactKeys := append([]string{}, defaultAdminRedactKeys...)
+	if config.ContainsKey("admin.redactKeys") {
+		var extra []string
+		config.MustGetStruct("admin.redactKeys", &extra)
+		redactKeys = append(redactKeys, extra...)
+	}
+	for i, key := range redactKeys {
+		redactKeys[i] = strings.ToLower(key)
+	}
+
+	allowUnixSocket := config.ContainsKey("admin.allowUnixSocket") && config.MustGetBoolean("admin.allowUnixSocket")
+	if !allowUnixSocket && config.ContainsKey("http.socketPath") && config.ContainsKey("http.localSocketPath") {
+		return errors.New(
+			"admin endpoints are unreachable with http.socketPath and http.localSocketPath set, " +
+				"set admin.allowUnixSocket to serve them over the sockets",
+		)
+	}
+
+	gateway.admin = &adminHandler{
+		gateway:         gateway,
+		bindIP:          bindIP,
+		allowlist:       nets,
+		redactKeys:      redactKeys,
+		allowUnixSocket: allowUnixSocket,
+	}
+	return nil
+}
+
+// parseAllowlist parses a list of IP addresses and CIDR blocks.
+func parseAllowlist(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, errors.Errorf("invalid IP address: %s", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// register adds the admin endpoints to the gateway's http router.
+func (admin *adminHandler) register(deps *DefaultDependencies) error {
+	handlers := []struct {
+		method, path, handlerID string
+		handler                 HandlerFn
+	}{
+		{"GET", "/admin/config", "config", admin.handleConfig},
+		{"GET", "/admin/routes", "routes", admin.handleRoutes},
+		{"GET", "/admin/tchannel", "tchannel", admin.handleTChannel},
+		{"GET", "/admin/middlewares", "middlewares", admin.handleMiddlewares},
+		{"GET", "/admin/clients", "clients", admin.handleClients},
+		{"GET", "/admin/loglevel", "loglevel", admin.handleLogLevel},
+		{"PUT", "/admin/loglevel", "setLoglevel", admin.handleSetLogLevel},
+	}
+	for _, h := range handlers {
+		endpoint := NewRouterEndpoint(
+			admin.gateway.ContextExtractor, deps,
+			adminEndpointID, h.handlerID,
+			admin.protect(h.handler),
+		)
+		endpoint.Criticality = CriticalityCritical
+		endpoint.RateLimit = nil
+		if err := admin.gateway.HTTPRouter.Handle(h.method, h.path, endpoint); err != nil {
+			return errors.Wrapf(err, "could not register admin endpoint %s %s", h.method, h.path)
+		}
+	}
+	return nil
+}
+
+// protect rejects requests that did not arrive on the bind address or
+// whose remote address is not in the allowlist.
+func (admin *adminHandler) protect(handler HandlerFn) HandlerFn {
+	return func(ctx context.Context, req *ServerHTTPRequest, res *ServerHTTPResponse) context.Context {
+		if !admin.allowed(req.httpRequest) {
+			admin.gateway.RootScope.Counter(adminRequestRejected).Inc(1)
+			admin.gateway.ContextLogger.Warn(ctx, "Rejected admin request",
+				zap.String("remoteAddr", req.httpRequest.RemoteAddr),
+			)
+			res.SendErrorString(http.StatusForbidden, "Forbidden")
+			return ctx
+		}
+		return handler(ctx, req, res)
+	}
+}
+
+func (admin *adminHandler) allowed(r *http.Request) bool {
+	localAddr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if ok && localAddr.Network() == "unix" {
+		return admin.allowUnixSocket
+	}
+	if !admin.bindIP.IsUnspecified() {
+		if !ok {
+			return false
+		}
+		localIP := addrIP(localAddr.String())
+		if localIP == nil || !localIP.Equal(admin.bindIP) {
+			return false
+		}
+	}
+
+	remoteIP := addrIP(r.RemoteAddr)
+	if remoteIP == nil {
+		return false
+	}
+	for _, ipNet := range admin.allowlist {
+		if ipNet.Contains(remoteIP) {
+			return true
+		}
+	}
+	return false
+}
+
+func addrIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.ParseIP(host)
+}
+
+func (admin *adminHandler) writeJSON(res *ServerHTTPResponse, value interface{}) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		res.SendError(http.StatusInternalServerError, "Could not serialize admin response", err)
+		return
+	}
+	res.WriteJSONBytes(http.StatusOK, nil, bytes)
+}
+
+func (admin *adminHandler) handleConfig(
+	ctx context.Context,
+	req *ServerHTTPRequest,
+	res *ServerHTTPResponse,
+) context.Context {
+	var config map[string]interface{}
+	if admin.gateway.DynamicConfig != nil {
+		config = admin.gateway.DynamicConfig.InspectOrDie()
+	} else {
+		config = admin.gateway.InspectOrDie()
+	}
+	admin.writeJSON(res, admin.redact(config))
+	return ctx
+}
+
+// redact replaces the values of keys matching the redact rules, nested
+// objects are redacted recursively.
+func (admin *adminHandler) redact(values map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if admin.isSecret(key) {
+			redacted[key] = adminRedactedValue
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = admin.redact(nested)
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+func (admin *adminHandler) isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range admin.redactKeys {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func (admin *adminHandler) handleRoutes(
+	ctx context.Context,
+	req *ServerHTTPRequest,
+	res *ServerHTTPResponse,
+) context.Context {
+	routes := []adminRoute{}
+	if router, ok := admin.gateway.HTTPRouter.(*httpRouter); ok {
+		for _, r := range router.routes() {
+			route := adminRoute{Method: r.Method, Path: r.Path, Host: r.Match.Host, Headers: r.Match.Headers}
+			if r.Endpoint != nil {
+				route.EndpointID = r.Endpoint.EndpointName
+				route.HandlerID = r.Endpoint.HandlerName
+				route.Middlewares = r.Endpoint.Middlewares()
+				if r.Endpoint.APIVersion != nil {
+					route.APIVersion = r.Endpoint.APIVersion.Name
+				}
+			}
+			routes = append(routes, route)
+		}
+	}
+	admin.writeJSON(res, routes)
+	return ctx
+}
+
+func (admin *adminHandler) handleTChannel(
+	ctx context.Context,
+	req *ServerHTTPRequest,
+	res *ServerHTTPResponse,
+) context.Context {
+	methods := []adminTChannelMethod{}
+	if admin.gateway.ServerTChannelRouter != nil {
+		for _, e := range admin.gateway.ServerTChannelRouter.registeredEndpoints() {
+			methods = append(methods, adminTChannelMethod{
+				Method:      e.Method,
+				EndpointID:  e.EndpointID,
+				HandlerID:   e.HandlerID,
+				Middlewares: e.Middlewares(),
+			})
+		}
+	}
+	admin.writeJSON(res, methods)
+	return ctx
+}
+
+func (admin *adminHandler) handleMiddlewares(
+	ctx context.Context,
+	req *ServerHTTPRequest,
+	res *ServerHTTPResponse,
+) context.Context {
+	stacks := []adminMiddlewareStack{}
+	seen := make(map[string]bool)
+	if router, ok := admin.gateway.HTTPRouter.(*httpRouter); ok {
+		for _, r := range router.routes() {
+			if r.Endpoint == nil || r.Endpoint.EndpointName == adminEndpointID {
+				continue
+			}
+			key := r.Endpoint.EndpointName + "." + r.Endpoint.HandlerName
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			stacks = append(stacks, adminMiddlewareStack{
+				Protocol:    scopeTagHTTP,
+				EndpointID:  r.Endpoint.EndpointName,
+				HandlerID:   r.Endpoint.HandlerName,
+				Middlewares: r.Endpoint.Middlewares(),
+			})
+		}
+	}
+	if admin.gateway.ServerTChannelRouter != nil {
+		for _, e := range admin.gateway.ServerTChannelRouter.registeredEndpoints() {
+			stacks = append(stacks, adminMiddlewareStack{
+				Protocol:    scopeTagTChannel,
+				EndpointID:  e.EndpointID,
+				HandlerID:   e.HandlerID,
+				Middlewares: e.Middlewares(),
+			})
+		}
+	}
+	admin.writeJSON(res, stacks)
+	return ctx
+}
+
+func (admin *adminHandler) handleClients(
+	ctx context.Context,
+	req *ServerHTTPRequest,
+	res *ServerHTTPResponse,
+) context.Context {
+	config := admin.gateway.Config
+	clientIDs := make(map[string]bool)
+	for key := range config.InspectOrDie() {
+		if !strings.HasPrefix(key, "clients.") {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(key, "clients."), ".", 2)
+		if len(parts) == 2 && parts[0] != "" {
+			clientIDs[parts[0]] = true
+		}
+	}
+
+	circuitBreakers := admin.gateway.CircuitBreakers()
+	for clientID := range circuitBreakers {
+		clientIDs[clientID] = true
+	}
+
+	clients := make([]adminClient, 0, len(clientIDs))
+	for clientID := range clientIDs {
+		disabledKey := "clients." + clientID + ".circuitBreakerDisabled"
+		client := adminClient{
+			ClientID:               clientID,
+			CircuitBreakerDisabled: config.ContainsKey(disabledKey) && config.MustGetBoolean(disabledKey),
+			CircuitBreakers:        make(map[string]string),
+		}
+		// circuits are named "<clientID>-<method>"
+		if cb, ok := circuitBreakers[clientID]; ok {
+			for _, name := range cb.Circuits() {
+				client.CircuitBreakers[strings.TrimPrefix(name, clientID+"-")] = cb.State(name).String()
+			}
+		}
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].ClientID < clients[j].ClientID
+	})
+
+	admin.writeJSON(res, clients)
+	return ctx
+}
+
+func (admin *adminHandler) handleLogLevel(
+	ctx context.Context,
+	req *ServerHTTPRequest,
+	res *ServerHTTPResponse,
+) context.Context {
+	admin.writeJSON(res, admin.logLevels())
+	return ctx
+}
+
+// adminLogLevelRequest changes the level of a logger, the logger defaults to
+// the root logger and the optional TTL is a duration such as "5m".
+type adminLogLevelRequest struct {
+	Logger string `json:"logger"`
+	Level  string `json:"level"`
+	TTL    string `json:"ttl"`
+}
+
+func (admin *adminHandler) handleSetLogLevel(
+	ctx context.Context,
+	req *ServerHTTPRequest,
+	res *ServerHTTPResponse,
+) context.Context {
+	body, ok := req.ReadAll()
+	if !ok {
+		return ctx
+	}
+	var change adminLogLevelRequest
+	if err := json.Unmarshal(body, &change); err != nil {
+		res.SendError(http.StatusBadRequest, "Could not parse log level request", err)
+		return ctx
+	}
+	if change.Logger == "" {
+		change.Logger = RootLoggerName
+	}
+	level, ok := levelMap[change.Level]
+	if !ok {
+		res.SendErrorString(http.StatusBadRequest, "Unknown log level")
+		return ctx
+	}
+	var ttl time.Duration
+	if change.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(change.TTL); err != nil {
+			res.SendError(http.StatusBadRequest, "Could not parse ttl", err)
+			return ctx
+		}
+	}
+	if err := admin.gateway.SetLogLevel(change.Logger, level, ttl); err != nil {
+		res.SendError(http.StatusBadRequest, "Unknown logger", err)
+		return ctx
+	}
+	admin.writeJSON(res, admin.logLevels())
+	return ctx
+}
+
+func (admin *adminHandler) logLevels() map[string]interface{} {
+	levels := admin.gateway.LogLevels()
+	return map[string]interface{}{
+		"level":   levels[RootLoggerName],
+		"loggers": levels,
+	}
+}
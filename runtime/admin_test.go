@@ -0,0 +1,119 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllowlist(t *testing.T) {
+	nets, err := parseAllowlist([]string{"127.0.0.1", "::1", "10.0.0.0/8"})
+	require.NoError(t, err)
+	require.Len(t, nets, 3)
+	assert.True(t, nets[0].Contains(net.ParseIP("127.0.0.1")))
+	assert.False(t, nets[0].Contains(net.ParseIP("127.0.0.2")))
+	assert.True(t, nets[1].Contains(net.ParseIP("::1")))
+	assert.True(t, nets[2].Contains(net.ParseIP("10.1.2.3")))
+
+	_, err = parseAllowlist([]string{"not-an-ip"})
+	assert.Error(t, err)
+	_, err = parseAllowlist([]string{"10.0.0.0/99"})
+	assert.Error(t, err)
+}
+
+func TestAdminAllowed(t *testing.T) {
+	nets, err := parseAllowlist(defaultAdminAllowlist)
+	require.NoError(t, err)
+	admin := &adminHandler{bindIP: net.ParseIP(localhost), allowlist: nets}
+
+	newRequest := func(localAddr, remoteAddr string) *http.Request {
+		r, _ := http.NewRequest("GET", "/admin/config", nil)
+		r.RemoteAddr = remoteAddr
+		if localAddr != "" {
+			addr, err := net.ResolveTCPAddr("tcp", localAddr)
+			require.NoError(t, err)
+			r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, addr))
+		}
+		return r
+	}
+
+	assert.True(t, admin.allowed(newRequest("127.0.0.1:8080", "127.0.0.1:5555")))
+	assert.False(t, admin.allowed(newRequest("10.0.0.1:8080", "127.0.0.1:5555")), "wrong bind address")
+	assert.False(t, admin.allowed(newRequest("127.0.0.1:8080", "10.0.0.2:5555")), "not in allowlist")
+	assert.False(t, admin.allowed(newRequest("", "127.0.0.1:5555")), "unknown local address")
+
+	admin.bindIP = net.IPv4zero
+	assert.True(t, admin.allowed(newRequest("10.0.0.1:8080", "127.0.0.1:5555")))
+
+	r, _ := http.NewRequest("GET", "/admin/config", nil)
+	r.RemoteAddr = "@"
+	r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey,
+		&net.UnixAddr{Name: "/tmp/gateway.sock", Net: "unix"}))
+	assert.False(t, admin.allowed(r), "unix socket")
+	admin.allowUnixSocket = true
+	assert.True(t, admin.allowed(r))
+}
+
+func TestSetupAdminUnixSockets(t *testing.T) {
+	gateway := &Gateway{}
+	require.NoError(t, gateway.setupAdmin(NewStaticConfigOrDie(nil, map[string]interface{}{
+		"admin.enabled":   true,
+		"http.socketPath": "/tmp/gateway.sock",
+	})))
+	assert.False(t, gateway.admin.allowUnixSocket)
+
+	config := map[string]interface{}{
+		"admin.enabled":        true,
+		"http.socketPath":      "/tmp/gateway.sock",
+		"http.localSocketPath": "/tmp/gateway-local.sock",
+	}
+	err := gateway.setupAdmin(NewStaticConfigOrDie(nil, config))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "admin.allowUnixSocket")
+
+	config["admin.allowUnixSocket"] = true
+	require.NoError(t, gateway.setupAdmin(NewStaticConfigOrDie(nil, config)))
+	assert.True(t, gateway.admin.allowUnixSocket)
+}
+
+func TestAdminRedact(t *testing.T) {
+	admin := &adminHandler{redactKeys: []string{"password", "token"}}
+	redacted := admin.redact(map[string]interface{}{
+		"clients.foo.timeout":  100,
+		"clients.foo.Password": "hunter2",
+		"clients.foo.alternates": map[string]interface{}{
+			"authToken": "abc",
+			"ip":        "127.0.0.1",
+		},
+	})
+	assert.Equal(t, 100, redacted["clients.foo.timeout"])
+	assert.Equal(t, adminRedactedValue, redacted["clients.foo.Password"])
+	assert.Equal(t, map[string]interface{}{
+		"authToken": adminRedactedValue,
+		"ip":        "127.0.0.1",
+	}, redacted["clients.foo.alternates"])
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	endpointDeprecatedRequests = "endpoint.deprecated.requests"
+	scopeTagAPIVersion         = "apiversion"
+)
+
+// APIVersion is a version of the API served by an endpoint, each version of
+// an endpoint is registered on its own route, see WithAPIVersion.
+type APIVersion struct {
+	// Name of the version such as "v1", it tags the usage metric of
+	// deprecated versions.
+	Name string
+	// PathPrefix selects the version by path, it is prepended to the path of
+	// the endpoint, e.g. "/v1".
+	PathPrefix string
+	// Headers select the version by header values such as
+	// {"x-api-version": "2"}, they add to the MatchHeaders of the endpoint.
+	Headers map[string]string
+	// Deprecated versions answer with a Deprecation header and count their
+	// requests.
+	Deprecated bool
+	// DeprecatedSince is when the version was deprecated, zero when unknown.
+	DeprecatedSince time.Time
+	// Sunset is when the version stops being served, zero when unknown.
+	Sunset time.Time
+}
+
+// Path returns the path of the version for the path of an endpoint.
+func (v APIVersion) Path(path string) string {
+	return v.PathPrefix + path
+}
+
+// WithAPIVersion returns a copy of the endpoint that serves the version.
+func (endpoint *RouterEndpoint) WithAPIVersion(version APIVersion) *RouterEndpoint {
+	versioned := *endpoint
+	versioned.APIVersion = &version
+	if len(version.Headers) > 0 {
+		headers := make(map[string]string, len(endpoint.MatchHeaders)+len(version.Headers))
+		for name, value := range endpoint.MatchHeaders {
+			headers[name] = value
+		}
+		for name, value := range version.Headers {
+			headers[name] = value
+		}
+		versioned.MatchHeaders = headers
+	}
+	return &versioned
+}
+
+// deprecate sets the Deprecation and Sunset headers of a request to a
+// deprecated version and counts it.
+func (router *httpRouter) deprecate(w http.ResponseWriter, endpoint *RouterEndpoint) {
+	version := endpoint.APIVersion
+	if version == nil || !version.Deprecated {
+		return
+	}
+	// the Deprecation header is a structured date (RFC 9745), "true" is
+	// the earlier draft form for an unknown date
+	deprecation := "true"
+	if !version.DeprecatedSince.IsZero() {
+		deprecation = "@" + strconv.FormatInt(version.DeprecatedSince.Unix(), 10)
+	}
+	w.Header().Set("Deprecation", deprecation)
+	if !version.Sunset.IsZero() {
+		w.Header().Set("Sunset", version.Sunset.UTC().Format(http.TimeFormat))
+	}
+	router.gateway.RootScope.Tagged(map[string]string{
+		scopeTagEndpoint:   endpoint.EndpointName,
+		scopeTagHandler:    endpoint.HandlerName,
+		scopeTagProtocol:   scopeTagHTTP,
+		scopeTagAPIVersion: version.Name,
+	}).Counter(endpointDeprecatedRequests).Inc(1)
+}
@@ -0,0 +1,361 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+const (
+	// CircuitBreakerConfigKey is the key of the circuit breaker parameters
+	// by qps level.
+	CircuitBreakerConfigKey = "circuitbreaking-configurations"
+
+	circuitBreakerTypeHystrix       = "hystrix"
+	circuitBreakerTypeSlidingWindow = "slidingWindow"
+	circuitBreakerForceOpen         = "open"
+	circuitBreakerForceClosed       = "closed"
+
+	circuitBreakerStateChange = "circuitbreaker.state-change"
+
+	scopeTagCircuit = "circuit"
+	scopeTagState   = "state"
+)
+
+var (
+	// ErrCircuitOpen is returned for calls rejected by an open circuit.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrCircuitMaxConcurrency is returned for calls rejected because the
+	// circuit has too many calls in flight.
+	ErrCircuitMaxConcurrency = errors.New("circuit breaker max concurrency reached")
+)
+
+// CircuitBreakerState is the state of a circuit.
+type CircuitBreakerState int
+
+const (
+	// CircuitClosed lets calls through.
+	CircuitClosed CircuitBreakerState = iota
+	// CircuitOpen rejects calls.
+	CircuitOpen
+	// CircuitHalfOpen lets a probe call through to decide whether the
+	// circuit closes again.
+	CircuitHalfOpen
+)
+
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreakerStateChange is called when a circuit changes state.
+type CircuitBreakerStateChange func(name string, from, to CircuitBreakerState)
+
+// CircuitBreaker guards the calls of a client, every client method has its
+// own named circuit. Calls are rejected with an error matching ErrCircuitOpen,
+// as reported by errors.Is, while the circuit of the method is open.
+type CircuitBreaker interface {
+	// Do calls run unless the circuit is open, an error returned by run
+	// counts as a failure.
+	Do(ctx context.Context, name string, run func(ctx context.Context) error) error
+	// Configure sets the settings of a circuit.
+	Configure(name string, settings CircuitBreakerSettings)
+	// State returns the state of a circuit.
+	State(name string) CircuitBreakerState
+	// Circuits returns the names of the configured circuits.
+	Circuits() []string
+}
+
+// CircuitBreakerSettings are the settings of a circuit.
+type CircuitBreakerSettings struct {
+	// Timeout fails calls that take longer.
+	Timeout time.Duration
+	// MaxConcurrentRequests rejects calls beyond this many in flight.
+	MaxConcurrentRequests int
+	// ErrorPercentThreshold opens the circuit at or above this percentage
+	// of failed calls.
+	ErrorPercentThreshold int
+	// RequestVolumeThreshold is the minimum number of calls in the rolling
+	// window of 10s before the circuit can open.
+	RequestVolumeThreshold int
+	// SleepWindow is how long an open circuit rejects calls before letting
+	// a probe through.
+	SleepWindow time.Duration
+	// ForceOpen rejects every call.
+	ForceOpen bool
+	// ForceClosed lets every call through regardless of failures.
+	ForceClosed bool
+}
+
+// CircuitBreakerConfig is used for storing the circuit breaker parameters for each qps level
+type CircuitBreakerConfig struct {
+	Parameters map[string]map[string]int
+}
+
+// NewCircuitBreakerSettings returns the settings of a client method circuit
+// with the given timeout in ms. The parameters of the qps level under
+// CircuitBreakerConfigKey override the defaults and the client settings
+// override both. "clients.<clientID>.circuitBreaker.force" set to "open" or
+// "closed" overrides the state of the circuit.
+func NewCircuitBreakerSettings(config ConfigReader, clientID string, qpsLevel string, timeout int) CircuitBreakerSettings {
+	// sleepWindowInMilliseconds sets the amount of time, after tripping the circuit,
+	// to reject requests before allowing attempts again to determine if the circuit should again be closed
+	sleepWindowInMilliseconds := 5000
+	// maxConcurrentRequests sets how many requests can be run at the same time, beyond which requests are rejected
+	maxConcurrentRequests := 20
+	// errorPercentThreshold sets the error percentage at or above which the circuit should trip open
+	errorPercentThreshold := 20
+	// requestVolumeThreshold sets a minimum number of requests that will trip the circuit in a rolling window of 10s
+	// For example, if the value is 20, then if only 19 requests are received in the rolling window of 10 seconds
+	// the circuit will not trip open even if all 19 failed.
+	requestVolumeThreshold := 20
+	// parses circuit breaker configurations
+	if config.ContainsKey(CircuitBreakerConfigKey) {
+		var cbConfig CircuitBreakerConfig
+		config.MustGetStruct(CircuitBreakerConfigKey, &cbConfig)
+		// first checks if level exists in configurations then assigns parameters
+		// if "default" qps level assigns default parameters from circuit breaker configurations
+		if settings, ok := cbConfig.Parameters[qpsLevel]; ok {
+			if sleep, ok := settings["sleepWindowInMilliseconds"]; ok {
+				sleepWindowInMilliseconds = sleep
+			}
+			if max, ok := settings["maxConcurrentRequests"]; ok {
+				maxConcurrentRequests = max
+			}
+			if errorPercent, ok := settings["errorPercentThreshold"]; ok {
+				errorPercentThreshold = errorPercent
+			}
+			if reqVolThreshold, ok := settings["requestVolumeThreshold"]; ok {
+				requestVolumeThreshold = reqVolThreshold
+			}
+		}
+	}
+	// client settings override parameters
+	prefix := "clients." + clientID + "."
+	if config.ContainsKey(prefix + "sleepWindowInMilliseconds") {
+		sleepWindowInMilliseconds = int(config.MustGetInt(prefix + "sleepWindowInMilliseconds"))
+	}
+	if config.ContainsKey(prefix + "maxConcurrentRequests") {
+		maxConcurrentRequests = int(config.MustGetInt(prefix + "maxConcurrentRequests"))
+	}
+	if config.ContainsKey(prefix + "errorPercentThreshold") {
+		errorPercentThreshold = int(config.MustGetInt(prefix + "errorPercentThreshold"))
+	}
+	if config.ContainsKey(prefix + "requestVolumeThreshold") {
+		requestVolumeThreshold = int(config.MustGetInt(prefix + "requestVolumeThreshold"))
+	}
+
+	settings := CircuitBreakerSettings{
+		Timeout:                time.Duration(timeout) * time.Millisecond,
+		MaxConcurrentRequests:  maxConcurrentRequests,
+		ErrorPercentThreshold:  errorPercentThreshold,
+		RequestVolumeThreshold: requestVolumeThreshold,
+		SleepWindow:            time.Duration(sleepWindowInMilliseconds) * time.Millisecond,
+	}
+	if config.ContainsKey(prefix + "circuitBreaker.force") {
+		switch config.MustGetString(prefix + "circuitBreaker.force") {
+		case circuitBreakerForceOpen:
+			settings.ForceOpen = true
+		case circuitBreakerForceClosed:
+			settings.ForceClosed = true
+		}
+	}
+	return settings
+}
+
+// NewCircuitBreaker returns the circuit breaker of a client, the
+// implementation is chosen by "clients.<clientID>.circuitBreaker.type",
+// "hystrix" by default or "slidingWindow". State changes are logged and
+// counted.
+func NewCircuitBreaker(config ConfigReader, clientID string, logger *zap.Logger, scope tally.Scope) CircuitBreaker {
+	onStateChange := func(name string, from, to CircuitBreakerState) {
+		scope.Tagged(map[string]string{
+			scopeTagClient:  clientID,
+			scopeTagCircuit: name,
+			scopeTagState:   to.String(),
+		}).Counter(circuitBreakerStateChange).Inc(1)
+		logger.Warn("Circuit breaker state changed",
+			zap.String(logFieldClientID, clientID),
+			zap.String("circuit", name),
+			zap.Stringer("from", from),
+			zap.Stringer("to", to),
+		)
+	}
+
+	typeKey := "clients." + clientID + ".circuitBreaker.type"
+	if config.ContainsKey(typeKey) && config.MustGetString(typeKey) == circuitBreakerTypeSlidingWindow {
+		return NewSlidingWindowCircuitBreaker(onStateChange)
+	}
+	return NewHystrixCircuitBreaker(onStateChange)
+}
+
+// hystrixCircuitBreaker runs calls with hystrix, hystrix keeps its circuits
+// globally by name.
+type hystrixCircuitBreaker struct {
+	onStateChange CircuitBreakerStateChange
+
+	mu       sync.Mutex
+	settings map[string]CircuitBreakerSettings // protected by mu
+	states   map[string]CircuitBreakerState    // protected by mu
+}
+
+// NewHystrixCircuitBreaker returns a CircuitBreaker backed by hystrix,
+// onStateChange may be nil.
+func NewHystrixCircuitBreaker(onStateChange CircuitBreakerStateChange) CircuitBreaker {
+	return &hystrixCircuitBreaker{
+		onStateChange: onStateChange,
+		settings:      make(map[string]CircuitBreakerSettings),
+		states:        make(map[string]CircuitBreakerState),
+	}
+}
+
+func (b *hystrixCircuitBreaker) Do(ctx context.Context, name string, run func(ctx context.Context) error) error {
+	b.mu.Lock()
+	settings := b.settings[name]
+	b.mu.Unlock()
+	if settings.ForceOpen {
+		return ErrCircuitOpen
+	}
+	if settings.ForceClosed {
+		return runWithTimeout(ctx, settings.Timeout, run)
+	}
+
+	err := hystrix.DoC(ctx, name, run, nil)
+	b.observe(name)
+	switch err {
+	case hystrix.ErrCircuitOpen:
+		return &circuitError{err: ErrCircuitOpen, cause: err}
+	case hystrix.ErrMaxConcurrency:
+		return &circuitError{err: ErrCircuitMaxConcurrency, cause: err}
+	}
+	return err
+}
+
+// circuitError is ErrCircuitOpen or ErrCircuitMaxConcurrency caused by an
+// error of the circuit breaker implementation, errors.Is matches both.
+type circuitError struct {
+	err   error
+	cause error
+}
+
+func (e *circuitError) Error() string { return e.err.Error() }
+
+func (e *circuitError) Is(target error) bool { return target == e.err }
+
+func (e *circuitError) Unwrap() error { return e.cause }
+
+func (b *hystrixCircuitBreaker) Configure(name string, settings CircuitBreakerSettings) {
+	hystrix.ConfigureCommand(name, hystrix.CommandConfig{
+		MaxConcurrentRequests:  settings.MaxConcurrentRequests,
+		ErrorPercentThreshold:  settings.ErrorPercentThreshold,
+		SleepWindow:            int(settings.SleepWindow / time.Millisecond),
+		RequestVolumeThreshold: settings.RequestVolumeThreshold,
+		Timeout:                int(settings.Timeout / time.Millisecond),
+	})
+	b.mu.Lock()
+	b.settings[name] = settings
+	b.mu.Unlock()
+	b.observe(name)
+}
+
+func (b *hystrixCircuitBreaker) State(name string) CircuitBreakerState {
+	b.mu.Lock()
+	settings := b.settings[name]
+	b.mu.Unlock()
+	if settings.ForceOpen {
+		return CircuitOpen
+	}
+	if settings.ForceClosed {
+		return CircuitClosed
+	}
+	circuit, _, err := hystrix.GetCircuit(name)
+	if err != nil || circuit == nil || !circuit.IsOpen() {
+		return CircuitClosed
+	}
+	return CircuitOpen
+}
+
+func (b *hystrixCircuitBreaker) Circuits() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return sortedKeys(b.settings)
+}
+
+// observe calls onStateChange when the state of the circuit differs from
+// the last one seen, hystrix does not report state changes itself.
+func (b *hystrixCircuitBreaker) observe(name string) {
+	state := b.State(name)
+	b.mu.Lock()
+	from, seen := b.states[name]
+	b.states[name] = state
+	b.mu.Unlock()
+	if seen && from != state && b.onStateChange != nil {
+		b.onStateChange(name, from, state)
+	}
+}
+
+func sortedKeys(settings map[string]CircuitBreakerSettings) []string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterCircuitBreaker makes the circuit breaker of a client visible to
+// the admin clients endpoint, it is safe to call on a nil Gateway.
+func (gateway *Gateway) RegisterCircuitBreaker(clientID string, cb CircuitBreaker) {
+	if gateway == nil {
+		return
+	}
+	gateway.circuitBreakersMu.Lock()
+	defer gateway.circuitBreakersMu.Unlock()
+	if gateway.circuitBreakers == nil {
+		gateway.circuitBreakers = make(map[string]CircuitBreaker)
+	}
+	gateway.circuitBreakers[clientID] = cb
+}
+
+// CircuitBreakers returns the registered circuit breakers by client ID.
+func (gateway *Gateway) CircuitBreakers() map[string]CircuitBreaker {
+	gateway.circuitBreakersMu.Lock()
+	defer gateway.circuitBreakersMu.Unlock()
+	cbs := make(map[string]CircuitBreaker, len(gateway.circuitBreakers))
+	for clientID, cb := range gateway.circuitBreakers {
+		cbs[clientID] = cb
+	}
+	return cbs
+}
@@ -0,0 +1,333 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+var errCircuitTest = errors.New("downstream failed")
+
+type stateChange struct {
+	name     string
+	from, to CircuitBreakerState
+}
+
+func newTestWindowBreaker(settings CircuitBreakerSettings) (*slidingWindowCircuitBreaker, *time.Time, *[]stateChange) {
+	now := time.Unix(1000, 0)
+	var changes []stateChange
+	cb := NewSlidingWindowCircuitBreaker(func(name string, from, to CircuitBreakerState) {
+		changes = append(changes, stateChange{name, from, to})
+	}).(*slidingWindowCircuitBreaker)
+	cb.now = func() time.Time { return now }
+	cb.Configure("foo-bar", settings)
+	return cb, &now, &changes
+}
+
+func failCall(ctx context.Context) error { return errCircuitTest }
+
+func okCall(ctx context.Context) error { return nil }
+
+func TestNewCircuitBreakerSettings(t *testing.T) {
+	config := NewStaticConfigOrDie([]*ConfigOption{
+		ConfigFileContents([]byte(`
+circuitbreaking-configurations:
+  parameters:
+    '1':
+      sleepWindowInMilliseconds: 1000
+      maxConcurrentRequests: 5
+      errorPercentThreshold: 30
+      requestVolumeThreshold: 10
+clients.foo.requestVolumeThreshold: 40
+clients.foo.circuitBreaker.force: open
+`)),
+	}, nil)
+
+	settings := NewCircuitBreakerSettings(config, "foo", "1", 100)
+	assert.Equal(t, CircuitBreakerSettings{
+		Timeout:                100 * time.Millisecond,
+		MaxConcurrentRequests:  5,
+		ErrorPercentThreshold:  30,
+		RequestVolumeThreshold: 40,
+		SleepWindow:            time.Second,
+		ForceOpen:              true,
+	}, settings)
+
+	settings = NewCircuitBreakerSettings(config, "bar", "default", 200)
+	assert.Equal(t, CircuitBreakerSettings{
+		Timeout:                200 * time.Millisecond,
+		MaxConcurrentRequests:  20,
+		ErrorPercentThreshold:  20,
+		RequestVolumeThreshold: 20,
+		SleepWindow:            5 * time.Second,
+	}, settings)
+}
+
+func TestSlidingWindowCircuitBreakerOpens(t *testing.T) {
+	cb, now, changes := newTestWindowBreaker(CircuitBreakerSettings{
+		ErrorPercentThreshold:  50,
+		RequestVolumeThreshold: 4,
+		SleepWindow:            time.Second,
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, cb.Do(ctx, "foo-bar", okCall))
+	assert.Equal(t, errCircuitTest, cb.Do(ctx, "foo-bar", failCall))
+	assert.NoError(t, cb.Do(ctx, "foo-bar", okCall))
+	assert.Equal(t, CircuitClosed, cb.State("foo-bar"))
+
+	// the volume threshold is reached with half the calls failed
+	assert.Equal(t, errCircuitTest, cb.Do(ctx, "foo-bar", failCall))
+	assert.Equal(t, CircuitOpen, cb.State("foo-bar"))
+
+	var called bool
+	err := cb.Do(ctx, "foo-bar", func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.False(t, called)
+
+	// calls older than the window do not count
+	*now = now.Add(2 * time.Second)
+	assert.NoError(t, cb.Do(ctx, "foo-bar", okCall))
+	assert.Equal(t, CircuitClosed, cb.State("foo-bar"))
+	*now = now.Add(circuitWindowBuckets * time.Second)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, cb.Do(ctx, "foo-bar", okCall))
+	}
+	assert.Equal(t, errCircuitTest, cb.Do(ctx, "foo-bar", failCall))
+	assert.Equal(t, CircuitClosed, cb.State("foo-bar"))
+
+	assert.Equal(t, []stateChange{
+		{"foo-bar", CircuitClosed, CircuitOpen},
+		{"foo-bar", CircuitOpen, CircuitHalfOpen},
+		{"foo-bar", CircuitHalfOpen, CircuitClosed},
+	}, *changes)
+}
+
+func TestSlidingWindowCircuitBreakerHalfOpen(t *testing.T) {
+	cb, now, changes := newTestWindowBreaker(CircuitBreakerSettings{
+		ErrorPercentThreshold:  50,
+		RequestVolumeThreshold: 1,
+		SleepWindow:            time.Second,
+	})
+	ctx := context.Background()
+
+	assert.Equal(t, errCircuitTest, cb.Do(ctx, "foo-bar", failCall))
+	assert.Equal(t, CircuitOpen, cb.State("foo-bar"))
+
+	// a failed probe opens the circuit again
+	*now = now.Add(time.Second)
+	assert.Equal(t, errCircuitTest, cb.Do(ctx, "foo-bar", failCall))
+	assert.Equal(t, CircuitOpen, cb.State("foo-bar"))
+	assert.Equal(t, ErrCircuitOpen, cb.Do(ctx, "foo-bar", okCall))
+
+	// only one probe goes through while half open
+	*now = now.Add(time.Second)
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, cb.Do(ctx, "foo-bar", func(ctx context.Context) error {
+			close(probing)
+			<-release
+			return nil
+		}))
+	}()
+	<-probing
+	assert.Equal(t, CircuitHalfOpen, cb.State("foo-bar"))
+	assert.Equal(t, ErrCircuitOpen, cb.Do(ctx, "foo-bar", okCall))
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, CircuitClosed, cb.State("foo-bar"))
+	assert.NoError(t, cb.Do(ctx, "foo-bar", okCall))
+
+	assert.Equal(t, []stateChange{
+		{"foo-bar", CircuitClosed, CircuitOpen},
+		{"foo-bar", CircuitOpen, CircuitHalfOpen},
+		{"foo-bar", CircuitHalfOpen, CircuitOpen},
+		{"foo-bar", CircuitOpen, CircuitHalfOpen},
+		{"foo-bar", CircuitHalfOpen, CircuitClosed},
+	}, *changes)
+}
+
+func TestSlidingWindowCircuitBreakerLimits(t *testing.T) {
+	cb, _, _ := newTestWindowBreaker(CircuitBreakerSettings{
+		Timeout:                10 * time.Millisecond,
+		MaxConcurrentRequests:  1,
+		ErrorPercentThreshold:  50,
+		RequestVolumeThreshold: 100,
+	})
+	ctx := context.Background()
+
+	err := cb.Do(ctx, "foo-bar", func(ctx context.Context) error {
+		assert.Equal(t, ErrCircuitMaxConcurrency, cb.Do(ctx, "foo-bar", okCall))
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.NoError(t, cb.Do(ctx, "foo-bar", okCall))
+}
+
+func TestCircuitBreakerForce(t *testing.T) {
+	for _, cb := range []CircuitBreaker{
+		NewSlidingWindowCircuitBreaker(nil),
+		NewHystrixCircuitBreaker(nil),
+	} {
+		cb.Configure("force-open", CircuitBreakerSettings{ForceOpen: true})
+		cb.Configure("force-closed", CircuitBreakerSettings{
+			ForceClosed:            true,
+			ErrorPercentThreshold:  1,
+			RequestVolumeThreshold: 1,
+		})
+		ctx := context.Background()
+
+		assert.Equal(t, ErrCircuitOpen, cb.Do(ctx, "force-open", okCall))
+		assert.Equal(t, CircuitOpen, cb.State("force-open"))
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, errCircuitTest, cb.Do(ctx, "force-closed", failCall))
+		}
+		assert.Equal(t, CircuitClosed, cb.State("force-closed"))
+		assert.Equal(t, []string{"force-closed", "force-open"}, cb.Circuits())
+	}
+}
+
+func TestCircuitBreakerForceClosedTimeout(t *testing.T) {
+	for _, cb := range []CircuitBreaker{
+		NewSlidingWindowCircuitBreaker(nil),
+		NewHystrixCircuitBreaker(nil),
+	} {
+		cb.Configure("force-closed-timeout", CircuitBreakerSettings{
+			Timeout:     10 * time.Millisecond,
+			ForceClosed: true,
+		})
+
+		err := cb.Do(context.Background(), "force-closed-timeout", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		assert.Equal(t, context.DeadlineExceeded, err)
+	}
+}
+
+func TestHystrixCircuitBreakerMaxConcurrency(t *testing.T) {
+	cb := NewHystrixCircuitBreaker(nil)
+	cb.Configure("hystrix-max-concurrency", CircuitBreakerSettings{
+		Timeout:                time.Second,
+		MaxConcurrentRequests:  1,
+		ErrorPercentThreshold:  100,
+		RequestVolumeThreshold: 100,
+		SleepWindow:            time.Second,
+	})
+	ctx := context.Background()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- cb.Do(ctx, "hystrix-max-concurrency", func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	err := cb.Do(ctx, "hystrix-max-concurrency", okCall)
+	assert.True(t, errors.Is(err, ErrCircuitMaxConcurrency), "%v", err)
+	assert.True(t, errors.Is(err, hystrix.ErrMaxConcurrency), "%v", err)
+	assert.False(t, errors.Is(err, ErrCircuitOpen), "%v", err)
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+func TestSlidingWindowCircuitBreakerConfigureWhileRunning(t *testing.T) {
+	cb := NewSlidingWindowCircuitBreaker(nil)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, cb.Do(ctx, "foo-bar", okCall))
+		}()
+		go func() {
+			defer wg.Done()
+			cb.Configure("foo-bar", CircuitBreakerSettings{Timeout: time.Second})
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewCircuitBreaker(t *testing.T) {
+	config := NewStaticConfigOrDie([]*ConfigOption{
+		ConfigFileContents([]byte(`
+clients.foo.circuitBreaker.type: slidingWindow
+`)),
+	}, nil)
+	scope := tally.NewTestScope("", nil)
+
+	cb := NewCircuitBreaker(config, "foo", zap.NewNop(), scope)
+	require.IsType(t, &slidingWindowCircuitBreaker{}, cb)
+	assert.IsType(t, &hystrixCircuitBreaker{}, NewCircuitBreaker(config, "bar", zap.NewNop(), scope))
+
+	cb.Configure("foo-bar", CircuitBreakerSettings{
+		ErrorPercentThreshold:  50,
+		RequestVolumeThreshold: 1,
+		SleepWindow:            time.Minute,
+	})
+	assert.Equal(t, errCircuitTest, cb.Do(context.Background(), "foo-bar", failCall))
+
+	key := tally.KeyForPrefixedStringMap(circuitBreakerStateChange, map[string]string{
+		scopeTagClient:  "foo",
+		scopeTagCircuit: "foo-bar",
+		scopeTagState:   "open",
+	})
+	counter, ok := scope.Snapshot().Counters()[key]
+	require.True(t, ok, "state change is counted")
+	assert.Equal(t, int64(1), counter.Value())
+}
+
+func TestRegisterCircuitBreaker(t *testing.T) {
+	var nilGateway *Gateway
+	assert.NotPanics(t, func() {
+		nilGateway.RegisterCircuitBreaker("foo", NewSlidingWindowCircuitBreaker(nil))
+	})
+
+	gateway := &Gateway{}
+	cb := NewSlidingWindowCircuitBreaker(nil)
+	gateway.RegisterCircuitBreaker("foo", cb)
+	assert.Equal(t, map[string]CircuitBreaker{"foo": cb}, gateway.CircuitBreakers())
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// circuitWindowBuckets is the number of 1s buckets of the rolling window.
+const circuitWindowBuckets = 10
+
+// slidingWindowCircuitBreaker counts the calls and failures of every circuit
+// over a rolling window of 10s. A closed circuit opens once the window has
+// enough calls and the failure percentage reaches the threshold, an open
+// circuit lets a single probe through after the sleep window and closes
+// again when it succeeds.
+type slidingWindowCircuitBreaker struct {
+	onStateChange CircuitBreakerStateChange
+	now           func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*windowCircuit // protected by mu
+}
+
+type windowCircuit struct {
+	settings CircuitBreakerSettings
+	state    CircuitBreakerState
+	openedAt time.Time
+	inflight int
+	probing  bool
+	buckets  [circuitWindowBuckets]windowBucket
+}
+
+type windowBucket struct {
+	second   int64
+	calls    int
+	failures int
+}
+
+// NewSlidingWindowCircuitBreaker returns a native CircuitBreaker with a
+// rolling window of call outcomes, onStateChange may be nil.
+func NewSlidingWindowCircuitBreaker(onStateChange CircuitBreakerStateChange) CircuitBreaker {
+	return &slidingWindowCircuitBreaker{
+		onStateChange: onStateChange,
+		now:           time.Now,
+		circuits:      make(map[string]*windowCircuit),
+	}
+}
+
+func (b *slidingWindowCircuitBreaker) Do(ctx context.Context, name string, run func(ctx context.Context) error) error {
+	b.mu.Lock()
+	c := b.circuit(name)
+	// Configure may replace the settings once b.mu is released
+	settings := c.settings
+	if settings.ForceOpen {
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	}
+	if settings.ForceClosed {
+		b.mu.Unlock()
+		return runWithTimeout(ctx, settings.Timeout, run)
+	}
+	from := c.state
+	probe, err := c.admit(b.now())
+	to := c.state
+	b.mu.Unlock()
+	b.notify(name, from, to)
+	if err != nil {
+		return err
+	}
+
+	err = runWithTimeout(ctx, settings.Timeout, run)
+
+	b.mu.Lock()
+	from = c.state
+	c.record(b.now(), probe, err == nil)
+	to = c.state
+	b.mu.Unlock()
+	b.notify(name, from, to)
+	return err
+}
+
+func (b *slidingWindowCircuitBreaker) Configure(name string, settings CircuitBreakerSettings) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.circuit(name).settings = settings
+}
+
+func (b *slidingWindowCircuitBreaker) State(name string) CircuitBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[name]
+	switch {
+	case !ok || c.settings.ForceClosed:
+		return CircuitClosed
+	case c.settings.ForceOpen:
+		return CircuitOpen
+	}
+	return c.state
+}
+
+func (b *slidingWindowCircuitBreaker) Circuits() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	settings := make(map[string]CircuitBreakerSettings, len(b.circuits))
+	for name, c := range b.circuits {
+		settings[name] = c.settings
+	}
+	return sortedKeys(settings)
+}
+
+// circuit returns the circuit of name, creating it with the hystrix
+// default settings, b.mu must be held.
+func (b *slidingWindowCircuitBreaker) circuit(name string) *windowCircuit {
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &windowCircuit{
+			settings: CircuitBreakerSettings{
+				Timeout:                time.Second,
+				MaxConcurrentRequests:  10,
+				ErrorPercentThreshold:  50,
+				RequestVolumeThreshold: 20,
+				SleepWindow:            5 * time.Second,
+			},
+		}
+		b.circuits[name] = c
+	}
+	return c
+}
+
+func (b *slidingWindowCircuitBreaker) notify(name string, from, to CircuitBreakerState) {
+	if from != to && b.onStateChange != nil {
+		b.onStateChange(name, from, to)
+	}
+}
+
+// runWithTimeout runs run with a deadline of timeout, if any.
+func runWithTimeout(ctx context.Context, timeout time.Duration, run func(ctx context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return run(ctx)
+}
+
+// admit decides whether a call can go through, probe is true when the call
+// decides whether a half open circuit closes.
+func (c *windowCircuit) admit(now time.Time) (probe bool, err error) {
+	switch c.state {
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < c.settings.SleepWindow {
+			return false, ErrCircuitOpen
+		}
+		c.state = CircuitHalfOpen
+		probe = true
+	case CircuitHalfOpen:
+		if c.probing {
+			return false, ErrCircuitOpen
+		}
+		probe = true
+	}
+	if c.settings.MaxConcurrentRequests > 0 && c.inflight >= c.settings.MaxConcurrentRequests {
+		return false, ErrCircuitMaxConcurrency
+	}
+	c.inflight++
+	c.probing = c.probing || probe
+	return probe, nil
+}
+
+// record counts the outcome of a call and updates the state.
+func (c *windowCircuit) record(now time.Time, probe, success bool) {
+	c.inflight--
+	if probe {
+		c.probing = false
+		if success {
+			c.state = CircuitClosed
+			c.buckets = [circuitWindowBuckets]windowBucket{}
+		} else {
+			c.state = CircuitOpen
+			c.openedAt = now
+		}
+		return
+	}
+
+	second := now.Unix()
+	bucket := &c.buckets[second%circuitWindowBuckets]
+	if bucket.second != second {
+		*bucket = windowBucket{second: second}
+	}
+	bucket.calls++
+	if !success {
+		bucket.failures++
+	}
+	if c.state != CircuitClosed {
+		return
+	}
+
+	var calls, failures int
+	for _, bucket := range c.buckets {
+		if second-bucket.second < circuitWindowBuckets {
+			calls += bucket.calls
+			failures += bucket.failures
+		}
+	}
+	if calls > 0 && calls >= c.settings.RequestVolumeThreshold &&
+		failures*100 >= c.settings.ErrorPercentThreshold*calls {
+		c.state = CircuitOpen
+		c.openedAt = now
+	}
+}
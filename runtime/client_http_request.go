@@ -206,7 +206,7 @@ func (req *ClientHTTPRequest) Do() (*ClientHTTPResponse, error) {
 
 	// when timeoutAndRetryOptions per request is not configured, use default client level timeout
 	if req.timeoutAndRetryOptions == nil || req.timeoutAndRetryOptions.MaxAttempts == 0 {
-		res, err = req.client.Client.Do(req.httpReq.WithContext(ctx))
+		res, err = req.send(ctx)
 	} else {
 		res, retryCount, err = req.executeDoWithRetry(ctx) // new code for retry and timeout per ep level
 	}
@@ -232,28 +232,53 @@ func (req *ClientHTTPRequest) executeDoWithRetry(ctx context.Context) (*http.Res
 	var res *http.Response
 	var retryCount int64 = 0
 
+	policy := req.client.RetryPolicy
+	if policy != nil {
+		policy.OnCall(req.MethodName)
+	}
+
 	for i := 0; i < req.timeoutAndRetryOptions.MaxAttempts; i++ {
 		retryCount++
 		res, err = req.executeDo(ctx)
 
-		if err == nil {
+		// without a retry policy only errors are retried
+		if err == nil && policy == nil {
 			return res, retryCount, nil
 		}
 
 		var shouldRetry = false
-		// if attempts are pending, wait for backoff duration before next attempt
+		var backoff time.Duration
+		// if attempts are pending, ask the policy whether to retry
 		if i+1 < req.timeoutAndRetryOptions.MaxAttempts {
-			shouldRetry = req.client.CheckRetry(ctx, req.timeoutAndRetryOptions, res, err)
+			if policy != nil {
+				backoff, shouldRetry = policy.Retry(ctx, &RetryAttempt{
+					Method:   req.MethodName,
+					Request:  req.httpReq,
+					Response: res,
+					Err:      err,
+					Attempt:  i + 1,
+				})
+			} else {
+				shouldRetry = req.client.CheckRetry(ctx, req.timeoutAndRetryOptions, res, err)
+			}
+		}
+
+		if err == nil && !shouldRetry {
+			return res, retryCount, nil
 		}
 
-		req.ContextLogger.Warn(ctx, "errors while making http outbound request",
-			zap.Error(err),
+		fields := []zap.Field{
 			zap.String("clientId", req.ClientID), zap.String("methodName", req.MethodName),
 			zap.Int64("attempt", retryCount),
 			zap.Int("maxAttempts", req.timeoutAndRetryOptions.MaxAttempts),
-			zap.Bool("shouldRetry", shouldRetry))
-
-		// TODO (future releases) - make retry conditional, inspect error/response and then retry
+			zap.Bool("shouldRetry", shouldRetry),
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		} else {
+			fields = append(fields, zap.Int("statusCode", res.StatusCode))
+		}
+		req.ContextLogger.Warn(ctx, "errors while making http outbound request", fields...)
 
 		// reassign body
 		if req.rawBody != nil && len(req.rawBody) > 0 {
@@ -264,6 +289,16 @@ func (req *ClientHTTPRequest) executeDoWithRetry(ctx context.Context) (*http.Res
 		if !shouldRetry {
 			break
 		}
+
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, retryCount, ctx.Err()
+			}
+		}
 	}
 	return nil, retryCount, err
 }
@@ -272,7 +307,7 @@ func (req *ClientHTTPRequest) executeDoWithRetry(ctx context.Context) (*http.Res
 func (req *ClientHTTPRequest) executeDo(ctx context.Context) (*http.Response, error) {
 	ctx, cancel := context.WithTimeout(ctx, req.timeoutAndRetryOptions.RequestTimeoutPerAttemptInMs)
 	defer cancel()
-	res, err := req.client.Client.Do(req.httpReq.WithContext(ctx))
+	res, err := req.send(ctx)
 	// when no error, read body and capture before closing the connection
 	if err == nil {
 		req.res.setRawHTTPResponse(res)
@@ -284,6 +319,46 @@ func (req *ClientHTTPRequest) executeDo(ctx context.Context) (*http.Response, er
 	return res, err
 }
 
+// send sends the request once, or hedged when the hedging policy of the
+// client covers the method.
+func (req *ClientHTTPRequest) send(ctx context.Context) (*http.Response, error) {
+	if !req.client.Hedging.covers(req.MethodName) {
+		setContextTTLHeader(ctx, req.httpReq.Header)
+		return req.client.do(req.httpReq.WithContext(req.traceConnection(ctx)))
+	}
+
+	responses := make([]*http.Response, req.client.Hedging.MaxHedges+1)
+	winner, hedges, err := req.client.Hedging.do(ctx, req.MethodName, func(ctx context.Context, n int) func() error {
+		// req is reused by the next retry once do returns, the attempt only
+		// uses its clone
+		httpReq := req.httpReq.Clone(req.traceConnection(ctx))
+		if req.rawBody != nil {
+			httpReq.Body = io.NopCloser(bytes.NewReader(req.rawBody))
+		}
+		setContextTTLHeader(ctx, httpReq.Header)
+		return func() error {
+			res, err := req.client.do(httpReq)
+			if err != nil {
+				return err
+			}
+			// read the body before the attempt wins, losers are canceled
+			body, err := io.ReadAll(res.Body)
+			_ = res.Body.Close()
+			if err != nil {
+				return err
+			}
+			res.Body = io.NopCloser(bytes.NewReader(body))
+			responses[n] = res
+			return nil
+		}
+	})
+	recordHedging(req.ctx, req.Metrics, hedges, winner, err)
+	if err != nil {
+		return nil, err
+	}
+	return responses[winner], nil
+}
+
 // InjectSpanToHeader will inject span to request header
 // This method is current used for unit tests
 // TODO: we need to set source and test code as same pkg name which would makes UTs easier
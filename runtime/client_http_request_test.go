@@ -26,7 +26,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -670,3 +672,159 @@ func TestDefaultRetryPolicy(t *testing.T) {
 	assert.True(t, time.Now().Sub(startTime).Milliseconds() >= backOffTimeAcrossRetriesInMs.Milliseconds(),
 		fmt.Sprintf("expected runtime duration for DefaultRetryPolicy method is >= %d MS", backOffTimeAcrossRetriesInMs.Milliseconds()))
 }
+
+func TestMakingClientCallWithHedging(t *testing.T) {
+	gateway, err := benchGateway.CreateGateway(
+		map[string]interface{}{
+			"clients.baz.serviceName":            "baz",
+			"clients.bar.circuitBreakerDisabled": true,
+			"clients.bar.hedging.methods":        []string{"Normal"},
+			"clients.bar.hedging.delay":          20,
+		},
+		defaultTestOptions,
+		exampleGateway.CreateGateway,
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer gateway.Close()
+
+	bgateway := gateway.(*benchGateway.BenchGateway)
+
+	var calls int32
+	bgateway.HTTPBackends()["bar"].HandleFunc(
+		"POST", "/bar-path",
+		func(w http.ResponseWriter, r *http.Request) {
+			// the first attempt is slow enough to be hedged and is canceled
+			// once the hedge wins
+			if atomic.AddInt32(&calls, 1) == 1 {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+				return
+			}
+			w.WriteHeader(200)
+			_, err := w.Write([]byte(`{"stringField":"hedged","intWithRange":0,"intWithoutRange":0,"mapIntWithRange":{},"mapIntWithoutRange":{},"binaryField":"aGVsbG8="}`))
+			assert.NoError(t, err)
+		},
+	)
+
+	deps := bgateway.Dependencies.(*exampleGateway.DependenciesTree)
+	_, result, _, err := deps.Client.Bar.Normal(
+		context.Background(), nil, &clientsBarBar.Bar_Normal_Args{},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "hedged", result.StringField)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMakingClientCallWithRetryPolicy(t *testing.T) {
+	gateway, err := benchGateway.CreateGateway(
+		map[string]interface{}{
+			"clients.baz.serviceName":                      "baz",
+			"clients.bar.circuitBreakerDisabled":           true,
+			"clients.bar.retryPolicy.enabled":              true,
+			"clients.bar.retryPolicy.idempotentMethods":    map[string]bool{"Normal": true},
+			"clients.bar.retryPolicy.retryableStatusCodes": []int{503},
+			"clients.bar.retryPolicy.initialBackoff":       1,
+			"clients.bar.retryPolicy.budget.percent":       10,
+			"clients.bar.retryPolicy.budget.maxRetries":    1,
+		},
+		defaultTestOptions,
+		exampleGateway.CreateGateway,
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer gateway.Close()
+
+	bgateway := gateway.(*benchGateway.BenchGateway)
+
+	var calls int32
+	bgateway.HTTPBackends()["bar"].HandleFunc(
+		"POST", "/bar-path",
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, `"dummy body"`, string(body))
+			if atomic.AddInt32(&calls, 1)%2 == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(200)
+		},
+	)
+
+	deps := bgateway.Dependencies.(*exampleGateway.DependenciesTree)
+	client := deps.Client.Bar.HTTPClient()
+
+	retryOptionsCopy := retryOptions
+	retryOptionsCopy.MaxAttempts = 3
+	ctx := zanzibar.WithTimeAndRetryOptions(context.Background(), &retryOptionsCopy)
+
+	doRequest := func() *zanzibar.ClientHTTPResponse {
+		req := zanzibar.NewClientHTTPRequest(ctx, "bar", "Normal", "bar::Normal", client)
+		assert.NoError(t, req.WriteJSON("POST", client.BaseURL+"/bar-path", nil, "dummy body"))
+		res, err := req.Do()
+		assert.NoError(t, err)
+		return res
+	}
+
+	// the 503 is retried
+	res := doRequest()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// the retry budget is spent, the 503 is returned
+	res = doRequest()
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestMakingClientCallWithHostList(t *testing.T) {
+	var good, bad int32
+	goodBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&good, 1)
+		w.WriteHeader(200)
+	}))
+	defer goodBackend.Close()
+	badBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&bad, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer badBackend.Close()
+
+	gateway, err := benchGateway.CreateGateway(
+		map[string]interface{}{
+			"clients.baz.serviceName":            "baz",
+			"clients.bar.circuitBreakerDisabled": true,
+			"clients.bar.hostList": []string{
+				badBackend.Listener.Addr().String(),
+				goodBackend.Listener.Addr().String(),
+			},
+			"clients.bar.outlierDetection.consecutiveFailures": 2,
+		},
+		defaultTestOptions,
+		exampleGateway.CreateGateway,
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer gateway.Close()
+
+	bgateway := gateway.(*benchGateway.BenchGateway)
+	deps := bgateway.Dependencies.(*exampleGateway.DependenciesTree)
+	client := deps.Client.Bar.HTTPClient()
+
+	for i := 0; i < 6; i++ {
+		req := zanzibar.NewClientHTTPRequest(context.Background(), "bar", "Normal", "bar::Normal", client)
+		assert.NoError(t, req.WriteJSON("POST", client.BaseURL+"/bar-path", nil, "dummy body"))
+		_, err := req.Do()
+		assert.NoError(t, err)
+	}
+
+	// the failing host is ejected after two failures in a row
+	assert.Equal(t, int32(2), atomic.LoadInt32(&bad))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&good))
+}
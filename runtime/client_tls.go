@@ -0,0 +1,149 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+// NewHTTPClientTLSConfig returns the TLS config of the outbound connections
+// of a client when "clients.<clientID>.tls.enabled" is set, or nil
+// otherwise. Servers are verified with the CAs of
+// "clients.<clientID>.tls.caFile", or with the system CAs when it is not
+// set, and the certificate of "clients.<clientID>.tls.certFile" and
+// "keyFile" is presented to servers that ask for one. "serverName" overrides
+// the name verified in server certificates, it must be set to verify servers
+// dialed by IP address against "caFile", and "insecureSkipVerify" skips the
+// verification, for development only. The files are reloaded when they
+// change, checked at most every "reloadInterval" ms on new connections. It
+// panics when the config is invalid or the files can not be loaded.
+func NewHTTPClientTLSConfig(config ConfigReader, clientID string, logger *zap.Logger, scope tally.Scope) *tls.Config {
+	prefix := "clients." + clientID + ".tls."
+	if !config.ContainsKey(prefix+"enabled") || !config.MustGetBoolean(prefix+"enabled") {
+		return nil
+	}
+	getString := func(key string) string {
+		if config.ContainsKey(prefix + key) {
+			return config.MustGetString(prefix + key)
+		}
+		return ""
+	}
+
+	certFile, keyFile, caFile := getString("certFile"), getString("keyFile"), getString("caFile")
+	if (certFile == "") != (keyFile == "") {
+		panic(errors.Errorf("%scertFile and %skeyFile must be set together", prefix, prefix))
+	}
+	var insecure bool
+	if config.ContainsKey(prefix + "insecureSkipVerify") {
+		insecure = config.MustGetBoolean(prefix + "insecureSkipVerify")
+	}
+	minVersion := uint16(tls.VersionTLS12)
+	if value := getString("minVersion"); value != "" {
+		var ok bool
+		if minVersion, ok = tlsVersions[value]; !ok {
+			panic(errors.Errorf("unknown %sminVersion: %s", prefix, value))
+		}
+	}
+	if certFile == "" && caFile == "" {
+		return &tls.Config{
+			MinVersion:         minVersion,
+			ServerName:         getString("serverName"),
+			InsecureSkipVerify: insecure,
+		}
+	}
+
+	var interval time.Duration
+	if config.ContainsKey(prefix + "reloadInterval") {
+		interval = time.Duration(config.MustGetInt(prefix+"reloadInterval")) * time.Millisecond
+	}
+	reloader, err := newCertReloader(certFile, keyFile, caFile, interval, logger, scope.Tagged(map[string]string{
+		scopeTagClient: clientID,
+	}))
+	if err != nil {
+		panic(errors.Wrapf(err, "error setting up TLS of client %s", clientID))
+	}
+	return reloader.clientTLSConfig(getString("serverName"), insecure, minVersion)
+}
+
+// clientTLSConfig returns a client TLS config that presents the reloaded
+// certificate and verifies servers with the reloaded CAs.
+func (r *certReloader) clientTLSConfig(serverName string, insecure bool, minVersion uint16) *tls.Config {
+	config := &tls.Config{
+		MinVersion:         minVersion,
+		ServerName:         serverName,
+		InsecureSkipVerify: insecure,
+	}
+	if r.certFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			r.reloadIfDue()
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.cert, nil
+		}
+	}
+	if r.caFile != "" && !insecure {
+		// RootCAs can not change once the config is in use, so servers are
+		// verified against the current CAs instead
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return r.verifyServer(state, serverName)
+		}
+	}
+	return config
+}
+
+// verifyServer verifies the certificate chain of a server against the
+// current CAs and its name, serverName or else the name sent in SNI, as
+// crypto/tls does with RootCAs. Hosts that are IP addresses are not sent in
+// SNI, so servers dialed by IP address are rejected without a serverName
+// rather than accepted with any certificate of the CAs.
+func (r *certReloader) verifyServer(state tls.ConnectionState, serverName string) error {
+	r.reloadIfDue()
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	if serverName == "" {
+		serverName = state.ServerName
+	}
+	if serverName == "" {
+		return errors.New("server name unknown, set the tls.serverName of the client to verify a server dialed by IP address")
+	}
+	r.mu.RLock()
+	cas := r.cas
+	r.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         cas,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+func TestHTTPClientMutualTLS(t *testing.T) {
+	files := newTestTLSFiles(t)
+	dir := filepath.Dir(files.certFile)
+	clientCertFile, clientKeyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	newTestCert(t, "client", files.ca, false).write(t, clientCertFile, clientKeyFile)
+
+	cas := x509.NewCertPool()
+	cas.AddCert(files.ca.cert)
+	serverCert, err := tls.LoadX509KeyPair(files.certFile, files.keyFile)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    cas,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	tlsConfig := NewHTTPClientTLSConfig(NewStaticConfigOrDie(nil, map[string]interface{}{
+		"clients.foo.tls.enabled":        true,
+		"clients.foo.tls.caFile":         files.caFile,
+		"clients.foo.tls.certFile":       clientCertFile,
+		"clients.foo.tls.keyFile":        clientKeyFile,
+		"clients.foo.tls.serverName":     "localhost",
+		"clients.foo.tls.reloadInterval": 1,
+	}), "foo", zap.NewNop(), tally.NoopScope)
+	require.NotNil(t, tlsConfig)
+	opts := DefaultHTTPTransportOptions
+	opts.TLSConfig = tlsConfig
+	transport := opts.NewTransport()
+	transport.DisableKeepAlives = true
+	client := &http.Client{Transport: transport}
+
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "client", string(body))
+
+	// servers are verified with the reloaded CAs
+	otherCA := newTestCert(t, "other-ca", nil, true)
+	require.NoError(t, ioutil.WriteFile(files.caFile, otherCA.certPEM, 0600))
+	assert.Eventually(t, func() bool {
+		_, err := client.Get(server.URL)
+		return err != nil
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestHTTPClientTLSVerifiesIPHosts(t *testing.T) {
+	files := newTestTLSFiles(t)
+	serverCert, err := tls.LoadX509KeyPair(files.certFile, files.keyFile)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(serverName string) error {
+		values := map[string]interface{}{
+			"clients.foo.tls.enabled": true,
+			"clients.foo.tls.caFile":  files.caFile,
+		}
+		if serverName != "" {
+			values["clients.foo.tls.serverName"] = serverName
+		}
+		opts := DefaultHTTPTransportOptions
+		opts.TLSConfig = NewHTTPClientTLSConfig(NewStaticConfigOrDie(nil, values), "foo", zap.NewNop(), tally.NoopScope)
+		transport := opts.NewTransport()
+		defer transport.CloseIdleConnections()
+		// server.URL has an IP host, which is not sent in SNI
+		res, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err == nil {
+			_ = res.Body.Close()
+		}
+		return err
+	}
+
+	// the certificate is issued for localhost
+	assert.NoError(t, get("localhost"))
+	assert.Error(t, get("other.example.com"))
+	err = get("")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "server name unknown")
+	}
+}
+
+func TestNewHTTPClientTLSConfig(t *testing.T) {
+	files := newTestTLSFiles(t)
+	newConfig := func(values map[string]interface{}) *tls.Config {
+		return NewHTTPClientTLSConfig(NewStaticConfigOrDie(nil, values), "foo", zap.NewNop(), tally.NoopScope)
+	}
+
+	assert.Nil(t, newConfig(map[string]interface{}{}))
+	assert.Nil(t, newConfig(map[string]interface{}{"clients.foo.tls.enabled": false}))
+
+	config := newConfig(map[string]interface{}{
+		"clients.foo.tls.enabled":            true,
+		"clients.foo.tls.insecureSkipVerify": true,
+		"clients.foo.tls.minVersion":         "1.3",
+	})
+	assert.True(t, config.InsecureSkipVerify)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	assert.Nil(t, config.VerifyConnection)
+
+	config = newConfig(map[string]interface{}{
+		"clients.foo.tls.enabled": true,
+		"clients.foo.tls.caFile":  files.caFile,
+	})
+	assert.NotNil(t, config.VerifyConnection)
+	assert.Nil(t, config.GetClientCertificate)
+
+	for msg, values := range map[string]map[string]interface{}{
+		"clients.foo.tls.certFile and clients.foo.tls.keyFile must be set together": {
+			"clients.foo.tls.enabled":  true,
+			"clients.foo.tls.certFile": files.certFile,
+		},
+		"unknown clients.foo.tls.minVersion: 0.9": {
+			"clients.foo.tls.enabled":    true,
+			"clients.foo.tls.minVersion": "0.9",
+		},
+	} {
+		assert.PanicsWithError(t, msg, func() { newConfig(values) })
+	}
+	assert.Panics(t, func() {
+		newConfig(map[string]interface{}{
+			"clients.foo.tls.enabled": true,
+			"clients.foo.tls.caFile":  "/does/not/exist",
+		})
+	})
+}
@@ -35,6 +35,7 @@ const (
 	endpointOverheadLatency     = "endpoint.overhead.latency"
 	endpointOverheadLatencyHist = "endpoint.overhead.latency-hist"
 	endpointOverheadRatio       = "endpoint.overhead.latency.ratio"
+	endpointDeadlineExceeded    = "endpoint.deadline-exceeded"
 
 	// MetricEndpointPanics is endpoint level panic counter
 	MetricEndpointPanics = "endpoint.panic"
@@ -45,6 +46,7 @@ const (
 	MetricEndpointAppErrors = "endpoint.app-errors"
 
 	clientRequest      = "client.request"
+	clientAttempt      = "client.attempt"
 	clientSuccess      = "client.success"
 	clientStatus       = "client.status"
 	clientErrors       = "client.errors"
@@ -84,6 +84,7 @@ const (
 	scopeTagMiddleWare      = "middlewarename"
 	scopeTagStatus          = "status"
 	scopeTagProtocol        = "protocol"
+	scopeTagProtocolVersion = "protocolversion"
 	scopeTagHTTP            = "HTTP"
 	scopeTagTChannel        = "TChannel"
 	scopeTagsTargetService  = "targetservice"
@@ -0,0 +1,201 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	corsOrigin           = "Origin"
+	corsRequestMethod    = "Access-Control-Request-Method"
+	corsRequestHeaders   = "Access-Control-Request-Headers"
+	corsAllowOrigin      = "Access-Control-Allow-Origin"
+	corsAllowMethods     = "Access-Control-Allow-Methods"
+	corsAllowHeaders     = "Access-Control-Allow-Headers"
+	corsAllowCredentials = "Access-Control-Allow-Credentials"
+	corsMaxAge           = "Access-Control-Max-Age"
+)
+
+// CORSOptions is the cross-origin resource sharing policy of an endpoint.
+type CORSOptions struct {
+	// AllowedOrigins are the origins allowed to call the endpoint, "*"
+	// allows any origin and "https://*.example.com" allows the subdomains
+	// of example.com.
+	AllowedOrigins []string
+	// AllowedHeaders are the request headers allowed in preflight
+	// requests, "*" allows any header.
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies and authorization headers,
+	// it cannot be combined with the "*" origin.
+	AllowCredentials bool
+	// MaxAge is how long browsers cache a preflight response, zero leaves
+	// it to the browser.
+	MaxAge time.Duration
+}
+
+// NewCORSOptions returns the default policy of endpoints from the
+// "http.cors.*" keys, it returns nil when "http.cors.enabled" is not set.
+// It panics when credentials are allowed for any origin.
+func NewCORSOptions(config *StaticConfig) *CORSOptions {
+	if config == nil || !config.ContainsKey("http.cors.enabled") || !config.MustGetBoolean("http.cors.enabled") {
+		return nil
+	}
+	opts := &CORSOptions{}
+	if config.ContainsKey("http.cors.allowedOrigins") {
+		config.MustGetStruct("http.cors.allowedOrigins", &opts.AllowedOrigins)
+	}
+	if config.ContainsKey("http.cors.allowedHeaders") {
+		config.MustGetStruct("http.cors.allowedHeaders", &opts.AllowedHeaders)
+	}
+	if config.ContainsKey("http.cors.allowCredentials") {
+		opts.AllowCredentials = config.MustGetBoolean("http.cors.allowCredentials")
+	}
+	if config.ContainsKey("http.cors.maxAge") {
+		opts.MaxAge = time.Duration(config.MustGetInt("http.cors.maxAge")) * time.Millisecond
+	}
+	if opts.AllowCredentials && opts.allowsAnyOrigin() {
+		panic(errors.New(
+			`http.cors.allowCredentials cannot be combined with the "*" origin, list the allowed origins instead`,
+		))
+	}
+	return opts
+}
+
+// With returns a copy of the options where the non zero fields of overrides
+// win. Unlike rate limits, an endpoint with overrides has a policy even when
+// the gateway has none.
+func (o *CORSOptions) With(overrides CORSOptions) *CORSOptions {
+	var merged CORSOptions
+	if o != nil {
+		merged = *o
+	}
+	if len(overrides.AllowedOrigins) > 0 {
+		merged.AllowedOrigins = overrides.AllowedOrigins
+	}
+	if len(overrides.AllowedHeaders) > 0 {
+		merged.AllowedHeaders = overrides.AllowedHeaders
+	}
+	if overrides.AllowCredentials {
+		merged.AllowCredentials = true
+	}
+	if overrides.MaxAge != 0 {
+		merged.MaxAge = overrides.MaxAge
+	}
+	return &merged
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for the origin,
+// or "" when the origin is not allowed.
+func (o *CORSOptions) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range o.AllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if matchOrigin(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// matchOrigin reports whether the origin matches the allowed origin, which
+// may hold one "*" wildcard.
+func matchOrigin(allowed, origin string) bool {
+	allowed, origin = strings.ToLower(allowed), strings.ToLower(origin)
+	idx := strings.IndexByte(allowed, '*')
+	if idx < 0 {
+		return allowed == origin
+	}
+	prefix, suffix := allowed[:idx], allowed[idx+1:]
+	return len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
+
+// writeHeaders sets the CORS headers of a request, it reports whether the
+// origin of the request is allowed.
+func (o *CORSOptions) writeHeaders(h http.Header, r *http.Request) bool {
+	h.Add("Vary", corsOrigin)
+	allowOrigin := o.allowOrigin(r.Header.Get(corsOrigin))
+	if allowOrigin == "" {
+		return false
+	}
+	h.Set(corsAllowOrigin, allowOrigin)
+	// an endpoint allowing credentials under a gateway allowing any origin
+	// sends "*" without credentials rather than trusting every origin
+	if o.AllowCredentials && allowOrigin != "*" {
+		h.Set(corsAllowCredentials, "true")
+	}
+	return true
+}
+
+// writePreflightHeaders sets the CORS headers of a preflight request for a
+// route that allows the given methods.
+func (o *CORSOptions) writePreflightHeaders(h http.Header, r *http.Request, allow string) {
+	h.Add("Vary", corsRequestMethod)
+	h.Add("Vary", corsRequestHeaders)
+	if !o.writeHeaders(h, r) {
+		return
+	}
+	h.Set(corsAllowMethods, allow)
+	if requested := r.Header.Get(corsRequestHeaders); requested != "" {
+		if o.allowsAnyHeader() {
+			h.Set(corsAllowHeaders, requested)
+		} else if len(o.AllowedHeaders) > 0 {
+			h.Set(corsAllowHeaders, strings.Join(o.AllowedHeaders, ", "))
+		}
+	}
+	if o.MaxAge > 0 {
+		h.Set(corsMaxAge, strconv.Itoa(int(o.MaxAge/time.Second)))
+	}
+}
+
+func (o *CORSOptions) allowsAnyOrigin() bool {
+	for _, origin := range o.AllowedOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *CORSOptions) allowsAnyHeader() bool {
+	for _, header := range o.AllowedHeaders {
+		if header == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// isPreflight reports whether the OPTIONS request is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get(corsOrigin) != "" && r.Header.Get(corsRequestMethod) != ""
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCORSOptions(t *testing.T) {
+	assert.Nil(t, NewCORSOptions(nil))
+	assert.Nil(t, NewCORSOptions(NewStaticConfigOrDie(nil, map[string]interface{}{})))
+
+	cfg := NewStaticConfigOrDie(nil, map[string]interface{}{
+		"http.cors.enabled":          true,
+		"http.cors.allowedOrigins":   []string{"https://*.example.com"},
+		"http.cors.allowedHeaders":   []string{"X-Token"},
+		"http.cors.allowCredentials": true,
+		"http.cors.maxAge":           60000,
+	})
+	assert.Equal(t, &CORSOptions{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedHeaders:   []string{"X-Token"},
+		AllowCredentials: true,
+		MaxAge:           time.Minute,
+	}, NewCORSOptions(cfg))
+
+	cfg = NewStaticConfigOrDie(nil, map[string]interface{}{
+		"http.cors.enabled":          true,
+		"http.cors.allowedOrigins":   []string{"*"},
+		"http.cors.allowCredentials": true,
+	})
+	assert.Panics(t, func() { NewCORSOptions(cfg) })
+}
+
+func TestCORSOptionsWith(t *testing.T) {
+	var disabled *CORSOptions
+	assert.Equal(t, &CORSOptions{AllowedOrigins: []string{"*"}},
+		disabled.With(CORSOptions{AllowedOrigins: []string{"*"}}))
+
+	defaults := &CORSOptions{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedHeaders: []string{"X-Token"},
+		MaxAge:         time.Minute,
+	}
+	assert.Equal(t, &CORSOptions{
+		AllowedOrigins:   []string{"https://example.com"},
+		AllowedHeaders:   []string{"X-Other"},
+		AllowCredentials: true,
+		MaxAge:           time.Minute,
+	}, defaults.With(CORSOptions{AllowedHeaders: []string{"X-Other"}, AllowCredentials: true}))
+	assert.Equal(t, []string{"X-Token"}, defaults.AllowedHeaders)
+}
+
+func TestCORSAllowOrigin(t *testing.T) {
+	opts := &CORSOptions{AllowedOrigins: []string{"https://example.com", "https://*.example.org"}}
+	cases := map[string]string{
+		"https://example.com":      "https://example.com",
+		"https://EXAMPLE.com":      "https://EXAMPLE.com",
+		"https://a.example.org":    "https://a.example.org",
+		"https://a.b.example.org":  "https://a.b.example.org",
+		"https://example.org":      "",
+		"https://.example.org":     "",
+		"http://a.example.org":     "",
+		"https://example.com.evil": "",
+		"https://evilexample.org":  "",
+		"":                         "",
+	}
+	for origin, expected := range cases {
+		assert.Equal(t, expected, opts.allowOrigin(origin), origin)
+	}
+
+	wildcard := &CORSOptions{AllowedOrigins: []string{"*"}}
+	assert.Equal(t, "*", wildcard.allowOrigin("https://example.com"))
+	wildcard.AllowCredentials = true
+	assert.Equal(t, "*", wildcard.allowOrigin("https://example.com"))
+}
+
+func TestCORSWriteHeaders(t *testing.T) {
+	opts := &CORSOptions{AllowedOrigins: []string{"https://example.com"}, AllowCredentials: true}
+
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.Header.Set("Origin", "https://example.com")
+	h := http.Header{}
+	assert.True(t, opts.writeHeaders(h, r))
+	assert.Equal(t, "https://example.com", h.Get(corsAllowOrigin))
+	assert.Equal(t, "true", h.Get(corsAllowCredentials))
+	assert.Equal(t, []string{"Origin"}, h.Values("Vary"))
+
+	r.Header.Set("Origin", "https://evil.com")
+	h = http.Header{}
+	assert.False(t, opts.writeHeaders(h, r))
+	assert.Empty(t, h.Get(corsAllowOrigin))
+	assert.Empty(t, h.Get(corsAllowCredentials))
+	assert.Equal(t, []string{"Origin"}, h.Values("Vary"))
+
+	// the "*" of the gateway never sends credentials
+	opts = (&CORSOptions{AllowedOrigins: []string{"*"}}).With(CORSOptions{AllowCredentials: true})
+	r.Header.Set("Origin", "https://evil.com")
+	h = http.Header{}
+	assert.True(t, opts.writeHeaders(h, r))
+	assert.Equal(t, "*", h.Get(corsAllowOrigin))
+	assert.Empty(t, h.Get(corsAllowCredentials))
+}
+
+func TestCORSWritePreflightHeaders(t *testing.T) {
+	r := httptest.NewRequest("OPTIONS", "/foo", nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set(corsRequestMethod, "POST")
+	r.Header.Set(corsRequestHeaders, "X-Token, X-Other")
+	assert.True(t, isPreflight(r))
+
+	opts := &CORSOptions{
+		AllowedOrigins: []string{"*"},
+		AllowedHeaders: []string{"X-Token"},
+		MaxAge:         90 * time.Second,
+	}
+	h := http.Header{}
+	opts.writePreflightHeaders(h, r, "GET, OPTIONS, POST")
+	assert.Equal(t, "*", h.Get(corsAllowOrigin))
+	assert.Equal(t, "GET, OPTIONS, POST", h.Get(corsAllowMethods))
+	assert.Equal(t, "X-Token", h.Get(corsAllowHeaders))
+	assert.Equal(t, "90", h.Get(corsMaxAge))
+
+	opts.AllowedHeaders = []string{"*"}
+	h = http.Header{}
+	opts.writePreflightHeaders(h, r, "POST")
+	assert.Equal(t, "X-Token, X-Other", h.Get(corsAllowHeaders))
+
+	opts.AllowedOrigins = []string{"https://other.com"}
+	h = http.Header{}
+	opts.writePreflightHeaders(h, r, "POST")
+	assert.Empty(t, h.Get(corsAllowOrigin))
+	assert.Empty(t, h.Get(corsAllowMethods))
+
+	r.Header.Del(corsRequestMethod)
+	assert.False(t, isPreflight(r))
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ContextTTLHeader carries the remaining budget of a request in milliseconds.
+// Inbound HTTP requests are cut short when it is smaller than the endpoint
+// timeout, and outbound HTTP requests carry what is left of the deadline.
+const ContextTTLHeader = "Context-TTL-MS"
+
+// requestTimeout returns how long the endpoint may spend on r, the smaller
+// of the endpoint timeout and the budget the caller sent. Zero means the
+// request has no deadline.
+func (endpoint *RouterEndpoint) requestTimeout(r *http.Request) time.Duration {
+	timeout := endpoint.Timeout
+	if ttl, ok := inboundTTL(r.Header); ok && (timeout <= 0 || ttl < timeout) {
+		timeout = ttl
+	}
+	return timeout
+}
+
+// inboundTTL parses the Context-TTL-MS header, values that are not positive
+// integers are ignored.
+func inboundTTL(h http.Header) (time.Duration, bool) {
+	value := h.Get(ContextTTLHeader)
+	if value == "" {
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// remainingTimeout caps timeout by the time left before the deadline of ctx.
+func remainingTimeout(ctx context.Context, timeout time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout
+	}
+	if remaining := time.Until(deadline); remaining < timeout {
+		return remaining
+	}
+	return timeout
+}
+
+// setContextTTLHeader tells the downstream service how long the caller is
+// still waiting for, the header is left alone when ctx has no deadline.
+func setContextTTLHeader(ctx context.Context, h http.Header) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	ms := int64(time.Until(deadline) / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	h.Set(ContextTTLHeader, strconv.FormatInt(ms, 10))
+}
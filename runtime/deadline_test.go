@@ -0,0 +1,73 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInboundTTL(t *testing.T) {
+	cases := []struct {
+		value string
+		ttl   time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"250", 250 * time.Millisecond, true},
+		{"0", 0, false},
+		{"-5", 0, false},
+		{"soon", 0, false},
+	}
+	for _, c := range cases {
+		h := http.Header{}
+		if c.value != "" {
+			h.Set(ContextTTLHeader, c.value)
+		}
+		ttl, ok := inboundTTL(h)
+		assert.Equal(t, c.ok, ok, c.value)
+		assert.Equal(t, c.ttl, ttl, c.value)
+	}
+}
+
+func TestRemainingTimeout(t *testing.T) {
+	assert.Equal(t, time.Second, remainingTimeout(context.Background(), time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.True(t, remainingTimeout(ctx, time.Second) <= 100*time.Millisecond)
+	assert.Equal(t, 10*time.Millisecond, remainingTimeout(ctx, 10*time.Millisecond))
+}
+
+func TestSetContextTTLHeader(t *testing.T) {
+	h := http.Header{}
+	setContextTTLHeader(context.Background(), h)
+	assert.Empty(t, h.Get(ContextTTLHeader))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	setContextTTLHeader(ctx, h)
+	assert.Regexp(t, `^(59\d{3}|60000)$`, h.Get(ContextTTLHeader))
+}
@@ -0,0 +1,628 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+const (
+	dynamicConfigReloadSuccess = "dynamic-config.reload.success"
+	dynamicConfigReloadFailure = "dynamic-config.reload.failure"
+	dynamicConfigRejected      = "dynamic-config.rejected"
+	dynamicConfigChanged       = "dynamic-config.changed"
+)
+
+var defaultDynamicConfigPollInterval = 10 * time.Second
+
+// defaultImmutableConfigKeys are keys that can not be changed without a
+// restart. A trailing ".*" marks every key with the given prefix as immutable.
+var defaultImmutableConfigKeys = []string{
+	"serviceName",
+	"env",
+	"datacenter",
+	"useDatacenter",
+	"http.port",
+	"tchannel.port",
+	"tchannel.serviceName",
+	"tchannel.processName",
+	"metrics.serviceName",
+	"metrics.type",
+	"dynamicConfig.*",
+}
+
+// ConfigReader is the read-only config interface shared by StaticConfig
+// and DynamicConfig.
+type ConfigReader interface {
+	ContainsKey(key string) bool
+	MustGetBoolean(key string) bool
+	MustGetFloat(key string) float64
+	MustGetInt(key string) int64
+	MustGetString(key string) string
+	MustGetStruct(key string, ptr interface{})
+}
+
+var (
+	_ ConfigReader = (*StaticConfig)(nil)
+	_ ConfigReader = (*DynamicConfig)(nil)
+)
+
+// ConfigChange describes the change of a single config key.
+// Old is nil if the key did not exist before, New is nil if the key was removed.
+type ConfigChange struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// Int returns the new value as an int64, false if it is not an integer.
+func (c ConfigChange) Int() (int64, bool) {
+	switch v := c.New.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// Float returns the new value as a float64, false if it is not a number.
+func (c ConfigChange) Float() (float64, bool) {
+	switch v := c.New.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Bool returns the new value as a bool, false if it is not a boolean.
+func (c ConfigChange) Bool() (bool, bool) {
+	v, ok := c.New.(bool)
+	return v, ok
+}
+
+// String returns the new value as a string, false if it is not a string.
+func (c ConfigChange) String() (string, bool) {
+	v, ok := c.New.(string)
+	return v, ok
+}
+
+// ConfigChangeHandler is called with the changes of a successful reload.
+type ConfigChangeHandler func(change ConfigChange)
+
+// ImmutableConfigKeyError is returned when a reload tries to change keys
+// that can not be changed at runtime.
+type ImmutableConfigKeyError struct {
+	Keys []string
+}
+
+// Error returns the error string
+func (e *ImmutableConfigKeyError) Error() string {
+	return fmt.Sprintf(
+		"dynamic config can not change keys %s at runtime, a restart is required",
+		strings.Join(e.Keys, ", "),
+	)
+}
+
+// DynamicConfigOptions configures a DynamicConfig.
+type DynamicConfigOptions struct {
+	// Paths are YAML(JSON) files or directories of overlay files. The later
+	// files overwrite keys from earlier files, files in a directory are
+	// applied in lexical order.
+	Paths []string
+	// PollInterval is how often the paths are checked for changes.
+	PollInterval time.Duration
+	// ImmutableKeys are added to the default set of keys that can not be
+	// changed at runtime.
+	ImmutableKeys []string
+	Logger        *zap.Logger
+	Scope         tally.Scope
+}
+
+type configSubscription struct {
+	prefix  string
+	handler ConfigChangeHandler
+}
+
+// DynamicConfig is a hot-reloadable config layer on top of a StaticConfig.
+// Values from the overlay files take precedence over the static config,
+// keys absent from the overlay fall back to the static config.
+type DynamicConfig struct {
+	static        *StaticConfig
+	paths         []string
+	pollInterval  time.Duration
+	immutableKeys []string
+	logger        *zap.Logger
+	scope         tally.Scope
+
+	mu          sync.RWMutex
+	values      map[string]interface{} // protected by mu
+	fingerprint string                 // protected by mu
+
+	// reloadMu serializes reloads so that handlers see changes in order
+	reloadMu      sync.Mutex
+	subMu         sync.RWMutex
+	subscriptions []*configSubscription // protected by subMu
+
+	runningMu sync.Mutex
+	running   bool // protected by runningMu
+	stopped   bool // protected by runningMu
+	stop      chan struct{}
+}
+
+// NewDynamicConfig creates a DynamicConfig on top of the given static config.
+// It does not read the overlay files until Reload or Start is called.
+func NewDynamicConfig(static *StaticConfig, opts DynamicConfigOptions) *DynamicConfig {
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultDynamicConfigPollInterval
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	scope := opts.Scope
+	if scope == nil {
+		scope = tally.NoopScope
+	}
+
+	immutableKeys := make([]string, 0, len(defaultImmutableConfigKeys)+len(opts.ImmutableKeys))
+	immutableKeys = append(immutableKeys, defaultImmutableConfigKeys...)
+	immutableKeys = append(immutableKeys, opts.ImmutableKeys...)
+
+	return &DynamicConfig{
+		static:        static,
+		paths:         opts.Paths,
+		pollInterval:  pollInterval,
+		immutableKeys: immutableKeys,
+		logger:        logger,
+		scope:         scope,
+		values:        map[string]interface{}{},
+		stop:          make(chan struct{}),
+	}
+}
+
+// Subscribe registers a handler for changes of keys starting with prefix,
+// an empty prefix subscribes to all changes. Handlers are called
+// synchronously after a reload has been applied, in key order.
+// It is safe to call Subscribe on a nil DynamicConfig, which is a no-op.
+func (d *DynamicConfig) Subscribe(prefix string, handler ConfigChangeHandler) {
+	if d == nil {
+		return
+	}
+	d.subMu.Lock()
+	d.subscriptions = append(d.subscriptions, &configSubscription{
+		prefix:  prefix,
+		handler: handler,
+	})
+	d.subMu.Unlock()
+}
+
+// Start loads the overlay files and polls them for changes periodically.
+// It returns an error once the DynamicConfig has been stopped.
+func (d *DynamicConfig) Start() error {
+	d.runningMu.Lock()
+	defer d.runningMu.Unlock()
+	if d.stopped {
+		return errors.New("dynamic config can not be restarted once stopped")
+	}
+	if d.running {
+		return nil
+	}
+	if err := d.Reload(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(d.pollInterval)
+		for {
+			select {
+			case <-ticker.C:
+				if d.changedOnDisk() {
+					// errors are logged and counted by Reload
+					_ = d.Reload()
+				}
+			case <-d.stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	d.running = true
+	return nil
+}
+
+// Stop stops polling the overlay files. It cannot be restarted once stopped.
+func (d *DynamicConfig) Stop() {
+	d.runningMu.Lock()
+	defer d.runningMu.Unlock()
+	if !d.running {
+		return
+	}
+	close(d.stop)
+	d.running = false
+	d.stopped = true
+}
+
+// Reload reads the overlay files, validates the difference with the current
+// values and publishes the changes to subscribers. Nothing is applied if the
+// files can not be parsed or a change is rejected, and the previous values
+// are restored and published again if a handler panics on the new ones.
+func (d *DynamicConfig) Reload() error {
+	d.reloadMu.Lock()
+	defer d.reloadMu.Unlock()
+
+	fingerprint, err := d.fingerprintFiles()
+	if err != nil {
+		return d.reloadFailed(err)
+	}
+	values, err := d.readOverlay()
+	if err != nil {
+		return d.reloadFailed(err)
+	}
+
+	d.mu.RLock()
+	changes := d.diff(d.values, values)
+	d.mu.RUnlock()
+
+	if err := d.validate(changes); err != nil {
+		return d.reloadFailed(err)
+	}
+
+	d.mu.Lock()
+	previousValues, previousFingerprint := d.values, d.fingerprint
+	d.values = values
+	d.fingerprint = fingerprint
+	d.mu.Unlock()
+
+	if err := d.publish(changes); err != nil {
+		d.mu.Lock()
+		d.values = previousValues
+		d.fingerprint = previousFingerprint
+		d.mu.Unlock()
+
+		// handlers called before the panic have applied the new values
+		reverted := make([]ConfigChange, len(changes))
+		for i, change := range changes {
+			reverted[i] = ConfigChange{Key: change.Key, Old: change.New, New: change.Old}
+		}
+		if revertErr := d.publish(reverted); revertErr != nil {
+			d.logger.Error("Failed to restore dynamic config", zap.Error(revertErr))
+		}
+		return d.reloadFailed(err)
+	}
+
+	d.scope.Counter(dynamicConfigReloadSuccess).Inc(1)
+	if len(changes) == 0 {
+		return nil
+	}
+	d.scope.Counter(dynamicConfigChanged).Inc(int64(len(changes)))
+
+	keys := make([]string, 0, len(changes))
+	for _, change := range changes {
+		keys = append(keys, change.Key)
+	}
+	d.logger.Info("Dynamic config reloaded", zap.Strings("changedKeys", keys))
+	return nil
+}
+
+func (d *DynamicConfig) reloadFailed(err error) error {
+	d.scope.Counter(dynamicConfigReloadFailure).Inc(1)
+	d.logger.Error("Failed to reload dynamic config", zap.Error(err))
+	return err
+}
+
+// publish calls the handlers subscribed to the changes, it stops at the
+// first handler that panics, e.g. on a value it can not read, and returns
+// the panic as an error.
+func (d *DynamicConfig) publish(changes []ConfigChange) (err error) {
+	d.subMu.RLock()
+	subscriptions := make([]*configSubscription, len(d.subscriptions))
+	copy(subscriptions, d.subscriptions)
+	d.subMu.RUnlock()
+
+	var key string
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			d.scope.Tagged(map[string]string{
+				"key": key,
+			}).Counter(dynamicConfigRejected).Inc(1)
+			err = errors.Errorf("dynamic config handler failed on key (%s): %v", key, recovered)
+		}
+	}()
+	for _, change := range changes {
+		key = change.Key
+		for _, sub := range subscriptions {
+			if strings.HasPrefix(change.Key, sub.prefix) {
+				sub.handler(change)
+			}
+		}
+	}
+	return nil
+}
+
+// diff returns the changes of effective values between two overlays, sorted by key.
+func (d *DynamicConfig) diff(oldValues, newValues map[string]interface{}) []ConfigChange {
+	keys := make(map[string]struct{}, len(oldValues)+len(newValues))
+	for key := range oldValues {
+		keys[key] = struct{}{}
+	}
+	for key := range newValues {
+		keys[key] = struct{}{}
+	}
+
+	var changes []ConfigChange
+	for key := range keys {
+		oldValue, oldOK := oldValues[key]
+		if !oldOK {
+			oldValue, _ = d.static.lookup(key)
+		}
+		newValue, newOK := newValues[key]
+		if !newOK {
+			newValue, _ = d.static.lookup(key)
+		}
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, ConfigChange{Key: key, Old: oldValue, New: newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+func (d *DynamicConfig) validate(changes []ConfigChange) error {
+	var immutable []string
+	for _, change := range changes {
+		if d.isImmutable(change.Key) {
+			immutable = append(immutable, change.Key)
+			d.scope.Tagged(map[string]string{
+				"key": change.Key,
+			}).Counter(dynamicConfigRejected).Inc(1)
+			continue
+		}
+		if change.Old != nil && change.New != nil && !sameConfigType(change.Old, change.New) {
+			d.scope.Tagged(map[string]string{
+				"key": change.Key,
+			}).Counter(dynamicConfigRejected).Inc(1)
+			return errors.Errorf(
+				"dynamic config can not change the type of key (%s) from %T to %T",
+				change.Key, change.Old, change.New,
+			)
+		}
+	}
+	if len(immutable) > 0 {
+		return &ImmutableConfigKeyError{Keys: immutable}
+	}
+	return nil
+}
+
+func (d *DynamicConfig) isImmutable(key string) bool {
+	for _, immutableKey := range d.immutableKeys {
+		if strings.HasSuffix(immutableKey, ".*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(immutableKey, "*")) {
+				return true
+			}
+		} else if key == immutableKey {
+			return true
+		}
+	}
+	return false
+}
+
+func sameConfigType(a, b interface{}) bool {
+	if isConfigNumber(a) && isConfigNumber(b) {
+		return true
+	}
+	return reflect.TypeOf(a) == reflect.TypeOf(b)
+}
+
+func isConfigNumber(v interface{}) bool {
+	switch v.(type) {
+	case int, int64, float64:
+		return true
+	}
+	return false
+}
+
+// files returns the overlay files in the order they are applied.
+func (d *DynamicConfig) files() ([]string, error) {
+	var files []string
+	for _, path := range d.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// a missing overlay is the same as an empty one
+				continue
+			}
+			return nil, errors.Wrapf(err, "error reading dynamic config path %s", path)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading dynamic config directory %s", path)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+func (d *DynamicConfig) fingerprintFiles() (string, error) {
+	files, err := d.files()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", errors.Wrapf(err, "error reading dynamic config file %s", file)
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+func (d *DynamicConfig) changedOnDisk() bool {
+	fingerprint, err := d.fingerprintFiles()
+	if err != nil {
+		return true
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return fingerprint != d.fingerprint
+}
+
+func (d *DynamicConfig) readOverlay() (map[string]interface{}, error) {
+	files, err := d.files()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	for _, file := range files {
+		bytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading dynamic config file %s", file)
+		}
+		var object map[string]interface{}
+		if err := yaml.Unmarshal(bytes, &object); err != nil {
+			return nil, errors.Wrapf(err, "error parsing dynamic config file %s", file)
+		}
+		for key, value := range object {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+func (d *DynamicConfig) lookup(key string) (interface{}, bool) {
+	d.mu.RLock()
+	value, ok := d.values[key]
+	d.mu.RUnlock()
+	if ok {
+		return value, true
+	}
+	return d.static.lookup(key)
+}
+
+// ContainsKey returns true if key is found otherwise false.
+func (d *DynamicConfig) ContainsKey(key string) bool {
+	_, ok := d.lookup(key)
+	return ok
+}
+
+// MustGetBoolean returns the value as a boolean or panics.
+func (d *DynamicConfig) MustGetBoolean(key string) bool {
+	if value, ok := d.lookup(key); ok {
+		return value.(bool)
+	}
+	panic(errors.Errorf("Key (%s) not available", key))
+}
+
+// MustGetFloat returns the value as a float or panics.
+func (d *DynamicConfig) MustGetFloat(key string) float64 {
+	if value, ok := d.lookup(key); ok {
+		return mustConvertableToFloat(value, key)
+	}
+	panic(errors.Errorf("Key (%s) not available", key))
+}
+
+// MustGetInt returns the value as a int or panics.
+func (d *DynamicConfig) MustGetInt(key string) int64 {
+	if value, ok := d.lookup(key); ok {
+		return mustConvertableToInt(value, key)
+	}
+	panic(errors.Errorf("Key (%s) not available", key))
+}
+
+// MustGetString returns the value as a string or panics.
+func (d *DynamicConfig) MustGetString(key string) string {
+	if value, ok := d.lookup(key); ok {
+		return value.(string)
+	}
+	panic(errors.Errorf("Key (%s) not available", key))
+}
+
+// MustGetStruct reads the value into an interface{} or panics.
+// Recommended that this is used with pointers to structs
+func (d *DynamicConfig) MustGetStruct(key string, ptr interface{}) {
+	d.mu.RLock()
+	value, ok := d.values[key]
+	d.mu.RUnlock()
+	if !ok {
+		d.static.MustGetStruct(key, ptr)
+		return
+	}
+
+	rptr := reflect.ValueOf(ptr)
+	if rptr.Kind() != reflect.Ptr || rptr.IsNil() {
+		panic(errors.Errorf("Cannot GetStruct (%s) into nil ptr", key))
+	}
+	if err := d.static.mustGetStructHelper(key, value, ptr); err != nil {
+		panic(err)
+	}
+}
+
+// InspectOrDie returns the effective config, static values overwritten by
+// the dynamic overlay. This should only be used for inspection or debugging
+func (d *DynamicConfig) InspectOrDie() map[string]interface{} {
+	result := d.static.InspectOrDie()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for k, v := range d.values {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func newTestDynamicConfig(t *testing.T, overlay string) (*DynamicConfig, string, tally.TestScope) {
+	dir, err := ioutil.TempDir("", "dynamic-config")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "overlay.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(overlay), 0644))
+
+	static := NewStaticConfigOrDie([]*ConfigOption{
+		ConfigFileContents([]byte(`
+serviceName: test-gateway
+http.port: 8080
+clients.foo.timeout: 100
+clients.foo.circuitBreakerDisabled: false
+`)),
+	}, nil)
+	static.Freeze()
+
+	scope := tally.NewTestScope("", nil)
+	dc := NewDynamicConfig(static, DynamicConfigOptions{
+		Paths: []string{path},
+		Scope: scope,
+	})
+	return dc, path, scope
+}
+
+func TestDynamicConfigOverlay(t *testing.T) {
+	dc, _, _ := newTestDynamicConfig(t, "clients.foo.timeout: 250\nclients.foo.retryCount: 2\n")
+	require.NoError(t, dc.Reload())
+
+	assert.Equal(t, int64(250), dc.MustGetInt("clients.foo.timeout"))
+	assert.Equal(t, int64(2), dc.MustGetInt("clients.foo.retryCount"))
+	assert.Equal(t, "test-gateway", dc.MustGetString("serviceName"))
+	assert.False(t, dc.MustGetBoolean("clients.foo.circuitBreakerDisabled"))
+	assert.False(t, dc.ContainsKey("clients.bar.timeout"))
+	assert.Panics(t, func() { dc.MustGetInt("clients.bar.timeout") })
+
+	inspected := dc.InspectOrDie()
+	assert.Equal(t, float64(250), inspected["clients.foo.timeout"])
+}
+
+func TestDynamicConfigPublishesChanges(t *testing.T) {
+	dc, path, scope := newTestDynamicConfig(t, "")
+	require.NoError(t, dc.Reload())
+
+	var changes []ConfigChange
+	dc.Subscribe("clients.foo.", func(change ConfigChange) {
+		changes = append(changes, change)
+	})
+	var all int
+	dc.Subscribe("", func(change ConfigChange) {
+		all++
+	})
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("clients.foo.timeout: 300\nother.key: true\n"), 0644))
+	require.NoError(t, dc.Reload())
+
+	require.Len(t, changes, 1)
+	assert.Equal(t, "clients.foo.timeout", changes[0].Key)
+	timeout, ok := changes[0].Int()
+	assert.True(t, ok)
+	assert.Equal(t, int64(300), timeout)
+	assert.Equal(t, 2, all)
+
+	// removing the overlay falls back to the static value
+	require.NoError(t, ioutil.WriteFile(path, []byte(""), 0644))
+	require.NoError(t, dc.Reload())
+	require.Len(t, changes, 2)
+	timeout, ok = changes[1].Int()
+	assert.True(t, ok)
+	assert.Equal(t, int64(100), timeout)
+
+	assert.Equal(t, int64(3), scope.Snapshot().Counters()[dynamicConfigReloadSuccess+"+"].Value())
+}
+
+func TestDynamicConfigRejectsImmutableKeys(t *testing.T) {
+	dc, path, scope := newTestDynamicConfig(t, "clients.foo.timeout: 200\n")
+	require.NoError(t, dc.Reload())
+
+	var called bool
+	dc.Subscribe("", func(change ConfigChange) {
+		called = true
+	})
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("clients.foo.timeout: 300\nhttp.port: 9090\n"), 0644))
+	err := dc.Reload()
+	require.Error(t, err)
+	immutableErr, ok := err.(*ImmutableConfigKeyError)
+	require.True(t, ok)
+	assert.Equal(t, []string{"http.port"}, immutableErr.Keys)
+	assert.Contains(t, err.Error(), "http.port")
+
+	// nothing is applied when a change is rejected
+	assert.False(t, called)
+	assert.Equal(t, int64(200), dc.MustGetInt("clients.foo.timeout"))
+	assert.Equal(t, int64(8080), dc.MustGetInt("http.port"))
+
+	counters := scope.Snapshot().Counters()
+	assert.Equal(t, int64(1), counters[dynamicConfigRejected+"+key=http.port"].Value())
+	assert.Equal(t, int64(1), counters[dynamicConfigReloadFailure+"+"].Value())
+}
+
+func TestDynamicConfigRejectsTypeChange(t *testing.T) {
+	dc, _, _ := newTestDynamicConfig(t, "clients.foo.timeout: fast\n")
+	err := dc.Reload()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can not change the type of key (clients.foo.timeout)")
+}
+
+func TestDynamicConfigDirectoryOverlays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dynamic-config-dir")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "01-base.yaml"), []byte("a: 1\nb: 1\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "02-override.yaml"), []byte("b: 2\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("not config"), 0644))
+
+	static := NewStaticConfigOrDie(nil, nil)
+	dc := NewDynamicConfig(static, DynamicConfigOptions{
+		Paths: []string{dir, filepath.Join(dir, "missing.yaml")},
+	})
+	require.NoError(t, dc.Reload())
+	assert.Equal(t, int64(1), dc.MustGetInt("a"))
+	assert.Equal(t, int64(2), dc.MustGetInt("b"))
+}
+
+func TestDynamicConfigNilSubscribe(t *testing.T) {
+	var dc *DynamicConfig
+	assert.NotPanics(t, func() {
+		dc.Subscribe("", func(change ConfigChange) {})
+	})
+}
+
+func TestDynamicConfigRollsBackOnHandlerPanic(t *testing.T) {
+	dc, path, scope := newTestDynamicConfig(t, "clients.foo.timeout: 200\n")
+	require.NoError(t, dc.Reload())
+
+	var timeouts []int64
+	dc.Subscribe("clients.foo.", func(change ConfigChange) {
+		timeouts = append(timeouts, dc.MustGetInt("clients.foo.timeout"))
+		var mapping map[string]int64
+		if dc.ContainsKey("clients.foo.methodTimeoutMapping") {
+			dc.MustGetStruct("clients.foo.methodTimeoutMapping", &mapping)
+		}
+	})
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(
+		"clients.foo.methodTimeoutMapping: {Call: fast}\nclients.foo.timeout: 300\n",
+	), 0644))
+	err := dc.Reload()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "clients.foo.methodTimeoutMapping")
+
+	// the previous values are restored and published again
+	assert.Equal(t, int64(200), dc.MustGetInt("clients.foo.timeout"))
+	assert.False(t, dc.ContainsKey("clients.foo.methodTimeoutMapping"))
+	assert.Equal(t, []int64{300, 200, 200}, timeouts)
+
+	counters := scope.Snapshot().Counters()
+	assert.Equal(t, int64(1), counters[dynamicConfigRejected+"+key=clients.foo.methodTimeoutMapping"].Value())
+	assert.Equal(t, int64(1), counters[dynamicConfigReloadFailure+"+"].Value())
+}
+
+func TestDynamicConfigStartAfterStop(t *testing.T) {
+	dc, _, _ := newTestDynamicConfig(t, "")
+	require.NoError(t, dc.Start())
+	require.NoError(t, dc.Start())
+	dc.Stop()
+	assert.Error(t, dc.Start())
+}
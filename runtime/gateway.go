@@ -22,6 +22,7 @@ package zanzibar
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -32,6 +33,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	metricCollector "github.com/afex/hystrix-go/hystrix/metric_collector"
@@ -64,6 +66,10 @@ var levelMap = map[string]zapcore.Level{
 var defaultShutdownPollInterval = 500 * time.Millisecond
 var defaultCloseTimeout = 10000 * time.Millisecond
 
+// defaultSocketMode lets the owner and group of the gateway process, such as
+// a local proxy, connect to the Unix domain socket
+const defaultSocketMode os.FileMode = 0660
+
 const (
 	localhost                = "127.0.0.1"
 	testenv                  = "test"
@@ -108,6 +114,10 @@ type Gateway struct {
 	Logger                 *zap.Logger
 	ServiceName            string
 	Config                 *StaticConfig
+	DynamicConfig          *DynamicConfig
+	HealthChecker          *HealthChecker
+	RateLimiter            *RateLimiter
+	LoadShedder            *LoadShedder
 	HTTPRouter             HTTPRouter
 	ServerTChannelRouter   *TChannelRouter
 	TChannelSubLoggerLevel zapcore.Level
@@ -118,6 +128,7 @@ type Gateway struct {
 	GRPCClientDispatcher *yarpc.Dispatcher
 
 	atomLevel             *zap.AtomicLevel
+	logLevels             *logLevelController
 	loggerFile            *os.File
 	scopeCloser           io.Closer
 	metricsBackend        tally.CachedStatsReporter
@@ -131,9 +142,17 @@ type Gateway struct {
 	tchannelServer        *tchannel.Channel
 	tracerCloser          io.Closer
 	notFoundHandler       http.HandlerFunc
+	httpInflight          *inflightTracker
+	tlsReloader           *certReloader
+	tchannelInflight      *inflightTracker
+	circuitBreakersMu     sync.Mutex
+	circuitBreakers       map[string]CircuitBreaker // protected by circuitBreakersMu
+	peerProvidersMu       sync.Mutex
+	peerProviders         map[string]PeerProvider // protected by peerProvidersMu
 
 	requestUUIDHeaderKey string
-	isUnhealthy          bool
+	isUnhealthy          int32 // set with atomic operations
+	admin                *adminHandler
 }
 
 // DefaultDependencies are the common dependencies for all modules
@@ -250,6 +269,12 @@ func CreateGateway(
 		return nil, err
 	}
 
+	if err := gateway.setupDynamicConfig(config); err != nil {
+		return nil, err
+	}
+
+	gateway.setupHealthChecker(config)
+
 	if opts.Tracer != nil &&
 		opts.TracerCloser != nil &&
 		config.ContainsKey("jaeger.tracer.custom") &&
@@ -268,7 +293,16 @@ func CreateGateway(
 		gateway.notFoundHandler = opts.NotFoundHandler(gateway)
 	}
 
-	// setup router after metrics and logs
+	// count in flight requests to report them when shutting down
+	gateway.httpInflight = newInflightTracker(gateway.RootScope, scopeTagHTTP)
+	gateway.tchannelInflight = newInflightTracker(gateway.RootScope, scopeTagTChannel)
+
+	// setup router after metrics and logs, the routers share the rate limiter
+	// and the load shedder
+	gateway.RateLimiter = NewRateLimiter(gateway.Config)
+	gateway.LoadShedder = NewLoadShedder(
+		gateway.Config, gateway.runtimeMetrics, gateway.inflightTotal, gateway.RootScope,
+	)
 	gateway.HTTPRouter = NewHTTPRouter(gateway)
 
 	if err := gateway.setupHTTPServer(); err != nil {
@@ -284,7 +318,13 @@ func CreateGateway(
 		return nil, err
 	}
 
-	gateway.registerPredefined()
+	if err := gateway.setupAdmin(config); err != nil {
+		return nil, err
+	}
+
+	if err := gateway.registerPredefined(); err != nil {
+		return nil, err
+	}
 
 	return gateway, nil
 }
@@ -293,6 +333,12 @@ func CreateGateway(
 func (gateway *Gateway) Bootstrap() error {
 	env := gateway.Config.MustGetString("env")
 
+	// discover the peers of the clients before serving requests
+	if err := gateway.startPeerProviders(); err != nil {
+		gateway.Logger.Error("Error starting peer providers", zap.Error(err))
+		return err
+	}
+
 	// start HTTP server
 	gateway.RootScope.Counter("server.bootstrap").Inc(1)
 	_, err := gateway.localHTTPServer.JustListen()
@@ -300,7 +346,10 @@ func (gateway *Gateway) Bootstrap() error {
 		gateway.Logger.Error("Error listening on port", zap.Error(err))
 		return errors.Wrap(err, "error listening on port")
 	}
-	if gateway.localHTTPServer.RealIP != gateway.httpServer.RealIP && env != testenv {
+	// a Unix domain socket is never the same address as the local server
+	separate := env != testenv &&
+		(gateway.localHTTPServer.SocketPath != "" || gateway.localHTTPServer.RealIP != gateway.httpServer.RealIP)
+	if gateway.httpServer.SocketPath != "" || separate {
 		_, err := gateway.httpServer.JustListen()
 		if err != nil {
 			gateway.Logger.Error("Error listening on port", zap.Error(err))
@@ -316,6 +365,11 @@ func (gateway *Gateway) Bootstrap() error {
 	gateway.WaitGroup.Add(1)
 	go gateway.httpServer.JustServe(gateway.WaitGroup)
 
+	// pick up renewed certificates without a restart
+	if gateway.tlsReloader != nil {
+		gateway.tlsReloader.startPolling()
+	}
+
 	if gateway.httpServer != gateway.localHTTPServer {
 		gateway.WaitGroup.Add(1)
 		go gateway.localHTTPServer.JustServe(gateway.WaitGroup)
@@ -348,6 +402,22 @@ func (gateway *Gateway) Bootstrap() error {
 		return err
 	}
 
+	if gateway.DynamicConfig != nil {
+		if err := gateway.DynamicConfig.Start(); err != nil {
+			gateway.Logger.Error("Error starting dynamic config", zap.Error(err))
+			return errors.Wrap(err, "error starting dynamic config")
+		}
+	}
+
+	if gateway.Config.ContainsKey("logger.signals.enabled") &&
+		gateway.Config.MustGetBoolean("logger.signals.enabled") {
+		var ttl time.Duration
+		if gateway.Config.ContainsKey("logger.signals.ttl") {
+			ttl = time.Duration(gateway.Config.MustGetInt("logger.signals.ttl")) * time.Millisecond
+		}
+		gateway.logLevels.listenOnSignals(ttl)
+	}
+
 	gateway.RootScope.Counter("startup.success").Inc(1)
 
 	if gateway.GRPCClientDispatcher != nil {
@@ -358,10 +428,13 @@ func (gateway *Gateway) Bootstrap() error {
 		}
 	}
 
+	// probe dependencies once the clients can make requests
+	gateway.HealthChecker.Start()
+
 	return nil
 }
 
-func (gateway *Gateway) registerPredefined() {
+func (gateway *Gateway) registerPredefined() error {
 	deps := &DefaultDependencies{
 		Scope:         gateway.RootScope,
 		ContextLogger: gateway.ContextLogger,
@@ -369,12 +442,39 @@ func (gateway *Gateway) registerPredefined() {
 		Tracer:        gateway.Tracer,
 	}
 
+	// health checks are never shed nor rate limited, a gateway under load is
+	// still alive
 	tracer := NewRouterEndpoint(
 		gateway.ContextExtractor, deps,
 		"health", "health",
 		gateway.handleHealthRequest,
 	)
-	_ = gateway.HTTPRouter.Handle("GET", "/health", http.HandlerFunc(tracer.HandleRequest))
+	tracer.Criticality = CriticalityCritical
+	tracer.RateLimit = nil
+	_ = gateway.HTTPRouter.Handle("GET", "/health", tracer)
+
+	live := NewRouterEndpoint(
+		gateway.ContextExtractor, deps,
+		"health", "live",
+		gateway.handleLiveRequest,
+	)
+	live.Criticality = CriticalityCritical
+	live.RateLimit = nil
+	_ = gateway.HTTPRouter.Handle("GET", "/health/live", live)
+
+	ready := NewRouterEndpoint(
+		gateway.ContextExtractor, deps,
+		"health", "ready",
+		gateway.handleReadyRequest,
+	)
+	ready.Criticality = CriticalityCritical
+	ready.RateLimit = nil
+	_ = gateway.HTTPRouter.Handle("GET", "/health/ready", ready)
+
+	if gateway.admin != nil {
+		return gateway.admin.register(deps)
+	}
+	return nil
 }
 
 func (gateway *Gateway) handleHealthRequest(
@@ -382,7 +482,7 @@ func (gateway *Gateway) handleHealthRequest(
 	req *ServerHTTPRequest,
 	res *ServerHTTPResponse,
 ) context.Context {
-	if gateway.isUnhealthy {
+	if gateway.unhealthy() {
 		message := "Unhealthy, from " + gateway.ServiceName
 		bytes := []byte(
 			"{\"ok\":false,\"message\":\"" + message + "\"}\n",
@@ -399,10 +499,74 @@ func (gateway *Gateway) handleHealthRequest(
 	return ctx
 }
 
+// handleLiveRequest reports that the gateway process is up and serving,
+// regardless of its dependencies.
+func (gateway *Gateway) handleLiveRequest(
+	ctx context.Context,
+	req *ServerHTTPRequest,
+	res *ServerHTTPResponse,
+) context.Context {
+	message := "Alive, from " + gateway.ServiceName
+	bytes := []byte(
+		"{\"ok\":true,\"message\":\"" + message + "\"}\n",
+	)
+	res.WriteJSONBytes(200, nil, bytes)
+	return ctx
+}
+
+type readyResponse struct {
+	OK      bool                `json:"ok"`
+	Message string              `json:"message"`
+	Checks  []HealthCheckResult `json:"checks"`
+}
+
+// handleReadyRequest reports whether the gateway should receive traffic, it
+// is not ready while shutting down or while a critical dependency is not
+// healthy. The body lists the cached status of every dependency.
+func (gateway *Gateway) handleReadyRequest(
+	ctx context.Context,
+	req *ServerHTTPRequest,
+	res *ServerHTTPResponse,
+) context.Context {
+	report := gateway.HealthChecker.Report()
+	body := readyResponse{
+		OK:      report.Ready && !gateway.unhealthy(),
+		Message: "Ready, from " + gateway.ServiceName,
+		Checks:  report.Checks,
+	}
+	statusCode := http.StatusOK
+	if !body.OK {
+		body.Message = "Not ready, from " + gateway.ServiceName
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	bytes, err := json.Marshal(body)
+	if err != nil {
+		res.SendErrorString(http.StatusInternalServerError, "could not serialize health report")
+		return ctx
+	}
+	res.WriteJSONBytes(statusCode, nil, bytes)
+	return ctx
+}
+
+// unhealthy returns true once shutdown has started.
+func (gateway *Gateway) unhealthy() bool {
+	return atomic.LoadInt32(&gateway.isUnhealthy) == 1
+}
+
 // Shutdown starts the graceful shutdown, blocks until it is complete
 func (gateway *Gateway) Shutdown() {
 	// stop accepting incoming requests as soon as shutdown signal is received.
-	gateway.isUnhealthy = true
+	atomic.StoreInt32(&gateway.isUnhealthy, 1)
+
+	// keep serving while load balancers notice the failing health checks
+	if drainDelay := gateway.DrainDelay(); drainDelay > 0 {
+		gateway.Logger.Info("Draining before shutting down the servers",
+			zap.Duration("drainDelay", drainDelay),
+		)
+		gateway.RootScope.Counter("shutdown.drain").Inc(1)
+		time.Sleep(drainDelay)
+	}
 
 	var swg sync.WaitGroup
 	ctx, cancel := context.WithTimeout(context.Background(), gateway.ShutdownTimeout())
@@ -441,6 +605,9 @@ func (gateway *Gateway) Shutdown() {
 	// wait for servers to shutdown before stopping GRPCClientDispatcher
 	swg.Wait()
 
+	// requests still in flight were cut off by the shutdown timeout
+	gateway.logInflightRequests()
+
 	// stop all grpc clients
 	if gateway.GRPCClientDispatcher != nil {
 		swg.Add(1)
@@ -483,6 +650,29 @@ func (gateway *Gateway) Shutdown() {
 		_ = gateway.loggerFile.Close()
 	}
 
+	// stop watching dynamic config
+	if gateway.DynamicConfig != nil {
+		gateway.DynamicConfig.Stop()
+	}
+
+	// stop probing dependencies
+	if gateway.HealthChecker != nil {
+		gateway.HealthChecker.Stop()
+	}
+
+	// stop refreshing client peers
+	gateway.stopPeerProviders()
+
+	// stop watching TLS certificates
+	if gateway.tlsReloader != nil {
+		gateway.tlsReloader.stopPolling()
+	}
+
+	// stop runtime log level changes
+	if gateway.logLevels != nil {
+		gateway.logLevels.stopSignals()
+	}
+
 	// stop collecting runtime metrics
 	if gateway.runtimeMetrics != nil {
 		gateway.runtimeMetrics.Stop()
@@ -507,6 +697,29 @@ func (gateway *Gateway) Close() {
 		_ = gateway.loggerFile.Close()
 	}
 
+	// stop watching dynamic config
+	if gateway.DynamicConfig != nil {
+		gateway.DynamicConfig.Stop()
+	}
+
+	// stop probing dependencies
+	if gateway.HealthChecker != nil {
+		gateway.HealthChecker.Stop()
+	}
+
+	// stop refreshing client peers
+	gateway.stopPeerProviders()
+
+	// stop watching TLS certificates
+	if gateway.tlsReloader != nil {
+		gateway.tlsReloader.stopPolling()
+	}
+
+	// stop runtime log level changes
+	if gateway.logLevels != nil {
+		gateway.logLevels.stopSignals()
+	}
+
 	// stop collecting runtime metrics
 	if gateway.runtimeMetrics != nil {
 		gateway.runtimeMetrics.Stop()
@@ -521,6 +734,15 @@ func (gateway *Gateway) ShutdownTimeout() time.Duration {
 	return defaultCloseTimeout
 }
 
+// DrainDelay returns how long the gateway keeps serving with failing health
+// checks before shutting down the servers, which defaults to 0.
+func (gateway *Gateway) DrainDelay() time.Duration {
+	if gateway.Config.ContainsKey("shutdown.drainDelay") {
+		return time.Duration(gateway.Config.MustGetInt("shutdown.drainDelay")) * time.Millisecond
+	}
+	return 0
+}
+
 // InspectOrDie inspects the config for this gateway
 func (gateway *Gateway) InspectOrDie() map[string]interface{} {
 	return gateway.Config.InspectOrDie()
@@ -609,7 +831,8 @@ func (gateway *Gateway) setupMetrics(config *StaticConfig) (err error) {
 	// start collecting runtime metrics
 	collectInterval := time.Duration(config.MustGetInt("metrics.runtime.collectInterval")) * time.Millisecond
 	runtimeMetricsOpts := RuntimeMetricsOptions{
-		EnableCPUMetrics: config.MustGetBoolean("metrics.runtime.enableCPUMetrics"),
+		// load shedding reads the CPU utilization and goroutine count
+		EnableCPUMetrics: config.MustGetBoolean("metrics.runtime.enableCPUMetrics") || loadSheddingEnabled(config),
 		EnableMemMetrics: config.MustGetBoolean("metrics.runtime.enableMemMetrics"),
 		EnableGCMetrics:  config.MustGetBoolean("metrics.runtime.enableGCMetrics"),
 		CollectInterval:  collectInterval,
@@ -701,6 +924,7 @@ func (gateway *Gateway) setupLogger(config *StaticConfig) error {
 	))
 
 	gateway.atomLevel = &atomLevel
+	gateway.logLevels = newLogLevelController(atomLevel, gateway.RootScope)
 	gateway.logEncoder = logEncoder
 	gateway.logWriteSyncer = output
 
@@ -714,6 +938,7 @@ func (gateway *Gateway) setupLogger(config *StaticConfig) error {
 	)
 
 	gateway.ContextLogger = NewContextLogger(gateway.Logger)
+	gateway.logLevels.logger = gateway.Logger
 
 	if config.ContainsKey(skipZanzibarLogsKey) {
 		skipZanzibarLogs := config.MustGetBoolean(skipZanzibarLogsKey)
@@ -723,12 +948,66 @@ func (gateway *Gateway) setupLogger(config *StaticConfig) error {
 	return nil
 }
 
+func (gateway *Gateway) setupDynamicConfig(config *StaticConfig) error {
+	if !config.ContainsKey("dynamicConfig.paths") {
+		return nil
+	}
+
+	var paths []string
+	config.MustGetStruct("dynamicConfig.paths", &paths)
+	var immutableKeys []string
+	if config.ContainsKey("dynamicConfig.immutableKeys") {
+		config.MustGetStruct("dynamicConfig.immutableKeys", &immutableKeys)
+	}
+	var pollInterval time.Duration
+	if config.ContainsKey("dynamicConfig.pollInterval") {
+		pollInterval = time.Duration(config.MustGetInt("dynamicConfig.pollInterval")) * time.Millisecond
+	}
+
+	gateway.DynamicConfig = NewDynamicConfig(config, DynamicConfigOptions{
+		Paths:         paths,
+		PollInterval:  pollInterval,
+		ImmutableKeys: immutableKeys,
+		Logger:        gateway.Logger,
+		Scope:         gateway.RootScope,
+	})
+
+	// fail fast on a bad overlay instead of at bootstrap
+	if err := gateway.DynamicConfig.Reload(); err != nil {
+		return errors.Wrap(err, "error loading dynamic config")
+	}
+	return nil
+}
+
+func (gateway *Gateway) setupHealthChecker(config *StaticConfig) {
+	var interval, timeout time.Duration
+	if config.ContainsKey("health.interval") {
+		interval = time.Duration(config.MustGetInt("health.interval")) * time.Millisecond
+	}
+	if config.ContainsKey("health.timeout") {
+		timeout = time.Duration(config.MustGetInt("health.timeout")) * time.Millisecond
+	}
+
+	gateway.HealthChecker = NewHealthChecker(HealthCheckerOptions{
+		Interval: interval,
+		Timeout:  timeout,
+		Config:   config,
+		Logger:   gateway.Logger,
+		Scope:    gateway.RootScope,
+	})
+}
+
 // SubLogger returns a sub logger clone with given name and log level.
 func (gateway *Gateway) SubLogger(name string, level zapcore.Level) *zap.Logger {
+	// sub loggers share a level by name so it can be changed at runtime
+	var enabler zapcore.LevelEnabler = level
+	if gateway.logLevels != nil {
+		enabler = gateway.logLevels.atomicLevel(name, level)
+	}
 	newCore := zapcore.NewCore(
 		gateway.logEncoder.Clone(),
 		gateway.logWriteSyncer,
-		level,
+		enabler,
 	)
 	return gateway.Logger.With(
 		zap.String("subLogger", name),
@@ -790,21 +1069,62 @@ func (gateway *Gateway) setupHTTPServer() error {
 	if err != nil {
 		return errors.Wrap(err, "error finding the best IP")
 	}
+	tlsConfig, err := gateway.setupHTTPServerTLS(gateway.Config, httpLogger)
+	if err != nil {
+		return err
+	}
 	gateway.httpServer = &HTTPServer{
 		Server: &http.Server{
-			Addr:    listenIP.String() + ":" + strconv.FormatInt(int64(gateway.HTTPPort), 10),
-			Handler: gateway.HTTPRouter,
+			Addr:      listenIP.String() + ":" + strconv.FormatInt(int64(gateway.HTTPPort), 10),
+			Handler:   gateway.HTTPRouter,
+			TLSConfig: tlsConfig,
 		},
 		Logger: httpLogger,
 	}
 
 	gateway.localHTTPServer = &HTTPServer{
 		Server: &http.Server{
-			Addr:    "127.0.0.1:" + strconv.FormatInt(int64(gateway.HTTPPort), 10),
-			Handler: gateway.HTTPRouter,
+			Addr:      "127.0.0.1:" + strconv.FormatInt(int64(gateway.HTTPPort), 10),
+			Handler:   gateway.HTTPRouter,
+			TLSConfig: tlsConfig,
 		},
 		Logger: httpLogger,
 	}
+
+	if err := gateway.setupHTTPServerSockets(gateway.Config); err != nil {
+		return err
+	}
+
+	configureHTTPLimits(gateway.Config, gateway.httpServer.Server)
+	configureHTTPLimits(gateway.Config, gateway.localHTTPServer.Server)
+
+	if err := configureHTTP2(gateway.Config, gateway.httpServer.Server); err != nil {
+		return err
+	}
+	return configureHTTP2(gateway.Config, gateway.localHTTPServer.Server)
+}
+
+// setupHTTPServerSockets makes the HTTP server listen on "http.socketPath"
+// and the local HTTP server on "http.localSocketPath" instead of TCP.
+func (gateway *Gateway) setupHTTPServerSockets(config *StaticConfig) error {
+	socketMode := defaultSocketMode
+	if config.ContainsKey("http.socketMode") {
+		value := config.MustGetString("http.socketMode")
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil {
+			return errors.Wrapf(err, "invalid http.socketMode: %s", value)
+		}
+		socketMode = os.FileMode(mode)
+	}
+
+	if config.ContainsKey("http.socketPath") {
+		gateway.httpServer.SocketPath = config.MustGetString("http.socketPath")
+		gateway.httpServer.SocketMode = socketMode
+	}
+	if config.ContainsKey("http.localSocketPath") {
+		gateway.localHTTPServer.SocketPath = config.MustGetString("http.localSocketPath")
+		gateway.localHTTPServer.SocketMode = socketMode
+	}
 	return nil
 }
 
@@ -889,15 +1209,24 @@ func (gateway *Gateway) setupGRPCClientDispatcher(config *StaticConfig) error {
 		return nil
 	}
 
-	unaryOutbound := grpc.NewTransport(
+	grpcTransport := grpc.NewTransport(
 		grpc.Logger(gateway.Logger),
 		grpc.Tracer(gateway.Tracer),
-	).NewSingleOutbound(address)
+	)
+	unaryOutbound := grpcTransport.NewSingleOutbound(address)
 	outbounds := make(yarpc.Outbounds, len(clientServiceNameMapping))
 	for key, value := range clientServiceNameMapping {
+		var outbound transport.UnaryOutbound = unaryOutbound
+		// clients with a peer provider call their peers directly
+		if peers := NewPeerProvider(config, key, gateway.Logger, gateway.RootScope); peers != nil {
+			peerOutbound := newGRPCPeerOutbound(key, grpcTransport)
+			peers.Subscribe(peerOutbound.update)
+			gateway.RegisterPeerProvider(key, peers)
+			outbound = peerOutbound
+		}
 		outbounds[key] = transport.Outbounds{
 			ServiceName: value,
-			Unary:       unaryOutbound,
+			Unary:       outbound,
 		}
 	}
 
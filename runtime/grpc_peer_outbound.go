@@ -0,0 +1,132 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/transport/grpc"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// grpcPeerOutbound is a unary outbound that sends calls in turn to the peers
+// of a PeerProvider, with one outbound per peer.
+type grpcPeerOutbound struct {
+	clientID  string
+	transport *grpc.Transport
+
+	mu        sync.RWMutex
+	peers     []string                  // protected by mu
+	outbounds map[string]*grpc.Outbound // protected by mu
+	next      int                       // protected by mu
+	running   bool                      // protected by mu
+}
+
+var _ transport.UnaryOutbound = (*grpcPeerOutbound)(nil)
+
+func newGRPCPeerOutbound(clientID string, t *grpc.Transport) *grpcPeerOutbound {
+	return &grpcPeerOutbound{
+		clientID:  clientID,
+		transport: t,
+		outbounds: make(map[string]*grpc.Outbound),
+	}
+}
+
+// update is the PeersChangeHandler of the outbound, the outbounds of new
+// peers are started when the outbound is running and the outbounds of the
+// peers that are gone are stopped.
+func (o *grpcPeerOutbound) update(peers []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	added, removed := diffPeers(o.peers, peers)
+	for _, peer := range added {
+		outbound := o.transport.NewSingleOutbound(peer)
+		if o.running {
+			// a peer that fails to start is retried on the next change
+			if err := outbound.Start(); err != nil {
+				continue
+			}
+		}
+		o.outbounds[peer] = outbound
+	}
+	for _, peer := range removed {
+		if outbound, ok := o.outbounds[peer]; ok {
+			_ = outbound.Stop()
+			delete(o.outbounds, peer)
+		}
+	}
+	o.peers = o.peers[:0]
+	for _, peer := range peers {
+		if _, ok := o.outbounds[peer]; ok {
+			o.peers = append(o.peers, peer)
+		}
+	}
+}
+
+func (o *grpcPeerOutbound) Transports() []transport.Transport {
+	return []transport.Transport{o.transport}
+}
+
+func (o *grpcPeerOutbound) Start() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, outbound := range o.outbounds {
+		if err := outbound.Start(); err != nil {
+			return err
+		}
+	}
+	o.running = true
+	return nil
+}
+
+func (o *grpcPeerOutbound) Stop() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var err error
+	for _, outbound := range o.outbounds {
+		if stopErr := outbound.Stop(); stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}
+	o.running = false
+	return err
+}
+
+func (o *grpcPeerOutbound) IsRunning() bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.running
+}
+
+func (o *grpcPeerOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	o.mu.Lock()
+	if len(o.peers) == 0 {
+		o.mu.Unlock()
+		return nil, yarpcerrors.UnavailableErrorf("no peers available for client %s", o.clientID)
+	}
+	outbound := o.outbounds[o.peers[o.next%len(o.peers)]]
+	o.next++
+	o.mu.Unlock()
+	return outbound.Call(ctx, req)
+}
@@ -0,0 +1,388 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = time.Second
+
+	healthCheckSuccess = "health-check.success"
+	healthCheckFailure = "health-check.failure"
+	healthCheckLatency = "health-check.latency"
+)
+
+// Status of a health check in a HealthReport.
+const (
+	HealthStatusUnknown   = "unknown"
+	HealthStatusHealthy   = "healthy"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// HealthProbe checks whether a dependency of the gateway is reachable,
+// a nil error means the dependency is healthy.
+type HealthProbe func(ctx context.Context) error
+
+// HealthCheckOptions configures a registered health check, zero values fall
+// back to the defaults of the HealthChecker.
+type HealthCheckOptions struct {
+	// Critical checks make the gateway not ready while they are not healthy.
+	Critical bool
+	// Interval is how often the probe runs, its last result is cached in between.
+	Interval time.Duration
+	// Timeout bounds a single run of the probe.
+	Timeout time.Duration
+}
+
+// HealthCheckResult is the cached result of the last run of a health check.
+type HealthCheckResult struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Critical    bool       `json:"critical"`
+	Error       string     `json:"error,omitempty"`
+	LastChecked *time.Time `json:"lastChecked,omitempty"`
+	Latency     string     `json:"latency,omitempty"`
+}
+
+// HealthReport lists the status of every dependency of the gateway.
+type HealthReport struct {
+	// Ready is false when any critical check is not healthy.
+	Ready  bool                `json:"ready"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// HealthCheckerOptions configures a HealthChecker.
+type HealthCheckerOptions struct {
+	// Interval is the default interval of health checks.
+	Interval time.Duration
+	// Timeout is the default timeout of health checks.
+	Timeout time.Duration
+	// Config is read for the per client health check settings.
+	Config ConfigReader
+	Logger *zap.Logger
+	Scope  tally.Scope
+}
+
+type healthCheck struct {
+	name  string
+	probe HealthProbe
+	opts  HealthCheckOptions
+
+	mu     sync.RWMutex
+	result HealthCheckResult // protected by mu
+}
+
+// HealthChecker is a registry of health probes of the gateway's dependencies,
+// such as generated clients and custom modules. Probes run periodically once
+// started and the readiness of the gateway is computed from their cached
+// results, so that health requests never wait on a downstream.
+type HealthChecker struct {
+	interval time.Duration
+	timeout  time.Duration
+	config   ConfigReader
+	logger   *zap.Logger
+	scope    tally.Scope
+
+	mu      sync.RWMutex
+	checks  map[string]*healthCheck // protected by mu
+	running bool                    // protected by mu
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewHealthChecker creates a HealthChecker, probes do not run until Start
+// or Refresh is called.
+func NewHealthChecker(opts HealthCheckerOptions) *HealthChecker {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	scope := opts.Scope
+	if scope == nil {
+		scope = tally.NoopScope
+	}
+
+	return &HealthChecker{
+		interval: interval,
+		timeout:  timeout,
+		config:   opts.Config,
+		logger:   logger,
+		scope:    scope,
+		checks:   map[string]*healthCheck{},
+		stop:     make(chan struct{}),
+	}
+}
+
+// Register adds a named health check. Checks registered after Start begin
+// running right away. It is safe to call Register on a nil HealthChecker,
+// which is a no-op.
+func (hc *HealthChecker) Register(name string, probe HealthProbe, opts HealthCheckOptions) error {
+	if hc == nil {
+		return nil
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = hc.interval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = hc.timeout
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if _, ok := hc.checks[name]; ok {
+		return errors.Errorf("health check %q is already registered", name)
+	}
+	check := &healthCheck{
+		name:  name,
+		probe: probe,
+		opts:  opts,
+		result: HealthCheckResult{
+			Name:     name,
+			Status:   HealthStatusUnknown,
+			Critical: opts.Critical,
+		},
+	}
+	hc.checks[name] = check
+	if hc.running {
+		hc.runPeriodically(check)
+	}
+	return nil
+}
+
+// Start runs every probe and then again at the interval of its check.
+func (hc *HealthChecker) Start() {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.running {
+		return
+	}
+	for _, check := range hc.checks {
+		hc.runPeriodically(check)
+	}
+	hc.running = true
+}
+
+// Stop stops running the probes and waits for the ones in progress. It cannot
+// be restarted once stopped.
+func (hc *HealthChecker) Stop() {
+	hc.mu.Lock()
+	if !hc.running {
+		hc.mu.Unlock()
+		return
+	}
+	close(hc.stop)
+	hc.running = false
+	hc.mu.Unlock()
+	hc.wg.Wait()
+}
+
+func (hc *HealthChecker) runPeriodically(check *healthCheck) {
+	hc.wg.Add(1)
+	go func() {
+		defer hc.wg.Done()
+		hc.run(context.Background(), check)
+		ticker := time.NewTicker(check.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				hc.run(context.Background(), check)
+			case <-hc.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Refresh runs every probe once and waits for their results.
+func (hc *HealthChecker) Refresh(ctx context.Context) {
+	hc.mu.RLock()
+	checks := make([]*healthCheck, 0, len(hc.checks))
+	for _, check := range hc.checks {
+		checks = append(checks, check)
+	}
+	hc.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check *healthCheck) {
+			defer wg.Done()
+			hc.run(ctx, check)
+		}(check)
+	}
+	wg.Wait()
+}
+
+func (hc *HealthChecker) run(ctx context.Context, check *healthCheck) {
+	ctx, cancel := context.WithTimeout(ctx, check.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runHealthProbe(ctx, check.probe)
+	latency := time.Since(start)
+
+	scope := hc.scope.Tagged(map[string]string{"check": check.name})
+	scope.Timer(healthCheckLatency).Record(latency)
+	result := HealthCheckResult{
+		Name:        check.name,
+		Status:      HealthStatusHealthy,
+		Critical:    check.opts.Critical,
+		LastChecked: &start,
+		Latency:     latency.String(),
+	}
+	if err != nil {
+		result.Status = HealthStatusUnhealthy
+		result.Error = err.Error()
+		scope.Counter(healthCheckFailure).Inc(1)
+	} else {
+		scope.Counter(healthCheckSuccess).Inc(1)
+	}
+
+	check.mu.Lock()
+	previous := check.result.Status
+	check.result = result
+	check.mu.Unlock()
+
+	if previous != result.Status && result.Status == HealthStatusUnhealthy {
+		hc.logger.Warn("Health check failed",
+			zap.String("check", check.name),
+			zap.Bool("critical", check.opts.Critical),
+			zap.Error(err),
+		)
+	} else if previous == HealthStatusUnhealthy && result.Status == HealthStatusHealthy {
+		hc.logger.Info("Health check recovered", zap.String("check", check.name))
+	}
+}
+
+// runHealthProbe runs the probe and returns when it finishes or the context
+// is done, whichever happens first, so a probe ignoring its context can not
+// hold up the checks.
+func runHealthProbe(ctx context.Context, probe HealthProbe) (err error) {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- errors.Errorf("health probe panicked: %v", p)
+			}
+		}()
+		done <- probe(ctx)
+	}()
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "health probe timed out")
+	}
+}
+
+// Report returns the cached results of every health check sorted by name.
+func (hc *HealthChecker) Report() HealthReport {
+	report := HealthReport{
+		Ready:  true,
+		Checks: []HealthCheckResult{},
+	}
+	if hc == nil {
+		return report
+	}
+
+	hc.mu.RLock()
+	for _, check := range hc.checks {
+		check.mu.RLock()
+		result := check.result
+		check.mu.RUnlock()
+		if result.Critical && result.Status != HealthStatusHealthy {
+			report.Ready = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	hc.mu.RUnlock()
+
+	sort.Slice(report.Checks, func(i, j int) bool {
+		return report.Checks[i].Name < report.Checks[j].Name
+	})
+	return report
+}
+
+// clientOptions returns the health check options of a client from the
+// "clients.<clientID>.healthCheck.*" config keys, ok is false when the health
+// check of the client is not enabled.
+func (hc *HealthChecker) clientOptions(clientID string) (opts HealthCheckOptions, ok bool) {
+	if hc.config == nil {
+		return opts, false
+	}
+	prefix := "clients." + clientID + ".healthCheck."
+	if !hc.config.ContainsKey(prefix+"enabled") || !hc.config.MustGetBoolean(prefix+"enabled") {
+		return opts, false
+	}
+	if hc.config.ContainsKey(prefix + "critical") {
+		opts.Critical = hc.config.MustGetBoolean(prefix + "critical")
+	}
+	if hc.config.ContainsKey(prefix + "interval") {
+		opts.Interval = time.Duration(hc.config.MustGetInt(prefix+"interval")) * time.Millisecond
+	}
+	if hc.config.ContainsKey(prefix + "timeout") {
+		opts.Timeout = time.Duration(hc.config.MustGetInt(prefix+"timeout")) * time.Millisecond
+	}
+	return opts, true
+}
+
+func (hc *HealthChecker) clientString(clientID, key, defaultValue string) string {
+	key = "clients." + clientID + ".healthCheck." + key
+	if hc.config.ContainsKey(key) {
+		return hc.config.MustGetString(key)
+	}
+	return defaultValue
+}
+
+func (hc *HealthChecker) registerClient(clientID string, newProbe func() HealthProbe) {
+	if hc == nil {
+		return
+	}
+	opts, ok := hc.clientOptions(clientID)
+	if !ok {
+		return
+	}
+	if err := hc.Register(clientID, newProbe(), opts); err != nil {
+		hc.logger.Warn("Could not register client health check",
+			zap.String("clientID", clientID),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func healthyProbe(ctx context.Context) error {
+	return nil
+}
+
+func TestHealthCheckerReport(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	hc := NewHealthChecker(HealthCheckerOptions{Scope: scope})
+	require.NoError(t, hc.Register("foo", healthyProbe, HealthCheckOptions{Critical: true}))
+	require.NoError(t, hc.Register("bar", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}, HealthCheckOptions{}))
+
+	// critical checks that did not run yet are not ready
+	report := hc.Report()
+	assert.False(t, report.Ready)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, HealthStatusUnknown, report.Checks[1].Status)
+
+	hc.Refresh(context.Background())
+	report = hc.Report()
+	assert.True(t, report.Ready, "non critical failures do not change readiness")
+	require.Len(t, report.Checks, 2)
+
+	assert.Equal(t, "bar", report.Checks[0].Name)
+	assert.Equal(t, HealthStatusUnhealthy, report.Checks[0].Status)
+	assert.Equal(t, "connection refused", report.Checks[0].Error)
+	assert.False(t, report.Checks[0].Critical)
+
+	assert.Equal(t, "foo", report.Checks[1].Name)
+	assert.Equal(t, HealthStatusHealthy, report.Checks[1].Status)
+	assert.True(t, report.Checks[1].Critical)
+	assert.NotNil(t, report.Checks[1].LastChecked)
+	assert.NotEmpty(t, report.Checks[1].Latency)
+
+	counters := scope.Snapshot().Counters()
+	assert.Equal(t, int64(1), counters[healthCheckSuccess+"+check=foo"].Value())
+	assert.Equal(t, int64(1), counters[healthCheckFailure+"+check=bar"].Value())
+}
+
+func TestHealthCheckerCriticalFailure(t *testing.T) {
+	hc := NewHealthChecker(HealthCheckerOptions{})
+	require.NoError(t, hc.Register("foo", func(ctx context.Context) error {
+		return errors.New("unreachable")
+	}, HealthCheckOptions{Critical: true}))
+
+	hc.Refresh(context.Background())
+	assert.False(t, hc.Report().Ready)
+}
+
+func TestHealthCheckerDuplicateName(t *testing.T) {
+	hc := NewHealthChecker(HealthCheckerOptions{})
+	require.NoError(t, hc.Register("foo", healthyProbe, HealthCheckOptions{}))
+	err := hc.Register("foo", healthyProbe, HealthCheckOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `health check "foo" is already registered`)
+}
+
+func TestHealthCheckerTimeout(t *testing.T) {
+	hc := NewHealthChecker(HealthCheckerOptions{})
+	block := make(chan struct{})
+	defer close(block)
+	require.NoError(t, hc.Register("slow", func(ctx context.Context) error {
+		<-block
+		return nil
+	}, HealthCheckOptions{Timeout: 10 * time.Millisecond}))
+
+	hc.Refresh(context.Background())
+	report := hc.Report()
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, HealthStatusUnhealthy, report.Checks[0].Status)
+	assert.Contains(t, report.Checks[0].Error, "health probe timed out")
+}
+
+func TestHealthCheckerPanickingProbe(t *testing.T) {
+	hc := NewHealthChecker(HealthCheckerOptions{})
+	require.NoError(t, hc.Register("panic", func(ctx context.Context) error {
+		panic("boom")
+	}, HealthCheckOptions{}))
+
+	hc.Refresh(context.Background())
+	assert.Contains(t, hc.Report().Checks[0].Error, "health probe panicked: boom")
+}
+
+func TestHealthCheckerStartStop(t *testing.T) {
+	hc := NewHealthChecker(HealthCheckerOptions{Interval: 5 * time.Millisecond})
+	calls := make(chan struct{}, 100)
+	require.NoError(t, hc.Register("foo", func(ctx context.Context) error {
+		calls <- struct{}{}
+		return nil
+	}, HealthCheckOptions{}))
+
+	hc.Start()
+	hc.Start()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-calls:
+		case <-time.After(time.Second):
+			t.Fatal("probe did not run periodically")
+		}
+	}
+
+	// checks registered once started run right away
+	late := make(chan struct{}, 100)
+	require.NoError(t, hc.Register("late", func(ctx context.Context) error {
+		late <- struct{}{}
+		return nil
+	}, HealthCheckOptions{}))
+	select {
+	case <-late:
+	case <-time.After(time.Second):
+		t.Fatal("late probe did not run")
+	}
+
+	hc.Stop()
+	hc.Stop()
+	assert.Equal(t, HealthStatusHealthy, hc.Report().Checks[0].Status)
+}
+
+func TestHealthCheckerClientOptions(t *testing.T) {
+	config := NewStaticConfigOrDie([]*ConfigOption{
+		ConfigFileContents([]byte(`
+clients.foo.healthCheck.enabled: true
+clients.foo.healthCheck.critical: true
+clients.foo.healthCheck.interval: 500
+clients.foo.healthCheck.timeout: 50
+clients.foo.healthCheck.path: /status
+clients.bar.healthCheck.enabled: false
+`)),
+	}, nil)
+	hc := NewHealthChecker(HealthCheckerOptions{Config: config})
+
+	opts, ok := hc.clientOptions("foo")
+	assert.True(t, ok)
+	assert.Equal(t, HealthCheckOptions{
+		Critical: true,
+		Interval: 500 * time.Millisecond,
+		Timeout:  50 * time.Millisecond,
+	}, opts)
+	assert.Equal(t, "/status", hc.clientString("foo", "path", defaultHTTPHealthCheckPath))
+
+	_, ok = hc.clientOptions("bar")
+	assert.False(t, ok)
+	_, ok = hc.clientOptions("baz")
+	assert.False(t, ok)
+
+	hc.registerClient("foo", func() HealthProbe { return healthyProbe })
+	hc.registerClient("bar", func() HealthProbe { return healthyProbe })
+	report := hc.Report()
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "foo", report.Checks[0].Name)
+	assert.True(t, report.Checks[0].Critical)
+}
+
+func TestHealthCheckerNil(t *testing.T) {
+	var hc *HealthChecker
+	assert.NoError(t, hc.Register("foo", healthyProbe, HealthCheckOptions{}))
+	assert.NotPanics(t, func() {
+		hc.RegisterHTTPClient("foo", &HTTPClient{})
+	})
+	assert.True(t, hc.Report().Ready)
+}
+
+func TestHTTPClientHealthProbe(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/health", r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := &HTTPClient{client: server.Client(), BaseURL: server.URL}
+	probe := client.HealthProbe("/health")
+	assert.NoError(t, probe(context.Background()))
+
+	status = http.StatusServiceUnavailable
+	err := probe(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "503")
+}
+
+func TestGRPCHealthCheckEncoding(t *testing.T) {
+	assert.Nil(t, encodeGRPCHealthCheckRequest(""))
+	assert.Equal(t, []byte{0x0a, 0x03, 'f', 'o', 'o'}, encodeGRPCHealthCheckRequest("foo"))
+
+	status, err := decodeGRPCHealthCheckResponse([]byte{0x08, 0x01})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(grpcHealthServing), status)
+
+	// unknown fields are skipped
+	status, err = decodeGRPCHealthCheckResponse([]byte{0x12, 0x01, 'x', 0x08, 0x02})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), status)
+
+	// the default UNKNOWN status is not encoded
+	status, err = decodeGRPCHealthCheckResponse(nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), status)
+
+	_, err = decodeGRPCHealthCheckResponse([]byte{0x12, 0x05, 'x'})
+	assert.Error(t, err)
+}
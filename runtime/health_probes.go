@@ -0,0 +1,235 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.uber.org/thriftrw/wire"
+	"go.uber.org/yarpc/api/transport"
+)
+
+const (
+	defaultHTTPHealthCheckPath = "/health"
+
+	grpcHealthCheckProcedure = "grpc.health.v1.Health::Check"
+	// grpcHealthServing is the SERVING value of HealthCheckResponse.ServingStatus
+	grpcHealthServing = 1
+)
+
+// RegisterHTTPClient registers the health check of a generated HTTP client
+// when "clients.<clientID>.healthCheck.enabled" is set, the probe sends a GET
+// request to "clients.<clientID>.healthCheck.path" which defaults to /health.
+func (hc *HealthChecker) RegisterHTTPClient(clientID string, client *HTTPClient) {
+	hc.registerClient(clientID, func() HealthProbe {
+		return client.HealthProbe(hc.clientString(clientID, "path", defaultHTTPHealthCheckPath))
+	})
+}
+
+// RegisterTChannelClient registers the health check of a generated TChannel
+// client when "clients.<clientID>.healthCheck.enabled" is set, the probe
+// calls Meta::health on the downstream service.
+func (hc *HealthChecker) RegisterTChannelClient(clientID string, client *TChannelClient) {
+	hc.registerClient(clientID, client.HealthProbe)
+}
+
+// RegisterGRPCClient registers the health check of a generated gRPC client
+// when "clients.<clientID>.healthCheck.enabled" is set, the probe uses the
+// gRPC health checking protocol for "clients.<clientID>.healthCheck.service",
+// which defaults to the overall health of the server.
+func (hc *HealthChecker) RegisterGRPCClient(clientID string, oc *transport.OutboundConfig) {
+	hc.registerClient(clientID, func() HealthProbe {
+		return GRPCHealthProbe(oc, hc.clientString(clientID, "service", ""))
+	})
+}
+
+// HealthProbe returns a probe that sends a GET request to the given path of
+// the client's base URL, or of the next host of its host pool, and fails
+// unless the response status is 2xx.
+func (c *HTTPClient) HealthProbe(path string) HealthProbe {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequest("GET", c.BaseURL+path, nil)
+		if err != nil {
+			return errors.Wrap(err, "could not create health check request")
+		}
+		res, err := c.do(req.WithContext(ctx))
+		if err != nil {
+			return errors.Wrap(err, "could not make health check request")
+		}
+		defer func() { _ = res.Body.Close() }()
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return &UnexpectedHTTPError{StatusCode: res.StatusCode}
+		}
+		return nil
+	}
+}
+
+// HealthProbe returns a probe that calls Meta::health on the client's
+// service and fails unless the service reports ok.
+func (c *TChannelClient) HealthProbe() HealthProbe {
+	return func(ctx context.Context) error {
+		var result metaHealthResult
+		success, _, err := c.Call(ctx, "Meta", "health", nil, &metaHealthArgs{}, &result)
+		if err != nil {
+			return err
+		}
+		if !success {
+			return errors.New("Meta::health returned an application error")
+		}
+		if !result.ok {
+			return errors.Errorf("Meta::health is not ok: %s", result.message)
+		}
+		return nil
+	}
+}
+
+// GRPCHealthProbe returns a probe that calls grpc.health.v1.Health/Check on
+// the outbound and fails unless the given service is SERVING, an empty
+// service checks the overall health of the server.
+func GRPCHealthProbe(oc *transport.OutboundConfig, service string) HealthProbe {
+	return func(ctx context.Context) error {
+		res, err := oc.Outbounds.Unary.Call(ctx, &transport.Request{
+			Caller:    oc.CallerName,
+			Service:   oc.Outbounds.ServiceName,
+			Encoding:  transport.Encoding("proto"),
+			Procedure: grpcHealthCheckProcedure,
+			Body:      bytes.NewReader(encodeGRPCHealthCheckRequest(service)),
+		})
+		if err != nil {
+			return errors.Wrap(err, "could not make gRPC health check request")
+		}
+		defer func() { _ = res.Body.Close() }()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return errors.Wrap(err, "could not read gRPC health check response")
+		}
+		status, err := decodeGRPCHealthCheckResponse(body)
+		if err != nil {
+			return err
+		}
+		if status != grpcHealthServing {
+			return errors.Errorf("gRPC health status is %d, not SERVING", status)
+		}
+		return nil
+	}
+}
+
+// encodeGRPCHealthCheckRequest encodes a grpc.health.v1.HealthCheckRequest,
+// whose only field is "string service = 1".
+func encodeGRPCHealthCheckRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	buf := make([]byte, binary.MaxVarintLen64+1, binary.MaxVarintLen64+1+len(service))
+	buf[0] = 0x0a
+	n := binary.PutUvarint(buf[1:], uint64(len(service)))
+	return append(buf[:n+1], service...)
+}
+
+// decodeGRPCHealthCheckResponse decodes the "ServingStatus status = 1" field
+// of a grpc.health.v1.HealthCheckResponse, skipping unknown fields.
+func decodeGRPCHealthCheckResponse(b []byte) (uint64, error) {
+	var status uint64
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return 0, errors.New("malformed gRPC health check response")
+		}
+		b = b[n:]
+		switch key & 0x7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return 0, errors.New("malformed gRPC health check response")
+			}
+			if key>>3 == 1 {
+				status = v
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return 0, errors.New("malformed gRPC health check response")
+			}
+			b = b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return 0, errors.New("malformed gRPC health check response")
+			}
+			b = b[n+int(l):]
+		case 5:
+			if len(b) < 4 {
+				return 0, errors.New("malformed gRPC health check response")
+			}
+			b = b[4:]
+		default:
+			return 0, errors.Errorf("unexpected wire type %d in gRPC health check response", key&0x7)
+		}
+	}
+	return status, nil
+}
+
+// metaHealthArgs are the arguments of Meta::health, the optional health
+// request is left unset.
+type metaHealthArgs struct{}
+
+func (a *metaHealthArgs) ToWire() (wire.Value, error) {
+	return wire.NewValueStruct(wire.Struct{}), nil
+}
+
+func (a *metaHealthArgs) FromWire(w wire.Value) error {
+	return nil
+}
+
+// metaHealthResult is the result of Meta::health, whose success value is
+// "struct HealthStatus { 1: required bool ok, 2: optional string message }".
+type metaHealthResult struct {
+	ok      bool
+	message string
+}
+
+func (r *metaHealthResult) ToWire() (wire.Value, error) {
+	return wire.Value{}, errors.New("Meta::health result can not be written")
+}
+
+func (r *metaHealthResult) FromWire(w wire.Value) error {
+	for _, field := range w.GetStruct().Fields {
+		if field.ID != 0 || field.Value.Type() != wire.TStruct {
+			continue
+		}
+		for _, statusField := range field.Value.GetStruct().Fields {
+			switch {
+			case statusField.ID == 1 && statusField.Value.Type() == wire.TBool:
+				r.ok = statusField.Value.GetBool()
+			case statusField.ID == 2 && statusField.Value.Type() == wire.TBinary:
+				r.message = statusField.Value.GetString()
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHedgingMaxHedges = 1
+	// hedgingMinSamples is the number of latencies a method needs before
+	// its delay percentile is used, the fixed delay applies until then
+	hedgingMinSamples = 100
+	hedgingWindowSize = 1000
+
+	clientHedges      = "client.hedges"
+	clientHedgeWinner = "client.hedge.winner"
+
+	scopeTagAttempt = "attempt"
+)
+
+// HedgingPolicy sends hedged attempts of slow client calls. When the first
+// attempt of a covered method has not finished after the hedging delay a
+// second one is sent, and so on up to MaxHedges, the first attempt to succeed
+// wins and the others are canceled. Hedging sends a request more than once so
+// it must only cover idempotent methods.
+type HedgingPolicy struct {
+	// Methods are the client method names that are hedged.
+	Methods map[string]bool
+	// Delay is the fixed hedging delay, it is also used by a percentile
+	// policy until a method has enough latency samples.
+	Delay time.Duration
+	// DelayPercentile, when set, uses this percentile of the recent
+	// latencies of the method as the hedging delay, e.g. 95.
+	DelayPercentile float64
+	// MaxHedges caps the attempts sent in addition to the first one.
+	MaxHedges int
+
+	mu        sync.Mutex
+	latencies map[string]*latencyWindow // protected by mu
+}
+
+// NewHedgingPolicy returns the hedging policy of a client from the
+// "clients.<clientID>.hedging.*" keys, it returns nil when the client does
+// not hedge any method.
+func NewHedgingPolicy(config ConfigReader, clientID string) *HedgingPolicy {
+	prefix := "clients." + clientID + ".hedging."
+	if !config.ContainsKey(prefix + "methods") {
+		return nil
+	}
+	var methods []string
+	config.MustGetStruct(prefix+"methods", &methods)
+	if len(methods) == 0 {
+		return nil
+	}
+	p := &HedgingPolicy{
+		Methods:   make(map[string]bool, len(methods)),
+		MaxHedges: defaultHedgingMaxHedges,
+	}
+	for _, method := range methods {
+		p.Methods[method] = true
+	}
+	if config.ContainsKey(prefix + "delay") {
+		p.Delay = time.Duration(config.MustGetInt(prefix+"delay")) * time.Millisecond
+	}
+	if config.ContainsKey(prefix + "delayPercentile") {
+		p.DelayPercentile = config.MustGetFloat(prefix + "delayPercentile")
+	}
+	if config.ContainsKey(prefix + "maxHedges") {
+		p.MaxHedges = int(config.MustGetInt(prefix + "maxHedges"))
+	}
+	return p
+}
+
+// covers returns true when the method is hedged, it is safe to call covers
+// on a nil HedgingPolicy.
+func (p *HedgingPolicy) covers(method string) bool {
+	return p != nil && p.MaxHedges > 0 && p.Methods[method]
+}
+
+// delay returns the hedging delay of the method, false means that the
+// method has neither a fixed delay nor enough samples for its percentile.
+func (p *HedgingPolicy) delay(method string) (time.Duration, bool) {
+	if p.DelayPercentile > 0 {
+		p.mu.Lock()
+		window := p.latencies[method]
+		p.mu.Unlock()
+		if delay, ok := window.percentile(p.DelayPercentile); ok {
+			return delay, true
+		}
+	}
+	return p.Delay, p.Delay > 0
+}
+
+// observe records the latency of a first attempt for the delay percentile.
+func (p *HedgingPolicy) observe(method string, latency time.Duration) {
+	if p.DelayPercentile <= 0 {
+		return
+	}
+	p.mu.Lock()
+	if p.latencies == nil {
+		p.latencies = make(map[string]*latencyWindow)
+	}
+	window, ok := p.latencies[method]
+	if !ok {
+		window = &latencyWindow{samples: make([]time.Duration, hedgingWindowSize)}
+		p.latencies[method] = window
+	}
+	p.mu.Unlock()
+	window.add(latency)
+}
+
+type hedgeResult struct {
+	attempt int
+	err     error
+	latency time.Duration
+}
+
+// do runs the first attempt of a call and hedges it while it is slow. Each
+// attempt gets its own context which is canceled once do returns, attempts
+// must not share state: attempt is called on the goroutine of do to take a
+// snapshot of the call and the function it returns runs on its own
+// goroutine, which may outlive do. It returns the index of the attempt whose result is
+// returned, that is the winner or the last failure, and the number of hedges
+// sent. Failures are not hedged, once every attempt sent failed do returns.
+// The delay percentile samples the first attempts only, hedges win because
+// they are fast so their latencies would lower the delay call after call.
+func (p *HedgingPolicy) do(
+	ctx context.Context,
+	method string,
+	attempt func(ctx context.Context, n int) func() error,
+) (n int, hedges int, err error) {
+	maxAttempts := 1
+	delay, ok := p.delay(method)
+	if ok {
+		maxAttempts += p.MaxHedges
+	}
+
+	results := make(chan hedgeResult, maxAttempts)
+	cancels := make([]context.CancelFunc, 0, maxAttempts)
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+	launch := func(n int) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		run := attempt(attemptCtx, n)
+		start := time.Now()
+		go func() {
+			err := run()
+			results <- hedgeResult{attempt: n, err: err, latency: time.Since(start)}
+		}()
+	}
+
+	first, firstDone := time.Now(), false
+	launch(0)
+	sent, pending := 1, 1
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var hedge <-chan time.Time
+	if sent < maxAttempts {
+		hedge = timer.C
+	}
+	for {
+		select {
+		case <-hedge:
+			launch(sent)
+			sent++
+			pending++
+			if sent < maxAttempts {
+				timer.Reset(delay)
+			} else {
+				hedge = nil
+			}
+		case r := <-results:
+			pending--
+			if r.attempt == 0 {
+				firstDone = true
+			}
+			if r.err == nil {
+				if r.attempt == 0 {
+					p.observe(method, r.latency)
+				} else if !firstDone {
+					// the first attempt is canceled, it would have taken
+					// at least this long
+					p.observe(method, time.Since(first))
+				}
+				return r.attempt, sent - 1, nil
+			}
+			if pending == 0 {
+				return r.attempt, sent - 1, r.err
+			}
+		}
+	}
+}
+
+// recordHedging counts the hedges sent for a call and the attempt that won,
+// a failed call has no winner.
+func recordHedging(ctx context.Context, metrics ContextMetrics, hedges, winner int, err error) {
+	if hedges > 0 {
+		metrics.IncCounter(ctx, clientHedges, int64(hedges))
+	}
+	if err != nil {
+		return
+	}
+	ctx = WithScopeTagsDefault(ctx, map[string]string{
+		scopeTagAttempt: strconv.Itoa(winner),
+	}, metrics.Scope())
+	metrics.IncCounter(ctx, clientHedgeWinner, 1)
+}
+
+// latencyWindow keeps the most recent latencies of a method, the percentile
+// is cached and only sorted again after hedgingMinSamples new latencies.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration // protected by mu
+	next    int             // protected by mu
+	count   int             // protected by mu
+	added   int             // protected by mu
+
+	cached           time.Duration // protected by mu
+	cachedPercentile float64       // protected by mu
+	cachedAdded      int           // protected by mu
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % len(w.samples)
+	if w.count < len(w.samples) {
+		w.count++
+	}
+	w.added++
+}
+
+// percentile returns the given percentile of the window, false when the
+// window is nil or has too few samples.
+func (w *latencyWindow) percentile(percentile float64) (time.Duration, bool) {
+	if w == nil {
+		return 0, false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.count < hedgingMinSamples {
+		return 0, false
+	}
+	if w.cachedAdded > 0 && w.cachedPercentile == percentile && w.added-w.cachedAdded < hedgingMinSamples {
+		return w.cached, true
+	}
+
+	sorted := make([]time.Duration, w.count)
+	copy(sorted, w.samples[:w.count])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	} else if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	w.cached, w.cachedPercentile, w.cachedAdded = sorted[rank], percentile, w.added
+	return w.cached, true
+}
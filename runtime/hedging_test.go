@@ -0,0 +1,211 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runAttempt runs attempt on the goroutine of the attempt, with no snapshot.
+func runAttempt(attempt func(ctx context.Context, n int) error) func(ctx context.Context, n int) func() error {
+	return func(ctx context.Context, n int) func() error {
+		return func() error {
+			return attempt(ctx, n)
+		}
+	}
+}
+
+func TestNewHedgingPolicy(t *testing.T) {
+	assert.Nil(t, NewHedgingPolicy(NewStaticConfigOrDie(nil, nil), "bar"))
+	assert.Nil(t, NewHedgingPolicy(NewStaticConfigOrDie(nil, map[string]interface{}{
+		"clients.bar.hedging.methods": []string{},
+	}), "bar"))
+
+	p := NewHedgingPolicy(NewStaticConfigOrDie(nil, map[string]interface{}{
+		"clients.bar.hedging.methods":         []string{"Normal"},
+		"clients.bar.hedging.delay":           50,
+		"clients.bar.hedging.delayPercentile": 95,
+		"clients.bar.hedging.maxHedges":       2,
+	}), "bar")
+	require.NotNil(t, p)
+	assert.True(t, p.covers("Normal"))
+	assert.False(t, p.covers("Hello"))
+	assert.Equal(t, 50*time.Millisecond, p.Delay)
+	assert.Equal(t, float64(95), p.DelayPercentile)
+	assert.Equal(t, 2, p.MaxHedges)
+
+	var nilPolicy *HedgingPolicy
+	assert.False(t, nilPolicy.covers("Normal"))
+}
+
+func TestHedgingFastAttemptWins(t *testing.T) {
+	p := &HedgingPolicy{Methods: map[string]bool{"m": true}, Delay: 10 * time.Millisecond, MaxHedges: 2}
+
+	var canceled int32
+	winner, hedges, err := p.do(context.Background(), "m", runAttempt(func(ctx context.Context, n int) error {
+		if n == 0 {
+			<-ctx.Done()
+			atomic.AddInt32(&canceled, 1)
+			return ctx.Err()
+		}
+		return nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, winner)
+	assert.Equal(t, 1, hedges)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&canceled) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestHedgingNoHedgeWhenFast(t *testing.T) {
+	p := &HedgingPolicy{Methods: map[string]bool{"m": true}, Delay: time.Second, MaxHedges: 1}
+	var attempts int32
+	winner, hedges, err := p.do(context.Background(), "m", runAttempt(func(ctx context.Context, n int) error {
+		atomic.AddInt32(&attempts, 1)
+		return nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 0, winner)
+	assert.Equal(t, 0, hedges)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestHedgingFailuresAreNotHedged(t *testing.T) {
+	p := &HedgingPolicy{Methods: map[string]bool{"m": true}, Delay: time.Second, MaxHedges: 1}
+	failure := errors.New("failed")
+	winner, hedges, err := p.do(context.Background(), "m", runAttempt(func(ctx context.Context, n int) error {
+		return failure
+	}))
+	assert.Equal(t, failure, err)
+	assert.Equal(t, 0, winner)
+	assert.Equal(t, 0, hedges)
+}
+
+func TestHedgingWaitsForPendingAttempts(t *testing.T) {
+	p := &HedgingPolicy{Methods: map[string]bool{"m": true}, Delay: 10 * time.Millisecond, MaxHedges: 1}
+	release := make(chan struct{})
+	winner, hedges, err := p.do(context.Background(), "m", runAttempt(func(ctx context.Context, n int) error {
+		if n == 0 {
+			<-release
+			return nil
+		}
+		close(release)
+		return errors.New("hedge failed")
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 0, winner)
+	assert.Equal(t, 1, hedges)
+}
+
+func TestHedgingDelayPercentile(t *testing.T) {
+	p := &HedgingPolicy{Methods: map[string]bool{"m": true}, DelayPercentile: 90, MaxHedges: 1}
+
+	// without samples nor a fixed delay nothing is hedged
+	_, ok := p.delay("m")
+	assert.False(t, ok)
+
+	for i := 1; i <= hedgingMinSamples; i++ {
+		p.observe("m", time.Duration(i)*time.Millisecond)
+	}
+	delay, ok := p.delay("m")
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Millisecond, delay)
+
+	// the percentile is cached until enough new samples arrive
+	for i := 0; i < hedgingMinSamples-1; i++ {
+		p.observe("m", time.Second)
+	}
+	delay, _ = p.delay("m")
+	assert.Equal(t, 90*time.Millisecond, delay)
+	p.observe("m", time.Second)
+	delay, _ = p.delay("m")
+	assert.Equal(t, time.Second, delay)
+}
+
+func TestHedgingObservesFirstAttempts(t *testing.T) {
+	p := &HedgingPolicy{
+		Methods:         map[string]bool{"m": true},
+		Delay:           10 * time.Millisecond,
+		DelayPercentile: 50,
+		MaxHedges:       1,
+	}
+	winner, _, err := p.do(context.Background(), "m", runAttempt(func(ctx context.Context, n int) error {
+		if n == 0 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, winner)
+
+	// the canceled first attempt is sampled, not the faster hedge
+	window := p.latencies["m"]
+	require.NotNil(t, window)
+	assert.Equal(t, 1, window.count)
+	assert.True(t, window.samples[0] >= 10*time.Millisecond, window.samples[0])
+
+	_, _, err = p.do(context.Background(), "m", runAttempt(func(ctx context.Context, n int) error {
+		return nil
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 2, window.count)
+	assert.True(t, window.samples[1] < 10*time.Millisecond, window.samples[1])
+}
+
+func TestHedgingSnapshotsBeforeLaunch(t *testing.T) {
+	p := &HedgingPolicy{Methods: map[string]bool{"m": true}, Delay: time.Millisecond, MaxHedges: 1}
+	headers := map[string]string{"x-foo": "bar"}
+
+	launched := make(chan struct{})
+	hedgeDone := make(chan string, 1)
+	winner, _, err := p.do(context.Background(), "m", func(ctx context.Context, n int) func() error {
+		snapshot := make(map[string]string, len(headers))
+		for k, v := range headers {
+			snapshot[k] = v
+		}
+		if n == 1 {
+			close(launched)
+		}
+		return func() error {
+			if n == 0 {
+				// win once the hedge is launched, likely before it runs
+				<-launched
+				return nil
+			}
+			<-ctx.Done()
+			hedgeDone <- snapshot["x-foo"]
+			return ctx.Err()
+		}
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, winner)
+
+	// the caller reuses its state once do returns
+	headers["x-foo"] = "baz"
+	assert.Equal(t, "bar", <-hedgeDone)
+}
@@ -24,6 +24,7 @@ import (
 	"context"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/uber-go/tally"
@@ -39,13 +40,26 @@ type CheckRetry func(ctx context.Context, timeoutAndRetryOptions *TimeoutAndRetr
 
 // HTTPClient defines a http client.
 type HTTPClient struct {
-	Client         *http.Client
 	BaseURL        string
 	DefaultHeaders map[string]string
 	JSONWrapper    jsonwrapper.JSONWrapper
 	ContextLogger  ContextLogger
 	contextMetrics ContextMetrics
 	CheckRetry     CheckRetry
+	// RetryPolicy, when set, replaces CheckRetry to decide which failed
+	// attempts are retried and how long to back off.
+	RetryPolicy RetryPolicy
+	// Hedging sends hedged requests for the methods it covers, nil
+	// disables hedging.
+	Hedging *HedgingPolicy
+	// Hosts spreads requests over a static host list instead of the host
+	// of BaseURL, nil sends every request to BaseURL.
+	Hosts *HTTPHostPool
+
+	// client is swapped when the timeout or the transport is changed at
+	// runtime, it is guarded by clientMu and read with Client
+	client   *http.Client
+	clientMu sync.RWMutex
 }
 
 // UnexpectedHTTPError defines an error for HTTP
@@ -104,12 +118,8 @@ func NewHTTPClientContext(
 	}
 
 	return &HTTPClient{
-		Client: &http.Client{
-			Transport: &http.Transport{
-				DisableKeepAlives:   false,
-				MaxIdleConns:        500,
-				MaxIdleConnsPerHost: 500,
-			},
+		client: &http.Client{
+			Transport:     DefaultHTTPTransportOptions.NewTransport(),
 			Timeout:       timeout,
 			CheckRedirect: checkRedirect,
 		},
@@ -122,6 +132,24 @@ func NewHTTPClientContext(
 	}
 }
 
+// SetTimeout changes the client level timeout for subsequent requests,
+// requests in flight keep the timeout they started with.
+func (c *HTTPClient) SetTimeout(timeout time.Duration) {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+	client := *c.client
+	client.Timeout = timeout
+	c.client = &client
+}
+
+// Client returns the current underlying http.Client, it must not be
+// modified since SetTimeout and SetTransport swap it for a copy.
+func (c *HTTPClient) Client() *http.Client {
+	c.clientMu.RLock()
+	defer c.clientMu.RUnlock()
+	return c.client
+}
+
 // DefaultRetryPolicy allows retries for any type of server error
 func DefaultRetryPolicy(ctx context.Context, timeoutAndRetryOptions *TimeoutAndRetryOptions, resp *http.Response, err error) bool {
 	// do not retry on context.Canceled or context.DeadlineExceeded
@@ -0,0 +1,338 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+const (
+	// HTTPLoadBalancerRoundRobin sends requests to the hosts in turn.
+	HTTPLoadBalancerRoundRobin = "roundRobin"
+	// HTTPLoadBalancerLeastOutstanding sends requests to the host with the
+	// fewest requests in flight.
+	HTTPLoadBalancerLeastOutstanding = "leastOutstanding"
+	// HTTPLoadBalancerP2C sends requests to the host with fewer requests in
+	// flight out of two hosts picked at random.
+	HTTPLoadBalancerP2C = "p2c"
+
+	defaultOutlierConsecutiveFailures = 5
+	defaultOutlierBaseEjectionTime    = 30 * time.Second
+	defaultOutlierMaxEjectionTime     = 300 * time.Second
+	defaultOutlierMaxEjectionPercent  = 50
+
+	clientHostEjected  = "client.host.ejected"
+	clientHostReturned = "client.host.returned"
+	clientHostsEjected = "client.hosts.ejected"
+
+	scopeTagHost = "host"
+)
+
+// HTTPHostPool spreads the requests of an HTTP client over a static list of
+// hosts. A host that fails ConsecutiveFailures requests in a row, with a 5xx
+// response, an error or a timeout, is ejected from the pool for
+// BaseEjectionTime times the number of times it has been ejected, capped at
+// MaxEjectionTime.
+type HTTPHostPool struct {
+	// LoadBalancer is one of HTTPLoadBalancerRoundRobin,
+	// HTTPLoadBalancerLeastOutstanding or HTTPLoadBalancerP2C.
+	LoadBalancer string
+	// ConsecutiveFailures ejects a host after this many failed requests in
+	// a row, 0 disables ejection.
+	ConsecutiveFailures int
+	BaseEjectionTime    time.Duration
+	MaxEjectionTime     time.Duration
+	// MaxEjectionPercent is the maximum percentage of the hosts ejected at
+	// the same time.
+	MaxEjectionPercent int
+
+	clientID string
+	logger   *zap.Logger
+	scope    tally.Scope
+	now      func() time.Time
+	random   func(n int) int
+
+	mu    sync.Mutex
+	hosts []*poolHost // protected by mu
+	next  int         // protected by mu
+}
+
+// hostOutcome is the outcome of a request sent to a host of the pool.
+type hostOutcome int
+
+const (
+	hostSucceeded hostOutcome = iota
+	hostFailed
+	// hostCanceled is a request canceled by the caller, such as the loser
+	// of a hedged request, which says nothing about the host.
+	hostCanceled
+)
+
+type poolHost struct {
+	addr         string
+	outstanding  int
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// NewHTTPHostPool returns the host pool of a client from
+// "clients.<clientID>.hostList", a list of host:port, or nil when the client
+// has no host list. A client with a peer provider gets an empty pool whose
+// hosts are set by the provider. The load balancer is set by
+// "clients.<clientID>.loadBalancer" and the ejection of outliers by
+// "clients.<clientID>.outlierDetection.consecutiveFailures",
+// "baseEjectionTime" and "maxEjectionTime" in ms and "maxEjectionPercent".
+// It panics when the load balancer is unknown.
+func NewHTTPHostPool(config ConfigReader, clientID string, logger *zap.Logger, scope tally.Scope) *HTTPHostPool {
+	prefix := "clients." + clientID + "."
+	var addrs []string
+	if config.ContainsKey(prefix + "hostList") {
+		config.MustGetStruct(prefix+"hostList", &addrs)
+	}
+	if len(addrs) == 0 && !config.ContainsKey(prefix+"peerProvider.type") {
+		return nil
+	}
+
+	p := &HTTPHostPool{
+		LoadBalancer:        HTTPLoadBalancerRoundRobin,
+		ConsecutiveFailures: defaultOutlierConsecutiveFailures,
+		BaseEjectionTime:    defaultOutlierBaseEjectionTime,
+		MaxEjectionTime:     defaultOutlierMaxEjectionTime,
+		MaxEjectionPercent:  defaultOutlierMaxEjectionPercent,
+		clientID:            clientID,
+		logger:              logger,
+		scope:               scope,
+		now:                 time.Now,
+		random:              rand.Intn,
+	}
+	if config.ContainsKey(prefix + "loadBalancer") {
+		p.LoadBalancer = config.MustGetString(prefix + "loadBalancer")
+	}
+	switch p.LoadBalancer {
+	case HTTPLoadBalancerRoundRobin, HTTPLoadBalancerLeastOutstanding, HTTPLoadBalancerP2C:
+	default:
+		panic(errors.Errorf("unknown load balancer %q for client %s", p.LoadBalancer, clientID))
+	}
+	if config.ContainsKey(prefix + "outlierDetection.consecutiveFailures") {
+		p.ConsecutiveFailures = int(config.MustGetInt(prefix + "outlierDetection.consecutiveFailures"))
+	}
+	if config.ContainsKey(prefix + "outlierDetection.baseEjectionTime") {
+		p.BaseEjectionTime = time.Duration(config.MustGetInt(prefix+"outlierDetection.baseEjectionTime")) * time.Millisecond
+	}
+	if config.ContainsKey(prefix + "outlierDetection.maxEjectionTime") {
+		p.MaxEjectionTime = time.Duration(config.MustGetInt(prefix+"outlierDetection.maxEjectionTime")) * time.Millisecond
+	}
+	if config.ContainsKey(prefix + "outlierDetection.maxEjectionPercent") {
+		p.MaxEjectionPercent = int(config.MustGetInt(prefix + "outlierDetection.maxEjectionPercent"))
+	}
+	for _, addr := range addrs {
+		p.hosts = append(p.hosts, &poolHost{addr: addr})
+	}
+	return p
+}
+
+// SetHosts replaces the hosts of the pool, the hosts that stay keep their
+// requests in flight and ejection state.
+func (p *HTTPHostPool) SetHosts(addrs []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing := make(map[string]*poolHost, len(p.hosts))
+	for _, host := range p.hosts {
+		existing[host.addr] = host
+	}
+	hosts := make([]*poolHost, 0, len(addrs))
+	for _, addr := range addrs {
+		host, ok := existing[addr]
+		if !ok {
+			host = &poolHost{addr: addr}
+		}
+		hosts = append(hosts, host)
+	}
+	p.hosts = hosts
+	p.updateEjectedLocked()
+}
+
+// pick returns the host of the next request, the request must be released.
+// It returns nil when the pool has no hosts.
+func (p *HTTPHostPool) pick() *poolHost {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.hosts) == 0 {
+		return nil
+	}
+
+	now := p.now()
+	healthy := make([]*poolHost, 0, len(p.hosts))
+	for _, host := range p.hosts {
+		if !host.ejectedUntil.IsZero() && !now.Before(host.ejectedUntil) {
+			p.returnLocked(host)
+		}
+		if host.ejectedUntil.IsZero() {
+			healthy = append(healthy, host)
+		}
+	}
+	// every host is ejected, spread the requests over all of them
+	if len(healthy) == 0 {
+		healthy = p.hosts
+	}
+
+	var host *poolHost
+	switch n := len(healthy); {
+	case n == 1:
+		host = healthy[0]
+	case p.LoadBalancer == HTTPLoadBalancerLeastOutstanding:
+		// start from the next host in turn so that ties are spread out
+		for i := 0; i < n; i++ {
+			candidate := healthy[(p.next+i)%n]
+			if host == nil || candidate.outstanding < host.outstanding {
+				host = candidate
+			}
+		}
+		p.next++
+	case p.LoadBalancer == HTTPLoadBalancerP2C:
+		a, b := p.random(n), p.random(n-1)
+		if b >= a {
+			b++
+		}
+		host = healthy[a]
+		if healthy[b].outstanding < host.outstanding {
+			host = healthy[b]
+		}
+	default:
+		host = healthy[p.next%n]
+		p.next++
+	}
+	host.outstanding++
+	return host
+}
+
+// release records the outcome of a request sent to host, failed requests
+// count toward ejecting the host and a successful one resets the count.
+func (p *HTTPHostPool) release(host *poolHost, outcome hostOutcome) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	host.outstanding--
+	switch outcome {
+	case hostCanceled:
+		return
+	case hostSucceeded:
+		host.failures = 0
+		return
+	}
+	host.failures++
+	if p.ConsecutiveFailures <= 0 || host.failures < p.ConsecutiveFailures || !host.ejectedUntil.IsZero() {
+		return
+	}
+	if (p.ejectedLocked()+1)*100 > p.MaxEjectionPercent*len(p.hosts) {
+		return
+	}
+
+	host.ejections++
+	host.failures = 0
+	ejection := p.BaseEjectionTime * time.Duration(host.ejections)
+	if p.MaxEjectionTime > 0 && ejection > p.MaxEjectionTime {
+		ejection = p.MaxEjectionTime
+	}
+	host.ejectedUntil = p.now().Add(ejection)
+
+	p.hostScope(host).Counter(clientHostEjected).Inc(1)
+	p.updateEjectedLocked()
+	p.logger.Warn("Ejected outlier host",
+		zap.String(logFieldClientID, p.clientID),
+		zap.String("host", host.addr),
+		zap.Duration("ejection", ejection),
+	)
+}
+
+// returnLocked brings an ejected host back into the pool, p.mu must be held.
+func (p *HTTPHostPool) returnLocked(host *poolHost) {
+	host.ejectedUntil = time.Time{}
+	host.failures = 0
+	p.hostScope(host).Counter(clientHostReturned).Inc(1)
+	p.updateEjectedLocked()
+	p.logger.Info("Returned ejected host",
+		zap.String(logFieldClientID, p.clientID),
+		zap.String("host", host.addr),
+	)
+}
+
+func (p *HTTPHostPool) ejectedLocked() int {
+	var ejected int
+	for _, host := range p.hosts {
+		if !host.ejectedUntil.IsZero() {
+			ejected++
+		}
+	}
+	return ejected
+}
+
+func (p *HTTPHostPool) updateEjectedLocked() {
+	p.scope.Tagged(map[string]string{
+		scopeTagClient: p.clientID,
+	}).Gauge(clientHostsEjected).Update(float64(p.ejectedLocked()))
+}
+
+func (p *HTTPHostPool) hostScope(host *poolHost) tally.Scope {
+	return p.scope.Tagged(map[string]string{
+		scopeTagClient: p.clientID,
+		scopeTagHost:   host.addr,
+	})
+}
+
+// do sends the request to the next host of the pool, or to the host of the
+// request URL when the client has no host pool.
+func (c *HTTPClient) do(req *http.Request) (*http.Response, error) {
+	if c.Hosts == nil {
+		return c.Client().Do(req)
+	}
+
+	host := c.Hosts.pick()
+	if host == nil {
+		return nil, errors.Errorf("no hosts available for client %s", c.Hosts.clientID)
+	}
+	u := *req.URL
+	u.Host = host.addr
+	req = req.WithContext(req.Context())
+	req.URL = &u
+	req.Host = host.addr
+
+	res, err := c.Client().Do(req)
+	outcome := hostSucceeded
+	switch {
+	case err != nil && req.Context().Err() == context.Canceled:
+		outcome = hostCanceled
+	case err != nil || res.StatusCode >= http.StatusInternalServerError:
+		outcome = hostFailed
+	}
+	c.Hosts.release(host, outcome)
+	return res, err
+}
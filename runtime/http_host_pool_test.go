@@ -0,0 +1,254 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+func newTestHostPool(t *testing.T, config string) (*HTTPHostPool, *time.Time, tally.TestScope) {
+	scope := tally.NewTestScope("", nil)
+	pool := NewHTTPHostPool(NewStaticConfigOrDie([]*ConfigOption{
+		ConfigFileContents([]byte(config)),
+	}, nil), "foo", zap.NewNop(), scope)
+	require.NotNil(t, pool)
+	now := time.Unix(1000, 0)
+	pool.now = func() time.Time { return now }
+	return pool, &now, scope
+}
+
+func pickAddrs(pool *HTTPHostPool, n int) []string {
+	addrs := make([]string, n)
+	for i := range addrs {
+		host := pool.pick()
+		addrs[i] = host.addr
+		pool.release(host, hostSucceeded)
+	}
+	return addrs
+}
+
+func TestNewHTTPHostPool(t *testing.T) {
+	config := NewStaticConfigOrDie([]*ConfigOption{
+		ConfigFileContents([]byte(`
+clients.foo.hostList: ["a:1", "b:2"]
+clients.foo.loadBalancer: p2c
+clients.foo.outlierDetection.consecutiveFailures: 3
+clients.foo.outlierDetection.baseEjectionTime: 100
+clients.foo.outlierDetection.maxEjectionTime: 1000
+clients.foo.outlierDetection.maxEjectionPercent: 100
+clients.bar.ip: 127.0.0.1
+`)),
+	}, nil)
+
+	pool := NewHTTPHostPool(config, "foo", zap.NewNop(), tally.NoopScope)
+	require.NotNil(t, pool)
+	assert.Equal(t, HTTPLoadBalancerP2C, pool.LoadBalancer)
+	assert.Equal(t, 3, pool.ConsecutiveFailures)
+	assert.Equal(t, 100*time.Millisecond, pool.BaseEjectionTime)
+	assert.Equal(t, time.Second, pool.MaxEjectionTime)
+	assert.Equal(t, 100, pool.MaxEjectionPercent)
+	assert.Len(t, pool.hosts, 2)
+
+	assert.Nil(t, NewHTTPHostPool(config, "bar", zap.NewNop(), tally.NoopScope))
+}
+
+func TestNewHTTPHostPoolUnknownLoadBalancer(t *testing.T) {
+	config := NewStaticConfigOrDie([]*ConfigOption{
+		ConfigFileContents([]byte(`
+clients.foo.hostList: ["a:1"]
+clients.foo.loadBalancer: random
+`)),
+	}, nil)
+
+	assert.PanicsWithError(t, `unknown load balancer "random" for client foo`, func() {
+		NewHTTPHostPool(config, "foo", zap.NewNop(), tally.NoopScope)
+	})
+}
+
+func TestHTTPHostPoolLoadBalancers(t *testing.T) {
+	pool, _, _ := newTestHostPool(t, `clients.foo.hostList: ["a", "b", "c"]`)
+	assert.Equal(t, []string{"a", "b", "c", "a"}, pickAddrs(pool, 4))
+
+	pool.LoadBalancer = HTTPLoadBalancerLeastOutstanding
+	// every host is idle, ties go to the next host in turn
+	b := pool.pick()
+	assert.Equal(t, "b", b.addr)
+	assert.Equal(t, "c", pool.pick().addr)
+	assert.Equal(t, "a", pool.pick().addr)
+	pool.release(b, hostSucceeded)
+	assert.Equal(t, "b", pool.pick().addr)
+	assert.Equal(t, "c", pool.pick().addr)
+
+	pool, _, _ = newTestHostPool(t, `clients.foo.hostList: ["a", "b", "c"]`)
+	pool.LoadBalancer = HTTPLoadBalancerP2C
+	picks := []int{0, 1, 2, 0}
+	pool.random = func(n int) int {
+		pick := picks[0]
+		picks = picks[1:]
+		return pick
+	}
+	// a and c are picked, both idle
+	a := pool.pick()
+	assert.Equal(t, "a", a.addr)
+	// c and a are picked, a has a request in flight
+	assert.Equal(t, "c", pool.pick().addr)
+}
+
+func TestHTTPHostPoolEjection(t *testing.T) {
+	pool, now, scope := newTestHostPool(t, `
+clients.foo.hostList: ["a", "b", "c"]
+clients.foo.outlierDetection.consecutiveFailures: 2
+clients.foo.outlierDetection.baseEjectionTime: 1000
+clients.foo.outlierDetection.maxEjectionTime: 1500
+clients.foo.outlierDetection.maxEjectionPercent: 34
+`)
+	record := func(addr string, outcome hostOutcome) *poolHost {
+		for _, host := range pool.hosts {
+			if host.addr == addr {
+				host.outstanding++
+				pool.release(host, outcome)
+				return host
+			}
+		}
+		return nil
+	}
+
+	// a success resets the consecutive failures
+	a := record("a", hostFailed)
+	record("a", hostSucceeded)
+	record("a", hostFailed)
+	assert.True(t, a.ejectedUntil.IsZero())
+	record("a", hostFailed)
+	assert.False(t, a.ejectedUntil.IsZero())
+	assert.NotContains(t, pickAddrs(pool, 4), "a")
+
+	// no more than a third of the hosts are ejected
+	b := record("b", hostFailed)
+	record("b", hostFailed)
+	assert.True(t, b.ejectedUntil.IsZero())
+
+	// canceled requests do not count
+	c := record("c", hostFailed)
+	record("c", hostCanceled)
+	assert.Equal(t, 1, c.failures)
+
+	*now = now.Add(time.Second)
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, pickAddrs(pool, 3))
+
+	// the second ejection is longer, up to the max
+	record("a", hostFailed)
+	record("a", hostFailed)
+	*now = now.Add(time.Second)
+	assert.NotContains(t, pickAddrs(pool, 4), "a")
+	*now = now.Add(500 * time.Millisecond)
+	assert.Contains(t, pickAddrs(pool, 3), "a")
+
+	counters := scope.Snapshot().Counters()
+	tags := "+clientid=foo,host=a"
+	assert.Equal(t, int64(2), counters[clientHostEjected+tags].Value())
+	assert.Equal(t, int64(2), counters[clientHostReturned+tags].Value())
+	_, ok := counters[clientHostEjected+"+clientid=foo,host=b"]
+	assert.False(t, ok)
+	assert.Equal(t, float64(0), scope.Snapshot().Gauges()[clientHostsEjected+"+clientid=foo"].Value())
+}
+
+func TestHTTPHostPoolAllEjected(t *testing.T) {
+	pool, _, _ := newTestHostPool(t, `
+clients.foo.hostList: ["a"]
+clients.foo.outlierDetection.consecutiveFailures: 1
+clients.foo.outlierDetection.maxEjectionPercent: 100
+`)
+	host := pool.pick()
+	pool.release(host, hostFailed)
+	assert.False(t, host.ejectedUntil.IsZero())
+	assert.Equal(t, []string{"a"}, pickAddrs(pool, 1))
+}
+
+func TestHTTPClientHostPool(t *testing.T) {
+	var good, bad int32
+	goodServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&good, 1)
+		assert.Equal(t, "/path", r.URL.Path)
+	}))
+	defer goodServer.Close()
+	badServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&bad, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer badServer.Close()
+
+	pool, _, _ := newTestHostPool(t, `
+clients.foo.hostList: ["`+badServer.Listener.Addr().String()+`", "`+goodServer.Listener.Addr().String()+`"]
+clients.foo.outlierDetection.consecutiveFailures: 1
+`)
+	client := &HTTPClient{client: &http.Client{}, BaseURL: "http://foo", Hosts: pool}
+
+	statuses := make([]int, 4)
+	for i := range statuses {
+		req, err := http.NewRequest("GET", client.BaseURL+"/path", nil)
+		require.NoError(t, err)
+		res, err := client.do(req.WithContext(context.Background()))
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		statuses[i] = res.StatusCode
+	}
+	assert.Equal(t, []int{http.StatusBadGateway, 200, 200, 200}, statuses)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&bad))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&good))
+	for _, host := range pool.hosts {
+		assert.Equal(t, 0, host.outstanding)
+	}
+}
+
+func TestHTTPHostPoolSetHosts(t *testing.T) {
+	pool, _, _ := newTestHostPool(t, `
+clients.foo.peerProvider.type: file
+clients.foo.outlierDetection.consecutiveFailures: 1
+clients.foo.outlierDetection.maxEjectionPercent: 100
+`)
+	assert.Nil(t, pool.pick())
+	client := &HTTPClient{client: &http.Client{}, BaseURL: "http://foo", Hosts: pool}
+	req, err := http.NewRequest("GET", client.BaseURL+"/path", nil)
+	require.NoError(t, err)
+	_, err = client.do(req)
+	assert.EqualError(t, err, "no hosts available for client foo")
+
+	pool.SetHosts([]string{"a", "b"})
+	a := pool.pick()
+	require.Equal(t, "a", a.addr)
+	pool.release(a, hostFailed)
+	assert.False(t, a.ejectedUntil.IsZero())
+
+	// the hosts that stay keep their ejection
+	pool.SetHosts([]string{"a", "c"})
+	assert.Equal(t, []string{"c", "c"}, pickAddrs(pool, 2))
+	assert.Same(t, a, pool.hosts[0])
+}
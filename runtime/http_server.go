@@ -23,10 +23,12 @@ package zanzibar
 import (
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
@@ -39,6 +41,12 @@ type HTTPServer struct {
 	listeningSocket net.Listener
 	closing         bool
 
+	// SocketPath is a Unix domain socket to listen on instead of Addr,
+	// the socket file is removed when the server is closed.
+	SocketPath string
+	// SocketMode is the file mode of the Unix domain socket.
+	SocketMode os.FileMode
+
 	RealPort int32
 	RealIP   string
 	RealAddr string
@@ -46,6 +54,10 @@ type HTTPServer struct {
 
 // JustListen will only listen on port and query real addr
 func (server *HTTPServer) JustListen() (net.Listener, error) {
+	if server.SocketPath != "" {
+		return server.listenUnix()
+	}
+
 	addr := server.Addr
 	if addr == "" {
 		/* coverage ignore next line */
@@ -67,11 +79,50 @@ func (server *HTTPServer) JustListen() (net.Listener, error) {
 	return ln, nil
 }
 
+// listenUnix listens on the Unix domain socket, replacing the socket file
+// left behind by a previous process.
+func (server *HTTPServer) listenUnix() (net.Listener, error) {
+	if info, err := os.Lstat(server.SocketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, errors.Errorf("can not listen on %s: file exists and is not a socket", server.SocketPath)
+		}
+		if err := os.Remove(server.SocketPath); err != nil {
+			return nil, errors.Wrapf(err, "can not remove stale socket %s", server.SocketPath)
+		}
+	}
+
+	ln, err := net.Listen("unix", server.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+	if server.SocketMode != 0 {
+		if err := os.Chmod(server.SocketPath, server.SocketMode); err != nil {
+			_ = ln.Close()
+			return nil, errors.Wrapf(err, "can not change the mode of socket %s", server.SocketPath)
+		}
+	}
+	server.listeningSocket = ln
+
+	server.RealPort = 0
+	server.RealIP = ""
+	server.RealAddr = server.SocketPath
+	return ln, nil
+}
+
 // JustServe will serve all incoming requests
 func (server *HTTPServer) JustServe(waitGroup *sync.WaitGroup) {
-	ln := server.listeningSocket.(*net.TCPListener)
+	ln := server.listeningSocket
+	if tcpListener, ok := ln.(*net.TCPListener); ok {
+		ln = tcpKeepAliveListener{tcpListener}
+	}
 
-	err := server.Serve(tcpKeepAliveListener{ln})
+	var err error
+	if server.TLSConfig != nil {
+		// certificates are provided by the TLS config
+		err = server.ServeTLS(ln, "", "")
+	} else {
+		err = server.Serve(ln)
+	}
 	if err != nil && !server.closing {
 		/* coverage ignore next line */
 		server.Logger.Error("Error http serving", zap.Error(err))
@@ -0,0 +1,110 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newUnixSocketServer(t *testing.T, socketPath string) *HTTPServer {
+	return &HTTPServer{
+		Server: &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			}),
+		},
+		Logger:     zap.NewNop(),
+		SocketPath: socketPath,
+		SocketMode: 0600,
+	}
+}
+
+func TestHTTPServerUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "http-socket")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	socketPath := filepath.Join(dir, "gateway.sock")
+
+	// a socket left behind by a previous process is replaced
+	stale, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	server := newUnixSocketServer(t, socketPath)
+	_, err = server.JustListen()
+	require.NoError(t, err)
+	assert.Equal(t, socketPath, server.RealAddr)
+	assert.Equal(t, int32(0), server.RealPort)
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go server.JustServe(&wg)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	res, err := client.Get("http://unix/")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, "ok", string(body))
+
+	// the socket file is removed on close
+	server.Close()
+	wg.Wait()
+	_, err = os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHTTPServerUnixSocketNotASocket(t *testing.T) {
+	file, err := ioutil.TempFile("", "http-socket")
+	require.NoError(t, err)
+	defer func() { _ = os.Remove(file.Name()) }()
+	_ = file.Close()
+
+	_, err = newUnixSocketServer(t, file.Name()).JustListen()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "file exists and is not a socket")
+
+	_, err = os.Stat(file.Name())
+	assert.NoError(t, err, "regular files are not removed")
+}
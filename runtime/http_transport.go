@@ -0,0 +1,216 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+const (
+	clientConnReused          = "client.conn.reused"
+	clientConnNew             = "client.conn.new"
+	clientDNSLatency          = "client.dns.latency"
+	clientConnectLatency      = "client.connect.latency"
+	clientConnectErrors       = "client.connect.errors"
+	clientTLSHandshakeLatency = "client.tls-handshake.latency"
+	clientTLSHandshakeErrors  = "client.tls-handshake.errors"
+)
+
+// HTTPTransportOptions configures the connections of an HTTP client.
+type HTTPTransportOptions struct {
+	// MaxIdleConns limits the idle connections across all hosts, 0 means
+	// no limit.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost limits the idle connections kept to each host.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections to each host, including the
+	// ones in use, 0 means no limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout closes connections idle for longer, 0 means never.
+	IdleConnTimeout time.Duration
+	// DialTimeout limits the time to connect, 0 means no timeout.
+	DialTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes, 0 uses the
+	// default of the net package and a negative value disables them.
+	KeepAlive time.Duration
+	// TLSHandshakeTimeout limits the TLS handshake, 0 means no timeout.
+	TLSHandshakeTimeout time.Duration
+	// HTTP2 lets requests over TLS negotiate HTTP/2.
+	HTTP2 bool
+	// TLSConfig configures the TLS connections, nil uses the defaults of
+	// crypto/tls.
+	TLSConfig *tls.Config
+}
+
+// DefaultHTTPTransportOptions are the transport options of a client that
+// configures none.
+var DefaultHTTPTransportOptions = HTTPTransportOptions{
+	MaxIdleConns:        500,
+	MaxIdleConnsPerHost: 500,
+	HTTP2:               true,
+}
+
+// NewHTTPTransportOptions returns the transport options of a client from
+// "clients.<clientID>.transport.maxIdleConns", "maxIdleConnsPerHost",
+// "maxConnsPerHost", "idleConnTimeout", "dialTimeout", "keepAlive" and
+// "tlsHandshakeTimeout" in ms and "http2", falling back to
+// DefaultHTTPTransportOptions for the ones that are not set.
+func NewHTTPTransportOptions(config ConfigReader, clientID string) HTTPTransportOptions {
+	prefix := "clients." + clientID + ".transport."
+	opts := DefaultHTTPTransportOptions
+
+	ints := map[string]*int{
+		"maxIdleConns":        &opts.MaxIdleConns,
+		"maxIdleConnsPerHost": &opts.MaxIdleConnsPerHost,
+		"maxConnsPerHost":     &opts.MaxConnsPerHost,
+	}
+	for key, value := range ints {
+		if config.ContainsKey(prefix + key) {
+			*value = int(config.MustGetInt(prefix + key))
+		}
+	}
+	durations := map[string]*time.Duration{
+		"idleConnTimeout":     &opts.IdleConnTimeout,
+		"dialTimeout":         &opts.DialTimeout,
+		"keepAlive":           &opts.KeepAlive,
+		"tlsHandshakeTimeout": &opts.TLSHandshakeTimeout,
+	}
+	for key, value := range durations {
+		if config.ContainsKey(prefix + key) {
+			*value = time.Duration(config.MustGetInt(prefix+key)) * time.Millisecond
+		}
+	}
+	if config.ContainsKey(prefix + "http2") {
+		opts.HTTP2 = config.MustGetBoolean(prefix + "http2")
+	}
+	return opts
+}
+
+// NewTransport returns an http.Transport with the options.
+func (o HTTPTransportOptions) NewTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   o.DialTimeout,
+		KeepAlive: o.KeepAlive,
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		DisableKeepAlives:   false,
+		MaxIdleConns:        o.MaxIdleConns,
+		MaxIdleConnsPerHost: o.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     o.MaxConnsPerHost,
+		IdleConnTimeout:     o.IdleConnTimeout,
+		TLSHandshakeTimeout: o.TLSHandshakeTimeout,
+		ForceAttemptHTTP2:   o.HTTP2,
+		TLSClientConfig:     o.TLSConfig,
+	}
+	if !o.HTTP2 {
+		// a non nil empty map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// SetTransport replaces the transport of the client for subsequent
+// requests and closes the idle connections of the previous one.
+func (c *HTTPClient) SetTransport(transport http.RoundTripper) {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+	if prev := c.client.Transport; prev != transport {
+		if closer, ok := prev.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+	client := *c.client
+	client.Transport = transport
+	c.client = &client
+}
+
+// traceConnection returns ctx with an httptrace.ClientTrace recording how
+// the connection of a request was obtained: whether it was reused and how
+// long DNS, connect and TLS handshake took.
+func (req *ClientHTTPRequest) traceConnection(ctx context.Context) context.Context {
+	var (
+		mu           sync.Mutex
+		dnsStart     time.Time
+		connectStart = map[string]time.Time{}
+		tlsStart     time.Time
+	)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				req.Metrics.IncCounter(req.ctx, clientConnReused, 1)
+			} else {
+				req.Metrics.IncCounter(req.ctx, clientConnNew, 1)
+			}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !dnsStart.IsZero() {
+				req.Metrics.RecordTimer(req.ctx, clientDNSLatency, time.Since(dnsStart))
+			}
+		},
+		// several addresses may be dialed in parallel
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			defer mu.Unlock()
+			connectStart[network+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				req.Metrics.IncCounter(req.ctx, clientConnectErrors, 1)
+				return
+			}
+			if start, ok := connectStart[network+addr]; ok {
+				req.Metrics.RecordTimer(req.ctx, clientConnectLatency, time.Since(start))
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				req.Metrics.IncCounter(req.ctx, clientTLSHandshakeErrors, 1)
+				return
+			}
+			if !tlsStart.IsZero() {
+				req.Metrics.RecordTimer(req.ctx, clientTLSHandshakeLatency, time.Since(tlsStart))
+			}
+		},
+	})
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func TestNewHTTPTransportOptions(t *testing.T) {
+	config := NewStaticConfigOrDie([]*ConfigOption{
+		ConfigFileContents([]byte(`
+clients.foo.transport.maxIdleConns: 100
+clients.foo.transport.maxIdleConnsPerHost: 10
+clients.foo.transport.maxConnsPerHost: 20
+clients.foo.transport.idleConnTimeout: 90000
+clients.foo.transport.dialTimeout: 500
+clients.foo.transport.keepAlive: -1
+clients.foo.transport.tlsHandshakeTimeout: 1000
+clients.foo.transport.http2: false
+`)),
+	}, nil)
+
+	opts := NewHTTPTransportOptions(config, "foo")
+	assert.Equal(t, HTTPTransportOptions{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		MaxConnsPerHost:     20,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         500 * time.Millisecond,
+		KeepAlive:           -time.Millisecond,
+		TLSHandshakeTimeout: time.Second,
+		HTTP2:               false,
+	}, opts)
+	assert.Equal(t, DefaultHTTPTransportOptions, NewHTTPTransportOptions(config, "bar"))
+
+	transport := opts.NewTransport()
+	assert.Equal(t, 100, transport.MaxIdleConns)
+	assert.Equal(t, 10, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 20, transport.MaxConnsPerHost)
+	assert.Equal(t, 90*time.Second, transport.IdleConnTimeout)
+	assert.Equal(t, time.Second, transport.TLSHandshakeTimeout)
+	assert.False(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.TLSNextProto)
+
+	transport = DefaultHTTPTransportOptions.NewTransport()
+	assert.True(t, transport.ForceAttemptHTTP2)
+	assert.Nil(t, transport.TLSNextProto)
+}
+
+func TestHTTPClientSetTransport(t *testing.T) {
+	client := &HTTPClient{client: &http.Client{
+		Transport: DefaultHTTPTransportOptions.NewTransport(),
+		Timeout:   time.Second,
+	}}
+	prev := client.Client()
+	transport := &http.Transport{}
+	client.SetTransport(transport)
+	assert.Equal(t, transport, client.Client().Transport)
+	assert.Equal(t, time.Second, client.Client().Timeout)
+	assert.NotEqual(t, transport, prev.Transport)
+}
+
+func TestHTTPClientReloadWhileInFlight(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &HTTPClient{client: &http.Client{
+		Transport: DefaultHTTPTransportOptions.NewTransport(),
+		Timeout:   time.Second,
+	}, BaseURL: server.URL}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				req, err := http.NewRequest("GET", client.BaseURL, nil)
+				if !assert.NoError(t, err) {
+					return
+				}
+				res, err := client.do(req)
+				if !assert.NoError(t, err) {
+					return
+				}
+				_ = res.Body.Close()
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			assert.Equal(t, 2*time.Second, client.Client().Timeout)
+			return
+		default:
+		}
+		client.SetTimeout(2 * time.Second)
+		client.SetTransport(DefaultHTTPTransportOptions.NewTransport())
+	}
+}
+
+func TestClientHTTPRequestTraceConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	scope := tally.NewTestScope("", nil)
+	req := &ClientHTTPRequest{
+		Metrics: NewContextMetrics(scope),
+		ctx:     context.Background(),
+	}
+	client := &http.Client{Transport: DefaultHTTPTransportOptions.NewTransport()}
+	for i := 0; i < 2; i++ {
+		httpReq, err := http.NewRequest("GET", server.URL, nil)
+		require.NoError(t, err)
+		res, err := client.Do(httpReq.WithContext(req.traceConnection(context.Background())))
+		require.NoError(t, err)
+		_ = res.Body.Close()
+	}
+
+	snapshot := scope.Snapshot()
+	assert.Equal(t, int64(1), snapshot.Counters()[clientConnNew+"+"].Value())
+	assert.Equal(t, int64(1), snapshot.Counters()[clientConnReused+"+"].Value())
+	assert.Len(t, snapshot.Timers()[clientConnectLatency+"+"].Values(), 1)
+	_, ok := snapshot.Counters()[clientConnectErrors+"+"]
+	assert.False(t, ok)
+}
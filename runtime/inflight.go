@@ -0,0 +1,132 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"sync"
+
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+const serverInflight = "server.inflight"
+
+// inflightTracker counts the inbound requests in progress by endpoint and
+// reports their total as a gauge.
+type inflightTracker struct {
+	mu     sync.Mutex
+	counts map[string]int64 // protected by mu
+	total  int64            // protected by mu
+	gauge  tally.Gauge
+}
+
+func newInflightTracker(scope tally.Scope, protocol string) *inflightTracker {
+	return &inflightTracker{
+		counts: map[string]int64{},
+		gauge: scope.Tagged(map[string]string{
+			scopeTagProtocol: protocol,
+		}).Gauge(serverInflight),
+	}
+}
+
+// begin counts a request to the endpoint as in flight until the returned
+// func is called. It is safe to call begin on a nil inflightTracker.
+func (t *inflightTracker) begin(endpoint string) func() {
+	if t == nil {
+		return func() {}
+	}
+	t.mu.Lock()
+	t.counts[endpoint]++
+	t.total++
+	t.gauge.Update(float64(t.total))
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		if t.counts[endpoint]--; t.counts[endpoint] == 0 {
+			delete(t.counts, endpoint)
+		}
+		t.total--
+		t.gauge.Update(float64(t.total))
+		t.mu.Unlock()
+	}
+}
+
+// count returns the number of requests in flight, it is safe to call count
+// on a nil inflightTracker.
+func (t *inflightTracker) count() int64 {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total
+}
+
+// snapshot returns the number of requests in flight by endpoint, endpoints
+// without requests in flight are left out.
+func (t *inflightTracker) snapshot() map[string]int64 {
+	counts := map[string]int64{}
+	if t == nil {
+		return counts
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for endpoint, count := range t.counts {
+		counts[endpoint] = count
+	}
+	return counts
+}
+
+// InflightRequests returns the number of inbound HTTP and TChannel requests
+// in progress by endpoint, HTTP endpoints are keyed by method and path and
+// TChannel endpoints by their Thrift method.
+func (gateway *Gateway) InflightRequests() map[string]int64 {
+	counts := gateway.httpInflight.snapshot()
+	for endpoint, count := range gateway.tchannelInflight.snapshot() {
+		counts[endpoint] += count
+	}
+	return counts
+}
+
+// inflightTotal returns the number of inbound HTTP and TChannel requests in
+// progress.
+func (gateway *Gateway) inflightTotal() int64 {
+	return gateway.httpInflight.count() + gateway.tchannelInflight.count()
+}
+
+// logInflightRequests logs the endpoints that still have requests in flight,
+// which are the requests cut off when the shutdown timeout is hit.
+func (gateway *Gateway) logInflightRequests() {
+	counts := gateway.InflightRequests()
+	if len(counts) == 0 {
+		return
+	}
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	gateway.Logger.Warn("Requests still in flight at shutdown timeout",
+		zap.Int64("inflight", total),
+		zap.Any("endpoints", counts),
+	)
+	gateway.RootScope.Counter("shutdown.inflight").Inc(total)
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+)
+
+func TestInflightTracker(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	tracker := newInflightTracker(scope, scopeTagHTTP)
+	gauge := func() float64 {
+		return scope.Snapshot().Gauges()[serverInflight+"+protocol=HTTP"].Value()
+	}
+
+	doneFoo1 := tracker.begin("GET /foo")
+	doneFoo2 := tracker.begin("GET /foo")
+	doneBar := tracker.begin("POST /bar")
+	assert.Equal(t, map[string]int64{"GET /foo": 2, "POST /bar": 1}, tracker.snapshot())
+	assert.Equal(t, float64(3), gauge())
+
+	doneFoo1()
+	doneBar()
+	assert.Equal(t, map[string]int64{"GET /foo": 1}, tracker.snapshot())
+	assert.Equal(t, float64(1), gauge())
+
+	doneFoo2()
+	assert.Empty(t, tracker.snapshot())
+	assert.Equal(t, float64(0), gauge())
+}
+
+func TestInflightTrackerNil(t *testing.T) {
+	var tracker *inflightTracker
+	assert.NotPanics(t, func() {
+		tracker.begin("GET /foo")()
+	})
+	assert.Empty(t, tracker.snapshot())
+}
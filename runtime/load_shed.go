@@ -0,0 +1,238 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultShedMaxLevel     = 4
+	defaultShedDefaultLevel = 2
+	defaultShedLevelStep    = 0.1
+
+	loadShedRejected = "loadshed.rejected"
+	loadShedLevel    = "loadshed.level"
+
+	scopeTagShedLevel = "level"
+)
+
+// Criticality ranks an endpoint for load shedding. Under load the gateway
+// sheds the highest levels first and moves down as the load grows, critical
+// endpoints are never shed.
+type Criticality int
+
+const (
+	// CriticalityDefault uses the qpsLevel of the endpoint when it has one
+	// and the "loadShedding.defaultLevel" otherwise.
+	CriticalityDefault Criticality = 0
+	// CriticalityCritical endpoints are never shed.
+	CriticalityCritical Criticality = -1
+	// CriticalityHigh endpoints are shed last.
+	CriticalityHigh Criticality = 1
+	// CriticalityNormal endpoints are shed after the low ones.
+	CriticalityNormal Criticality = 2
+	// CriticalityLow endpoints are shed after the sheddable ones.
+	CriticalityLow Criticality = 3
+	// CriticalitySheddable endpoints are shed first.
+	CriticalitySheddable Criticality = 4
+)
+
+// LoadShedder rejects the least critical requests first when the CPU
+// utilization, the goroutine count or the requests in flight cross their
+// thresholds. Each signal is read as a ratio of its threshold, the gateway
+// is overloaded when the highest ratio reaches 1.
+type LoadShedder struct {
+	stats    func() runtimeStats
+	inflight func() int64
+
+	cpuThreshold       float64
+	goroutineThreshold float64
+	inflightThreshold  float64
+	// maxLevel is the first level shed, every levelStep of load over the
+	// thresholds sheds one more level
+	maxLevel     int
+	defaultLevel int
+	levelStep    float64
+
+	level tally.Gauge
+}
+
+// NewLoadShedder returns the load shedder configured by the "loadShedding.*"
+// keys, it returns nil when "loadShedding.enabled" is not set. CPU and
+// goroutine thresholds need a runtime metrics collector with CPU metrics
+// enabled, it panics without one. The CPU utilization is only measured on
+// Unix platforms.
+func NewLoadShedder(
+	config *StaticConfig,
+	collector RuntimeMetricsCollector,
+	inflight func() int64,
+	scope tally.Scope,
+) *LoadShedder {
+	if !loadSheddingEnabled(config) {
+		return nil
+	}
+	s := &LoadShedder{
+		stats:        func() runtimeStats { return runtimeStats{} },
+		inflight:     inflight,
+		maxLevel:     defaultShedMaxLevel,
+		defaultLevel: defaultShedDefaultLevel,
+		levelStep:    defaultShedLevelStep,
+		level:        scope.Gauge(loadShedLevel),
+	}
+	thresholds := map[string]*float64{
+		"loadShedding.cpuThreshold":       &s.cpuThreshold,
+		"loadShedding.goroutineThreshold": &s.goroutineThreshold,
+		"loadShedding.inflightThreshold":  &s.inflightThreshold,
+		"loadShedding.levelStep":          &s.levelStep,
+	}
+	for key, value := range thresholds {
+		if config.ContainsKey(key) {
+			*value = config.MustGetFloat(key)
+		}
+	}
+	if c, ok := collector.(*runtimeCollector); ok && c.opts.EnableCPUMetrics {
+		s.stats = c.stats
+	} else if s.cpuThreshold > 0 || s.goroutineThreshold > 0 {
+		panic(errors.New(
+			"loadShedding.cpuThreshold and loadShedding.goroutineThreshold need the runtime metrics collector " +
+				"with CPU metrics enabled",
+		))
+	}
+	if config.ContainsKey("loadShedding.maxLevel") {
+		s.maxLevel = int(config.MustGetInt("loadShedding.maxLevel"))
+	}
+	if config.ContainsKey("loadShedding.defaultLevel") {
+		s.defaultLevel = int(config.MustGetInt("loadShedding.defaultLevel"))
+	}
+	return s
+}
+
+func loadSheddingEnabled(config *StaticConfig) bool {
+	return config != nil && config.ContainsKey("loadShedding.enabled") &&
+		config.MustGetBoolean("loadShedding.enabled")
+}
+
+// load returns the highest ratio of a signal to its threshold, unset
+// thresholds are left out.
+func (s *LoadShedder) load() float64 {
+	stats := s.stats()
+	var load float64
+	if s.cpuThreshold > 0 {
+		load = math.Max(load, stats.cpuUtilization/s.cpuThreshold)
+	}
+	if s.goroutineThreshold > 0 {
+		load = math.Max(load, float64(stats.numGoroutines)/s.goroutineThreshold)
+	}
+	if s.inflightThreshold > 0 && s.inflight != nil {
+		load = math.Max(load, float64(s.inflight())/s.inflightThreshold)
+	}
+	return load
+}
+
+// cutoff returns the lowest level being shed, zero when nothing is shed.
+func (s *LoadShedder) cutoff() int {
+	load := s.load()
+	if load < 1 {
+		return 0
+	}
+	cutoff := s.maxLevel
+	if s.levelStep > 0 {
+		cutoff -= int((load - 1) / s.levelStep)
+	}
+	if cutoff < 1 {
+		cutoff = 1
+	}
+	return cutoff
+}
+
+// shedLevel returns the level an endpoint is shed at, it is negative for
+// critical endpoints.
+func (s *LoadShedder) shedLevel(criticality Criticality, qpsLevel int) int {
+	switch {
+	case criticality != CriticalityDefault:
+		return int(criticality)
+	case qpsLevel > 0:
+		return qpsLevel
+	default:
+		return s.defaultLevel
+	}
+}
+
+// admit returns false with the shed level when the request must be shed.
+// It is safe to call admit on a nil LoadShedder.
+func (s *LoadShedder) admit(criticality Criticality, qpsLevel int) (int, bool) {
+	if s == nil {
+		return 0, true
+	}
+	level := s.shedLevel(criticality, qpsLevel)
+	if level < 1 {
+		return level, true
+	}
+	cutoff := s.cutoff()
+	s.level.Update(float64(cutoff))
+	return level, cutoff == 0 || level < cutoff
+}
+
+// countShed counts a shed request on the endpoint scope by level.
+func countShed(scope tally.Scope, level int) {
+	scope.Tagged(map[string]string{
+		scopeTagShedLevel: strconv.Itoa(level),
+	}).Counter(loadShedRejected).Inc(1)
+}
+
+// shed applies load shedding to an HTTP request, a shed request is answered
+// with a 503 and shed returns false.
+func (router *httpRouter) shed(
+	w http.ResponseWriter,
+	r *http.Request,
+	endpoint *RouterEndpoint,
+) bool {
+	shedder := router.gateway.LoadShedder
+	if shedder == nil {
+		return true
+	}
+	level, ok := shedder.admit(endpoint.Criticality, endpoint.QPSLevel)
+	if ok {
+		return true
+	}
+	countShed(router.gateway.RootScope.Tagged(map[string]string{
+		scopeTagEndpoint: endpoint.EndpointName,
+		scopeTagHandler:  endpoint.HandlerName,
+		scopeTagProtocol: scopeTagHTTP,
+	}), level)
+
+	router.gateway.ContextLogger.WarnZ(r.Context(), "Shed request under load",
+		zap.String(logFieldEndpointID, endpoint.EndpointName),
+		zap.String(logFieldEndpointHandler, endpoint.HandlerName),
+		zap.Int(scopeTagShedLevel, level),
+	)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write([]byte(`{"error":"Service overloaded"}`))
+	return false
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+func newTestLoadShedder(t *testing.T, config map[string]interface{}) (*LoadShedder, *runtimeStats, *int64) {
+	config["loadShedding.enabled"] = true
+	stats := &runtimeStats{}
+	var inflight int64
+	collector := NewRuntimeMetricsCollector(RuntimeMetricsOptions{EnableCPUMetrics: true}, tally.NoopScope)
+	s := NewLoadShedder(NewStaticConfigOrDie(nil, config), collector, func() int64 { return inflight }, tally.NoopScope)
+	require.NotNil(t, s)
+	s.stats = func() runtimeStats { return *stats }
+	return s, stats, &inflight
+}
+
+func TestNewLoadShedderDisabled(t *testing.T) {
+	assert.Nil(t, NewLoadShedder(NewStaticConfigOrDie(nil, nil), nil, nil, tally.NoopScope))
+
+	var s *LoadShedder
+	_, ok := s.admit(CriticalitySheddable, 0)
+	assert.True(t, ok)
+}
+
+func TestNewLoadShedderWithoutRuntimeMetrics(t *testing.T) {
+	config := NewStaticConfigOrDie(nil, map[string]interface{}{
+		"loadShedding.enabled":           true,
+		"loadShedding.inflightThreshold": 100,
+	})
+	assert.NotNil(t, NewLoadShedder(config, nil, nil, tally.NoopScope))
+
+	config = NewStaticConfigOrDie(nil, map[string]interface{}{
+		"loadShedding.enabled":      true,
+		"loadShedding.cpuThreshold": 0.8,
+	})
+	assert.Panics(t, func() { NewLoadShedder(config, nil, nil, tally.NoopScope) })
+	collector := NewRuntimeMetricsCollector(RuntimeMetricsOptions{EnableMemMetrics: true}, tally.NoopScope)
+	assert.Panics(t, func() { NewLoadShedder(config, collector, nil, tally.NoopScope) })
+}
+
+func TestLoadShedderShedsLevelsInOrder(t *testing.T) {
+	s, _, inflight := newTestLoadShedder(t, map[string]interface{}{
+		"loadShedding.inflightThreshold": 100,
+		"loadShedding.levelStep":         0.5,
+	})
+
+	shed := func() []Criticality {
+		var levels []Criticality
+		for _, c := range []Criticality{
+			CriticalityCritical, CriticalityHigh, CriticalityNormal, CriticalityLow, CriticalitySheddable,
+		} {
+			if _, ok := s.admit(c, 0); !ok {
+				levels = append(levels, c)
+			}
+		}
+		return levels
+	}
+
+	*inflight = 99
+	assert.Empty(t, shed())
+	*inflight = 100
+	assert.Equal(t, []Criticality{CriticalitySheddable}, shed())
+	*inflight = 150
+	assert.Equal(t, []Criticality{CriticalityLow, CriticalitySheddable}, shed())
+	*inflight = 1000
+	assert.Equal(t, []Criticality{
+		CriticalityHigh, CriticalityNormal, CriticalityLow, CriticalitySheddable,
+	}, shed())
+}
+
+func TestLoadShedderSignals(t *testing.T) {
+	s, stats, _ := newTestLoadShedder(t, map[string]interface{}{
+		"loadShedding.cpuThreshold":       0.8,
+		"loadShedding.goroutineThreshold": 1000,
+	})
+
+	stats.cpuUtilization = 0.5
+	stats.numGoroutines = 500
+	assert.Equal(t, 0, s.cutoff())
+
+	stats.cpuUtilization = 0.8
+	assert.Equal(t, defaultShedMaxLevel, s.cutoff())
+
+	stats.cpuUtilization = 0
+	stats.numGoroutines = 1250
+	assert.Equal(t, defaultShedMaxLevel-2, s.cutoff())
+}
+
+func TestLoadShedderLevels(t *testing.T) {
+	s, _, inflight := newTestLoadShedder(t, map[string]interface{}{
+		"loadShedding.inflightThreshold": 1,
+		"loadShedding.defaultLevel":      3,
+	})
+	*inflight = 1
+
+	// the qpsLevel ranks endpoints without a criticality
+	level, ok := s.admit(CriticalityDefault, 4)
+	assert.Equal(t, 4, level)
+	assert.False(t, ok)
+	level, ok = s.admit(CriticalityDefault, 1)
+	assert.Equal(t, 1, level)
+	assert.True(t, ok)
+	level, ok = s.admit(CriticalityHigh, 4)
+	assert.Equal(t, 1, level)
+	assert.True(t, ok)
+	level, _ = s.admit(CriticalityDefault, 0)
+	assert.Equal(t, 3, level)
+}
+
+func TestRuntimeCollectorStats(t *testing.T) {
+	rm := NewRuntimeMetricsCollector(RuntimeMetricsOptions{EnableCPUMetrics: true}, tally.NoopScope)
+	collector := rm.(*runtimeCollector)
+	collector.collect()
+	stats := collector.stats()
+	assert.True(t, stats.numGoroutines > 0)
+	assert.True(t, stats.cpuUtilization >= 0)
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// RootLoggerName is the name of the gateway's root logger in log level changes.
+	RootLoggerName = "root"
+
+	logLevelChanged  = "logger.level.changed"
+	logLevelReverted = "logger.level.reverted"
+)
+
+// logLevelController changes the level of the root logger and of the named
+// sub loggers at runtime. A change with a TTL reverts to the configured level
+// once it expires.
+type logLevelController struct {
+	mu       sync.Mutex
+	levels   map[string]zap.AtomicLevel
+	defaults map[string]zapcore.Level
+	timers   map[string]*time.Timer
+	logger   *zap.Logger
+	scope    tally.Scope
+
+	signals chan os.Signal
+	done    chan struct{}
+}
+
+func newLogLevelController(root zap.AtomicLevel, scope tally.Scope) *logLevelController {
+	c := &logLevelController{
+		levels:   make(map[string]zap.AtomicLevel),
+		defaults: make(map[string]zapcore.Level),
+		timers:   make(map[string]*time.Timer),
+		logger:   zap.NewNop(),
+		scope:    scope,
+	}
+	c.levels[RootLoggerName] = root
+	c.defaults[RootLoggerName] = root.Level()
+	return c
+}
+
+// atomicLevel returns the level of the named logger, registering it at the
+// given level the first time the name is seen.
+func (c *logLevelController) atomicLevel(name string, level zapcore.Level) zap.AtomicLevel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if atomLevel, ok := c.levels[name]; ok {
+		return atomLevel
+	}
+	atomLevel := zap.NewAtomicLevelAt(level)
+	c.levels[name] = atomLevel
+	c.defaults[name] = level
+	return atomLevel
+}
+
+// set changes the level of the named logger, a positive ttl reverts the
+// change to the configured level when it expires.
+func (c *logLevelController) set(name string, level zapcore.Level, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.levels[name]; !ok {
+		return errors.Errorf("unknown logger %q", name)
+	}
+	c.setLocked(name, level, ttl)
+	return nil
+}
+
+// setAll changes the level of every logger.
+func (c *logLevelController) setAll(level zapcore.Level, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name := range c.levels {
+		c.setLocked(name, level, ttl)
+	}
+}
+
+func (c *logLevelController) setLocked(name string, level zapcore.Level, ttl time.Duration) {
+	if timer, ok := c.timers[name]; ok {
+		timer.Stop()
+		delete(c.timers, name)
+	}
+	c.levels[name].SetLevel(level)
+	c.scope.Tagged(map[string]string{
+		"logger": name,
+		"level":  level.String(),
+	}).Counter(logLevelChanged).Inc(1)
+	c.logger.Info("Changed log level",
+		zap.String("logger", name),
+		zap.Stringer("level", level),
+		zap.Duration("ttl", ttl),
+	)
+
+	if ttl <= 0 {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(ttl, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// a newer change replaced this one
+		if c.timers[name] != timer {
+			return
+		}
+		delete(c.timers, name)
+		c.revertLocked(name)
+	})
+	c.timers[name] = timer
+}
+
+// resetAll reverts every logger to its configured level.
+func (c *logLevelController) resetAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, timer := range c.timers {
+		timer.Stop()
+		delete(c.timers, name)
+	}
+	for name := range c.levels {
+		c.revertLocked(name)
+	}
+}
+
+func (c *logLevelController) revertLocked(name string) {
+	level := c.defaults[name]
+	if c.levels[name].Level() == level {
+		return
+	}
+	c.levels[name].SetLevel(level)
+	c.scope.Tagged(map[string]string{
+		"logger": name,
+		"level":  level.String(),
+	}).Counter(logLevelReverted).Inc(1)
+	c.logger.Info("Reverted log level",
+		zap.String("logger", name),
+		zap.Stringer("level", level),
+	)
+}
+
+// snapshot returns the current level by logger name.
+func (c *logLevelController) snapshot() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	levels := make(map[string]string, len(c.levels))
+	for name, atomLevel := range c.levels {
+		levels[name] = atomLevel.Level().String()
+	}
+	return levels
+}
+
+// listenOnSignals switches every logger to debug on SIGUSR1 and back to the
+// configured levels on SIGUSR2 until stopSignals is called, it does nothing
+// on platforms without these signals.
+func (c *logLevelController) listenOnSignals(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.signals != nil || debugLevelSignal == nil {
+		return
+	}
+	c.signals = make(chan os.Signal, 1)
+	c.done = make(chan struct{})
+	signal.Notify(c.signals, debugLevelSignal, resetLevelSignal)
+
+	go func(signals chan os.Signal, done chan struct{}) {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == debugLevelSignal {
+					c.setAll(zapcore.DebugLevel, ttl)
+				} else {
+					c.resetAll()
+				}
+			case <-done:
+				return
+			}
+		}
+	}(c.signals, c.done)
+}
+
+// stopSignals stops listening on signals and cancels pending reverts.
+func (c *logLevelController) stopSignals() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, timer := range c.timers {
+		timer.Stop()
+		delete(c.timers, name)
+	}
+	if c.signals == nil {
+		return
+	}
+	signal.Stop(c.signals)
+	close(c.done)
+	c.signals = nil
+	c.done = nil
+}
+
+// SetLogLevel changes the level of the root logger or of a named sub logger
+// such as "http", "tchannel" or "jaeger". A positive ttl reverts the change
+// to the configured level once it expires.
+func (gateway *Gateway) SetLogLevel(name string, level zapcore.Level, ttl time.Duration) error {
+	if gateway.logLevels == nil {
+		return errors.New("log levels can not be changed before the logger is set up")
+	}
+	return gateway.logLevels.set(name, level, ttl)
+}
+
+// LogLevels returns the current level of the root logger and of every sub logger.
+func (gateway *Gateway) LogLevels() map[string]string {
+	if gateway.logLevels == nil {
+		return map[string]string{}
+	}
+	return gateway.logLevels.snapshot()
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newTestLogLevelController() (*logLevelController, tally.TestScope) {
+	scope := tally.NewTestScope("", nil)
+	c := newLogLevelController(zap.NewAtomicLevelAt(zapcore.InfoLevel), scope)
+	c.atomicLevel("http", zapcore.WarnLevel)
+	return c, scope
+}
+
+func TestLogLevelControllerSet(t *testing.T) {
+	c, scope := newTestLogLevelController()
+
+	// the same name shares a level
+	httpLevel := c.atomicLevel("http", zapcore.ErrorLevel)
+	assert.Equal(t, zapcore.WarnLevel, httpLevel.Level())
+
+	require.NoError(t, c.set("http", zapcore.DebugLevel, 0))
+	assert.Equal(t, zapcore.DebugLevel, httpLevel.Level())
+	assert.Equal(t, map[string]string{"root": "info", "http": "debug"}, c.snapshot())
+
+	assert.Error(t, c.set("jaeger", zapcore.DebugLevel, 0))
+
+	counters := scope.Snapshot().Counters()
+	assert.Equal(t, int64(1), counters[logLevelChanged+"+level=debug,logger=http"].Value())
+
+	c.resetAll()
+	assert.Equal(t, map[string]string{"root": "info", "http": "warn"}, c.snapshot())
+}
+
+func TestLogLevelControllerTTL(t *testing.T) {
+	c, _ := newTestLogLevelController()
+
+	c.setAll(zapcore.DebugLevel, 10*time.Millisecond)
+	assert.Equal(t, map[string]string{"root": "debug", "http": "debug"}, c.snapshot())
+	assert.Eventually(t, func() bool {
+		levels := c.snapshot()
+		return levels["root"] == "info" && levels["http"] == "warn"
+	}, time.Second, 5*time.Millisecond)
+
+	// a change without ttl cancels the pending revert
+	require.NoError(t, c.set("root", zapcore.ErrorLevel, 10*time.Millisecond))
+	require.NoError(t, c.set("root", zapcore.DebugLevel, 0))
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, "debug", c.snapshot()["root"])
+}
+
+func TestLogLevelControllerStopSignals(t *testing.T) {
+	c, _ := newTestLogLevelController()
+	c.listenOnSignals(0)
+	c.listenOnSignals(0)
+	require.NoError(t, c.set("root", zapcore.DebugLevel, time.Hour))
+	c.stopSignals()
+	c.stopSignals()
+	assert.Empty(t, c.timers)
+}
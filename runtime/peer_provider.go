@@ -0,0 +1,397 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
+	"github.com/uber/tchannel-go"
+	"go.uber.org/zap"
+)
+
+const (
+	peerProviderTypeFile = "file"
+	peerProviderTypeDNS  = "dns"
+
+	defaultFilePeerRefreshInterval = 10 * time.Second
+	defaultDNSPeerRefreshInterval  = 30 * time.Second
+	dnsPeerLookupTimeout           = 5 * time.Second
+
+	clientPeers               = "client.peers"
+	clientPeersAdded          = "client.peers.added"
+	clientPeersRemoved        = "client.peers.removed"
+	clientPeersRefreshFailure = "client.peers.refresh.failure"
+)
+
+// PeersChangeHandler is called with the new peers of a client.
+type PeersChangeHandler func(peers []string)
+
+// PeerProvider discovers the peers of a client as host:port addresses and
+// notifies its subscribers when they change.
+type PeerProvider interface {
+	// Peers returns the current peers in order.
+	Peers() []string
+	// Subscribe registers a handler called with the peers every time they
+	// change, including when they are first discovered.
+	Subscribe(handler PeersChangeHandler)
+	// Start discovers the peers and keeps refreshing them until Stop.
+	Start() error
+	// Stop stops refreshing the peers, it cannot be restarted once stopped.
+	Stop()
+}
+
+// NewPeerProvider returns the peer provider of a client set by
+// "clients.<clientID>.peerProvider.type", or nil when the client has none.
+// The "file" provider reads a YAML or JSON list of peers from
+// "clients.<clientID>.peerProvider.path", the "dns" provider looks up the
+// SRV records of "clients.<clientID>.peerProvider.name", or its A records
+// when "clients.<clientID>.peerProvider.port" is set. Peers are refreshed
+// every "clients.<clientID>.peerProvider.refreshInterval" ms. It panics when
+// the config of the provider is invalid.
+func NewPeerProvider(config ConfigReader, clientID string, logger *zap.Logger, scope tally.Scope) PeerProvider {
+	prefix := "clients." + clientID + ".peerProvider."
+	if !config.ContainsKey(prefix + "type") {
+		return nil
+	}
+	var interval time.Duration
+	if config.ContainsKey(prefix + "refreshInterval") {
+		interval = time.Duration(config.MustGetInt(prefix+"refreshInterval")) * time.Millisecond
+	}
+
+	switch providerType := config.MustGetString(prefix + "type"); providerType {
+	case peerProviderTypeFile:
+		return NewFilePeerProvider(clientID, config.MustGetString(prefix+"path"), interval, logger, scope)
+	case peerProviderTypeDNS:
+		var port int
+		if config.ContainsKey(prefix + "port") {
+			port = int(config.MustGetInt(prefix + "port"))
+		}
+		return NewDNSPeerProvider(clientID, config.MustGetString(prefix+"name"), port, interval, logger, scope)
+	default:
+		panic(errors.Errorf("unknown peer provider type %q for client %s", providerType, clientID))
+	}
+}
+
+// NewFilePeerProvider returns a PeerProvider that reads a YAML or JSON list
+// of host:port peers from path every interval.
+func NewFilePeerProvider(clientID, path string, interval time.Duration, logger *zap.Logger, scope tally.Scope) PeerProvider {
+	if interval <= 0 {
+		interval = defaultFilePeerRefreshInterval
+	}
+	return newPollingPeerProvider(clientID, interval, func() ([]string, error) {
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading peer file %s", path)
+		}
+		var peers []string
+		if err := yaml.Unmarshal(bytes, &peers); err != nil {
+			return nil, errors.Wrapf(err, "error parsing peer file %s", path)
+		}
+		return peers, nil
+	}, logger, scope)
+}
+
+// dnsResolver is implemented by net.Resolver.
+type dnsResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// dnsPeers looks up the peers of a client in DNS.
+type dnsPeers struct {
+	name     string
+	port     int
+	resolver dnsResolver
+}
+
+// NewDNSPeerProvider returns a PeerProvider that looks up the peers of name
+// every interval. The peers are the targets of its SRV records when port is
+// 0, or its addresses with the given port otherwise.
+func NewDNSPeerProvider(clientID, name string, port int, interval time.Duration, logger *zap.Logger, scope tally.Scope) PeerProvider {
+	if interval <= 0 {
+		interval = defaultDNSPeerRefreshInterval
+	}
+	d := &dnsPeers{name: name, port: port, resolver: net.DefaultResolver}
+	return newPollingPeerProvider(clientID, interval, d.resolve, logger, scope)
+}
+
+func (d *dnsPeers) resolve() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsPeerLookupTimeout)
+	defer cancel()
+
+	if d.port > 0 {
+		addrs, err := d.resolver.LookupHost(ctx, d.name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error looking up %s", d.name)
+		}
+		peers := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			peers = append(peers, net.JoinHostPort(addr, strconv.Itoa(d.port)))
+		}
+		return peers, nil
+	}
+
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", d.name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error looking up SRV records of %s", d.name)
+	}
+	peers := make([]string, 0, len(records))
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		peers = append(peers, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+	}
+	return peers, nil
+}
+
+// pollingPeerProvider refreshes the peers of a client with resolve every
+// interval. A failed refresh, or one that finds no peers, keeps the last
+// known peers.
+type pollingPeerProvider struct {
+	clientID string
+	interval time.Duration
+	resolve  func() ([]string, error)
+	logger   *zap.Logger
+	scope    tally.Scope
+
+	// refreshMu serializes refreshes so that handlers see changes in order
+	refreshMu sync.Mutex
+	mu        sync.RWMutex
+	peers     []string             // protected by mu
+	handlers  []PeersChangeHandler // protected by mu
+
+	runningMu sync.Mutex
+	running   bool // protected by runningMu
+	stop      chan struct{}
+}
+
+func newPollingPeerProvider(
+	clientID string,
+	interval time.Duration,
+	resolve func() ([]string, error),
+	logger *zap.Logger,
+	scope tally.Scope,
+) *pollingPeerProvider {
+	return &pollingPeerProvider{
+		clientID: clientID,
+		interval: interval,
+		resolve:  resolve,
+		logger:   logger,
+		scope: scope.Tagged(map[string]string{
+			scopeTagClient: clientID,
+		}),
+		stop: make(chan struct{}),
+	}
+}
+
+func (p *pollingPeerProvider) Peers() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]string(nil), p.peers...)
+}
+
+func (p *pollingPeerProvider) Subscribe(handler PeersChangeHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers = append(p.handlers, handler)
+}
+
+func (p *pollingPeerProvider) Start() error {
+	p.runningMu.Lock()
+	defer p.runningMu.Unlock()
+	if p.running {
+		return nil
+	}
+	if err := p.refresh(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		for {
+			select {
+			case <-ticker.C:
+				// errors are logged and counted by refresh
+				_ = p.refresh()
+			case <-p.stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	p.running = true
+	return nil
+}
+
+func (p *pollingPeerProvider) Stop() {
+	p.runningMu.Lock()
+	defer p.runningMu.Unlock()
+	if !p.running {
+		return
+	}
+	close(p.stop)
+	p.running = false
+}
+
+// refresh resolves the peers and notifies the handlers when they changed.
+func (p *pollingPeerProvider) refresh() error {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+
+	peers, err := p.resolve()
+	if err == nil && len(peers) == 0 {
+		err = errors.New("no peers found")
+	}
+	if err != nil {
+		p.scope.Counter(clientPeersRefreshFailure).Inc(1)
+		p.logger.Error("Failed to refresh client peers",
+			zap.String(logFieldClientID, p.clientID),
+			zap.Error(err),
+		)
+		return errors.Wrapf(err, "could not discover the peers of client %s", p.clientID)
+	}
+	peers = uniquePeers(peers)
+
+	p.mu.Lock()
+	added, removed := diffPeers(p.peers, peers)
+	if len(added) == 0 && len(removed) == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	p.peers = peers
+	handlers := append([]PeersChangeHandler(nil), p.handlers...)
+	p.mu.Unlock()
+
+	p.scope.Counter(clientPeersAdded).Inc(int64(len(added)))
+	p.scope.Counter(clientPeersRemoved).Inc(int64(len(removed)))
+	p.scope.Gauge(clientPeers).Update(float64(len(peers)))
+	p.logger.Info("Client peers changed",
+		zap.String(logFieldClientID, p.clientID),
+		zap.Strings("added", added),
+		zap.Strings("removed", removed),
+	)
+	for _, handler := range handlers {
+		handler(append([]string(nil), peers...))
+	}
+	return nil
+}
+
+// uniquePeers returns the sorted peers without duplicates.
+func uniquePeers(peers []string) []string {
+	seen := make(map[string]bool, len(peers))
+	unique := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		if !seen[peer] {
+			seen[peer] = true
+			unique = append(unique, peer)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// diffPeers returns the peers of next missing from prev and the peers of
+// prev missing from next.
+func diffPeers(prev, next []string) (added, removed []string) {
+	inPrev := make(map[string]bool, len(prev))
+	for _, peer := range prev {
+		inPrev[peer] = true
+	}
+	inNext := make(map[string]bool, len(next))
+	for _, peer := range next {
+		inNext[peer] = true
+		if !inPrev[peer] {
+			added = append(added, peer)
+		}
+	}
+	for _, peer := range prev {
+		if !inNext[peer] {
+			removed = append(removed, peer)
+		}
+	}
+	return added, removed
+}
+
+// TChannelPeersUpdater returns a PeersChangeHandler that adds the new peers
+// to the peer list of a TChannel client and removes the peers that are gone.
+func TChannelPeersUpdater(peerList *tchannel.PeerList) PeersChangeHandler {
+	var (
+		mu      sync.Mutex
+		current []string
+	)
+	return func(peers []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		added, removed := diffPeers(current, peers)
+		for _, hostPort := range added {
+			peerList.Add(hostPort)
+		}
+		for _, hostPort := range removed {
+			// the peer may already be gone from the list
+			_ = peerList.Remove(hostPort)
+		}
+		current = peers
+	}
+}
+
+// RegisterPeerProvider makes the gateway start the peer provider of a client
+// when it bootstraps and stop it on shutdown, a nil provider is ignored.
+func (gateway *Gateway) RegisterPeerProvider(clientID string, provider PeerProvider) {
+	if provider == nil {
+		return
+	}
+	gateway.peerProvidersMu.Lock()
+	defer gateway.peerProvidersMu.Unlock()
+	if gateway.peerProviders == nil {
+		gateway.peerProviders = make(map[string]PeerProvider)
+	}
+	gateway.peerProviders[clientID] = provider
+}
+
+// startPeerProviders discovers the peers of the clients.
+func (gateway *Gateway) startPeerProviders() error {
+	gateway.peerProvidersMu.Lock()
+	defer gateway.peerProvidersMu.Unlock()
+	for clientID, provider := range gateway.peerProviders {
+		if err := provider.Start(); err != nil {
+			return errors.Wrapf(err, "error starting peer provider of client %s", clientID)
+		}
+	}
+	return nil
+}
+
+// stopPeerProviders stops refreshing the peers of the clients.
+func (gateway *Gateway) stopPeerProviders() {
+	gateway.peerProvidersMu.Lock()
+	defer gateway.peerProvidersMu.Unlock()
+	for _, provider := range gateway.peerProviders {
+		provider.Stop()
+	}
+}
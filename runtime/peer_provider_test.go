@@ -0,0 +1,203 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+func TestNewPeerProvider(t *testing.T) {
+	config := NewStaticConfigOrDie([]*ConfigOption{
+		ConfigFileContents([]byte(`
+clients.file.peerProvider.type: file
+clients.file.peerProvider.path: /tmp/peers.yaml
+clients.file.peerProvider.refreshInterval: 100
+clients.dns.peerProvider.type: dns
+clients.dns.peerProvider.name: _foo._tcp.example.com
+clients.dnsA.peerProvider.type: dns
+clients.dnsA.peerProvider.name: foo.example.com
+clients.dnsA.peerProvider.port: 8080
+clients.bad.peerProvider.type: zookeeper
+clients.none.ip: 127.0.0.1
+`)),
+	}, nil)
+
+	assert.Nil(t, NewPeerProvider(config, "none", zap.NewNop(), tally.NoopScope))
+
+	file := NewPeerProvider(config, "file", zap.NewNop(), tally.NoopScope).(*pollingPeerProvider)
+	assert.Equal(t, 100*time.Millisecond, file.interval)
+
+	dns := NewPeerProvider(config, "dns", zap.NewNop(), tally.NoopScope).(*pollingPeerProvider)
+	assert.Equal(t, defaultDNSPeerRefreshInterval, dns.interval)
+	assert.NotNil(t, NewPeerProvider(config, "dnsA", zap.NewNop(), tally.NoopScope))
+
+	assert.PanicsWithError(t, `unknown peer provider type "zookeeper" for client bad`, func() {
+		NewPeerProvider(config, "bad", zap.NewNop(), tally.NoopScope)
+	})
+}
+
+func TestFilePeerProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peers")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "peers.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("- b:2\n- a:1\n- b:2\n"), 0644))
+
+	scope := tally.NewTestScope("", nil)
+	provider := NewFilePeerProvider("foo", path, time.Hour, zap.NewNop(), scope).(*pollingPeerProvider)
+	var (
+		mu      sync.Mutex
+		updates [][]string
+	)
+	provider.Subscribe(func(peers []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		updates = append(updates, peers)
+	})
+	require.NoError(t, provider.Start())
+	defer provider.Stop()
+	assert.Equal(t, []string{"a:1", "b:2"}, provider.Peers())
+
+	// the file may also be JSON
+	require.NoError(t, ioutil.WriteFile(path, []byte(`["a:1", "c:3"]`), 0644))
+	require.NoError(t, provider.refresh())
+	assert.Equal(t, []string{"a:1", "c:3"}, provider.Peers())
+
+	// unchanged peers do not notify the handlers
+	require.NoError(t, provider.refresh())
+
+	// an invalid or empty file keeps the last peers
+	require.NoError(t, ioutil.WriteFile(path, []byte("a: {"), 0644))
+	assert.Error(t, provider.refresh())
+	require.NoError(t, ioutil.WriteFile(path, []byte("[]"), 0644))
+	assert.EqualError(t, provider.refresh(), "could not discover the peers of client foo: no peers found")
+	assert.Equal(t, []string{"a:1", "c:3"}, provider.Peers())
+
+	mu.Lock()
+	assert.Equal(t, [][]string{{"a:1", "b:2"}, {"a:1", "c:3"}}, updates)
+	mu.Unlock()
+
+	snapshot := scope.Snapshot()
+	counters := snapshot.Counters()
+	assert.Equal(t, int64(3), counters[clientPeersAdded+"+clientid=foo"].Value())
+	assert.Equal(t, int64(1), counters[clientPeersRemoved+"+clientid=foo"].Value())
+	assert.Equal(t, int64(2), counters[clientPeersRefreshFailure+"+clientid=foo"].Value())
+	assert.Equal(t, float64(2), snapshot.Gauges()[clientPeers+"+clientid=foo"].Value())
+}
+
+func TestFilePeerProviderMissingFile(t *testing.T) {
+	provider := NewFilePeerProvider("foo", "/does/not/exist", 0, zap.NewNop(), tally.NoopScope)
+	err := provider.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error reading peer file /does/not/exist")
+	assert.Empty(t, provider.Peers())
+	provider.Stop()
+}
+
+type fakeResolver struct {
+	srv   []*net.SRV
+	hosts []string
+	err   error
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return name, r.srv, r.err
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return r.hosts, r.err
+}
+
+func TestDNSPeers(t *testing.T) {
+	resolver := &fakeResolver{
+		srv: []*net.SRV{
+			{Target: "b.example.com.", Port: 2},
+			{Target: "a.example.com.", Port: 1},
+		},
+		hosts: []string{"10.0.0.1", "::1"},
+	}
+
+	srv := &dnsPeers{name: "_foo._tcp.example.com", resolver: resolver}
+	peers, err := srv.resolve()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.example.com:2", "a.example.com:1"}, peers)
+
+	a := &dnsPeers{name: "foo.example.com", port: 8080, resolver: resolver}
+	peers, err = a.resolve()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:8080", "[::1]:8080"}, peers)
+
+	resolver.err = errors.New("no such host")
+	_, err = srv.resolve()
+	assert.EqualError(t, err, "error looking up SRV records of _foo._tcp.example.com: no such host")
+	_, err = a.resolve()
+	assert.EqualError(t, err, "error looking up foo.example.com: no such host")
+}
+
+func TestDiffPeers(t *testing.T) {
+	added, removed := diffPeers([]string{"a", "b"}, []string{"b", "c", "d"})
+	assert.Equal(t, []string{"c", "d"}, added)
+	assert.Equal(t, []string{"a"}, removed)
+
+	added, removed = diffPeers(nil, nil)
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+}
+
+type fakePeerProvider struct {
+	err     error
+	started bool
+	stopped bool
+}
+
+func (p *fakePeerProvider) Peers() []string                      { return nil }
+func (p *fakePeerProvider) Subscribe(handler PeersChangeHandler) {}
+func (p *fakePeerProvider) Start() error                         { p.started = true; return p.err }
+func (p *fakePeerProvider) Stop()                                { p.stopped = true }
+
+func TestGatewayPeerProviders(t *testing.T) {
+	gateway := &Gateway{}
+	gateway.RegisterPeerProvider("nil", nil)
+	assert.Empty(t, gateway.peerProviders)
+
+	foo := &fakePeerProvider{}
+	gateway.RegisterPeerProvider("foo", foo)
+	require.NoError(t, gateway.startPeerProviders())
+	assert.True(t, foo.started)
+	gateway.stopPeerProviders()
+	assert.True(t, foo.stopped)
+
+	gateway.RegisterPeerProvider("bar", &fakePeerProvider{err: errors.New("boom")})
+	assert.EqualError(t, gateway.startPeerProviders(), "error starting peer provider of client bar: boom")
+}
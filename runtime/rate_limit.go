@@ -0,0 +1,481 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRateLimitCallerHeader = "RPC-Caller"
+	defaultRateLimitMaxKeys      = 10000
+
+	defaultConcurrencyInitialLimit = 100
+	defaultConcurrencyMinLimit     = 1
+	defaultConcurrencyMaxLimit     = 1000
+	defaultConcurrencyTarget       = time.Second
+	defaultConcurrencyBackoff      = 0.9
+
+	rateLimitRejected         = "ratelimit.rejected"
+	rateLimitFailedOpen       = "ratelimit.failed-open"
+	rateLimitConcurrencyLimit = "ratelimit.concurrency.limit"
+
+	// reasons a request was rejected, used as the "reason" tag
+	rejectedRate        = "rate"
+	rejectedCaller      = "caller"
+	rejectedKey         = "key"
+	rejectedConcurrency = "concurrency"
+)
+
+// RateLimitOptions are the inbound limits of an endpoint. Rates are in
+// requests per second, a zero rate means no limit and a zero burst defaults
+// to the rate.
+type RateLimitOptions struct {
+	// RPS and Burst size the token bucket shared by every request.
+	RPS   float64
+	Burst int
+	// CallerRPS and CallerBurst size a token bucket per caller, the caller is
+	// the caller name of TChannel requests and the value of the
+	// "rateLimit.callerHeader" header of HTTP requests.
+	CallerRPS   float64
+	CallerBurst int
+	// KeyHeader names a request header, each of its values gets a token
+	// bucket sized by KeyRPS and KeyBurst.
+	KeyHeader string
+	KeyRPS    float64
+	KeyBurst  int
+	// Concurrency bounds the requests in flight with a limit that adapts to
+	// the latency of the endpoint.
+	Concurrency bool
+}
+
+// NewRateLimitOptions returns the default limits of endpoints from the
+// "rateLimit.*" keys, it returns nil when "rateLimit.enabled" is not set.
+func NewRateLimitOptions(config *StaticConfig) *RateLimitOptions {
+	if config == nil || !config.ContainsKey("rateLimit.enabled") || !config.MustGetBoolean("rateLimit.enabled") {
+		return nil
+	}
+	opts := &RateLimitOptions{}
+	floats := map[string]*float64{
+		"rateLimit.rps":       &opts.RPS,
+		"rateLimit.callerRps": &opts.CallerRPS,
+		"rateLimit.keyRps":    &opts.KeyRPS,
+	}
+	for key, value := range floats {
+		if config.ContainsKey(key) {
+			*value = config.MustGetFloat(key)
+		}
+	}
+	ints := map[string]*int{
+		"rateLimit.burst":       &opts.Burst,
+		"rateLimit.callerBurst": &opts.CallerBurst,
+		"rateLimit.keyBurst":    &opts.KeyBurst,
+	}
+	for key, value := range ints {
+		if config.ContainsKey(key) {
+			*value = int(config.MustGetInt(key))
+		}
+	}
+	if config.ContainsKey("rateLimit.keyHeader") {
+		opts.KeyHeader = config.MustGetString("rateLimit.keyHeader")
+	}
+	if config.ContainsKey("rateLimit.concurrency.enabled") {
+		opts.Concurrency = config.MustGetBoolean("rateLimit.concurrency.enabled")
+	}
+	return opts
+}
+
+// With returns a copy of the options where the non zero fields of overrides
+// win. It returns nil when rate limiting is disabled, that is on nil options.
+func (o *RateLimitOptions) With(overrides RateLimitOptions) *RateLimitOptions {
+	if o == nil {
+		return nil
+	}
+	merged := *o
+	if overrides.RPS != 0 {
+		merged.RPS, merged.Burst = overrides.RPS, overrides.Burst
+	}
+	if overrides.CallerRPS != 0 {
+		merged.CallerRPS, merged.CallerBurst = overrides.CallerRPS, overrides.CallerBurst
+	}
+	if overrides.KeyHeader != "" {
+		merged.KeyHeader = overrides.KeyHeader
+	}
+	if overrides.KeyRPS != 0 {
+		merged.KeyRPS, merged.KeyBurst = overrides.KeyRPS, overrides.KeyBurst
+	}
+	if overrides.Concurrency {
+		merged.Concurrency = true
+	}
+	return &merged
+}
+
+// tokenBucket allows rate requests per second with bursts of up to burst.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: now}
+}
+
+// check reports whether the bucket has a token without consuming it, it
+// returns how long to wait for the next one when the bucket is empty.
+func (b *tokenBucket) check(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	if b.tokens >= 1 {
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// take consumes a token, check must have found one.
+func (b *tokenBucket) take(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	b.tokens--
+}
+
+// full reports whether the bucket refilled, an idle bucket can be dropped
+// without changing what it allows.
+func (b *tokenBucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// concurrencyLimiter bounds the requests in flight, the limit grows by one
+// while requests finish within the target latency and backs off when they
+// do not (AIMD).
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	opts     concurrencyOptions
+	gauge    tally.Gauge
+}
+
+type concurrencyOptions struct {
+	initial, min, max float64
+	target            time.Duration
+	backoff           float64
+}
+
+func newConcurrencyLimiter(opts concurrencyOptions, gauge tally.Gauge) *concurrencyLimiter {
+	gauge.Update(opts.initial)
+	return &concurrencyLimiter{limit: opts.initial, opts: opts, gauge: gauge}
+}
+
+func (c *concurrencyLimiter) acquire() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight >= int(c.limit) {
+		return false
+	}
+	c.inflight++
+	return true
+}
+
+func (c *concurrencyLimiter) release(latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight--
+	switch {
+	case latency > c.opts.target:
+		c.limit = math.Max(c.opts.min, c.limit*c.opts.backoff)
+	case float64(c.inflight+1)*2 >= c.limit:
+		// only grow a limit that is being used
+		c.limit = math.Min(c.opts.max, c.limit+1)
+	default:
+		return
+	}
+	c.gauge.Update(c.limit)
+}
+
+// RateLimiter holds the token buckets and concurrency limiters of the
+// endpoints, the limits themselves come with each request.
+type RateLimiter struct {
+	mu sync.Mutex
+	// buckets indexes the elements of lru, which holds the buckets from
+	// the most to the least recently used
+	buckets     map[string]*list.Element
+	lru         *list.List
+	concurrency map[string]*concurrencyLimiter
+
+	callerHeader string
+	maxKeys      int
+	defaults     *RateLimitOptions
+	concOpts     concurrencyOptions
+	now          func() time.Time
+}
+
+// NewRateLimiter creates a rate limiter configured by the "rateLimit.*" keys.
+func NewRateLimiter(config *StaticConfig) *RateLimiter {
+	l := &RateLimiter{
+		buckets:      make(map[string]*list.Element),
+		lru:          list.New(),
+		concurrency:  make(map[string]*concurrencyLimiter),
+		callerHeader: defaultRateLimitCallerHeader,
+		maxKeys:      defaultRateLimitMaxKeys,
+		defaults:     NewRateLimitOptions(config),
+		concOpts: concurrencyOptions{
+			initial: defaultConcurrencyInitialLimit,
+			min:     defaultConcurrencyMinLimit,
+			max:     defaultConcurrencyMaxLimit,
+			target:  defaultConcurrencyTarget,
+			backoff: defaultConcurrencyBackoff,
+		},
+		now: time.Now,
+	}
+	if config.ContainsKey("rateLimit.callerHeader") {
+		l.callerHeader = config.MustGetString("rateLimit.callerHeader")
+	}
+	if config.ContainsKey("rateLimit.maxKeys") {
+		l.maxKeys = int(config.MustGetInt("rateLimit.maxKeys"))
+	}
+	limits := map[string]*float64{
+		"rateLimit.concurrency.initialLimit": &l.concOpts.initial,
+		"rateLimit.concurrency.minLimit":     &l.concOpts.min,
+		"rateLimit.concurrency.maxLimit":     &l.concOpts.max,
+		"rateLimit.concurrency.backoffRatio": &l.concOpts.backoff,
+	}
+	for key, value := range limits {
+		if config.ContainsKey(key) {
+			*value = config.MustGetFloat(key)
+		}
+	}
+	if config.ContainsKey("rateLimit.concurrency.targetLatency") {
+		l.concOpts.target = time.Duration(config.MustGetInt("rateLimit.concurrency.targetLatency")) * time.Millisecond
+	}
+	return l
+}
+
+// admit checks the limits of a request to the endpoint identified by key,
+// scope is tagged with the endpoint. It returns a release func to call once
+// the request is served, or the reason the request is rejected and how long
+// the caller should wait before retrying.
+func (l *RateLimiter) admit(
+	key string,
+	scope tally.Scope,
+	opts *RateLimitOptions,
+	caller string,
+	header func(string) string,
+) (release func(), reason string, retryAfter time.Duration) {
+	release = func() {}
+	if l == nil || opts == nil {
+		return release, "", 0
+	}
+	reason, retryAfter = l.takeTokens(key, scope, opts, caller, header)
+	if reason == "" && opts.Concurrency {
+		start := l.now()
+		limiter := l.concurrencyLimiter(key, scope)
+		if limiter.acquire() {
+			release = func() { limiter.release(l.now().Sub(start)) }
+		} else {
+			reason = rejectedConcurrency
+		}
+	}
+	if reason != "" {
+		scope.Tagged(map[string]string{scopeTagReason: reason}).Counter(rateLimitRejected).Inc(1)
+	}
+	return release, reason, retryAfter
+}
+
+// bucketEntry is an element of the LRU list of buckets.
+type bucketEntry struct {
+	key    string
+	bucket *tokenBucket
+}
+
+// bucketLimit is a token bucket that applies to a request.
+type bucketLimit struct {
+	key    string
+	reason string
+	rate   float64
+	burst  int
+}
+
+// takeTokens takes a token from every bucket that applies to the request
+// once all of them have one, a rejected request consumes no token. It
+// returns the reason of the first empty bucket and how long to wait.
+func (l *RateLimiter) takeTokens(
+	key string,
+	scope tally.Scope,
+	opts *RateLimitOptions,
+	caller string,
+	header func(string) string,
+) (string, time.Duration) {
+	// the most specific bucket is reported first
+	var limits []bucketLimit
+	if opts.KeyHeader != "" && opts.KeyRPS > 0 {
+		if value := header(opts.KeyHeader); value != "" {
+			limits = append(limits, bucketLimit{key + "|key|" + value, rejectedKey, opts.KeyRPS, opts.KeyBurst})
+		}
+	}
+	if opts.CallerRPS > 0 && caller != "" {
+		limits = append(limits, bucketLimit{key + "|caller|" + caller, rejectedCaller, opts.CallerRPS, opts.CallerBurst})
+	}
+	if opts.RPS > 0 {
+		limits = append(limits, bucketLimit{key, rejectedRate, opts.RPS, opts.Burst})
+	}
+	if len(limits) == 0 {
+		return "", 0
+	}
+
+	now := l.now()
+	// checking and taking is atomic so that concurrent requests can not
+	// both pass the check on the last token
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	buckets := make([]*tokenBucket, 0, len(limits))
+	for _, limit := range limits {
+		bucket := l.bucketLocked(limit, now)
+		if bucket == nil {
+			scope.Tagged(map[string]string{scopeTagReason: limit.reason}).Counter(rateLimitFailedOpen).Inc(1)
+			continue
+		}
+		if ok, wait := bucket.check(now); !ok {
+			return limit.reason, wait
+		}
+		buckets = append(buckets, bucket)
+	}
+	for _, bucket := range buckets {
+		bucket.take(now)
+	}
+	return "", 0
+}
+
+// bucketLocked returns the bucket of the limit, or nil when there are too
+// many buckets to add one.
+func (l *RateLimiter) bucketLocked(limit bucketLimit, now time.Time) *tokenBucket {
+	if elem, ok := l.buckets[limit.key]; ok {
+		l.lru.MoveToFront(elem)
+		return elem.Value.(*bucketEntry).bucket
+	}
+	if len(l.buckets) >= l.maxKeys && !l.evictLocked(now) {
+		// fail open rather than grow without bound
+		return nil
+	}
+	bucket := newTokenBucket(limit.rate, limit.burst, now)
+	l.buckets[limit.key] = l.lru.PushFront(&bucketEntry{key: limit.key, bucket: bucket})
+	return bucket
+}
+
+// evictLocked drops the least recently used bucket if it refilled since it
+// was last used, the other buckets were used more recently and are less
+// likely to be idle.
+func (l *RateLimiter) evictLocked(now time.Time) bool {
+	elem := l.lru.Back()
+	if elem == nil {
+		return false
+	}
+	entry := elem.Value.(*bucketEntry)
+	if !entry.bucket.full(now) {
+		return false
+	}
+	l.lru.Remove(elem)
+	delete(l.buckets, entry.key)
+	return true
+}
+
+func (l *RateLimiter) concurrencyLimiter(key string, scope tally.Scope) *concurrencyLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiter, ok := l.concurrency[key]
+	if !ok {
+		limiter = newConcurrencyLimiter(l.concOpts, scope.Gauge(rateLimitConcurrencyLimit))
+		l.concurrency[key] = limiter
+	}
+	return limiter
+}
+
+// defaultOptions returns the limits of endpoints that do not set their own.
+func (l *RateLimiter) defaultOptions() *RateLimitOptions {
+	if l == nil {
+		return nil
+	}
+	return l.defaults
+}
+
+// admit applies the rate limits of the endpoint to an HTTP request, a
+// rejected request is answered with a 429 and ok is false.
+func (router *httpRouter) admit(
+	w http.ResponseWriter,
+	r *http.Request,
+	endpoint *RouterEndpoint,
+) (release func(), ok bool) {
+	limiter := router.gateway.RateLimiter
+	if limiter == nil || endpoint.RateLimit == nil {
+		return func() {}, true
+	}
+	scope := router.gateway.RootScope.Tagged(map[string]string{
+		scopeTagEndpoint: endpoint.EndpointName,
+		scopeTagHandler:  endpoint.HandlerName,
+		scopeTagProtocol: scopeTagHTTP,
+	})
+	key := scopeTagHTTP + ":" + endpoint.EndpointName + "." + endpoint.HandlerName
+	caller := r.Header.Get(limiter.callerHeader)
+	release, reason, retryAfter := limiter.admit(key, scope, endpoint.RateLimit, caller, r.Header.Get)
+	if reason == "" {
+		return release, true
+	}
+
+	router.gateway.ContextLogger.WarnZ(r.Context(), "Rejected request over the rate limit",
+		zap.String(logFieldEndpointID, endpoint.EndpointName),
+		zap.String(logFieldEndpointHandler, endpoint.HandlerName),
+		zap.String(scopeTagReason, reason),
+	)
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_, _ = w.Write([]byte(`{"error":"Too many requests"}`))
+	return release, false
+}
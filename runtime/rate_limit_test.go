@@ -0,0 +1,223 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestRateLimiter(t *testing.T, config map[string]interface{}) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1500000000, 0)}
+	l := NewRateLimiter(NewStaticConfigOrDie(nil, config))
+	l.now = clock.Now
+	return l, clock
+}
+
+func noHeaders(string) string { return "" }
+
+func TestNewRateLimitOptions(t *testing.T) {
+	assert.Nil(t, NewRateLimitOptions(nil))
+	assert.Nil(t, NewRateLimitOptions(NewStaticConfigOrDie(nil, map[string]interface{}{
+		"rateLimit.rps": 10,
+	})))
+
+	opts := NewRateLimitOptions(NewStaticConfigOrDie(nil, map[string]interface{}{
+		"rateLimit.enabled":             true,
+		"rateLimit.rps":                 10,
+		"rateLimit.burst":               20,
+		"rateLimit.callerRps":           2.5,
+		"rateLimit.keyHeader":           "x-user",
+		"rateLimit.keyRps":              1,
+		"rateLimit.concurrency.enabled": true,
+	}))
+	require.NotNil(t, opts)
+	assert.Equal(t, RateLimitOptions{
+		RPS:         10,
+		Burst:       20,
+		CallerRPS:   2.5,
+		KeyHeader:   "x-user",
+		KeyRPS:      1,
+		Concurrency: true,
+	}, *opts)
+
+	merged := opts.With(RateLimitOptions{RPS: 100, CallerRPS: 5, CallerBurst: 10})
+	assert.Equal(t, float64(100), merged.RPS)
+	assert.Equal(t, 0, merged.Burst)
+	assert.Equal(t, float64(5), merged.CallerRPS)
+	assert.Equal(t, 10, merged.CallerBurst)
+	assert.Equal(t, "x-user", merged.KeyHeader)
+	assert.Equal(t, float64(10), opts.RPS, "defaults are not changed")
+
+	var disabled *RateLimitOptions
+	assert.Nil(t, disabled.With(RateLimitOptions{RPS: 1}))
+}
+
+func TestRateLimiterEndpointBucket(t *testing.T) {
+	l, clock := newTestRateLimiter(t, nil)
+	scope := tally.NewTestScope("", nil)
+	opts := &RateLimitOptions{RPS: 2, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		_, reason, _ := l.admit("ep", scope, opts, "", noHeaders)
+		assert.Empty(t, reason)
+	}
+	_, reason, retryAfter := l.admit("ep", scope, opts, "", noHeaders)
+	assert.Equal(t, rejectedRate, reason)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	_, reason, _ = l.admit("ep", scope, opts, "", noHeaders)
+	assert.Empty(t, reason)
+
+	counter := scope.Snapshot().Counters()[rateLimitRejected+"+reason=rate"]
+	require.NotNil(t, counter)
+	assert.Equal(t, int64(1), counter.Value())
+}
+
+func TestRateLimiterCallerAndKeyBuckets(t *testing.T) {
+	l, _ := newTestRateLimiter(t, nil)
+	scope := tally.NewTestScope("", nil)
+	opts := &RateLimitOptions{CallerRPS: 1, KeyHeader: "x-user", KeyRPS: 1}
+	user := func(name string) func(string) string {
+		return func(header string) string {
+			if header == "x-user" {
+				return name
+			}
+			return ""
+		}
+	}
+
+	_, reason, _ := l.admit("ep", scope, opts, "a", user("alice"))
+	assert.Empty(t, reason)
+	_, reason, _ = l.admit("ep", scope, opts, "a", user("bob"))
+	assert.Equal(t, rejectedCaller, reason)
+	_, reason, _ = l.admit("ep", scope, opts, "b", user("alice"))
+	assert.Equal(t, rejectedKey, reason)
+	_, reason, _ = l.admit("ep", scope, opts, "b", user("carol"))
+	assert.Empty(t, reason)
+	// requests without a caller or key only use the shared limits
+	_, reason, _ = l.admit("ep", scope, opts, "", noHeaders)
+	assert.Empty(t, reason)
+}
+
+func TestRateLimiterRejectionTakesNoToken(t *testing.T) {
+	l, clock := newTestRateLimiter(t, nil)
+	scope := tally.NewTestScope("", nil)
+	opts := &RateLimitOptions{RPS: 1, Burst: 1, CallerRPS: 0.001, CallerBurst: 2}
+
+	_, reason, _ := l.admit("ep", scope, opts, "a", noHeaders)
+	assert.Empty(t, reason)
+	_, reason, _ = l.admit("ep", scope, opts, "a", noHeaders)
+	assert.Equal(t, rejectedRate, reason)
+
+	// the request the endpoint rejected left the caller's token
+	clock.now = clock.now.Add(time.Second)
+	_, reason, _ = l.admit("ep", scope, opts, "a", noHeaders)
+	assert.Empty(t, reason)
+	clock.now = clock.now.Add(time.Second)
+	_, reason, _ = l.admit("ep", scope, opts, "a", noHeaders)
+	assert.Equal(t, rejectedCaller, reason)
+}
+
+func TestRateLimiterEvictsIdleBuckets(t *testing.T) {
+	l, clock := newTestRateLimiter(t, map[string]interface{}{
+		"rateLimit.maxKeys": 2,
+	})
+	scope := tally.NewTestScope("", nil)
+	opts := &RateLimitOptions{KeyHeader: "x-user", KeyRPS: 1}
+	key := func(value string) func(string) string {
+		return func(string) string { return value }
+	}
+
+	_, _, _ = l.admit("ep", scope, opts, "", key("a"))
+	_, _, _ = l.admit("ep", scope, opts, "", key("b"))
+	// no bucket is idle yet, new keys are let through without one
+	_, reason, _ := l.admit("ep", scope, opts, "", key("c"))
+	assert.Empty(t, reason)
+	assert.Len(t, l.buckets, 2)
+	counter := scope.Snapshot().Counters()[rateLimitFailedOpen+"+reason=key"]
+	require.NotNil(t, counter)
+	assert.Equal(t, int64(1), counter.Value())
+
+	// "a" is used again, which leaves "b" the least recently used bucket
+	clock.now = clock.now.Add(time.Second)
+	_, reason, _ = l.admit("ep", scope, opts, "", key("a"))
+	assert.Empty(t, reason)
+	_, _, _ = l.admit("ep", scope, opts, "", key("c"))
+	assert.Len(t, l.buckets, 2)
+	assert.Contains(t, l.buckets, "ep|key|a")
+	assert.Contains(t, l.buckets, "ep|key|c")
+
+	// the least recently used bucket "a" is not idle, "b" fails open
+	_, reason, _ = l.admit("ep", scope, opts, "", key("b"))
+	assert.Empty(t, reason)
+	assert.NotContains(t, l.buckets, "ep|key|b")
+	assert.Equal(t, int64(2), scope.Snapshot().Counters()[rateLimitFailedOpen+"+reason=key"].Value())
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	l, clock := newTestRateLimiter(t, map[string]interface{}{
+		"rateLimit.concurrency.initialLimit":  2,
+		"rateLimit.concurrency.minLimit":      1,
+		"rateLimit.concurrency.maxLimit":      3,
+		"rateLimit.concurrency.targetLatency": 100,
+		"rateLimit.concurrency.backoffRatio":  0.5,
+	})
+	scope := tally.NewTestScope("", nil)
+	opts := &RateLimitOptions{Concurrency: true}
+
+	release1, reason, _ := l.admit("ep", scope, opts, "", noHeaders)
+	assert.Empty(t, reason)
+	release2, reason, _ := l.admit("ep", scope, opts, "", noHeaders)
+	assert.Empty(t, reason)
+	_, reason, _ = l.admit("ep", scope, opts, "", noHeaders)
+	assert.Equal(t, rejectedConcurrency, reason)
+
+	// fast requests grow the limit up to the max
+	release1()
+	release2()
+	gauge := scope.Snapshot().Gauges()[rateLimitConcurrencyLimit+"+"]
+	require.NotNil(t, gauge)
+	assert.Equal(t, float64(3), gauge.Value())
+
+	// a slow request backs the limit off
+	release, _, _ := l.admit("ep", scope, opts, "", noHeaders)
+	clock.now = clock.now.Add(time.Second)
+	release()
+	assert.Equal(t, float64(1.5), scope.Snapshot().Gauges()[rateLimitConcurrencyLimit+"+"].Value())
+}
+
+func TestNilRateLimiter(t *testing.T) {
+	var l *RateLimiter
+	release, reason, _ := l.admit("ep", tally.NoopScope, &RateLimitOptions{RPS: 1}, "", noHeaders)
+	assert.Empty(t, reason)
+	assert.NotPanics(t, release)
+	assert.Nil(t, l.defaultOptions())
+}
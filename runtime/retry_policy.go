@@ -0,0 +1,308 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryMaxBackoff       = time.Second
+	defaultRetryBackoffFactor    = 2.0
+	defaultRetryJitter           = 0.2
+	defaultRetryBudgetMaxRetries = 10
+)
+
+// defaultRetryableStatusCodes are the responses that show the request was
+// not processed and can safely be sent again.
+var defaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryAttempt describes a failed attempt of an outbound HTTP call.
+type RetryAttempt struct {
+	// Method is the client method name.
+	Method string
+	// Request is the outbound request.
+	Request *http.Request
+	// Response is the response of the attempt, nil when Err is set.
+	Response *http.Response
+	// Err is the error of the attempt.
+	Err error
+	// Attempt is the number of the failed attempt, starting at 1.
+	Attempt int
+}
+
+// RetryPolicy decides whether a failed attempt of an outbound HTTP call is
+// retried and how long to wait before the next attempt. The number of
+// attempts is set by the TimeoutAndRetryOptions of the context.
+type RetryPolicy interface {
+	// OnCall is called once for every call before its first attempt.
+	OnCall(method string)
+	// Retry returns the backoff before the next attempt and whether the
+	// failed attempt should be retried.
+	Retry(ctx context.Context, attempt *RetryAttempt) (time.Duration, bool)
+}
+
+// BackoffRetryPolicy retries idempotent methods on errors and retryable
+// status codes with an exponential backoff and jitter. A Retry-After header
+// on the response overrides the backoff, and retries stop once the retry
+// budget is spent.
+type BackoffRetryPolicy struct {
+	// InitialBackoff is the backoff before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the backoff.
+	MaxBackoff time.Duration
+	// BackoffFactor multiplies the backoff after every retry.
+	BackoffFactor float64
+	// Jitter is the fraction of the backoff that is randomized, from 0 to 1.
+	Jitter float64
+	// RetryableStatusCodes are the response status codes that are retried.
+	RetryableStatusCodes map[int]bool
+	// IdempotentMethods flags client methods as idempotent or not, methods
+	// that are not listed are idempotent when their HTTP method is.
+	IdempotentMethods map[string]bool
+
+	budget *retryBudget
+	random func() float64
+}
+
+// NewRetryPolicy returns the retry policy of a client from the
+// "clients.<clientID>.retryPolicy.*" keys, or nil when it is not enabled.
+// idempotentMethods are the idempotency flags generated from the Thrift
+// annotations of the client methods.
+func NewRetryPolicy(config ConfigReader, clientID string, idempotentMethods map[string]bool) RetryPolicy {
+	if p := NewBackoffRetryPolicy(config, clientID, idempotentMethods); p != nil {
+		return p
+	}
+	return nil
+}
+
+// NewBackoffRetryPolicy is NewRetryPolicy returning the concrete policy, it
+// returns nil when the policy is not enabled.
+func NewBackoffRetryPolicy(config ConfigReader, clientID string, idempotentMethods map[string]bool) *BackoffRetryPolicy {
+	prefix := "clients." + clientID + ".retryPolicy."
+	if !config.ContainsKey(prefix+"enabled") || !config.MustGetBoolean(prefix+"enabled") {
+		return nil
+	}
+
+	p := &BackoffRetryPolicy{
+		InitialBackoff:    DefaultBackOffTimeAcrossRetries,
+		MaxBackoff:        defaultRetryMaxBackoff,
+		BackoffFactor:     defaultRetryBackoffFactor,
+		Jitter:            defaultRetryJitter,
+		IdempotentMethods: make(map[string]bool, len(idempotentMethods)),
+	}
+	for method, idempotent := range idempotentMethods {
+		p.IdempotentMethods[method] = idempotent
+	}
+	if config.ContainsKey(prefix + "initialBackoff") {
+		p.InitialBackoff = time.Duration(config.MustGetInt(prefix+"initialBackoff")) * time.Millisecond
+	}
+	if config.ContainsKey(prefix + "maxBackoff") {
+		p.MaxBackoff = time.Duration(config.MustGetInt(prefix+"maxBackoff")) * time.Millisecond
+	}
+	if config.ContainsKey(prefix + "backoffFactor") {
+		p.BackoffFactor = config.MustGetFloat(prefix + "backoffFactor")
+	}
+	if config.ContainsKey(prefix + "jitter") {
+		p.Jitter = config.MustGetFloat(prefix + "jitter")
+	}
+
+	statusCodes := defaultRetryableStatusCodes
+	if config.ContainsKey(prefix + "retryableStatusCodes") {
+		statusCodes = nil
+		config.MustGetStruct(prefix+"retryableStatusCodes", &statusCodes)
+	}
+	p.RetryableStatusCodes = make(map[int]bool, len(statusCodes))
+	for _, code := range statusCodes {
+		p.RetryableStatusCodes[code] = true
+	}
+
+	// the idempotency of a method can be overridden in config
+	if config.ContainsKey(prefix + "idempotentMethods") {
+		var methods map[string]bool
+		config.MustGetStruct(prefix+"idempotentMethods", &methods)
+		for method, idempotent := range methods {
+			p.IdempotentMethods[method] = idempotent
+		}
+	}
+
+	if config.ContainsKey(prefix + "budget.percent") {
+		maxRetries := float64(defaultRetryBudgetMaxRetries)
+		if config.ContainsKey(prefix + "budget.maxRetries") {
+			maxRetries = float64(config.MustGetInt(prefix + "budget.maxRetries"))
+		}
+		p.budget = newRetryBudget(config.MustGetFloat(prefix+"budget.percent")/100, maxRetries)
+	}
+	return p
+}
+
+// OnCall deposits into the retry budget.
+func (p *BackoffRetryPolicy) OnCall(method string) {
+	if p == nil {
+		return
+	}
+	p.budget.deposit()
+}
+
+// Retry implements RetryPolicy.
+func (p *BackoffRetryPolicy) Retry(ctx context.Context, attempt *RetryAttempt) (time.Duration, bool) {
+	if attempt.Err == nil && (attempt.Response == nil || !p.RetryableStatusCodes[attempt.Response.StatusCode]) {
+		return 0, false
+	}
+	if !p.idempotent(attempt) {
+		return 0, false
+	}
+	backoff := p.backoff(attempt.Attempt)
+	if retryAfter, ok := retryAfter(attempt.Response, time.Now()); ok {
+		backoff = retryAfter
+	}
+	return p.allow(ctx, backoff)
+}
+
+// allow returns whether a retry that waits for backoff can be made before
+// the deadline of ctx and within the retry budget.
+func (p *BackoffRetryPolicy) allow(ctx context.Context, backoff time.Duration) (time.Duration, bool) {
+	// do not retry on context.Canceled or context.DeadlineExceeded
+	if ctx.Err() != nil {
+		return 0, false
+	}
+	// the next attempt would start after the deadline
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+		return 0, false
+	}
+	if !p.budget.withdraw() {
+		return 0, false
+	}
+	return backoff, true
+}
+
+func (p *BackoffRetryPolicy) idempotent(attempt *RetryAttempt) bool {
+	if idempotent, ok := p.IdempotentMethods[attempt.Method]; ok {
+		return idempotent
+	}
+	return attempt.Request != nil && IdempotentHTTPMethod(attempt.Request.Method)
+}
+
+// backoff returns the jittered exponential backoff after the given attempt.
+func (p *BackoffRetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.BackoffFactor, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		random := p.random
+		if random == nil {
+			random = rand.Float64
+		}
+		backoff -= backoff * p.Jitter * random()
+	}
+	return time.Duration(backoff)
+}
+
+// IdempotentHTTPMethod returns whether requests with the HTTP method can be
+// sent more than once without changing the result.
+func IdempotentHTTPMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header of a response, in seconds or as
+// an HTTP date.
+func retryAfter(res *http.Response, now time.Time) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := date.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+// retryBudget caps retries to a ratio of the calls. Every call deposits
+// ratio tokens and every retry withdraws one, the balance is capped at
+// maxRetries so that a burst of failures after a quiet period can not
+// retry more than that.
+type retryBudget struct {
+	ratio     float64
+	maxTokens float64
+
+	mu     sync.Mutex
+	tokens float64 // protected by mu
+}
+
+func newRetryBudget(ratio, maxRetries float64) *retryBudget {
+	return &retryBudget{
+		ratio:     ratio,
+		maxTokens: maxRetries,
+		tokens:    maxRetries,
+	}
+}
+
+func (b *retryBudget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.tokens+b.ratio, b.maxTokens)
+}
+
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRetryPolicy(t *testing.T, config map[string]interface{}) *BackoffRetryPolicy {
+	config["clients.bar.retryPolicy.enabled"] = true
+	policy := NewRetryPolicy(NewStaticConfigOrDie(nil, config), "bar", map[string]bool{
+		"Normal": false,
+		"Hello":  true,
+	})
+	require.NotNil(t, policy)
+	p := policy.(*BackoffRetryPolicy)
+	p.random = func() float64 { return 1 }
+	return p
+}
+
+func failedAttempt(method, httpMethod string, statusCode int, err error) *RetryAttempt {
+	attempt := &RetryAttempt{
+		Method:  method,
+		Request: &http.Request{Method: httpMethod},
+		Err:     err,
+		Attempt: 1,
+	}
+	if err == nil {
+		attempt.Response = &http.Response{StatusCode: statusCode, Header: http.Header{}}
+	}
+	return attempt
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	assert.Nil(t, NewRetryPolicy(NewStaticConfigOrDie(nil, nil), "bar", nil))
+	assert.Nil(t, NewRetryPolicy(NewStaticConfigOrDie(nil, map[string]interface{}{
+		"clients.bar.retryPolicy.enabled": false,
+	}), "bar", nil))
+
+	p := newTestRetryPolicy(t, map[string]interface{}{
+		"clients.bar.retryPolicy.initialBackoff":       20,
+		"clients.bar.retryPolicy.maxBackoff":           100,
+		"clients.bar.retryPolicy.backoffFactor":        3,
+		"clients.bar.retryPolicy.jitter":               0.5,
+		"clients.bar.retryPolicy.retryableStatusCodes": []int{503},
+		"clients.bar.retryPolicy.idempotentMethods":    map[string]bool{"Normal": true},
+		"clients.bar.retryPolicy.budget.percent":       20,
+		"clients.bar.retryPolicy.budget.maxRetries":    5,
+	})
+	assert.Equal(t, 20*time.Millisecond, p.InitialBackoff)
+	assert.Equal(t, 100*time.Millisecond, p.MaxBackoff)
+	assert.Equal(t, float64(3), p.BackoffFactor)
+	assert.Equal(t, 0.5, p.Jitter)
+	assert.Equal(t, map[int]bool{503: true}, p.RetryableStatusCodes)
+	assert.Equal(t, map[string]bool{"Normal": true, "Hello": true}, p.IdempotentMethods)
+	require.NotNil(t, p.budget)
+	assert.Equal(t, 0.2, p.budget.ratio)
+	assert.Equal(t, float64(5), p.budget.maxTokens)
+}
+
+func TestRetryPolicyClassification(t *testing.T) {
+	p := newTestRetryPolicy(t, map[string]interface{}{
+		"clients.bar.retryPolicy.jitter": 0,
+	})
+	ctx := context.Background()
+	failure := errors.New("connection refused")
+
+	cases := []struct {
+		attempt *RetryAttempt
+		retry   bool
+	}{
+		{failedAttempt("Hello", "GET", 0, failure), true},
+		{failedAttempt("Hello", "GET", http.StatusServiceUnavailable, nil), true},
+		{failedAttempt("Hello", "GET", http.StatusTooManyRequests, nil), true},
+		{failedAttempt("Hello", "GET", http.StatusInternalServerError, nil), false},
+		{failedAttempt("Hello", "GET", http.StatusOK, nil), false},
+		// flagged as not idempotent by its annotation
+		{failedAttempt("Normal", "GET", 0, failure), false},
+		// unknown methods fall back to their HTTP method
+		{failedAttempt("Other", "PUT", 0, failure), true},
+		{failedAttempt("Other", "POST", 0, failure), false},
+	}
+	for i, c := range cases {
+		backoff, retry := p.Retry(ctx, c.attempt)
+		assert.Equal(t, c.retry, retry, "case %d", i)
+		if retry {
+			assert.Equal(t, DefaultBackOffTimeAcrossRetries, backoff, "case %d", i)
+		}
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, retry := p.Retry(canceled, failedAttempt("Hello", "GET", 0, failure))
+	assert.False(t, retry)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := newTestRetryPolicy(t, map[string]interface{}{
+		"clients.bar.retryPolicy.initialBackoff": 10,
+		"clients.bar.retryPolicy.maxBackoff":     50,
+		"clients.bar.retryPolicy.jitter":         0,
+	})
+	assert.Equal(t, 10*time.Millisecond, p.backoff(1))
+	assert.Equal(t, 20*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 40*time.Millisecond, p.backoff(3))
+	assert.Equal(t, 50*time.Millisecond, p.backoff(4))
+
+	// the jitter takes up to its fraction off the backoff
+	p.Jitter = 0.5
+	p.random = func() float64 { return 1 }
+	assert.Equal(t, 5*time.Millisecond, p.backoff(1))
+	p.random = func() float64 { return 0 }
+	assert.Equal(t, 10*time.Millisecond, p.backoff(1))
+}
+
+func TestRetryPolicyRetryAfter(t *testing.T) {
+	p := newTestRetryPolicy(t, map[string]interface{}{})
+
+	attempt := failedAttempt("Hello", "GET", http.StatusServiceUnavailable, nil)
+	attempt.Response.Header.Set("Retry-After", "2")
+	backoff, retry := p.Retry(context.Background(), attempt)
+	assert.True(t, retry)
+	assert.Equal(t, 2*time.Second, backoff)
+
+	// no retry when the backoff would outlast the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, retry = p.Retry(ctx, attempt)
+	assert.False(t, retry)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	res := &http.Response{Header: http.Header{}}
+	res.Header.Set("Retry-After", now.Add(3*time.Second).Format(http.TimeFormat))
+	wait, ok := retryAfter(res, now)
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, wait)
+
+	res.Header.Set("Retry-After", "soon")
+	_, ok = retryAfter(res, now)
+	assert.False(t, ok)
+	_, ok = retryAfter(nil, now)
+	assert.False(t, ok)
+}
+
+func TestRetryPolicyBudget(t *testing.T) {
+	p := newTestRetryPolicy(t, map[string]interface{}{
+		"clients.bar.retryPolicy.budget.percent":    50,
+		"clients.bar.retryPolicy.budget.maxRetries": 1,
+	})
+	ctx := context.Background()
+	attempt := failedAttempt("Hello", "GET", http.StatusServiceUnavailable, nil)
+
+	_, retry := p.Retry(ctx, attempt)
+	assert.True(t, retry)
+
+	// the budget is spent, every call earns half a retry
+	p.OnCall("Hello")
+	_, retry = p.Retry(ctx, attempt)
+	assert.False(t, retry)
+	p.OnCall("Hello")
+	_, retry = p.Retry(ctx, attempt)
+	assert.True(t, retry)
+
+	// the balance is capped at maxRetries
+	for i := 0; i < 10; i++ {
+		p.OnCall("Hello")
+	}
+	_, retry = p.Retry(ctx, attempt)
+	assert.True(t, retry)
+	_, retry = p.Retry(ctx, attempt)
+	assert.False(t, retry)
+}
+
+func TestIdempotentHTTPMethod(t *testing.T) {
+	assert.True(t, IdempotentHTTPMethod("GET"))
+	assert.True(t, IdempotentHTTPMethod("DELETE"))
+	assert.False(t, IdempotentHTTPMethod("POST"))
+	assert.False(t, IdempotentHTTPMethod("PATCH"))
+}
@@ -25,6 +25,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/pborman/uuid"
@@ -83,12 +85,37 @@ type RouterEndpoint struct {
 	HandlerName  string
 	HandlerFn    HandlerFn
 	JSONWrapper  jsonwrapper.JSONWrapper
+	// Timeout bounds the time spent on a request, zero means no deadline
+	// unless the caller sends one in the Context-TTL-MS header.
+	Timeout time.Duration
+	// MaxBodyBytes caps the request body, zero means no limit.
+	MaxBodyBytes int64
+	// RateLimit holds the inbound limits of the endpoint, nil means the
+	// endpoint is not rate limited. It defaults to the "rateLimit.*" config,
+	// the health and admin endpoints of the gateway are never rate limited.
+	RateLimit *RateLimitOptions
+	// Criticality and QPSLevel rank the endpoint for load shedding.
+	Criticality Criticality
+	QPSLevel    int
+	// CORS is the cross-origin policy of the endpoint, nil means the
+	// endpoint sets no CORS headers.
+	CORS *CORSOptions
+	// MatchHost and MatchHeaders restrict the endpoint to the requests sent
+	// to the host and carrying the header values, so that several endpoints
+	// can serve the same method and path. MatchHost may be a wildcard such
+	// as "*.example.com" and the header value "*" only requires the header.
+	MatchHost    string
+	MatchHeaders map[string]string
+	// APIVersion is the version of the API the endpoint serves, nil for an
+	// unversioned endpoint.
+	APIVersion *APIVersion
 
 	contextExtractor ContextExtractor
 	contextLogger    ContextLogger
 	scope            tally.Scope
 	tracer           opentracing.Tracer
 	config           *StaticConfig
+	middlewares      *MiddlewareStack
 }
 
 // NewRouterEndpoint creates an endpoint that can be registered to HTTPRouter
@@ -99,8 +126,20 @@ func NewRouterEndpoint(
 	handlerID string,
 	handler HandlerFn,
 ) *RouterEndpoint {
+	var timeout time.Duration
+	if deps.Config != nil && deps.Config.ContainsKey("http.endpointTimeout") {
+		timeout = time.Duration(deps.Config.MustGetInt("http.endpointTimeout")) * time.Millisecond
+	}
+	var maxBodyBytes int64
+	if deps.Config != nil && deps.Config.ContainsKey("http.maxBodyBytes") {
+		maxBodyBytes = deps.Config.MustGetInt("http.maxBodyBytes")
+	}
 	return &RouterEndpoint{
 		EndpointName:     endpointID,
+		Timeout:          timeout,
+		MaxBodyBytes:     maxBodyBytes,
+		RateLimit:        NewRateLimitOptions(deps.Config),
+		CORS:             NewCORSOptions(deps.Config),
 		HandlerName:      handlerID,
 		HandlerFn:        handler,
 		contextExtractor: extractor,
@@ -112,23 +151,57 @@ func NewRouterEndpoint(
 	}
 }
 
+// NewRouterEndpointWithStack creates an endpoint that runs the given
+// middleware stack, the stack is kept for introspection.
+func NewRouterEndpointWithStack(
+	extractor ContextExtractor,
+	deps *DefaultDependencies,
+	endpointID string,
+	handlerID string,
+	stack *MiddlewareStack,
+) *RouterEndpoint {
+	endpoint := NewRouterEndpoint(extractor, deps, endpointID, handlerID, stack.Handle)
+	endpoint.middlewares = stack
+	return endpoint
+}
+
+// Middlewares returns the names of the middlewares the endpoint runs, in order.
+func (endpoint *RouterEndpoint) Middlewares() []string {
+	if endpoint.middlewares == nil {
+		return []string{}
+	}
+	names := make([]string, len(endpoint.middlewares.Middlewares()))
+	for i, m := range endpoint.middlewares.Middlewares() {
+		names[i] = m.Name()
+	}
+	return names
+}
+
+// ServeHTTP implements http.Handler so the endpoint can be registered directly.
+func (endpoint *RouterEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint.HandleRequest(w, r)
+}
+
 // HandleRequest is called by the router and starts the request
 func (endpoint *RouterEndpoint) HandleRequest(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
-	// TODO: (lu) get timeout from endpoint config
-	//_, ok := ctx.Deadline()
-	//if !ok {
-	//	var cancel context.CancelFunc
-	//	ctx, cancel = context.WithTimeout(ctx, time.Duration(100)*time.Millisecond)
-	//	defer cancel()
-	//}
+	if timeout := endpoint.requestTimeout(r); timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
 
 	urlValues := ParamsFromContext(r.Context())
 	req := NewServerHTTPRequest(w, r, urlValues, endpoint)
 	ctx := req.Context()
-	endpoint.HandlerFn(ctx, req, req.res)
+	if req.limitBody(endpoint.MaxBodyBytes) {
+		endpoint.HandlerFn(ctx, req, req.res)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		req.res.deadlineExceeded(ctx)
+	}
 	req.res.flush(ctx)
 }
 
@@ -140,6 +213,9 @@ type httpRouter struct {
 	methodNotAllowedEndpoint *RouterEndpoint
 	panicCount               tally.Counter
 	routeMap                 map[string]*RouterEndpoint
+	maxHeaderBytes           int
+	// cors is the policy of handlers that are not a *RouterEndpoint
+	cors *CORSOptions
 
 	requestUUIDHeaderKey string
 }
@@ -168,22 +244,37 @@ func NewHTTPRouter(gateway *Gateway) HTTPRouter {
 		gateway:    gateway,
 		panicCount: gateway.RootScope.Counter("runtime.router.panic"),
 		routeMap:   make(map[string]*RouterEndpoint),
+		cors:       NewCORSOptions(gateway.Config),
 
 		requestUUIDHeaderKey: gateway.requestUUIDHeaderKey,
 	}
+	// only the endpoints registered by the service are rate limited
+	router.notFoundEndpoint.RateLimit = nil
+	router.methodNotAllowedEndpoint.RateLimit = nil
 
 	notFoundHandler := http.HandlerFunc(router.handleNotFound)
 	if gateway.notFoundHandler != nil {
 		notFoundHandler = gateway.notFoundHandler
 	}
 
+	if gateway.Config.ContainsKey("http.maxHeaderBytes") {
+		router.maxHeaderBytes = int(gateway.Config.MustGetInt("http.maxHeaderBytes"))
+	}
+
 	handleMethodNotAllowed := true
 	if gateway.Config.ContainsKey("http.handleMethodNotAllowed") {
 		handleMethodNotAllowed = gateway.Config.MustGetBoolean("http.handleMethodNotAllowed")
 	}
 
+	handleOptions := true
+	if gateway.Config.ContainsKey("http.handleOptions") {
+		handleOptions = gateway.Config.MustGetBoolean("http.handleOptions")
+	}
+
 	router.httpRouter = &zrouter.Router{
 		HandleMethodNotAllowed: handleMethodNotAllowed,
+		HandleOPTIONS:          handleOptions,
+		GlobalOPTIONS:          http.HandlerFunc(router.handleOptions),
 		NotFound:               notFoundHandler,
 		MethodNotAllowed:       http.HandlerFunc(router.handleMethodNotAllowed),
 		PanicHandler:           router.handlePanic,
@@ -194,7 +285,31 @@ func NewHTTPRouter(gateway *Gateway) HTTPRouter {
 
 // Register register a handler function.
 func (router *httpRouter) Handle(method, prefix string, handler http.Handler) (err error) {
+	endpoint, isEndpoint := handler.(*RouterEndpoint)
+	var match zrouter.Match
+	if isEndpoint {
+		match = zrouter.Match{Host: endpoint.MatchHost, Headers: endpoint.MatchHeaders}
+	}
+	key := routeKey(method, prefix, match)
 	h := func(w http.ResponseWriter, r *http.Request) {
+		defer router.gateway.httpInflight.begin(key)()
+		// CORS headers are set before the middlewares run so that rejected
+		// requests carry them too
+		if cors := router.corsOptions(endpoint); cors != nil {
+			cors.writeHeaders(w.Header(), r)
+		}
+		if isEndpoint {
+			router.deprecate(w, endpoint)
+			if !router.shed(w, r, endpoint) {
+				return
+			}
+			release, ok := router.admit(w, r, endpoint)
+			if !ok {
+				return
+			}
+			defer release()
+		}
+
 		reqUUID := r.Header.Get(router.requestUUIDHeaderKey)
 		if reqUUID == "" {
 			reqUUID = uuid.New()
@@ -205,13 +320,90 @@ func (router *httpRouter) Handle(method, prefix string, handler http.Handler) (e
 		handler.ServeHTTP(w, r)
 	}
 
-	return router.httpRouter.Handle(method, prefix, http.HandlerFunc(h))
+	if err := router.httpRouter.HandleMatch(method, prefix, match, &routeHandler{HandlerFunc: h, endpoint: endpoint}); err != nil {
+		return err
+	}
+	if isEndpoint {
+		router.routeMap[key] = endpoint
+	}
+	return nil
+}
+
+// routeHandler is the handler registered with the underlying router, it
+// keeps the endpoint to find its CORS policy on preflight requests.
+type routeHandler struct {
+	http.HandlerFunc
+	endpoint *RouterEndpoint
+}
+
+// corsOptions returns the CORS policy of the endpoint, or of the gateway for
+// handlers that are not a *RouterEndpoint.
+func (router *httpRouter) corsOptions(endpoint *RouterEndpoint) *CORSOptions {
+	if endpoint != nil {
+		return endpoint.CORS
+	}
+	return router.cors
+}
+
+// handleOptions replies to OPTIONS requests of routes without an OPTIONS
+// handler, the Allow header is set by the underlying router. Preflight
+// requests get the CORS headers of the route of the requested method.
+func (router *httpRouter) handleOptions(w http.ResponseWriter, r *http.Request) {
+	if isPreflight(r) {
+		handler, _, ok := router.httpRouter.Lookup(r.Header.Get(corsRequestMethod), r)
+		if h, isRoute := handler.(*routeHandler); ok && isRoute {
+			if cors := router.corsOptions(h.endpoint); cors != nil {
+				cors.writePreflightHeaders(w.Header(), r, w.Header().Get("Allow"))
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// routes returns the registered routes with the endpoint serving each one,
+// the endpoint is nil for handlers that are not a *RouterEndpoint.
+func (router *httpRouter) routes() []httpRoute {
+	registered := router.httpRouter.Routes()
+	routes := make([]httpRoute, 0, len(registered))
+	for _, r := range registered {
+		route := httpRoute{Method: r.Method, Path: r.Path, Match: r.Match}
+		if endpoint, ok := router.routeMap[routeKey(r.Method, r.Path, r.Match)]; ok {
+			route.Endpoint = endpoint
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// routeKey matches the path normalization of the underlying router, which
+// does not treat "/a" and "/a/" as different routes.
+func routeKey(method, path string, match zrouter.Match) string {
+	path = strings.TrimSuffix(path, "/")
+	if path == "" {
+		path = "/"
+	}
+	if m := match.String(); m != "" {
+		return method + " " + path + " " + m
+	}
+	return method + " " + path
+}
+
+// httpRoute is a registered method, path and match.
+type httpRoute struct {
+	Method   string
+	Path     string
+	Match    zrouter.Match
+	Endpoint *RouterEndpoint
 }
 
 // ServeHTTP implements the http.Handle as a convenience to allow HTTPRouter to be invoked by the standard library HTTP server.
 func (router *httpRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := WithSafeLogFields(r.Context())
-	r = r.WithContext(ctx)
+	r = withPeerIdentity(r.WithContext(ctx))
+	if router.maxHeaderBytes > 0 && headerBytes(r) > router.maxHeaderBytes {
+		router.rejectLargeHeader(w, r)
+		return
+	}
 	router.httpRouter.ServeHTTP(w, r)
 }
 
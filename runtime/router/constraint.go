@@ -0,0 +1,264 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// segmentKind is the kind of a path segment, kinds are declared in order of
+// precedence: when several routes match a url, the route whose first
+// differing segment has the lower kind wins.
+type segmentKind int
+
+const (
+	// e.g. "users"
+	staticSegment segmentKind = iota
+	// e.g. "{id:int}", "{uuid:uuid}" or "{name:[a-z]+}"
+	constrainedSegment
+	// e.g. ":id"
+	paramSegment
+	// "*", which must be the last segment
+	wildcardSegment
+)
+
+// builtinConstraints are the named constraints of constrained params, they
+// take precedence over regular expressions in the order they are listed.
+var builtinConstraints = []struct {
+	name    string
+	pattern string
+}{
+	{"int", `-?[0-9]+`},
+	{"uuid", `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`},
+}
+
+// segment is a parsed path segment.
+type segment struct {
+	kind segmentKind
+	// text is the static text of a static segment or the param name of a
+	// param or constrained segment
+	text string
+	// constraint is the constraint of a constrained segment as written,
+	// rank orders it among constraints, re matches it and canonical is its
+	// simplified regular expression, equivalent constraints such as "int"
+	// and "-?\d+" have the same canonical form
+	constraint string
+	rank       int
+	re         *regexp.Regexp
+	canonical  string
+}
+
+// parseSegments parses the segments of a path that starts with "/" and has
+// no trailing slash.
+func parseSegments(path string) ([]segment, error) {
+	if path == "" {
+		return nil, nil
+	}
+	// a constraint matches a single url segment
+	depth := 0
+	for _, c := range path {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '/':
+			if depth > 0 {
+				return nil, fmt.Errorf("path %q has a constraint that contains \"/\", a constraint matches a single path segment", path)
+			}
+		}
+	}
+	parts := strings.Split(path[1:], "/")
+	segments := make([]segment, len(parts))
+	for i, part := range parts {
+		switch {
+		case part == "*":
+			segments[i] = segment{kind: wildcardSegment}
+		case strings.HasPrefix(part, ":"):
+			segments[i] = segment{kind: paramSegment, text: part[1:]}
+		case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			s, err := parseConstrainedSegment(part)
+			if err != nil {
+				return nil, err
+			}
+			segments[i] = s
+		default:
+			segments[i] = segment{kind: staticSegment, text: part}
+		}
+	}
+	return segments, nil
+}
+
+// parseConstrainedSegment parses a "{name:constraint}" segment, where the
+// constraint is a builtin constraint or a regular expression that must match
+// the whole url segment.
+func parseConstrainedSegment(part string) (segment, error) {
+	inner := part[1 : len(part)-1]
+	idx := strings.Index(inner, ":")
+	if idx <= 0 || idx == len(inner)-1 {
+		return segment{}, fmt.Errorf("path segment %q must be of the form {name:constraint}, use \":name\" for a param without constraint", part)
+	}
+	s := segment{
+		kind:       constrainedSegment,
+		text:       inner[:idx],
+		constraint: inner[idx+1:],
+	}
+	pattern := s.constraint
+	s.rank = len(builtinConstraints)
+	for rank, builtin := range builtinConstraints {
+		if builtin.name == s.constraint {
+			pattern, s.rank = builtin.pattern, rank
+			break
+		}
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return segment{}, fmt.Errorf("path segment %q has an invalid constraint: %s", part, err)
+	}
+	s.re = re
+	// the pattern compiled, so it parses
+	parsed, _ := syntax.Parse(pattern, syntax.Perl)
+	s.canonical = parsed.Simplify().String()
+	return s, nil
+}
+
+// compareSegments orders the segments of two routes by precedence, it
+// returns a negative number when a takes precedence over b, a positive
+// number when b takes precedence over a and 0 when they are the same route.
+// Equivalent constraints are the same, other constrained params with the
+// same builtin rank are ordered by constraint so that the order does not
+// depend on the order routes are registered in.
+func compareSegments(a, b []segment) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, y := a[i], b[i]
+		if x.kind != y.kind {
+			return int(x.kind) - int(y.kind)
+		}
+		switch x.kind {
+		case staticSegment:
+			if x.text != y.text {
+				return strings.Compare(x.text, y.text)
+			}
+		case constrainedSegment:
+			if x.canonical == y.canonical {
+				continue
+			}
+			if x.rank != y.rank {
+				return x.rank - y.rank
+			}
+			if x.constraint != y.constraint {
+				return strings.Compare(x.constraint, y.constraint)
+			}
+		}
+	}
+	return len(a) - len(b)
+}
+
+// constrainedRoute is a route with at least one constrained param.
+type constrainedRoute struct {
+	path     string
+	segments []segment
+	value    http.Handler
+}
+
+// match returns the params of path when it matches the route.
+func (r *constrainedRoute) match(parts []string) ([]Param, bool) {
+	var params []Param
+	for i, s := range r.segments {
+		if s.kind == wildcardSegment {
+			return params, i < len(parts)
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		part := parts[i]
+		switch s.kind {
+		case staticSegment:
+			if part != s.text {
+				return nil, false
+			}
+		case constrainedSegment:
+			if !s.re.MatchString(part) {
+				return nil, false
+			}
+			params = append(params, Param{s.text, part})
+		case paramSegment:
+			if part == "" {
+				return nil, false
+			}
+			params = append(params, Param{s.text, part})
+		}
+	}
+	return params, len(parts) == len(r.segments)
+}
+
+// setConstrained adds a route with constrained params, the routes are kept
+// in order of precedence.
+func (t *Trie) setConstrained(path string, segments []segment, value http.Handler) error {
+	for _, r := range t.constrained {
+		if compareSegments(r.segments, segments) == 0 {
+			return errExist
+		}
+	}
+	t.constrained = append(t.constrained, &constrainedRoute{
+		path:     path,
+		segments: segments,
+		value:    value,
+	})
+	sort.SliceStable(t.constrained, func(i, j int) bool {
+		return compareSegments(t.constrained[i].segments, t.constrained[j].segments) < 0
+	})
+	return nil
+}
+
+// getConstrained returns the route with constrained params that matches
+// path with the highest precedence and whose value is accepted, or nil when
+// none does.
+func (t *Trie) getConstrained(path string, accept func(http.Handler) bool) (*constrainedRoute, []Param) {
+	if len(t.constrained) == 0 {
+		return nil, nil
+	}
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path[1:], "/")
+	}
+	for _, r := range t.constrained {
+		if params, ok := r.match(parts); ok && (accept == nil || accept(r.value)) {
+			return r, params
+		}
+	}
+	return nil, nil
+}
+
+// hasConstraint reports whether the segments have a constrained param.
+func hasConstraint(segments []segment) bool {
+	for _, s := range segments {
+		if s.kind == constrainedSegment {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package router
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Match holds the host and header predicates a route matches on top of its
+// method and path, the zero Match matches every request.
+type Match struct {
+	// Host is the host the request is sent to, without port. A leading "*."
+	// label such as "*.example.com" matches the subdomains of example.com.
+	Host string
+	// Headers are the header values the request must have, the value "*"
+	// only requires the header to be present.
+	Headers map[string]string
+}
+
+// validate checks the host and header names of the match.
+func (m Match) validate() error {
+	if strings.Contains(strings.TrimPrefix(m.Host, "*."), "*") {
+		return fmt.Errorf("host %q must be a hostname, optionally prefixed with \"*.\"", m.Host)
+	}
+	for name := range m.Headers {
+		if name == "" {
+			return fmt.Errorf("header predicates must have a header name")
+		}
+	}
+	return nil
+}
+
+// String returns the predicates of the match in a deterministic order, it
+// is empty for the zero Match.
+func (m Match) String() string {
+	var parts []string
+	if m.Host != "" {
+		parts = append(parts, "host="+strings.ToLower(m.Host))
+	}
+	names := make([]string, 0, len(m.Headers))
+	for name := range m.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, http.CanonicalHeaderKey(name)+"="+m.Headers[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+// matches reports whether the request satisfies the predicates.
+func (m Match) matches(req *http.Request) bool {
+	if m.Host != "" && !matchHost(m.Host, req.Host) {
+		return false
+	}
+	for name, value := range m.Headers {
+		actual, ok := req.Header[http.CanonicalHeaderKey(name)]
+		if !ok || (value != "*" && actual[0] != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchHost reports whether the host of a request, which may have a port,
+// matches an exact or a wildcard host.
+func matchHost(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	pattern, host = strings.ToLower(pattern), strings.ToLower(host)
+	if strings.HasPrefix(pattern, "*.") {
+		suffix := pattern[1:]
+		return len(host) > len(suffix) && strings.HasSuffix(host, suffix)
+	}
+	return pattern == host
+}
+
+// compareMatches orders matches by precedence: exact hosts first, then
+// wildcard hosts from the longest, then no host, and for equal hosts the
+// match with more header predicates first. The result is 0 only for
+// equivalent matches.
+func compareMatches(a, b Match) int {
+	if ra, rb := hostRank(a.Host), hostRank(b.Host); ra != rb {
+		return ra - rb
+	}
+	if len(a.Host) != len(b.Host) {
+		return len(b.Host) - len(a.Host)
+	}
+	if len(a.Headers) != len(b.Headers) {
+		return len(b.Headers) - len(a.Headers)
+	}
+	return strings.Compare(a.String(), b.String())
+}
+
+// Ambiguous reports whether a request can satisfy two different matches
+// while neither takes precedence over the other by its host or its number of
+// header predicates, such a request is served by the match whose String
+// sorts first.
+func Ambiguous(a, b Match) bool {
+	if compareMatches(a, b) == 0 || !strings.EqualFold(a.Host, b.Host) || len(a.Headers) != len(b.Headers) {
+		return false
+	}
+	headers := make(map[string]string, len(b.Headers))
+	for name, value := range b.Headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	for name, value := range a.Headers {
+		other, ok := headers[http.CanonicalHeaderKey(name)]
+		if ok && value != other && value != "*" && other != "*" {
+			return false
+		}
+	}
+	return true
+}
+
+func hostRank(host string) int {
+	switch {
+	case host == "":
+		return 2
+	case strings.HasPrefix(host, "*."):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// matchedHandler is a handler registered with a Match.
+type matchedHandler struct {
+	match   Match
+	handler http.Handler
+}
+
+// routeSet holds the handlers registered for a method and path, sorted by
+// the precedence of their matches. It is the value the tries store.
+type routeSet struct {
+	handlers []matchedHandler
+}
+
+// ServeHTTP serves the request with the handler of the first match the
+// request satisfies, it lets a routeSet be stored in a Trie.
+func (s *routeSet) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if handler := s.pick(req); handler != nil {
+		handler.ServeHTTP(w, req)
+	}
+}
+
+// add registers the handler, it returns false when a handler is registered
+// with an equivalent match.
+func (s *routeSet) add(match Match, handler http.Handler) bool {
+	i := sort.Search(len(s.handlers), func(i int) bool {
+		return compareMatches(s.handlers[i].match, match) >= 0
+	})
+	if i < len(s.handlers) && compareMatches(s.handlers[i].match, match) == 0 {
+		return false
+	}
+	s.handlers = append(s.handlers, matchedHandler{})
+	copy(s.handlers[i+1:], s.handlers[i:])
+	s.handlers[i] = matchedHandler{match: match, handler: handler}
+	return true
+}
+
+// pick returns the handler of the first match the request satisfies, or nil.
+func (s *routeSet) pick(req *http.Request) http.Handler {
+	for _, h := range s.handlers {
+		if h.match.matches(req) {
+			return h.handler
+		}
+	}
+	return nil
+}
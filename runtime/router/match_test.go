@@ -0,0 +1,106 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package router
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchHost(t *testing.T) {
+	cases := []struct {
+		pattern, host string
+		matches       bool
+	}{
+		{"api.example.com", "api.example.com", true},
+		{"api.example.com", "API.Example.com:443", true},
+		{"api.example.com", "example.com", false},
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "a.b.example.com:8080", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", ".example.com", false},
+		{"*.example.com", "evilexample.com", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.matches, matchHost(c.pattern, c.host), "%s %s", c.pattern, c.host)
+	}
+}
+
+func TestMatchHeaders(t *testing.T) {
+	match := Match{Headers: map[string]string{"x-api-version": "2", "x-beta": "*"}}
+	req := httptest.NewRequest("GET", "/", nil)
+	assert.False(t, match.matches(req))
+	req.Header.Set("X-Api-Version", "2")
+	assert.False(t, match.matches(req))
+	req.Header.Set("X-Beta", "")
+	assert.True(t, match.matches(req))
+	req.Header.Set("X-Api-Version", "3")
+	assert.False(t, match.matches(req))
+	assert.True(t, Match{}.matches(req))
+}
+
+func TestCompareMatches(t *testing.T) {
+	ordered := []Match{
+		{Host: "api.example.com", Headers: map[string]string{"a": "1"}},
+		{Host: "api.example.com"},
+		{Host: "*.eu.example.com"},
+		{Host: "*.example.com"},
+		{Headers: map[string]string{"a": "1", "b": "2"}},
+		{Headers: map[string]string{"a": "1"}},
+		{Headers: map[string]string{"b": "1"}},
+		{},
+	}
+	for i := range ordered {
+		for j := range ordered {
+			cmp := compareMatches(ordered[i], ordered[j])
+			switch {
+			case i < j:
+				assert.True(t, cmp < 0, "%v %v", ordered[i], ordered[j])
+			case i > j:
+				assert.True(t, cmp > 0, "%v %v", ordered[i], ordered[j])
+			default:
+				assert.Equal(t, 0, cmp)
+			}
+		}
+	}
+}
+
+func TestAmbiguous(t *testing.T) {
+	cases := []struct {
+		a, b      Match
+		ambiguous bool
+	}{
+		{Match{Headers: map[string]string{"a": "1"}}, Match{Headers: map[string]string{"b": "1"}}, true},
+		{Match{Headers: map[string]string{"a": "1"}}, Match{Headers: map[string]string{"A": "*"}}, true},
+		{Match{Headers: map[string]string{"a": "1"}}, Match{Headers: map[string]string{"a": "2"}}, false},
+		{Match{Headers: map[string]string{"a": "1"}}, Match{Headers: map[string]string{"a": "1"}}, false},
+		{Match{Headers: map[string]string{"a": "1"}}, Match{}, false},
+		{Match{Host: "api.example.com", Headers: map[string]string{"a": "1"}}, Match{Headers: map[string]string{"b": "1"}}, false},
+		{Match{Host: "*.example.com", Headers: map[string]string{"a": "1"}}, Match{Host: "*.Example.com", Headers: map[string]string{"b": "1"}}, true},
+		{Match{Host: "a.example.com"}, Match{Host: "b.example.com"}, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.ambiguous, Ambiguous(c.a, c.b), "%v %v", c.a, c.b)
+		assert.Equal(t, c.ambiguous, Ambiguous(c.b, c.a), "%v %v", c.b, c.a)
+	}
+}
@@ -35,10 +35,16 @@ import (
 // 2. this router does not treat "/a/:b" and "/a/:c" as different routes and therefore does not allow them to be registered at the same time (https://github.com/julienschmidt/httprouter/issues/6)
 // 3. this router does not treat "/a" and "/a/" as different routes
 // 4. this router treats "/a" and "/:b" as different paths for whitelisted paths
+// 5. this router supports constrained params such as "/a/{id:int}", which do not conflict with
+// "/a/b" or "/a/:c", see Trie.Set for how the route of a url is picked
+// 6. this router can register several handlers for a method and path that match on the host
+// and headers of the request, see HandleMatch
 // Also the `*` pattern is greedy, if a handler is register for `/a/*`, then no handler
 // can be further registered for any path that starts with `/a/`
 type Router struct {
 	tries map[string]*Trie
+	// sets are the route sets stored in the tries by method and path
+	sets map[string]*routeSet
 
 	// If enabled, the router checks if another method is allowed for the
 	// current route, if the current request can not be routed.
@@ -55,6 +61,17 @@ type Router struct {
 	// is called.
 	MethodNotAllowed http.Handler
 
+	// If enabled, the router automatically replies to OPTIONS requests with
+	// the allowed methods of the route in the "Allow" header.
+	// Custom OPTIONS handlers take priority over automatic replies.
+	HandleOPTIONS bool
+
+	// Configurable http.Handler which is called on automatic OPTIONS
+	// requests. If it is not set, the reply is a 204 with no body.
+	// The "Allow" header with allowed request methods is set before the
+	// handler is called.
+	GlobalOPTIONS http.Handler
+
 	// Configurable http.Handler which is called when no matching route is
 	// found. If it is not set, http.NotFound is used.
 	NotFound http.Handler
@@ -69,8 +86,6 @@ type Router struct {
 	// Used for special behavior using which different handlers can configured
 	// for paths such as /a and /:b in router.
 	WhitelistedPaths []string
-
-	// TODO: (clu) maybe support OPTIONS
 }
 
 type paramsKey string
@@ -87,30 +102,122 @@ func ParamsFromContext(ctx context.Context) []Param {
 
 // Handle registers a http.Handler for given method and path.
 func (r *Router) Handle(method, path string, handler http.Handler) error {
+	return r.HandleMatch(method, path, Match{}, handler)
+}
+
+// HandleMatch registers a http.Handler for given method and path that only
+// serves the requests satisfying the match. Several handlers can share a
+// method and path as long as their matches differ, the match is evaluated
+// once the path is matched: exact hosts take precedence over wildcard hosts,
+// which take precedence over routes without host, and for the same host the
+// route with more header predicates wins. A request that satisfies no match
+// of the path falls back to the next path that matches its url, e.g. a
+// request to "/users/me" that satisfies no match of "/users/me" is served by
+// "/users/:id", and is not found when there is none.
+func (r *Router) HandleMatch(method, path string, match Match, handler http.Handler) error {
+	if err := match.validate(); err != nil {
+		return err
+	}
+	normalized, err := normalizePath(path)
+	if err != nil {
+		return err
+	}
 	if r.tries == nil {
 		r.tries = make(map[string]*Trie)
+		r.sets = make(map[string]*routeSet)
 	}
 
+	key := method + " " + normalized
+	set, ok := r.sets[key]
+	if !ok {
+		trie, ok := r.tries[method]
+		if !ok {
+			trie = NewTrie()
+			r.tries[method] = trie
+		}
+		set = &routeSet{}
+		err = trie.Set(path, set, r.isWhitelistedPath(path))
+		if err == errExist {
+			return &urlFailure{url: path, method: method}
+		}
+		if err != nil {
+			return err
+		}
+		r.sets[key] = set
+	}
+	if !set.add(match, handler) {
+		return &urlFailure{url: path, method: method, match: match}
+	}
+	return nil
+}
+
+// find returns the handler of the method that serves the request and the
+// params of its path.
+func (r *Router) find(method string, req *http.Request, isWhitelisted bool) (http.Handler, []Param, bool) {
 	trie, ok := r.tries[method]
 	if !ok {
-		trie = NewTrie()
-		r.tries[method] = trie
+		return nil, nil, false
 	}
-	err := trie.Set(path, handler, r.isWhitelistedPath(path))
-	if err == errExist {
-		return &urlFailure{url: path, method: method}
+	value, params, err := trie.getAccepted(req.URL.Path, isWhitelisted, func(value http.Handler) bool {
+		return value.(*routeSet).pick(req) != nil
+	})
+	if err != nil {
+		return nil, nil, false
+	}
+	return value.(*routeSet).pick(req), params, true
+}
+
+// Lookup returns the handler that would serve the request if it had the
+// given method and the params of its path, it is useful to check the route
+// of a request for another method such as the one of a CORS preflight
+// request.
+func (r *Router) Lookup(method string, req *http.Request) (http.Handler, []Param, bool) {
+	return r.find(method, req, r.isWhitelistedPath(req.URL.Path))
+}
+
+// Route is a http.Handler registered for a method, path and match.
+type Route struct {
+	Method  string
+	Path    string
+	Match   Match
+	Handler http.Handler
+}
+
+// Routes returns the registered routes sorted by path and then method, the
+// routes of a method and path are in order of precedence.
+func (r *Router) Routes() []Route {
+	var routes []Route
+	for method, trie := range r.tries {
+		trie.walk(func(path string, value http.Handler) {
+			if path == "" {
+				path = "/"
+			}
+			for _, h := range value.(*routeSet).handlers {
+				routes = append(routes, Route{Method: method, Path: path, Match: h.match, Handler: h.handler})
+			}
+		})
 	}
-	return err
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
 }
 
 // urlFailure captures errors for conflicting paths
 type urlFailure struct {
 	method string
 	url    string
+	match  Match
 }
 
 // Error returns the error string
 func (e *urlFailure) Error() string {
+	if m := e.match.String(); m != "" {
+		return fmt.Sprintf("panic: path: %q method: %q match: %q conflicts with an existing path", e.url, e.method, m)
+	}
 	return fmt.Sprintf("panic: path: %q method: %q conflicts with an existing path", e.url, e.method)
 }
 
@@ -124,19 +231,26 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}(w, req)
 	}
 
-	reqPath := req.URL.Path
-	isWhitelisted := r.isWhitelistedPath(reqPath)
-	if trie, ok := r.tries[req.Method]; ok {
-		if handler, params, err := trie.Get(reqPath, isWhitelisted); err == nil {
-			ctx := context.WithValue(req.Context(), urlParamsKey, params)
-			req = req.WithContext(ctx)
-			handler.ServeHTTP(w, req)
-			return
-		}
+	isWhitelisted := r.isWhitelistedPath(req.URL.Path)
+	if handler, params, ok := r.find(req.Method, req, isWhitelisted); ok {
+		ctx := context.WithValue(req.Context(), urlParamsKey, params)
+		req = req.WithContext(ctx)
+		handler.ServeHTTP(w, req)
+		return
 	}
 
-	if r.HandleMethodNotAllowed {
-		if allowed := r.allowed(reqPath, req.Method, isWhitelisted); allowed != "" {
+	if req.Method == http.MethodOptions && r.HandleOPTIONS {
+		if allowed := r.allowed(req, isWhitelisted); allowed != "" {
+			w.Header().Set("Allow", allowed)
+			if r.GlobalOPTIONS != nil {
+				r.GlobalOPTIONS.ServeHTTP(w, req)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		}
+	} else if r.HandleMethodNotAllowed {
+		if allowed := r.allowed(req, isWhitelisted); allowed != "" {
 			w.Header().Set("Allow", allowed)
 			if r.MethodNotAllowed != nil {
 				r.MethodNotAllowed.ServeHTTP(w, req)
@@ -157,18 +271,21 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (r *Router) allowed(path, reqMethod string, isWhitelisted bool) string {
+func (r *Router) allowed(req *http.Request, isWhitelisted bool) string {
 	var allow []string
 
-	for method, trie := range r.tries {
-		if method == reqMethod || method == http.MethodOptions {
+	for method := range r.tries {
+		if method == req.Method || method == http.MethodOptions {
 			continue
 		}
 
-		if _, _, err := trie.Get(path, isWhitelisted); err == nil {
+		if _, _, ok := r.find(method, req, isWhitelisted); ok {
 			allow = append(allow, method)
 		}
 	}
+	if len(allow) > 0 && r.HandleOPTIONS {
+		allow = append(allow, http.MethodOptions)
+	}
 	sort.Slice(allow, func(i, j int) bool {
 		return allow[i] < allow[j]
 	})
@@ -203,6 +203,203 @@ func TestMethodNotAllowedCustom(t *testing.T) {
 	assert.Equal(t, "GET, POST", res.Result().Header.Get("Allow"))
 }
 
+func TestOPTIONSDefault(t *testing.T) {
+	r := &Router{HandleMethodNotAllowed: true, HandleOPTIONS: true}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	assert.NoError(t, r.Handle("GET", "/foo", handler))
+	assert.NoError(t, r.Handle("POST", "/foo", handler))
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest("OPTIONS", "/foo", nil))
+	assert.Equal(t, http.StatusNoContent, res.Code)
+	assert.Equal(t, "GET, OPTIONS, POST", res.Header().Get("Allow"))
+
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest("PUT", "/foo", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, res.Code)
+	assert.Equal(t, "GET, OPTIONS, POST", res.Header().Get("Allow"))
+
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest("OPTIONS", "/bar", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestOPTIONSCustom(t *testing.T) {
+	var global, custom bool
+	r := &Router{
+		HandleOPTIONS: true,
+		GlobalOPTIONS: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			global = true
+			w.WriteHeader(http.StatusOK)
+		}),
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	assert.NoError(t, r.Handle("GET", "/foo", handler))
+	assert.NoError(t, r.Handle("GET", "/bar", handler))
+	assert.NoError(t, r.Handle("OPTIONS", "/bar",
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			custom = true
+		})))
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest("OPTIONS", "/foo", nil))
+	assert.True(t, global)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "GET, OPTIONS", res.Header().Get("Allow"))
+
+	global = false
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("OPTIONS", "/bar", nil))
+	assert.False(t, global)
+	assert.True(t, custom)
+}
+
+func TestLookup(t *testing.T) {
+	r := &Router{}
+	handled := false
+	assert.NoError(t, r.Handle("GET", "/foo/:id",
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			handled = true
+		})))
+
+	req := httptest.NewRequest("OPTIONS", "/foo/42", nil)
+	handler, params, ok := r.Lookup("GET", req)
+	assert.True(t, ok)
+	assert.Equal(t, []Param{{"id", "42"}}, params)
+	handler.ServeHTTP(nil, nil)
+	assert.True(t, handled)
+
+	_, _, ok = r.Lookup("POST", req)
+	assert.False(t, ok)
+	_, _, ok = r.Lookup("GET", httptest.NewRequest("GET", "/bar", nil))
+	assert.False(t, ok)
+}
+
+func TestHandleMatch(t *testing.T) {
+	r := &Router{HandleMethodNotAllowed: true}
+	var served string
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			served = name
+		})
+	}
+	assert.NoError(t, r.Handle("GET", "/users/:id", handler("default")))
+	assert.NoError(t, r.HandleMatch("GET", "/users/:id", Match{
+		Headers: map[string]string{"x-api-version": "2"},
+	}, handler("v2")))
+	assert.NoError(t, r.HandleMatch("GET", "/users/:id", Match{Host: "*.example.com"}, handler("wildcard")))
+	assert.NoError(t, r.HandleMatch("GET", "/users/:id", Match{Host: "api.example.com"}, handler("exact")))
+	assert.NoError(t, r.HandleMatch("POST", "/users/:id", Match{Host: "api.example.com"}, handler("post")))
+
+	cases := []struct {
+		method, host, version string
+		served                string
+		status                int
+	}{
+		{"GET", "other.com", "", "default", http.StatusOK},
+		{"GET", "other.com", "2", "v2", http.StatusOK},
+		{"GET", "eu.example.com", "2", "wildcard", http.StatusOK},
+		{"GET", "API.example.com:8080", "2", "exact", http.StatusOK},
+		{"GET", "example.com", "", "default", http.StatusOK},
+		{"POST", "api.example.com", "", "post", http.StatusOK},
+		{"POST", "other.com", "", "", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		served = ""
+		req := httptest.NewRequest(c.method, "/users/42", nil)
+		req.Host = c.host
+		if c.version != "" {
+			req.Header.Set("X-Api-Version", c.version)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, c.status, w.Code, "%s %s", c.method, c.host)
+		assert.Equal(t, c.served, served, "%s %s", c.method, c.host)
+	}
+
+	var got []string
+	for _, route := range r.Routes() {
+		got = append(got, route.Method+" "+route.Path+" "+route.Match.String())
+	}
+	assert.Equal(t, []string{
+		"GET /users/:id host=api.example.com",
+		"GET /users/:id host=*.example.com",
+		"GET /users/:id X-Api-Version=2",
+		"GET /users/:id ",
+		"POST /users/:id host=api.example.com",
+	}, got)
+}
+
+func TestHandleMatchConflicts(t *testing.T) {
+	r := &Router{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	match := Match{Host: "api.example.com", Headers: map[string]string{"x-api-version": "2"}}
+	assert.NoError(t, r.HandleMatch("GET", "/a", match, handler))
+
+	err := r.HandleMatch("GET", "/a/", Match{
+		Host:    "API.example.com",
+		Headers: map[string]string{"X-Api-Version": "2"},
+	}, handler)
+	assert.EqualError(t, err,
+		`panic: path: "/a/" method: "GET" match: "host=api.example.com X-Api-Version=2" conflicts with an existing path`)
+
+	assert.NoError(t, r.Handle("GET", "/b/:id", handler))
+	assert.Error(t, r.HandleMatch("GET", "/b/:name", Match{Host: "api.example.com"}, handler))
+
+	assert.Error(t, r.HandleMatch("GET", "/c", Match{Host: "api.*.com"}, handler))
+	assert.Error(t, r.HandleMatch("GET", "/c", Match{Headers: map[string]string{"": "2"}}, handler))
+}
+
+func TestHandleMatchFallback(t *testing.T) {
+	r := &Router{WhitelistedPaths: []string{"/users/me", "/users/:id"}}
+	var served string
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			served = name
+		})
+	}
+	assert.NoError(t, r.Handle("GET", "/users/:id", handler("id")))
+	assert.NoError(t, r.HandleMatch("GET", "/users/me", Match{Host: "api.example.com"}, handler("me")))
+	assert.NoError(t, r.HandleMatch("GET", "/users/{id:int}", Match{Host: "api.example.com"}, handler("int")))
+	assert.NoError(t, r.Handle("GET", "/files/*", handler("files")))
+	assert.NoError(t, r.HandleMatch("GET", "/files/{id:uuid}", Match{Host: "api.example.com"}, handler("uuid")))
+	assert.NoError(t, r.HandleMatch("GET", "/orders/{id:int}", Match{Host: "api.example.com"}, handler("order")))
+
+	uuid := "/files/123e4567-e89b-12d3-a456-426614174000"
+	cases := []struct {
+		host, path string
+		served     string
+		status     int
+	}{
+		{"api.example.com", "/users/me", "me", http.StatusOK},
+		{"other.com", "/users/me", "id", http.StatusOK},
+		{"api.example.com", "/users/42", "int", http.StatusOK},
+		{"other.com", "/users/42", "id", http.StatusOK},
+		{"api.example.com", uuid, "uuid", http.StatusOK},
+		{"other.com", uuid, "files", http.StatusOK},
+		{"api.example.com", "/orders/1", "order", http.StatusOK},
+		{"other.com", "/orders/1", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		served = ""
+		req := httptest.NewRequest("GET", c.path, nil)
+		req.Host = c.host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, c.status, w.Code, "%s %s", c.host, c.path)
+		assert.Equal(t, c.served, served, "%s %s", c.host, c.path)
+	}
+}
+
+func TestHandleMatchWithoutLeadingSlash(t *testing.T) {
+	r := &Router{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	assert.NoError(t, r.Handle("GET", "a", handler))
+	assert.NoError(t, r.HandleMatch("GET", "/a/", Match{Host: "api.example.com"}, handler))
+	assert.Error(t, r.Handle("GET", "/a", handler))
+	assert.Len(t, r.Routes(), 2)
+	assert.Equal(t, errPath, r.Handle("GET", "", handler))
+}
+
 func TestNotFoundDefault(t *testing.T) {
 	r := &Router{}
 
@@ -235,3 +432,62 @@ func TestUrlFailureError(t *testing.T) {
 	}
 	assert.Equalf(t, "panic: path: \"example\" method: \"testmethod\" conflicts with an existing path", e.Error(), "Error()")
 }
+
+func TestRoutes(t *testing.T) {
+	r := &Router{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+
+	assert.Empty(t, r.Routes())
+
+	for _, route := range []struct{ method, path string }{
+		{"GET", "/health"},
+		{"POST", "/a/:b/c"},
+		{"GET", "/a/:b/c"},
+		{"GET", "/a/b"},
+		{"PUT", "/files/*"},
+		{"GET", "/"},
+		{"GET", "/a/{id:int}"},
+	} {
+		assert.NoError(t, r.Handle(route.method, route.path, handler))
+	}
+
+	var got []string
+	for _, route := range r.Routes() {
+		assert.NotNil(t, route.Handler)
+		got = append(got, route.Method+" "+route.Path)
+	}
+	assert.Equal(t, []string{
+		"GET /",
+		"GET /a/:b/c",
+		"POST /a/:b/c",
+		"GET /a/b",
+		"GET /a/{id:int}",
+		"PUT /files/*",
+		"GET /health",
+	}, got)
+}
+
+func TestConstrainedParams(t *testing.T) {
+	r := &Router{HandleMethodNotAllowed: true}
+	var params []Param
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		params = ParamsFromContext(req.Context())
+	})
+	assert.NoError(t, r.Handle("GET", "/users/me", handler))
+	assert.NoError(t, r.Handle("GET", "/users/{id:int}", handler))
+	assert.NoError(t, r.Handle("DELETE", "/users/{id:int}", handler))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []Param{{"id", "42"}}, params)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("PUT", "/users/42", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "DELETE, GET", w.Header().Get("Allow"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/users/bob", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
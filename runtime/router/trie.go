@@ -51,9 +51,11 @@ type Param struct {
 }
 
 // Trie is a radix trie to store string value at given url path,
-// a trie node corresponds to an arbitrary path substring.
+// a trie node corresponds to an arbitrary path substring. Paths with
+// constrained params are kept apart in order of precedence.
 type Trie struct {
-	root *tnode
+	root        *tnode
+	constrained []*constrainedRoute
 }
 
 type tnode struct {
@@ -77,16 +79,18 @@ func NewTrie() *Trie {
 // equality (e.g. url is "/foo" and path is "/foo") or url matches path pattern, which has two forms:
 // - path ends with "/*", e.g. url "/foo" and "/foo/bar" both matches path "/*"
 // - path contains colon wildcard ("/:"), e.g. url "/a/b" and "/a/c" bot matches path "/a/:var"
+// - path contains constrained params ("/{name:constraint}"), e.g. url "/a/1" matches path "/a/{id:int}"
+// but url "/a/b" does not, the constraint is "int", "uuid" or a regular expression without "/"
+// Paths with constrained params only conflict with paths whose constraints are equivalent, e.g.
+// "/a/{id:int}" and "/a/{n:-?[0-9]+}". Otherwise, when several paths match a url
+// the first differing segment decides: a static segment takes precedence over a constrained param,
+// which takes precedence over a colon wildcard, which takes precedence over "*".
 // isWhitelisted - Used for special behavior using which different handlers can configured for paths such as /a and /:b in router
 func (t *Trie) Set(path string, value http.Handler, isWhitelisted bool) error {
-	if path == "" || strings.Contains(path, "//") {
-		return errPath
-	}
-	if !strings.HasPrefix(path, "/") {
-		path = "/" + path
+	path, err := normalizePath(path)
+	if err != nil {
+		return err
 	}
-	// ignore trailing slash
-	path = strings.TrimSuffix(path, "/")
 
 	// validate "*"
 	if strings.Contains(path, "*") && !strings.HasSuffix(path, "/*") {
@@ -96,8 +100,18 @@ func (t *Trie) Set(path string, value http.Handler, isWhitelisted bool) error {
 		return errors.New("path can not contain more than one *")
 	}
 
+	if strings.Contains(path, "{") {
+		segments, err := parseSegments(path)
+		if err != nil {
+			return err
+		}
+		if hasConstraint(segments) {
+			return t.setConstrained(path, segments, value)
+		}
+	}
+
 	colonAsPattern := !isWhitelisted
-	err := t.root.set(path, value, false, false, colonAsPattern, isWhitelisted)
+	err = t.root.set(path, value, false, false, colonAsPattern, isWhitelisted)
 
 	if e, ok := err.(*paramMismatch); ok {
 		return fmt.Errorf("path %q has a different param key %q, it should be the same key %q as in existing path %q", path, e.actual, e.expected, e.existingPath)
@@ -110,16 +124,54 @@ func (t *Trie) Set(path string, value http.Handler, isWhitelisted bool) error {
 // "/:foo/bar", then calling Get with path "/xyz/bar" returns a param whose key is "foo" and value is "xyz".
 // isWhitelisted - Used for special behavior using which different handlers can configured for paths such as /a and /:b in router
 func (t *Trie) Get(path string, isWhitelisted bool) (http.Handler, []Param, error) {
+	return t.getAccepted(path, isWhitelisted, nil)
+}
+
+// getAccepted is Get among the values that accept returns true for, when
+// the value of the path with the highest precedence is not accepted the
+// path with the next highest precedence is tried. A nil accept accepts
+// every value.
+func (t *Trie) getAccepted(path string, isWhitelisted bool, accept func(http.Handler) bool) (http.Handler, []Param, error) {
+	path, err := normalizePath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	colonAsPattern := isWhitelisted
+	value, params, pattern, err := t.root.get(path, false, false, colonAsPattern, isWhitelisted, accept)
+
+	route, constrainedParams := t.getConstrained(path, accept)
+	if route == nil {
+		return value, params, err
+	}
+	if err == nil {
+		// the pattern was registered, so it parses
+		segments, _ := parseSegments(pattern)
+		if compareSegments(segments, route.segments) < 0 {
+			return value, params, nil
+		}
+	}
+	return route.value, constrainedParams, nil
+}
+
+// normalizePath validates a path and returns it with a leading slash and
+// without trailing slash.
+func normalizePath(path string) (string, error) {
 	if path == "" || strings.Contains(path, "//") {
-		return nil, nil, errPath
+		return "", errPath
 	}
 	if !strings.HasPrefix(path, "/") {
 		path = "/" + path
 	}
 	// ignore trailing slash
-	path = strings.TrimSuffix(path, "/")
-	colonAsPattern := isWhitelisted
-	return t.root.get(path, false, false, colonAsPattern, isWhitelisted)
+	return strings.TrimSuffix(path, "/"), nil
+}
+
+// walk calls fn with every path that holds a value.
+func (t *Trie) walk(fn func(path string, value http.Handler)) {
+	t.root.walk("", fn)
+	for _, r := range t.constrained {
+		fn(r.path, r.value)
+	}
 }
 
 // set sets the handler for given path, creates new child node if necessary
@@ -175,7 +227,7 @@ func (t *tnode) set(path string, value http.Handler, lastKeyCharSlash, lastPathC
 	// already exists for the path.
 	if keyMatchIdx == keyLength {
 		for _, c := range t.children {
-			if _, _, err := c.get(path[pathMatchIdx:], lastKeyCharSlash, lastPathCharSlash, colonAsPattern, isWhitelisted); err == nil {
+			if _, _, _, err := c.get(path[pathMatchIdx:], lastKeyCharSlash, lastPathCharSlash, colonAsPattern, isWhitelisted, nil); err == nil {
 				return errExist
 			}
 		}
@@ -246,7 +298,9 @@ func (t *tnode) set(path string, value http.Handler, lastKeyCharSlash, lastPathC
 	return nil
 }
 
-func (t *tnode) get(path string, lastKeyCharSlash, lastPathCharSlash, colonAsPattern, isWhitelistedPath bool) (http.Handler, []Param, error) {
+// get returns the value for given path along with the url params and the
+// path pattern that matched, values that are not accepted are skipped.
+func (t *tnode) get(path string, lastKeyCharSlash, lastPathCharSlash, colonAsPattern, isWhitelistedPath bool, accept func(http.Handler) bool) (http.Handler, []Param, string, error) {
 	keyLength, pathLength := len(t.key), len(path)
 	var params []Param
 
@@ -279,33 +333,41 @@ func (t *tnode) get(path string, lastKeyCharSlash, lastPathCharSlash, colonAsPat
 	if keyIdx < keyLength {
 		// path matches up to node key's second to last character,
 		// the last char of node key is "*" and path is no shorter than longest matched prefix
-		if t.key[keyIdx:] == "*" && pathIdx < pathLength {
-			return t.value, params, nil
+		if t.key[keyIdx:] == "*" && pathIdx < pathLength && t.accepts(accept) {
+			return t.value, params, t.key, nil
 		}
-		return nil, nil, errNotFound
+		return nil, nil, "", errNotFound
 	}
 
 	// ':' in path matches '*' in node key
 	if keyIdx > 0 && t.key[keyIdx-1] == '*' {
-		return t.value, params, nil
+		if t.accepts(accept) {
+			return t.value, params, t.key, nil
+		}
+		return nil, nil, "", errNotFound
 	}
 
 	// longest matched prefix matches up to node key length and path length
 	if pathIdx == pathLength {
-		if t.value != nil {
-			return t.value, params, nil
+		if t.value != nil && t.accepts(accept) {
+			return t.value, params, t.key, nil
 		}
-		return nil, nil, errNotFound
+		return nil, nil, "", errNotFound
 	}
 
 	// longest matched prefix matches up to node key length but not path length
 	for _, c := range t.children {
-		if v, ps, err := c.get(path[pathIdx:], lastKeyCharSlash, lastPathCharSlash, colonAsPattern, isWhitelistedPath); err == nil {
-			return v, append(params, ps...), nil
+		if v, ps, pattern, err := c.get(path[pathIdx:], lastKeyCharSlash, lastPathCharSlash, colonAsPattern, isWhitelistedPath, accept); err == nil {
+			return v, append(params, ps...), t.key + pattern, nil
 		}
 	}
 
-	return nil, nil, errNotFound
+	return nil, nil, "", errNotFound
+}
+
+// accepts reports whether accept, which may be nil, accepts the value.
+func (t *tnode) accepts(accept func(http.Handler) bool) bool {
+	return accept == nil || accept(t.value)
 }
 
 func (t *tnode) addChildren(child *tnode, lastPathCharSlash bool) {
@@ -318,6 +380,17 @@ func (t *tnode) addChildren(child *tnode, lastPathCharSlash bool) {
 	}
 }
 
+// walk calls fn with the full path of every node that holds a value.
+func (t *tnode) walk(prefix string, fn func(path string, value http.Handler)) {
+	path := prefix + t.key
+	if t.value != nil {
+		fn(path, t.value)
+	}
+	for _, c := range t.children {
+		c.walk(path, fn)
+	}
+}
+
 func (t *tnode) isSetWildCardPattern(path string, keyIdx, pathIdx int, lastKeyCharSlash, lastPathCharSlash, isWhitelistedPath bool) bool {
 	if isWhitelistedPath {
 		// For whitelisted paths, it will treat as wild card pattern only if key and path params are :var
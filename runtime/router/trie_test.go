@@ -303,6 +303,82 @@ func TestTriePathsWithPatten(t *testing.T) {
 	runTrieTestsWithWhitelist(t, NewTrie(), tests, true)
 }
 
+func TestTrieConstrainedParams(t *testing.T) {
+	tests := []ts{
+		// constrained params do not conflict with static segments
+		{op: set, path: "/users/{id:int}", value: "int"},
+		{op: set, path: "/users/me", value: "me"},
+		{op: set, path: "/users/{uuid:uuid}", value: "uuid"},
+		{op: set, path: "/users/{name:[a-z]+}", value: "name"},
+		{op: set, path: "/users/{id:int}/friends", value: "friends"},
+		{op: set, path: "/users/{key:int}", errMsg: errExist.Error()},
+		{op: set, path: "/users/{id:int}/", errMsg: errExist.Error()},
+		// equivalent constraints are the same route
+		{op: set, path: "/users/{n:-?[0-9]+}", errMsg: errExist.Error()},
+		{op: set, path: "/users/{n:-?\\d+}/friends", errMsg: errExist.Error()},
+		{op: set, path: "/users/{name:[a-z]{1,}}", errMsg: errExist.Error()},
+		// static segments come first
+		{op: get, path: "/users/me", expectedValue: "me"},
+		// then builtin constraints in order
+		{op: get, path: "/users/42", expectedValue: "int", expectedParams: []Param{{"id", "42"}}},
+		{op: get, path: "/users/-1/", expectedValue: "int", expectedParams: []Param{{"id", "-1"}}},
+		{op: get, path: "/users/123e4567-e89b-12d3-a456-426614174000", expectedValue: "uuid",
+			expectedParams: []Param{{"uuid", "123e4567-e89b-12d3-a456-426614174000"}}},
+		// then regular expressions
+		{op: get, path: "/users/bob", expectedValue: "name", expectedParams: []Param{{"name", "bob"}}},
+		{op: get, path: "/users/Bob", errMsg: errNotFound.Error()},
+		{op: get, path: "/users/42/friends", expectedValue: "friends", expectedParams: []Param{{"id", "42"}}},
+		{op: get, path: "/users/bob/friends", errMsg: errNotFound.Error()},
+		{op: get, path: "/users", errMsg: errNotFound.Error()},
+	}
+	runTrieTests(t, NewTrie(), tests)
+
+	tests = []ts{
+		// the first differing segment decides
+		{op: set, path: "/a/*", value: "wildcard"},
+		{op: set, path: "/a/{id:int}/b", value: "int"},
+		{op: set, path: "/a/:x/{y:int}", value: "param"},
+		{op: set, path: "/{v:v[0-9]+}/a", value: "version"},
+		// nor with colon wildcards, which come after constrained params
+		{op: set, path: "/b/:name", value: "name"},
+		{op: set, path: "/b/{id:int}", value: "id"},
+		{op: get, path: "/b/1", expectedValue: "id", expectedParams: []Param{{"id", "1"}}},
+		{op: get, path: "/b/x", expectedValue: "name", expectedParams: []Param{{"name", "x"}}},
+		{op: get, path: "/a/1/b", expectedValue: "int", expectedParams: []Param{{"id", "1"}}},
+		{op: get, path: "/a/1/2", expectedValue: "param", expectedParams: []Param{{"x", "1"}, {"y", "2"}}},
+		{op: get, path: "/a/1/c", expectedValue: "wildcard"},
+		{op: get, path: "/a", errMsg: errNotFound.Error()},
+		{op: get, path: "/v2/a", expectedValue: "version", expectedParams: []Param{{"v", "v2"}}},
+	}
+	runTrieTests(t, NewTrie(), tests)
+
+	tests = []ts{
+		{op: set, path: "/a/{id}", errMsg: `path segment "{id}" must be of the form {name:constraint}, use ":name" for a param without constraint`},
+		{op: set, path: "/a/{id:}", errMsg: `path segment "{id:}" must be of the form {name:constraint}, use ":name" for a param without constraint`},
+		{op: set, path: "/a/{id:[0-9/]+}", errMsg: `path "/a/{id:[0-9/]+}" has a constraint that contains "/", a constraint matches a single path segment`},
+		{op: set, path: "/a/{id:(x/y)}/b", errMsg: `path "/a/{id:(x/y)}/b" has a constraint that contains "/", a constraint matches a single path segment`},
+		{op: set, path: "/a/{id:[}", errMsg: "path segment \"{id:[}\" has an invalid constraint: error parsing regexp: missing closing ]: `[)$`"},
+	}
+	runTrieTests(t, NewTrie(), tests)
+}
+
+func TestTrieConstrainedPrecedenceIsDeterministic(t *testing.T) {
+	paths := []string{"/a/{x:[0-9]+}", "/a/{x:[0-9a-f]+}", "/a/{x:int}"}
+	for i := range paths {
+		trie := NewTrie()
+		for j := range paths {
+			path := paths[(i+j)%len(paths)]
+			assert.NoError(t, trie.Set(path, namedHandler{id: path}, false))
+		}
+		v, _, err := trie.Get("/a/12", false)
+		assert.NoError(t, err)
+		assert.Equal(t, "/a/{x:int}", v.(namedHandler).id)
+		v, _, err = trie.Get("/a/ff", false)
+		assert.NoError(t, err)
+		assert.Equal(t, "/a/{x:[0-9a-f]+}", v.(namedHandler).id)
+	}
+}
+
 // simple test for coverage
 func TestParamMismatch(t *testing.T) {
 	pm := paramMismatch{
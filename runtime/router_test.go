@@ -22,11 +22,14 @@ package zanzibar
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 	"github.com/uber-go/tally"
@@ -161,6 +164,361 @@ func (s *routerSuite) TestRouteConflictVariable() {
 	s.Equal([]byte("foo a\n"), body)
 }
 
+func (s *routerSuite) newDeadlineEndpoint(timeout time.Duration, status int) *RouterEndpoint {
+	deps := &DefaultDependencies{
+		ContextLogger: s.gw.ContextLogger,
+		Scope:         s.gw.RootScope,
+		Config:        s.gw.Config,
+	}
+	endpoint := NewRouterEndpoint(nil, deps, "deadline", "slow",
+		func(ctx context.Context, req *ServerHTTPRequest, res *ServerHTTPResponse) context.Context {
+			<-ctx.Done()
+			res.SendErrorString(status, "downstream failed")
+			return ctx
+		},
+	)
+	endpoint.Timeout = timeout
+	return endpoint
+}
+
+func (s *routerSuite) TestEndpointTimeout() {
+	s.NoError(s.router.Handle("GET", "/slow", s.newDeadlineEndpoint(10*time.Millisecond, 500)))
+
+	req := httptest.NewRequest("GET", "/slow", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusGatewayTimeout, w.Code)
+	body, _ := io.ReadAll(w.Result().Body)
+	s.Equal(`{"error":"Request deadline exceeded"}`, string(body))
+
+	var exceeded int64
+	for _, c := range s.scope.Snapshot().Counters() {
+		if c.Name() == endpointDeadlineExceeded && c.Tags()[scopeTagHandler] == "slow" {
+			exceeded += c.Value()
+		}
+	}
+	s.Equal(int64(1), exceeded)
+}
+
+func (s *routerSuite) TestEndpointTimeoutKeepsClientErrors() {
+	s.NoError(s.router.Handle("GET", "/slow", s.newDeadlineEndpoint(10*time.Millisecond, 400)))
+
+	req := httptest.NewRequest("GET", "/slow", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (s *routerSuite) TestContextTTLHeader() {
+	// the caller's budget wins when it is shorter than the endpoint timeout
+	s.NoError(s.router.Handle("GET", "/slow", s.newDeadlineEndpoint(time.Minute, 500)))
+
+	req := httptest.NewRequest("GET", "/slow", nil)
+	req.Header.Set(ContextTTLHeader, "10")
+	w := httptest.NewRecorder()
+	start := time.Now()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusGatewayTimeout, w.Code)
+	s.True(time.Since(start) < time.Second)
+}
+
+func (s *routerSuite) rejectedCount(name, reason string) int64 {
+	var count int64
+	for _, c := range s.scope.Snapshot().Counters() {
+		if c.Name() == name && c.Tags()[scopeTagReason] == reason {
+			count += c.Value()
+		}
+	}
+	return count
+}
+
+func (s *routerSuite) TestMaxBodyBytes() {
+	deps := &DefaultDependencies{
+		ContextLogger: s.gw.ContextLogger,
+		Scope:         s.gw.RootScope,
+		Config:        s.gw.Config,
+	}
+	endpoint := NewRouterEndpoint(nil, deps, "limits", "upload",
+		func(ctx context.Context, req *ServerHTTPRequest, res *ServerHTTPResponse) context.Context {
+			if body, ok := req.ReadAll(); ok {
+				res.WriteJSONBytes(200, nil, body)
+			}
+			return ctx
+		},
+	)
+	endpoint.MaxBodyBytes = 8
+	s.NoError(s.router.Handle("POST", "/upload", endpoint))
+
+	cases := []struct {
+		body          string
+		contentLength int64
+		status        int
+	}{
+		{"12345678", 8, http.StatusOK},
+		{"123456789", 9, http.StatusRequestEntityTooLarge},
+		// chunked bodies are only caught while being read
+		{"123456789", -1, http.StatusRequestEntityTooLarge},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", "/upload", bytes.NewBufferString(c.body))
+		req.ContentLength = c.contentLength
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(c.status, w.Code, c.body)
+	}
+	s.Equal(int64(2), s.rejectedCount(endpointRejected, rejectedBodyTooLarge))
+}
+
+func (s *routerSuite) TestMaxHeaderBytes() {
+	s.router.maxHeaderBytes = 256
+	s.NoError(s.router.Handle("GET", "/small", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("small\n"))
+	})))
+
+	req := httptest.NewRequest("GET", "/small", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/small", nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 256))
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusRequestHeaderFieldsTooLarge, w.Code)
+	s.Equal(int64(1), s.rejectedCount(serverRejected, rejectedHeaderTooLarge))
+}
+
+func (s *routerSuite) TestRateLimit() {
+	s.gw.RateLimiter = NewRateLimiter(s.gw.Config)
+	deps := &DefaultDependencies{
+		ContextLogger: s.gw.ContextLogger,
+		Scope:         s.gw.RootScope,
+		Config:        s.gw.Config,
+	}
+	endpoint := NewRouterEndpoint(nil, deps, "limits", "hello",
+		func(ctx context.Context, req *ServerHTTPRequest, res *ServerHTTPResponse) context.Context {
+			res.WriteJSONBytes(200, nil, []byte(`"hello"`))
+			return ctx
+		},
+	)
+	endpoint.RateLimit = &RateLimitOptions{RPS: 1, Burst: 1}
+	s.NoError(s.router.Handle("GET", "/hello", endpoint))
+
+	req := httptest.NewRequest("GET", "/hello", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/hello", nil)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusTooManyRequests, w.Code)
+	s.Equal("1", w.Header().Get("Retry-After"))
+	s.Equal(int64(1), s.rejectedCount(rateLimitRejected, rejectedRate))
+}
+
+func (s *routerSuite) TestLoadShedding() {
+	s.gw.LoadShedder = NewLoadShedder(
+		NewStaticConfigOrDie(nil, map[string]interface{}{
+			"loadShedding.enabled":           true,
+			"loadShedding.inflightThreshold": 1,
+		}),
+		nil, func() int64 { return 1 }, s.gw.RootScope,
+	)
+	deps := &DefaultDependencies{
+		ContextLogger: s.gw.ContextLogger,
+		Scope:         s.gw.RootScope,
+		Config:        s.gw.Config,
+	}
+	newEndpoint := func(handlerID string, criticality Criticality) *RouterEndpoint {
+		endpoint := NewRouterEndpoint(nil, deps, "shed", handlerID,
+			func(ctx context.Context, req *ServerHTTPRequest, res *ServerHTTPResponse) context.Context {
+				res.WriteJSONBytes(200, nil, []byte(`"ok"`))
+				return ctx
+			},
+		)
+		endpoint.Criticality = criticality
+		return endpoint
+	}
+	s.NoError(s.router.Handle("GET", "/sheddable", newEndpoint("sheddable", CriticalitySheddable)))
+	s.NoError(s.router.Handle("GET", "/critical", newEndpoint("critical", CriticalityCritical)))
+
+	req := httptest.NewRequest("GET", "/sheddable", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusServiceUnavailable, w.Code)
+
+	req = httptest.NewRequest("GET", "/critical", nil)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+
+	var shed int64
+	for _, c := range s.scope.Snapshot().Counters() {
+		if c.Name() == loadShedRejected && c.Tags()[scopeTagShedLevel] == "4" {
+			shed += c.Value()
+		}
+	}
+	s.Equal(int64(1), shed)
+}
+
+func (s *routerSuite) TestCORS() {
+	deps := &DefaultDependencies{
+		ContextLogger: s.gw.ContextLogger,
+		Scope:         s.gw.RootScope,
+		Config:        s.gw.Config,
+	}
+	endpoint := NewRouterEndpoint(nil, deps, "cors", "hello",
+		func(ctx context.Context, req *ServerHTTPRequest, res *ServerHTTPResponse) context.Context {
+			res.WriteJSONBytes(200, nil, []byte(`"hello"`))
+			return ctx
+		},
+	)
+	endpoint.CORS = &CORSOptions{
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedHeaders: []string{"X-Token"},
+		MaxAge:         time.Minute,
+	}
+	s.NoError(s.router.Handle("POST", "/hello", endpoint))
+	s.NoError(s.router.Handle("GET", "/hello", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	req := httptest.NewRequest("OPTIONS", "/hello", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "X-Token")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusNoContent, w.Code)
+	s.Equal("GET, OPTIONS, POST", w.Header().Get("Allow"))
+	s.Equal("https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	s.Equal("GET, OPTIONS, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	s.Equal("X-Token", w.Header().Get("Access-Control-Allow-Headers"))
+	s.Equal("60", w.Header().Get("Access-Control-Max-Age"))
+
+	// the GET handler has the policy of the gateway, which has none
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusNoContent, w.Code)
+	s.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+
+	req = httptest.NewRequest("POST", "/hello", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	req = httptest.NewRequest("POST", "/hello", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+	s.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func (s *routerSuite) TestMatchHostAndHeaders() {
+	deps := &DefaultDependencies{
+		ContextLogger: s.gw.ContextLogger,
+		Scope:         s.gw.RootScope,
+		Config:        s.gw.Config,
+	}
+	newEndpoint := func(handlerID string) *RouterEndpoint {
+		return NewRouterEndpoint(nil, deps, "users", handlerID,
+			func(ctx context.Context, req *ServerHTTPRequest, res *ServerHTTPResponse) context.Context {
+				res.WriteJSONBytes(200, nil, []byte(`"`+handlerID+`"`))
+				return ctx
+			},
+		)
+	}
+	v1 := newEndpoint("v1")
+	v2 := newEndpoint("v2")
+	v2.MatchHeaders = map[string]string{"x-api-version": "2"}
+	vhost := newEndpoint("vhost")
+	vhost.MatchHost = "*.example.com"
+	s.NoError(s.router.Handle("GET", "/users", v1))
+	s.NoError(s.router.Handle("GET", "/users", v2))
+	s.NoError(s.router.Handle("GET", "/users", vhost))
+	s.Error(s.router.Handle("GET", "/users", newEndpoint("v1")))
+
+	cases := []struct {
+		host, version, body string
+	}{
+		{"localhost", "", `"v1"`},
+		{"localhost", "2", `"v2"`},
+		{"api.example.com", "2", `"vhost"`},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.Host = c.host
+		if c.version != "" {
+			req.Header.Set("x-api-version", c.version)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusOK, w.Code)
+		s.Equal(c.body, w.Body.String())
+	}
+
+	var handlers []string
+	for _, r := range s.router.routes() {
+		handlers = append(handlers, r.Endpoint.HandlerName)
+	}
+	s.Equal([]string{"vhost", "v2", "v1"}, handlers)
+}
+
+func (s *routerSuite) TestAPIVersions() {
+	deps := &DefaultDependencies{
+		ContextLogger: s.gw.ContextLogger,
+		Scope:         s.gw.RootScope,
+		Config:        s.gw.Config,
+	}
+	endpoint := NewRouterEndpoint(nil, deps, "users", "list",
+		func(ctx context.Context, req *ServerHTTPRequest, res *ServerHTTPResponse) context.Context {
+			res.WriteJSONBytes(200, nil, []byte(`"users"`))
+			return ctx
+		},
+	)
+	sunset := time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+	for _, version := range []APIVersion{
+		{Name: "v1", PathPrefix: "/v1", Deprecated: true, Sunset: sunset},
+		{Name: "v2", Headers: map[string]string{"x-api-version": "2"}},
+		{Name: "v3", Deprecated: true, DeprecatedSince: time.Unix(1700000000, 0)},
+	} {
+		s.NoError(s.router.Handle("GET", version.Path("/users"), endpoint.WithAPIVersion(version)))
+	}
+	s.Nil(endpoint.APIVersion)
+
+	req := httptest.NewRequest("GET", "/v1/users", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("true", w.Header().Get("Deprecation"))
+	s.Equal("Wed, 30 Jun 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+
+	req = httptest.NewRequest("GET", "/users", nil)
+	req.Header.Set("x-api-version", "2")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+	s.Empty(w.Header().Get("Deprecation"))
+
+	req = httptest.NewRequest("GET", "/users", nil)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("@1700000000", w.Header().Get("Deprecation"))
+	s.Empty(w.Header().Get("Sunset"))
+
+	usage := map[string]int64{}
+	for _, c := range s.scope.Snapshot().Counters() {
+		if c.Name() == endpointDeprecatedRequests {
+			usage[c.Tags()[scopeTagAPIVersion]] += c.Value()
+		}
+	}
+	s.Equal(map[string]int64{"v1": 1, "v3": 1}, usage)
+}
+
 func TestRouterSuite(t *testing.T) {
 	s := new(routerSuite)
 	suite.Run(t, s)
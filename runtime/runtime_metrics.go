@@ -52,6 +52,8 @@ type runtimeMetrics struct {
 	numCPUs tally.Gauge
 	// number of goroutines that currently exist
 	numGoRoutines tally.Gauge
+	// share of GOMAXPROCS used by the process since the last collection
+	cpuUtilization tally.Gauge
 
 	// bytes of allocated heap objects
 	heapAlloc tally.Gauge
@@ -78,6 +80,17 @@ type runtimeCollector struct {
 	running      bool // protected by runningMutex
 	stop         chan struct{}
 	lastNumGC    uint32
+
+	statsMutex  sync.RWMutex
+	lastStats   runtimeStats // protected by statsMutex
+	lastCPUTime time.Duration
+	lastCPUAt   time.Time
+}
+
+// runtimeStats is the load of the process at the last collection.
+type runtimeStats struct {
+	cpuUtilization float64
+	numGoroutines  int
 }
 
 // StartRuntimeMetricsCollector starts collecting runtime metrics periodically.
@@ -112,9 +125,10 @@ func NewRuntimeMetricsCollector(
 		scope: scope,
 		metrics: runtimeMetrics{
 			// CPU
-			goMaxProcs:    scope.Gauge("gomaxprocs"),
-			numCPUs:       scope.Gauge("num-cpu"),
-			numGoRoutines: scope.Gauge("num-goroutines"),
+			goMaxProcs:     scope.Gauge("gomaxprocs"),
+			numCPUs:        scope.Gauge("num-cpu"),
+			numGoRoutines:  scope.Gauge("num-goroutines"),
+			cpuUtilization: scope.Gauge("cpu-utilization"),
 
 			// Memory
 			heapAlloc:  scope.Gauge("memory.heap"),
@@ -127,9 +141,11 @@ func NewRuntimeMetricsCollector(
 			gcPauseMs:     scope.Timer("memory.gc-pause-ms"),
 			gcPauseMsHist: scope.Histogram("memory.gc-pause-ms-hist", tally.DefaultBuckets),
 		},
-		running:   false,
-		stop:      make(chan struct{}),
-		lastNumGC: memstats.NumGC,
+		running:     false,
+		stop:        make(chan struct{}),
+		lastNumGC:   memstats.NumGC,
+		lastCPUTime: processCPUTime(),
+		lastCPUAt:   time.Now(),
 	}
 }
 
@@ -193,9 +209,30 @@ func (r *runtimeCollector) collect() {
 }
 
 func (r *runtimeCollector) collectCPUMetrics() {
-	r.metrics.goMaxProcs.Update(float64(runtime.GOMAXPROCS(0)))
+	maxProcs := runtime.GOMAXPROCS(0)
+	numGoroutines := runtime.NumGoroutine()
+	r.metrics.goMaxProcs.Update(float64(maxProcs))
 	r.metrics.numCPUs.Update(float64(runtime.NumCPU()))
-	r.metrics.numGoRoutines.Update(float64(runtime.NumGoroutine()))
+	r.metrics.numGoRoutines.Update(float64(numGoroutines))
+
+	now, cpuTime := time.Now(), processCPUTime()
+	var utilization float64
+	if elapsed := now.Sub(r.lastCPUAt); elapsed > 0 {
+		utilization = float64(cpuTime-r.lastCPUTime) / float64(elapsed) / float64(maxProcs)
+	}
+	r.lastCPUTime, r.lastCPUAt = cpuTime, now
+	r.metrics.cpuUtilization.Update(utilization)
+
+	r.statsMutex.Lock()
+	r.lastStats = runtimeStats{cpuUtilization: utilization, numGoroutines: numGoroutines}
+	r.statsMutex.Unlock()
+}
+
+// stats returns the load of the process at the last CPU metrics collection.
+func (r *runtimeCollector) stats() runtimeStats {
+	r.statsMutex.RLock()
+	defer r.statsMutex.RUnlock()
+	return r.lastStats
 }
 
 func (r *runtimeCollector) collectMemMetrics(memStats *runtime.MemStats) {
@@ -0,0 +1,69 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// configureHTTP2 enables HTTP/2 on the server when "http.http2.enabled" is
+// set, over TLS when the server has a TLS config and over cleartext (h2c)
+// when "http.http2.h2c" is set as well. Servers only speak HTTP/1.1 otherwise.
+func configureHTTP2(config *StaticConfig, server *http.Server) error {
+	if !config.ContainsKey("http.http2.enabled") || !config.MustGetBoolean("http.http2.enabled") {
+		// the standard library negotiates HTTP/2 over TLS unless told not to
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		return nil
+	}
+
+	h2s := &http2.Server{}
+	if config.ContainsKey("http.http2.maxConcurrentStreams") {
+		h2s.MaxConcurrentStreams = uint32(config.MustGetInt("http.http2.maxConcurrentStreams"))
+	}
+	if config.ContainsKey("http.http2.maxReadFrameSize") {
+		h2s.MaxReadFrameSize = uint32(config.MustGetInt("http.http2.maxReadFrameSize"))
+	}
+	if config.ContainsKey("http.http2.idleTimeout") {
+		h2s.IdleTimeout = time.Duration(config.MustGetInt("http.http2.idleTimeout")) * time.Millisecond
+	}
+
+	if server.TLSConfig != nil {
+		if err := http2.ConfigureServer(server, h2s); err != nil {
+			return errors.Wrap(err, "error configuring HTTP/2 over TLS")
+		}
+	}
+	if config.ContainsKey("http.http2.h2c") && config.MustGetBoolean("http.http2.h2c") {
+		server.Handler = h2c.NewHandler(server.Handler, h2s)
+	}
+	return nil
+}
+
+// protocolVersion returns the HTTP version of the request, such as "1.1" or "2.0".
+func protocolVersion(r *http.Request) string {
+	return strconv.Itoa(r.ProtoMajor) + "." + strconv.Itoa(r.ProtoMinor)
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+)
+
+func serveHTTP2Test(t *testing.T, values map[string]interface{}, tlsConfig *tls.Config) string {
+	server := &HTTPServer{
+		Server: &http.Server{
+			Addr: "127.0.0.1:0",
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(protocolVersion(r)))
+			}),
+			TLSConfig: tlsConfig,
+		},
+		Logger: zap.NewNop(),
+	}
+	require.NoError(t, configureHTTP2(NewStaticConfigOrDie(nil, values), server.Server))
+	_, err := server.JustListen()
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go server.JustServe(&wg)
+	t.Cleanup(func() {
+		server.Close()
+		wg.Wait()
+	})
+	return server.RealAddr
+}
+
+func getProtocolVersion(t *testing.T, client *http.Client, url string) string {
+	res, err := client.Get(url)
+	require.NoError(t, err)
+	defer func() { _ = res.Body.Close() }()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestH2C(t *testing.T) {
+	addr := serveHTTP2Test(t, map[string]interface{}{
+		"http.http2.enabled":              true,
+		"http.http2.h2c":                  true,
+		"http.http2.maxConcurrentStreams": 10,
+	}, nil)
+
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+	assert.Equal(t, "2.0", getProtocolVersion(t, h2cClient, "http://"+addr+"/"))
+
+	// HTTP/1.1 clients are still served
+	assert.Equal(t, "1.1", getProtocolVersion(t, &http.Client{}, "http://"+addr+"/"))
+}
+
+func TestHTTP2OverTLS(t *testing.T) {
+	files := newTestTLSFiles(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(files.ca.cert)
+	newClient := func() *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots},
+			ForceAttemptHTTP2: true,
+		}}
+	}
+	newTLSConfig := func() *tls.Config {
+		reloader, err := newCertReloader(files.certFile, files.keyFile, "", 0, zap.NewNop(), tally.NoopScope)
+		require.NoError(t, err)
+		return reloader.tlsConfig(tls.NoClientCert, tls.VersionTLS12)
+	}
+	localhost := func(addr string) string {
+		_, port, err := net.SplitHostPort(addr)
+		require.NoError(t, err)
+		return "https://localhost:" + port + "/"
+	}
+
+	addr := serveHTTP2Test(t, map[string]interface{}{
+		"http.http2.enabled": true,
+	}, newTLSConfig())
+	assert.Equal(t, "2.0", getProtocolVersion(t, newClient(), localhost(addr)))
+
+	// HTTP/2 is opt-in
+	addr = serveHTTP2Test(t, map[string]interface{}{}, newTLSConfig())
+	assert.Equal(t, "1.1", getProtocolVersion(t, newClient(), localhost(addr)))
+}
@@ -86,9 +86,10 @@ func NewServerHTTPRequest(
 
 	// put request scope tags on context
 	scopeTags := map[string]string{
-		scopeTagEndpoint: endpoint.EndpointName,
-		scopeTagHandler:  endpoint.HandlerName,
-		scopeTagProtocol: scopeTagHTTP,
+		scopeTagEndpoint:        endpoint.EndpointName,
+		scopeTagHandler:         endpoint.HandlerName,
+		scopeTagProtocol:        scopeTagHTTP,
+		scopeTagProtocolVersion: protocolVersion(r),
 	}
 	if endpoint.contextExtractor != nil {
 		headers := map[string]string{}
@@ -294,6 +295,27 @@ func (req *ServerHTTPRequest) parseQueryValues() bool {
 	return true
 }
 
+// GetParamInt will return the path param key as an integer of bitSize bits,
+// it sends a 400 response when the param is not a valid integer of that size.
+func (req *ServerHTTPRequest) GetParamInt(key string, bitSize int) (int64, bool) {
+	value := req.Params.Get(key)
+	number, err := strconv.ParseInt(value, 10, bitSize)
+	if err != nil {
+		req.contextLogger.WarnZ(req.Context(), "Got request with invalid path param types",
+			zap.String("expected", "int"+strconv.Itoa(bitSize)),
+			zap.String("actual", value),
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		if !req.parseFailed {
+			req.res.SendError(400, "Could not parse path params", err)
+			req.parseFailed = true
+		}
+		return 0, false
+	}
+	return number, true
+}
+
 // GetQueryValue will return the first query parameter for key or empty string
 func (req *ServerHTTPRequest) GetQueryValue(key string) (string, bool) {
 	success := req.parseQueryValues()
@@ -806,6 +828,13 @@ func (req *ServerHTTPRequest) ReadAll() ([]byte, bool) {
 		return req.rawBody, true
 	}
 	rawBody, err := io.ReadAll(req.httpRequest.Body)
+	if err == errBodyTooLarge {
+		req.contextLogger.WarnZ(req.Context(), "Request body too large", zap.Error(err))
+		if !req.parseFailed {
+			req.bodyTooLarge(err)
+		}
+		return nil, false
+	}
 	if err != nil {
 		req.contextLogger.ErrorZ(req.Context(), "Could not read request body", zap.Error(err))
 		if !req.parseFailed {
@@ -28,6 +28,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/buger/jsonparser"
@@ -299,6 +300,72 @@ func TestFailingGetQueryInt8(t *testing.T) {
 	assert.Equal(t, 1, len(logs["Finished an incoming server HTTP request with 400 status code"]))
 }
 
+func TestGetParamInt(t *testing.T) {
+	gateway, err := benchGateway.CreateGateway(
+		defaultTestConfig,
+		defaultTestOptions,
+		exampleGateway.CreateGateway,
+	)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer gateway.Close()
+
+	bgateway := gateway.(*benchGateway.BenchGateway)
+	deps := &zanzibar.DefaultDependencies{
+		Scope:         bgateway.ActualGateway.RootScope,
+		Logger:        bgateway.ActualGateway.Logger,
+		ContextLogger: bgateway.ActualGateway.ContextLogger,
+		Tracer:        bgateway.ActualGateway.Tracer,
+	}
+	err = bgateway.ActualGateway.HTTPRouter.Handle(
+		"GET", "/foo/{id:int}", http.HandlerFunc(zanzibar.NewRouterEndpoint(
+			bgateway.ActualGateway.ContextExtractor,
+			deps,
+			"foo", "foo",
+			func(
+				ctx context.Context,
+				req *zanzibar.ServerHTTPRequest,
+				res *zanzibar.ServerHTTPResponse,
+			) context.Context {
+				id, ok := req.GetParamInt("id", 8)
+				if !ok {
+					return ctx
+				}
+				res.WriteJSONBytes(200, nil, []byte(`{"id":`+strconv.FormatInt(id, 10)+`}`))
+				return ctx
+			},
+		).HandleRequest),
+	)
+	assert.NoError(t, err)
+
+	resp, err := gateway.MakeRequest("GET", "/foo/-12", nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 200, resp.StatusCode)
+	respBytes, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":-12}`, string(respBytes))
+
+	// the route only matches integers but they may not fit the field
+	resp, err = gateway.MakeRequest("GET", "/foo/1000", nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 400, resp.StatusCode)
+	respBytes, err = io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"error":"Could not parse path params"}`, string(respBytes))
+
+	resp, err = gateway.MakeRequest("GET", "/foo/bar", nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
 func TestFailingHasQueryValue(t *testing.T) {
 	gateway, err := benchGateway.CreateGateway(
 		defaultTestConfig,
@@ -183,6 +183,20 @@ func (res *ServerHTTPResponse) SendError(
 	)
 }
 
+// deadlineExceeded replaces a failed or missing response with a 504 once
+// the request deadline has passed, successful responses are kept.
+func (res *ServerHTTPResponse) deadlineExceeded(ctx context.Context) {
+	if res.pendingStatusCode != 0 && res.pendingStatusCode < 500 {
+		return
+	}
+	err := res.Err
+	if err == nil {
+		err = ctx.Err()
+	}
+	res.scope.Counter(endpointDeadlineExceeded).Inc(1)
+	res.SendError(http.StatusGatewayTimeout, "Request deadline exceeded", err)
+}
+
 // WriteBytes writes a byte[] slice that is valid Response
 func (res *ServerHTTPResponse) WriteBytes(
 	statusCode int, headers Header, bytes []byte,
@@ -0,0 +1,146 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultReadHeaderTimeout stops slow clients from holding connections
+	// open while trickling in request headers.
+	defaultReadHeaderTimeout = 10 * time.Second
+
+	endpointRejected = "endpoint.rejected"
+	serverRejected   = "server.rejected"
+
+	scopeTagReason         = "reason"
+	rejectedBodyTooLarge   = "body-too-large"
+	rejectedHeaderTooLarge = "header-too-large"
+)
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// configureHTTPLimits sets the timeouts and the header size limit of the
+// server from "http.readHeaderTimeout", "http.readTimeout",
+// "http.writeTimeout", "http.idleTimeout" (all in ms) and
+// "http.maxHeaderBytes". Only the header read timeout has a default.
+func configureHTTPLimits(config *StaticConfig, server *http.Server) {
+	server.ReadHeaderTimeout = defaultReadHeaderTimeout
+	timeouts := map[string]*time.Duration{
+		"http.readHeaderTimeout": &server.ReadHeaderTimeout,
+		"http.readTimeout":       &server.ReadTimeout,
+		"http.writeTimeout":      &server.WriteTimeout,
+		"http.idleTimeout":       &server.IdleTimeout,
+	}
+	for key, timeout := range timeouts {
+		if config.ContainsKey(key) {
+			*timeout = time.Duration(config.MustGetInt(key)) * time.Millisecond
+		}
+	}
+	if config.ContainsKey("http.maxHeaderBytes") {
+		server.MaxHeaderBytes = int(config.MustGetInt("http.maxHeaderBytes"))
+	}
+}
+
+// headerBytes approximates the size of the request line and headers the
+// same way the standard library does for http.Server.MaxHeaderBytes.
+func headerBytes(r *http.Request) int {
+	n := len(r.Method) + len(r.RequestURI) + len(r.Proto) + 4
+	for key, values := range r.Header {
+		for _, value := range values {
+			n += len(key) + len(value) + 4
+		}
+	}
+	return n
+}
+
+// rejectLargeHeader answers with a 431, the server only enforces its own
+// limit loosely and without metrics.
+func (router *httpRouter) rejectLargeHeader(w http.ResponseWriter, r *http.Request) {
+	router.gateway.RootScope.Tagged(map[string]string{
+		scopeTagProtocol: scopeTagHTTP,
+		scopeTagReason:   rejectedHeaderTooLarge,
+	}).Counter(serverRejected).Inc(1)
+	router.gateway.ContextLogger.WarnZ(r.Context(), "Rejected request with headers too large",
+		zap.Int("headerBytes", headerBytes(r)),
+		zap.Int("maxHeaderBytes", router.maxHeaderBytes),
+	)
+	http.Error(w,
+		http.StatusText(http.StatusRequestHeaderFieldsTooLarge),
+		http.StatusRequestHeaderFieldsTooLarge,
+	)
+}
+
+// limitedBody fails the read that goes past the limit with errBodyTooLarge,
+// a body of exactly the limit is read in full.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// one more byte tells a body of exactly the limit from a larger one
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, errBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// limitBody caps the request body, a request that declares a larger body
+// is rejected before the handler runs.
+func (req *ServerHTTPRequest) limitBody(limit int64) bool {
+	if limit <= 0 {
+		return true
+	}
+	if req.httpRequest.ContentLength > limit {
+		req.bodyTooLarge(errBodyTooLarge)
+		return false
+	}
+	req.httpRequest.Body = &limitedBody{ReadCloser: req.httpRequest.Body, remaining: limit}
+	return true
+}
+
+// bodyTooLarge answers with a 413 and closes the connection rather than
+// draining the rest of the body.
+func (req *ServerHTTPRequest) bodyTooLarge(err error) {
+	req.scope.Tagged(map[string]string{
+		scopeTagReason: rejectedBodyTooLarge,
+	}).Counter(endpointRejected).Inc(1)
+	req.res.Headers().Set("Connection", "close")
+	req.res.SendError(http.StatusRequestEntityTooLarge, "Request body too large", err)
+	req.parseFailed = true
+}
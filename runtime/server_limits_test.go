@@ -0,0 +1,78 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureHTTPLimits(t *testing.T) {
+	server := &http.Server{}
+	configureHTTPLimits(NewStaticConfigOrDie(nil, nil), server)
+	assert.Equal(t, defaultReadHeaderTimeout, server.ReadHeaderTimeout)
+	assert.Zero(t, server.ReadTimeout)
+	assert.Zero(t, server.MaxHeaderBytes)
+
+	server = &http.Server{}
+	configureHTTPLimits(NewStaticConfigOrDie(nil, map[string]interface{}{
+		"http.readHeaderTimeout": 500,
+		"http.readTimeout":       2000,
+		"http.writeTimeout":      3000,
+		"http.idleTimeout":       60000,
+		"http.maxHeaderBytes":    8192,
+	}), server)
+	assert.Equal(t, 500*time.Millisecond, server.ReadHeaderTimeout)
+	assert.Equal(t, 2*time.Second, server.ReadTimeout)
+	assert.Equal(t, 3*time.Second, server.WriteTimeout)
+	assert.Equal(t, time.Minute, server.IdleTimeout)
+	assert.Equal(t, 8192, server.MaxHeaderBytes)
+}
+
+func TestLimitedBody(t *testing.T) {
+	read := func(body string, limit int64) ([]byte, error) {
+		return io.ReadAll(&limitedBody{
+			ReadCloser: io.NopCloser(strings.NewReader(body)),
+			remaining:  limit,
+		})
+	}
+
+	bytes, err := read("0123456789", 10)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(bytes))
+
+	_, err = read("0123456789a", 10)
+	assert.Equal(t, errBodyTooLarge, err)
+}
+
+func TestHeaderBytes(t *testing.T) {
+	r := httptest.NewRequest("GET", "/foo", nil)
+	empty := headerBytes(r)
+	r.Header.Set("X-Large", strings.Repeat("a", 100))
+	assert.Equal(t, empty+len("X-Large")+100+4, headerBytes(r))
+}
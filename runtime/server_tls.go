@@ -0,0 +1,374 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+const (
+	peerIdentityKey = contextFieldKey("peerIdentity")
+
+	defaultTLSReloadInterval = 10 * time.Second
+
+	tlsReloadSuccess = "http.tls.reload.success"
+	tlsReloadFailure = "http.tls.reload.failure"
+)
+
+var tlsClientAuthTypes = map[string]tls.ClientAuthType{
+	"none":             tls.NoClientCert,
+	"request":          tls.RequestClientCert,
+	"require":          tls.RequireAnyClientCert,
+	"verifyIfGiven":    tls.VerifyClientCertIfGiven,
+	"requireAndVerify": tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// PeerIdentity is the identity of a client that presented a verified
+// certificate over mTLS.
+type PeerIdentity struct {
+	// Subject is the distinguished name of the certificate subject.
+	Subject    string
+	CommonName string
+	DNSNames   []string
+	// URIs are the URI SANs of the certificate, such as SPIFFE IDs.
+	URIs         []string
+	SerialNumber string
+}
+
+// WithPeerIdentity returns a context with the identity of the TLS peer.
+func WithPeerIdentity(ctx context.Context, identity *PeerIdentity) context.Context {
+	return context.WithValue(ctx, peerIdentityKey, identity)
+}
+
+// GetPeerIdentityFromCtx returns the identity of the TLS peer of the inbound
+// request, or nil when the client did not present a verified certificate.
+func GetPeerIdentityFromCtx(ctx context.Context) *PeerIdentity {
+	if val := ctx.Value(peerIdentityKey); val != nil {
+		identity, _ := val.(*PeerIdentity)
+		return identity
+	}
+	return nil
+}
+
+// withPeerIdentity puts the identity of the verified client certificate of
+// the request, if any, on the request context.
+func withPeerIdentity(r *http.Request) *http.Request {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return r
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	identity := &PeerIdentity{
+		Subject:      cert.Subject.String(),
+		CommonName:   cert.Subject.CommonName,
+		DNSNames:     cert.DNSNames,
+		URIs:         make([]string, len(cert.URIs)),
+		SerialNumber: cert.SerialNumber.String(),
+	}
+	for i, uri := range cert.URIs {
+		identity.URIs[i] = uri.String()
+	}
+	return r.WithContext(WithPeerIdentity(r.Context(), identity))
+}
+
+// certReloader serves a certificate and CAs from files and reloads them when
+// the files change on disk, the CAs are the client CAs of a server and the
+// root CAs of a client. The certificates in use are kept when a reload fails.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	interval time.Duration
+	logger   *zap.Logger
+	scope    tally.Scope
+
+	mu          sync.RWMutex
+	cert        *tls.Certificate // protected by mu
+	cas         *x509.CertPool   // protected by mu
+	fingerprint string           // protected by mu
+
+	checkMu   sync.Mutex
+	lastCheck time.Time // protected by checkMu
+
+	runningMu sync.Mutex
+	running   bool // protected by runningMu
+	stop      chan struct{}
+}
+
+func newCertReloader(
+	certFile, keyFile, caFile string,
+	interval time.Duration,
+	logger *zap.Logger,
+	scope tally.Scope,
+) (*certReloader, error) {
+	if interval <= 0 {
+		interval = defaultTLSReloadInterval
+	}
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: interval,
+		logger:   logger,
+		scope:    scope,
+		stop:     make(chan struct{}),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) files() []string {
+	var files []string
+	if r.certFile != "" {
+		files = append(files, r.certFile, r.keyFile)
+	}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+// fingerprintFiles identifies the current version of the files by their
+// size and modification time.
+func (r *certReloader) fingerprintFiles() (string, error) {
+	var fingerprint string
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fingerprint += file + ":" + strconv.FormatInt(info.Size(), 10) + ":" +
+			strconv.FormatInt(info.ModTime().UnixNano(), 10) + ";"
+	}
+	return fingerprint, nil
+}
+
+// reload reads the certificate, key and CA files.
+func (r *certReloader) reload() error {
+	fingerprint, err := r.fingerprintFiles()
+	if err != nil {
+		return errors.Wrap(err, "could not read TLS files")
+	}
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		keyPair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return errors.Wrap(err, "could not load TLS certificate")
+		}
+		cert = &keyPair
+	}
+	var cas *x509.CertPool
+	if r.caFile != "" {
+		bytes, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return errors.Wrap(err, "could not read TLS CA file")
+		}
+		cas = x509.NewCertPool()
+		if !cas.AppendCertsFromPEM(bytes) {
+			return errors.Errorf("no certificates found in TLS CA file %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = cert
+	r.cas = cas
+	r.fingerprint = fingerprint
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) changedOnDisk() bool {
+	fingerprint, err := r.fingerprintFiles()
+	if err != nil {
+		// files are being replaced, check again on the next tick
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return fingerprint != r.fingerprint
+}
+
+// startPolling polls the files for changes.
+func (r *certReloader) startPolling() {
+	r.runningMu.Lock()
+	defer r.runningMu.Unlock()
+	if r.running {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		for {
+			select {
+			case <-ticker.C:
+				r.reloadChanged()
+			case <-r.stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	r.running = true
+}
+
+// reloadChanged reloads the files when they changed on disk.
+func (r *certReloader) reloadChanged() {
+	if !r.changedOnDisk() {
+		return
+	}
+	if err := r.reload(); err != nil {
+		r.logger.Error("Error reloading TLS certificates", zap.Error(err))
+		r.scope.Counter(tlsReloadFailure).Inc(1)
+		return
+	}
+	r.logger.Info("Reloaded TLS certificates")
+	r.scope.Counter(tlsReloadSuccess).Inc(1)
+}
+
+// reloadIfDue reloads the files when they changed on disk, checking at most
+// once per interval. Clients check on new connections instead of polling.
+func (r *certReloader) reloadIfDue() {
+	r.checkMu.Lock()
+	if time.Since(r.lastCheck) < r.interval {
+		r.checkMu.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	r.checkMu.Unlock()
+	r.reloadChanged()
+}
+
+// stopPolling stops polling the files, it cannot be restarted once stopped.
+func (r *certReloader) stopPolling() {
+	r.runningMu.Lock()
+	defer r.runningMu.Unlock()
+	if !r.running {
+		return
+	}
+	close(r.stop)
+	r.running = false
+}
+
+// getCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// tlsConfig returns a server TLS config that picks up reloaded certificates
+// and client CAs for new connections.
+func (r *certReloader) tlsConfig(clientAuth tls.ClientAuthType, minVersion uint16) *tls.Config {
+	base := &tls.Config{
+		MinVersion:     minVersion,
+		ClientAuth:     clientAuth,
+		GetCertificate: r.getCertificate,
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		clientCAs := r.cas
+		r.mu.RUnlock()
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.ClientCAs = clientCAs
+		return config, nil
+	}
+	return base
+}
+
+// setupHTTPServerTLS reads the "http.tls.*" config keys and returns the TLS
+// config of the HTTP servers, which is nil when TLS is not enabled.
+func (gateway *Gateway) setupHTTPServerTLS(config *StaticConfig, logger *zap.Logger) (*tls.Config, error) {
+	if !config.ContainsKey("http.tls.enabled") || !config.MustGetBoolean("http.tls.enabled") {
+		return nil, nil
+	}
+	if !config.ContainsKey("http.tls.certFile") || !config.ContainsKey("http.tls.keyFile") {
+		return nil, errors.New("http.tls.certFile and http.tls.keyFile are required when http.tls.enabled is set")
+	}
+
+	var caFile string
+	if config.ContainsKey("http.tls.caFile") {
+		caFile = config.MustGetString("http.tls.caFile")
+	}
+
+	// client certificates are verified by default when a CA is given
+	clientAuth := tls.NoClientCert
+	if caFile != "" {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+	if config.ContainsKey("http.tls.clientAuth") {
+		value := config.MustGetString("http.tls.clientAuth")
+		var ok bool
+		if clientAuth, ok = tlsClientAuthTypes[value]; !ok {
+			return nil, errors.Errorf("unknown http.tls.clientAuth: %s", value)
+		}
+	}
+	if clientAuth >= tls.VerifyClientCertIfGiven && caFile == "" {
+		return nil, errors.New("http.tls.caFile is required to verify client certificates")
+	}
+
+	minVersion := uint16(tls.VersionTLS12)
+	if config.ContainsKey("http.tls.minVersion") {
+		value := config.MustGetString("http.tls.minVersion")
+		var ok bool
+		if minVersion, ok = tlsVersions[value]; !ok {
+			return nil, errors.Errorf("unknown http.tls.minVersion: %s", value)
+		}
+	}
+
+	var interval time.Duration
+	if config.ContainsKey("http.tls.reloadInterval") {
+		interval = time.Duration(config.MustGetInt("http.tls.reloadInterval")) * time.Millisecond
+	}
+
+	reloader, err := newCertReloader(
+		config.MustGetString("http.tls.certFile"),
+		config.MustGetString("http.tls.keyFile"),
+		caFile,
+		interval,
+		logger,
+		gateway.RootScope,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error setting up http server TLS")
+	}
+	gateway.tlsReloader = reloader
+	return reloader.tlsConfig(clientAuth, minVersion), nil
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zanzibar
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	spiffeID, err := url.Parse("spiffe://example.org/" + cn)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		URIs:         []*url.URL{spiffeID},
+		IsCA:         isCA,
+
+		BasicConstraintsValid: true,
+	}
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	require.NoError(t, ioutil.WriteFile(certFile, c.certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, c.keyPEM, 0600))
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	require.NoError(t, err)
+	return cert
+}
+
+type testTLSFiles struct {
+	ca                        *testCert
+	certFile, keyFile, caFile string
+}
+
+func newTestTLSFiles(t *testing.T) *testTLSFiles {
+	dir, err := ioutil.TempDir("", "server-tls")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	files := &testTLSFiles{
+		ca:       newTestCert(t, "ca", nil, true),
+		certFile: filepath.Join(dir, "server.crt"),
+		keyFile:  filepath.Join(dir, "server.key"),
+		caFile:   filepath.Join(dir, "ca.crt"),
+	}
+	newTestCert(t, "server", files.ca, false).write(t, files.certFile, files.keyFile)
+	require.NoError(t, ioutil.WriteFile(files.caFile, files.ca.certPEM, 0600))
+	return files
+}
+
+func TestHTTPServerMutualTLS(t *testing.T) {
+	files := newTestTLSFiles(t)
+	reloader, err := newCertReloader(files.certFile, files.keyFile, files.caFile, 0, zap.NewNop(), tally.NoopScope)
+	require.NoError(t, err)
+
+	server := &HTTPServer{
+		Server: &http.Server{
+			Addr: "127.0.0.1:0",
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				identity := GetPeerIdentityFromCtx(withPeerIdentity(r).Context())
+				if !assert.NotNil(t, identity) {
+					return
+				}
+				assert.Equal(t, []string{"spiffe://example.org/client"}, identity.URIs)
+				_, _ = w.Write([]byte(identity.CommonName))
+			}),
+			TLSConfig: reloader.tlsConfig(tls.RequireAndVerifyClientCert, tls.VersionTLS12),
+		},
+		Logger: zap.NewNop(),
+	}
+	_, err = server.JustListen()
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go server.JustServe(&wg)
+	defer func() {
+		server.Close()
+		wg.Wait()
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(files.ca.cert)
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: certs,
+		}}}
+	}
+	addr := "https://localhost:" + server.RealAddr[len("127.0.0.1:"):] + "/"
+
+	client := newTestCert(t, "client", files.ca, false)
+	res, err := newClient(client.tlsCertificate(t)).Get(addr)
+	require.NoError(t, err)
+	defer func() { _ = res.Body.Close() }()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "client", string(body))
+
+	// a client without a certificate is rejected
+	_, err = newClient().Get(addr)
+	assert.Error(t, err)
+
+	// so is a client with a certificate from another CA
+	other := newTestCert(t, "other", newTestCert(t, "other-ca", nil, true), false)
+	_, err = newClient(other.tlsCertificate(t)).Get(addr)
+	assert.Error(t, err)
+}
+
+func TestCertReloader(t *testing.T) {
+	files := newTestTLSFiles(t)
+	scope := tally.NewTestScope("", nil)
+	reloader, err := newCertReloader(files.certFile, files.keyFile, "", 5*time.Millisecond, zap.NewNop(), scope)
+	require.NoError(t, err)
+	assert.False(t, reloader.changedOnDisk())
+
+	leafCN := func() string {
+		cert, err := reloader.getCertificate(nil)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+	assert.Equal(t, "server", leafCN())
+
+	reloader.startPolling()
+	defer reloader.stopPolling()
+
+	// a broken key is not picked up
+	require.NoError(t, ioutil.WriteFile(files.keyFile, []byte("not a key"), 0600))
+	assert.Eventually(t, func() bool {
+		counter, ok := scope.Snapshot().Counters()[tlsReloadFailure+"+"]
+		return ok && counter.Value() > 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "server", leafCN())
+
+	newTestCert(t, "renewed", files.ca, false).write(t, files.certFile, files.keyFile)
+	assert.Eventually(t, func() bool {
+		return leafCN() == "renewed"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestSetupHTTPServerTLS(t *testing.T) {
+	files := newTestTLSFiles(t)
+	setup := func(values map[string]interface{}) (*tls.Config, error) {
+		gateway := &Gateway{RootScope: tally.NoopScope}
+		return gateway.setupHTTPServerTLS(NewStaticConfigOrDie(nil, values), zap.NewNop())
+	}
+
+	config, err := setup(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+
+	config, err = setup(map[string]interface{}{
+		"http.tls.enabled":  true,
+		"http.tls.certFile": files.certFile,
+		"http.tls.keyFile":  files.keyFile,
+		"http.tls.caFile":   files.caFile,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+
+	config, err = setup(map[string]interface{}{
+		"http.tls.enabled":    true,
+		"http.tls.certFile":   files.certFile,
+		"http.tls.keyFile":    files.keyFile,
+		"http.tls.minVersion": "1.3",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, config.ClientAuth)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+
+	for name, values := range map[string]map[string]interface{}{
+		"http.tls.certFile and http.tls.keyFile are required": {
+			"http.tls.enabled": true,
+		},
+		"unknown http.tls.clientAuth: always": {
+			"http.tls.enabled":    true,
+			"http.tls.certFile":   files.certFile,
+			"http.tls.keyFile":    files.keyFile,
+			"http.tls.clientAuth": "always",
+		},
+		"http.tls.caFile is required to verify client certificates": {
+			"http.tls.enabled":    true,
+			"http.tls.certFile":   files.certFile,
+			"http.tls.keyFile":    files.keyFile,
+			"http.tls.clientAuth": "requireAndVerify",
+		},
+		"could not load TLS certificate": {
+			"http.tls.enabled":  true,
+			"http.tls.certFile": files.certFile,
+			"http.tls.keyFile":  files.caFile,
+		},
+	} {
+		_, err := setup(values)
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), name)
+		}
+	}
+}
@@ -187,6 +187,17 @@ func (conf *StaticConfig) MustGetInt(key string) int64 {
 	panic(errors.Errorf("Key (%s) not available", key))
 }
 
+// lookup returns the raw value for the key, seed config takes precedence.
+func (conf *StaticConfig) lookup(key string) (interface{}, bool) {
+	conf.checkConfDestroyed(key)
+
+	if value, contains := conf.seedConfig[key]; contains {
+		return value, true
+	}
+	value, contains := conf.configValues[key]
+	return value, contains
+}
+
 // ContainsKey returns true if key is found otherwise false.
 func (conf *StaticConfig) ContainsKey(key string) bool {
 	if conf.destroyed {
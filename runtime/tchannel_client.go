@@ -22,7 +22,10 @@ package zanzibar
 
 import (
 	"context"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -74,6 +77,14 @@ type TChannelClientOption struct {
 
 	// MaxAttempts is the maximum retry count for a client
 	MaxAttempts int
+
+	// Hedging sends hedged calls for the methods it covers, nil disables
+	// hedging.
+	Hedging *HedgingPolicy
+
+	// RetryPolicy sets the backoff between attempts and the retry budget,
+	// nil retries right away without a budget.
+	RetryPolicy *BackoffRetryPolicy
 }
 
 // TChannelClient implements TChannelCaller and makes outgoing Thrift calls.
@@ -98,6 +109,12 @@ type TChannelClient struct {
 	headerPatterns       []string
 	altChannelMap        map[string]*tchannel.SubChannel
 	maxAttempts          int
+	hedging              *HedgingPolicy
+	retryPolicy          *BackoffRetryPolicy
+
+	// optionsMu guards timeout, timeoutPerAttempt and maxAttempts which
+	// can be changed at runtime
+	optionsMu sync.RWMutex
 }
 
 // NewTChannelClient is deprecated, use NewTChannelClientContext instead
@@ -143,10 +160,27 @@ func NewTChannelClientContext(
 		headerPatterns:       opt.HeaderPatterns,
 		altChannelMap:        opt.AltChannelMap,
 		maxAttempts:          opt.MaxAttempts,
+		hedging:              opt.Hedging,
+		retryPolicy:          opt.RetryPolicy,
 	}
 	return client
 }
 
+// SetTimeout changes the overall and per attempt timeouts for subsequent calls.
+func (c *TChannelClient) SetTimeout(timeout, timeoutPerAttempt time.Duration) {
+	c.optionsMu.Lock()
+	defer c.optionsMu.Unlock()
+	c.timeout = timeout
+	c.timeoutPerAttempt = timeoutPerAttempt
+}
+
+// SetMaxAttempts changes the maximum number of attempts for subsequent calls.
+func (c *TChannelClient) SetMaxAttempts(maxAttempts int) {
+	c.optionsMu.Lock()
+	defer c.optionsMu.Unlock()
+	c.maxAttempts = maxAttempts
+}
+
 // Call makes a RPC call to the given service.
 func (c *TChannelClient) Call(
 	ctx context.Context,
@@ -202,6 +236,7 @@ func (c *TChannelClient) call(
 	// Start passing the MaxAttempt field which will be used while creating the RetryOptions.
 	// Note : No impact on the existing clients because MaxAttempt will be passed as 0 and it will default to 5 while retrying the execution.
 	// More details can be found at https://t3.uberinternal.com/browse/EDGE-8526
+	c.optionsMu.RLock()
 	retryOpts := tchannel.RetryOptions{
 		TimeoutPerAttempt: c.timeoutPerAttempt,
 		MaxAttempts:       c.maxAttempts,
@@ -211,6 +246,7 @@ func (c *TChannelClient) call(
 	//when retryCount is 0, we assume endpoint level’s config is not provided
 
 	timeout := c.timeout
+	c.optionsMu.RUnlock()
 	if timeoutAndRetryOptions != nil && timeoutAndRetryOptions.MaxAttempts != 0 {
 		retryOpts = tchannel.RetryOptions{
 			TimeoutPerAttempt: timeoutAndRetryOptions.RequestTimeoutPerAttemptInMs,
@@ -219,6 +255,8 @@ func (c *TChannelClient) call(
 		timeout = timeoutAndRetryOptions.OverallTimeoutInMs
 	}
 
+	// the call can not outlive the deadline of the inbound request
+	timeout = remainingTimeout(ctx, timeout)
 	ctxBuilder := tchannel.NewContextBuilder(timeout).
 		SetParentContext(ctx).
 		SetRetryOptions(&retryOpts)
@@ -238,44 +276,12 @@ func (c *TChannelClient) call(
 	ctx, cancel := ctxBuilder.Build()
 	defer cancel()
 
-	err = c.ch.RunWithRetry(ctx, func(ctx netContext.Context, rs *tchannel.RequestState) (cerr error) {
-		call.resHeaders = map[string]string{}
-		call.success = false
-
-		sc, ctx := c.getDynamicChannelWithFallback(reqHeaders, c.sc, ctx)
-		call.call, cerr = sc.BeginCall(ctx, call.serviceMethod, &tchannel.CallOptions{
-			Format:          tchannel.Thrift,
-			ShardKey:        GetShardKeyFromCtx(ctx),
-			RequestState:    rs,
-			RoutingDelegate: GetRoutingDelegateFromCtx(ctx),
-		})
-		if cerr != nil {
-			return errors.Wrapf(
-				cerr, "Could not begin outbound %s.%s (%s %s) request",
-				call.client.ClientID, call.methodName, call.client.serviceName, call.serviceMethod,
-			)
-		}
-
-		// trace request
-		reqHeaders = tchannel.InjectOutboundSpan(call.call.Response(), reqHeaders)
-
-		if cerr := call.writeReqHeaders(reqHeaders); cerr != nil {
-			return cerr
-		}
-		if cerr := call.writeReqBody(ctx, req); cerr != nil {
-			return cerr
-		}
-
-		response := call.call.Response()
-		if cerr = call.readResHeaders(response); cerr != nil {
-			return cerr
-		}
-		if cerr = call.readResBody(ctx, response, resp); cerr != nil {
-			return cerr
-		}
-
-		return cerr
-	})
+	c.retryPolicy.OnCall(call.methodName)
+	if c.hedging.covers(call.methodName) {
+		err = c.runHedged(ctx, call, reqHeaders, req, resp)
+	} else {
+		err = c.run(ctx, call, reqHeaders, req, resp)
+	}
 
 	if err != nil {
 		// Do not wrap system errors.
@@ -291,6 +297,134 @@ func (c *TChannelClient) call(
 	return call.success, call.resHeaders, err
 }
 
+// run makes the call with the retry options of the context. Only errors
+// tchannel deems retryable are retried, declared Thrift exceptions are
+// successful calls with success set to false and are never retried.
+func (c *TChannelClient) run(
+	ctx netContext.Context,
+	call *tchannelOutboundCall,
+	reqHeaders map[string]string,
+	req, resp RWTStruct,
+) error {
+	err := c.ch.RunWithRetry(ctx, func(attemptCtx netContext.Context, rs *tchannel.RequestState) error {
+		attemptScope := map[string]string{scopeTagAttempt: strconv.Itoa(rs.Attempt)}
+		c.metrics.IncCounter(WithScopeTagsDefault(attemptCtx, attemptScope, c.metrics.Scope()), clientAttempt, 1)
+
+		err := c.attempt(attemptCtx, rs, call, reqHeaders, req, resp)
+		if err == nil || c.retryPolicy == nil || !rs.HasRetries(err) {
+			return err
+		}
+		backoff, ok := c.retryPolicy.allow(ctx, c.retryPolicy.backoff(rs.Attempt))
+		if !ok {
+			// stops RunWithRetry, run returns the error of the attempt
+			return &retryDeniedError{err: err}
+		}
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return &retryDeniedError{err: err}
+			}
+		}
+		return err
+	})
+	if denied, ok := err.(*retryDeniedError); ok {
+		return denied.err
+	}
+	return err
+}
+
+// retryDeniedError is returned by an attempt that is not retried because
+// of the retry policy, tchannel does not retry it as it is not a system error.
+type retryDeniedError struct {
+	err error
+}
+
+func (e *retryDeniedError) Error() string {
+	return e.err.Error()
+}
+
+// attempt makes a single attempt of the call.
+func (c *TChannelClient) attempt(
+	ctx netContext.Context,
+	rs *tchannel.RequestState,
+	call *tchannelOutboundCall,
+	reqHeaders map[string]string,
+	req, resp RWTStruct,
+) (cerr error) {
+	call.resHeaders = map[string]string{}
+	call.success = false
+
+	sc, ctx := c.getDynamicChannelWithFallback(reqHeaders, c.sc, ctx)
+	call.call, cerr = sc.BeginCall(ctx, call.serviceMethod, &tchannel.CallOptions{
+		Format:          tchannel.Thrift,
+		ShardKey:        GetShardKeyFromCtx(ctx),
+		RequestState:    rs,
+		RoutingDelegate: GetRoutingDelegateFromCtx(ctx),
+	})
+	if cerr != nil {
+		return errors.Wrapf(
+			cerr, "Could not begin outbound %s.%s (%s %s) request",
+			call.client.ClientID, call.methodName, call.client.serviceName, call.serviceMethod,
+		)
+	}
+
+	// trace request
+	reqHeaders = tchannel.InjectOutboundSpan(call.call.Response(), reqHeaders)
+
+	if cerr := call.writeReqHeaders(reqHeaders); cerr != nil {
+		return cerr
+	}
+	if cerr := call.writeReqBody(ctx, req); cerr != nil {
+		return cerr
+	}
+
+	response := call.call.Response()
+	if cerr = call.readResHeaders(response); cerr != nil {
+		return cerr
+	}
+	if cerr = call.readResBody(ctx, response, resp); cerr != nil {
+		return cerr
+	}
+
+	return cerr
+}
+
+// runHedged makes the call with hedged attempts, each attempt has its own
+// outbound call and response and the winner's are copied to call and resp.
+func (c *TChannelClient) runHedged(
+	ctx netContext.Context,
+	call *tchannelOutboundCall,
+	reqHeaders map[string]string,
+	req, resp RWTStruct,
+) error {
+	calls := make([]*tchannelOutboundCall, c.hedging.MaxHedges+1)
+	resps := make([]RWTStruct, c.hedging.MaxHedges+1)
+	winner, hedges, err := c.hedging.do(ctx, call.methodName, func(ctx context.Context, n int) func() error {
+		// call is written once do returns, the attempt only uses its copy
+		attemptCall := *call
+		headers := make(map[string]string, len(reqHeaders))
+		for k, v := range reqHeaders {
+			headers[k] = v
+		}
+		calls[n] = &attemptCall
+		resps[n] = reflect.New(reflect.TypeOf(resp).Elem()).Interface().(RWTStruct)
+		return func() error {
+			return c.run(ctx, calls[n], headers, req, resps[n])
+		}
+	})
+	recordHedging(ctx, c.metrics, hedges, winner, err)
+
+	call.call = calls[winner].call
+	call.success = calls[winner].success
+	call.reqHeaders = calls[winner].reqHeaders
+	call.resHeaders = calls[winner].resHeaders
+	reflect.ValueOf(resp).Elem().Set(reflect.ValueOf(resps[winner]).Elem())
+	return err
+}
+
 // first rule match, would be the chosen channel. if nothing matches fallback to default channel
 func (c *TChannelClient) getDynamicChannelWithFallback(reqHeaders map[string]string,
 	sc *tchannel.SubChannel, ctx netContext.Context) (*tchannel.SubChannel, netContext.Context) {
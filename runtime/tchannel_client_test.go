@@ -22,11 +22,17 @@ package zanzibar
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
 	"github.com/uber/tchannel-go"
+	"go.uber.org/thriftrw/wire"
+	"go.uber.org/zap"
 )
 
 func TestNilCallReferenceForLogger(t *testing.T) {
@@ -119,3 +125,73 @@ func TestMaxAttemptFieldWithNoSet(t *testing.T) {
 	maxAttempts := contextBuilder.RetryOptions.MaxAttempts
 	assert.Equal(t, maxAttempts, 0)
 }
+
+type emptyStruct struct{}
+
+func (*emptyStruct) ToWire() (wire.Value, error) {
+	return wire.NewValueStruct(wire.Struct{}), nil
+}
+
+func (*emptyStruct) FromWire(wire.Value) error {
+	return nil
+}
+
+func TestTChannelClientRetryBudget(t *testing.T) {
+	ch, err := tchannel.NewChannel("client", nil)
+	require.NoError(t, err)
+	defer ch.Close()
+
+	// every attempt goes to a new peer, all of them are busy
+	var calls int32
+	for i := 0; i < 3; i++ {
+		server, err := tchannel.NewChannel("busy", nil)
+		require.NoError(t, err)
+		defer server.Close()
+		server.Register(tchannel.HandlerFunc(func(ctx context.Context, call *tchannel.InboundCall) {
+			atomic.AddInt32(&calls, 1)
+			_ = call.Response().SendSystemError(tchannel.ErrServerBusy)
+		}), "SimpleService::call")
+		require.NoError(t, server.ListenAndServe("127.0.0.1:0"))
+		ch.Peers().Add(server.PeerInfo().HostPort)
+	}
+
+	scope := tally.NewTestScope("", nil)
+	client := NewTChannelClientContext(ch, NewContextLogger(zap.NewNop()), NewContextMetrics(scope), nil, &TChannelClientOption{
+		ServiceName:       "busy",
+		ClientID:          "busy",
+		MethodNames:       map[string]string{"SimpleService::call": "Call"},
+		Timeout:           time.Second,
+		TimeoutPerAttempt: 200 * time.Millisecond,
+		MaxAttempts:       3,
+		// a single retry in the budget
+		RetryPolicy: &BackoffRetryPolicy{
+			InitialBackoff: time.Millisecond,
+			budget:         newRetryBudget(0, 1),
+		},
+	})
+
+	call := func() error {
+		_, _, err := client.Call(context.Background(), "SimpleService", "call", nil, &emptyStruct{}, &emptyStruct{})
+		return err
+	}
+
+	// the budget allows the second attempt but not the third
+	err = call()
+	require.Error(t, err)
+	assert.Equal(t, tchannel.ErrCodeBusy, tchannel.GetSystemErrorCode(errors.Cause(err)))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// the budget is spent
+	err = call()
+	require.Error(t, err)
+	assert.Equal(t, tchannel.ErrCodeBusy, tchannel.GetSystemErrorCode(errors.Cause(err)))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	attempts := map[string]int64{}
+	for _, c := range scope.Snapshot().Counters() {
+		if c.Name() == clientAttempt {
+			attempts[c.Tags()[scopeTagAttempt]] += c.Value()
+		}
+	}
+	assert.Equal(t, map[string]int64{"1": 2, "2": 1}, attempts)
+}
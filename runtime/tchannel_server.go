@@ -23,6 +23,7 @@ package zanzibar
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -49,6 +50,12 @@ type TChannelEndpoint struct {
 	EndpointID string
 	HandlerID  string
 	Method     string
+	// RateLimit holds the inbound limits of the endpoint, the gateway
+	// defaults apply when it is nil.
+	RateLimit *RateLimitOptions
+	// Criticality and QPSLevel rank the endpoint for load shedding.
+	Criticality Criticality
+	QPSLevel    int
 
 	callback PostResponseCB
 }
@@ -61,6 +68,9 @@ type TChannelRouter struct {
 	contextLogger ContextLogger
 	scope         tally.Scope
 	extractor     ContextExtractor
+	inflight      *inflightTracker
+	rateLimiter   *RateLimiter
+	loadShedder   *LoadShedder
 
 	requestUUIDHeaderKey string
 }
@@ -102,6 +112,19 @@ func NewTChannelEndpointWithPostResponseCB(
 	}
 }
 
+// Middlewares returns the names of the middlewares the endpoint runs, in order.
+func (e *TChannelEndpoint) Middlewares() []string {
+	stack, ok := e.TChannelHandler.(*MiddlewareTchannelStack)
+	if !ok {
+		return []string{}
+	}
+	names := make([]string, len(stack.TchannelMiddlewares()))
+	for i, m := range stack.TchannelMiddlewares() {
+		names[i] = m.Name()
+	}
+	return names
+}
+
 // NewTChannelRouter returns a TChannel router that can serve thrift services over TChannel.
 func NewTChannelRouter(registrar tchannel.Registrar, g *Gateway) *TChannelRouter {
 	return &TChannelRouter{
@@ -110,6 +133,9 @@ func NewTChannelRouter(registrar tchannel.Registrar, g *Gateway) *TChannelRouter
 		contextLogger: g.ContextLogger,
 		scope:         g.RootScope,
 		extractor:     g.ContextExtractor,
+		inflight:      g.tchannelInflight,
+		rateLimiter:   g.RateLimiter,
+		loadShedder:   g.LoadShedder,
 
 		requestUUIDHeaderKey: g.requestUUIDHeaderKey,
 	}
@@ -123,6 +149,9 @@ func (s *TChannelRouter) Register(e *TChannelEndpoint) error {
 		return fmt.Errorf("handler for '%s' is already registered", e.Method)
 	}
 	s.RUnlock()
+	if e.RateLimit == nil {
+		e.RateLimit = s.rateLimiter.defaultOptions()
+	}
 	s.Lock()
 	s.endpoints[e.Method] = e
 	s.Unlock()
@@ -132,6 +161,20 @@ func (s *TChannelRouter) Register(e *TChannelEndpoint) error {
 	return nil
 }
 
+// registeredEndpoints returns the registered endpoints sorted by method.
+func (s *TChannelRouter) registeredEndpoints() []*TChannelEndpoint {
+	s.RLock()
+	defer s.RUnlock()
+	endpoints := make([]*TChannelEndpoint, 0, len(s.endpoints))
+	for _, e := range s.endpoints {
+		endpoints = append(endpoints, e)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	return endpoints
+}
+
 // Handle handles an incoming TChannel call and forwards it to the correct handler.
 func (s *TChannelRouter) Handle(ctx context.Context, call *tchannel.InboundCall) {
 	method := call.MethodString()
@@ -149,6 +192,7 @@ func (s *TChannelRouter) Handle(ctx context.Context, call *tchannel.InboundCall)
 		)
 		return
 	}
+	defer s.inflight.begin(method)()
 
 	// put log fields on the context
 	logFields := []zap.Field{
@@ -182,6 +226,12 @@ func (s *TChannelRouter) Handle(ctx context.Context, call *tchannel.InboundCall)
 		return
 	}
 
+	release, err := s.admit(ctx, c)
+	if err != nil {
+		return
+	}
+	defer release()
+
 	errc := make(chan error, 1)
 	go func() { errc <- s.handleBody(ctx, c) }()
 	select {
@@ -197,6 +247,39 @@ func (s *TChannelRouter) Handle(ctx context.Context, call *tchannel.InboundCall)
 	}
 }
 
+// admit applies load shedding and the rate limits of the endpoint to a
+// TChannel call, a rejected call is answered with a busy error which is
+// returned.
+func (s *TChannelRouter) admit(
+	ctx context.Context,
+	c *tchannelInboundCall,
+) (release func(), err error) {
+	e := c.endpoint
+	if level, ok := s.loadShedder.admit(e.Criticality, e.QPSLevel); !ok {
+		countShed(c.scope, level)
+		s.contextLogger.WarnZ(ctx, "Shed request under load", zap.Int(scopeTagShedLevel, level))
+		return func() {}, s.sendBusy(ctx, c)
+	}
+
+	key := scopeTagTChannel + ":" + e.EndpointID + "." + e.HandlerID
+	header := func(name string) string { return c.reqHeaders[name] }
+	release, reason, _ := s.rateLimiter.admit(key, c.scope, e.RateLimit, c.call.CallerName(), header)
+	if reason == "" {
+		return release, nil
+	}
+
+	s.contextLogger.WarnZ(ctx, "Rejected request over the rate limit", zap.String(scopeTagReason, reason))
+	return release, s.sendBusy(ctx, c)
+}
+
+// sendBusy answers a rejected call with a busy error and returns it.
+func (s *TChannelRouter) sendBusy(ctx context.Context, c *tchannelInboundCall) error {
+	if err := c.call.Response().SendSystemError(tchannel.ErrServerBusy); err != nil {
+		s.contextLogger.WarnZ(ctx, "Error sending server busy response", zap.Error(err))
+	}
+	return tchannel.ErrServerBusy
+}
+
 func (s *TChannelRouter) handleHeader(
 	ctx context.Context,
 	c *tchannelInboundCall,
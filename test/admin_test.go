@@ -0,0 +1,171 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gateway_test
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exampleGateway "github.com/uber/zanzibar/examples/example-gateway/build/services/example-gateway"
+	benchGateway "github.com/uber/zanzibar/test/lib/bench_gateway"
+	testGateway "github.com/uber/zanzibar/test/lib/test_gateway"
+	"github.com/uber/zanzibar/test/lib/util"
+)
+
+func createAdminGateway(t *testing.T, config map[string]interface{}) *benchGateway.BenchGateway {
+	config["clients.baz.serviceName"] = "baz"
+	gateway, err := benchGateway.CreateGateway(
+		config,
+		&testGateway.Options{
+			TestBinary:            util.DefaultMainFile("example-gateway"),
+			ConfigFiles:           util.DefaultConfigFiles("example-gateway"),
+			KnownHTTPBackends:     []string{"bar", "contacts", "google-now"},
+			KnownTChannelBackends: []string{"baz"},
+		},
+		exampleGateway.CreateGateway,
+	)
+	require.NoError(t, err)
+	return gateway.(*benchGateway.BenchGateway)
+}
+
+func getAdmin(t *testing.T, gateway *benchGateway.BenchGateway, path string, v interface{}) int {
+	res, err := gateway.MakeRequest("GET", path, nil, nil)
+	require.NoError(t, err)
+	defer func() { _ = res.Body.Close() }()
+	bytes, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	if res.StatusCode == 200 {
+		require.NoError(t, json.Unmarshal(bytes, v), string(bytes))
+	}
+	return res.StatusCode
+}
+
+func TestAdminDisabledByDefault(t *testing.T) {
+	gateway := createAdminGateway(t, map[string]interface{}{})
+	defer gateway.Close()
+
+	res, err := gateway.MakeRequest("GET", "/admin/routes", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 404, res.StatusCode)
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	gateway := createAdminGateway(t, map[string]interface{}{
+		"admin.enabled":        true,
+		"clients.bar.apiToken": "do-not-leak",
+	})
+	defer gateway.Close()
+
+	var config map[string]interface{}
+	assert.Equal(t, 200, getAdmin(t, gateway, "/admin/config", &config))
+	assert.Equal(t, "<redacted>", config["clients.bar.apiToken"])
+	assert.Equal(t, "bar", config["clients.bar.serviceName"])
+
+	var routes []map[string]interface{}
+	assert.Equal(t, 200, getAdmin(t, gateway, "/admin/routes", &routes))
+	var found bool
+	for _, route := range routes {
+		if route["path"] == "/bar/arg-not-struct-path" {
+			found = true
+			assert.Equal(t, "POST", route["method"])
+			assert.Equal(t, "bar", route["endpointID"])
+			assert.Equal(t, "argNotStruct", route["handlerID"])
+			assert.Equal(t, []interface{}{"default_example2", "default_example"}, route["middlewares"])
+		}
+	}
+	assert.True(t, found, "bar route is listed")
+
+	var methods []map[string]interface{}
+	assert.Equal(t, 200, getAdmin(t, gateway, "/admin/tchannel", &methods))
+	found = false
+	for _, method := range methods {
+		if method["method"] == "Echo::echo" {
+			found = true
+			assert.Equal(t, "echo", method["endpointID"])
+			assert.Equal(t, []interface{}{"default_example_tchannel"}, method["middlewares"])
+		}
+	}
+	assert.True(t, found, "echo method is listed")
+
+	var stacks []map[string]interface{}
+	assert.Equal(t, 200, getAdmin(t, gateway, "/admin/middlewares", &stacks))
+	assert.NotEmpty(t, stacks)
+
+	var clients []map[string]interface{}
+	assert.Equal(t, 200, getAdmin(t, gateway, "/admin/clients", &clients))
+	found = false
+	for _, client := range clients {
+		if client["clientID"] == "bar" {
+			found = true
+			assert.NotEmpty(t, client["circuitBreakers"])
+		}
+	}
+	assert.True(t, found, "bar client is listed")
+
+	var level map[string]interface{}
+	assert.Equal(t, 200, getAdmin(t, gateway, "/admin/loglevel", &level))
+	assert.NotEmpty(t, level["level"])
+}
+
+func TestAdminAllowlist(t *testing.T) {
+	gateway := createAdminGateway(t, map[string]interface{}{
+		"admin.enabled":   true,
+		"admin.allowlist": []string{"10.0.0.0/8"},
+	})
+	defer gateway.Close()
+
+	var routes []map[string]interface{}
+	assert.Equal(t, 403, getAdmin(t, gateway, "/admin/routes", &routes))
+}
+
+func TestAdminSetLogLevel(t *testing.T) {
+	gateway := createAdminGateway(t, map[string]interface{}{
+		"admin.enabled": true,
+	})
+	defer gateway.Close()
+
+	res, err := gateway.MakeRequest("PUT", "/admin/loglevel", nil,
+		strings.NewReader(`{"logger":"http","level":"debug","ttl":"5m"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode)
+
+	var levels struct {
+		Level   string            `json:"level"`
+		Loggers map[string]string `json:"loggers"`
+	}
+	assert.Equal(t, 200, getAdmin(t, gateway, "/admin/loglevel", &levels))
+	assert.Equal(t, "debug", levels.Loggers["http"])
+	assert.Equal(t, levels.Level, levels.Loggers["root"])
+
+	res, err = gateway.MakeRequest("PUT", "/admin/loglevel", nil,
+		strings.NewReader(`{"logger":"unknown","level":"debug"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode)
+
+	res, err = gateway.MakeRequest("PUT", "/admin/loglevel", nil,
+		strings.NewReader(`{"level":"loud"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gateway_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exampleGateway "github.com/uber/zanzibar/examples/example-gateway/build/services/example-gateway"
+	benchGateway "github.com/uber/zanzibar/test/lib/bench_gateway"
+	testGateway "github.com/uber/zanzibar/test/lib/test_gateway"
+	"github.com/uber/zanzibar/test/lib/util"
+)
+
+func TestDeprecatedAPIVersion(t *testing.T) {
+	gw, err := benchGateway.CreateGateway(
+		map[string]interface{}{
+			"clients.baz.serviceName": "baz",
+		},
+		&testGateway.Options{
+			TestBinary:        util.DefaultMainFile("example-gateway"),
+			ConfigFiles:       util.DefaultConfigFiles("example-gateway"),
+			KnownHTTPBackends: []string{"bar", "contacts", "google-now"},
+		},
+		exampleGateway.CreateGateway,
+	)
+	require.NoError(t, err)
+	gateway := gw.(*benchGateway.BenchGateway)
+	defer gateway.Close()
+
+	gateway.HTTPBackends()["bar"].HandleFunc(
+		"GET", "/bar/hello",
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			_, _ = w.Write([]byte(`"hello"`))
+		},
+	)
+
+	// bar/hello.yaml deprecates v1, which is served under /v1
+	res, err := gateway.MakeRequest("GET", "/v1/bar/hello", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "true", res.Header.Get("Deprecation"))
+	assert.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", res.Header.Get("Sunset"))
+
+	res, err = gateway.MakeRequest("GET", "/bar/hello", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Empty(t, res.Header.Get("Deprecation"))
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gateway_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exampleGateway "github.com/uber/zanzibar/examples/example-gateway/build/services/example-gateway"
+	zanzibar "github.com/uber/zanzibar/runtime"
+	benchGateway "github.com/uber/zanzibar/test/lib/bench_gateway"
+	testGateway "github.com/uber/zanzibar/test/lib/test_gateway"
+	"github.com/uber/zanzibar/test/lib/util"
+)
+
+// createSlowBarGateway starts a gateway whose bar backend only answers
+// /bar/hello once the gateway gave up, it reports the budget it was sent.
+func createSlowBarGateway(t *testing.T, config map[string]interface{}) (*benchGateway.BenchGateway, chan string) {
+	gw, err := benchGateway.CreateGateway(
+		config,
+		&testGateway.Options{
+			TestBinary:        util.DefaultMainFile("example-gateway"),
+			ConfigFiles:       util.DefaultConfigFiles("example-gateway"),
+			KnownHTTPBackends: []string{"bar", "contacts", "google-now"},
+		},
+		exampleGateway.CreateGateway,
+	)
+	require.NoError(t, err)
+	gateway := gw.(*benchGateway.BenchGateway)
+
+	ttls := make(chan string, 1)
+	gateway.HTTPBackends()["bar"].HandleFunc(
+		"GET", "/bar/hello",
+		func(w http.ResponseWriter, r *http.Request) {
+			ttls <- r.Header.Get(zanzibar.ContextTTLHeader)
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			w.WriteHeader(200)
+			_, _ = w.Write([]byte(`"hello"`))
+		},
+	)
+	return gateway, ttls
+}
+
+func assertBudget(t *testing.T, ttl string, max int) {
+	ms, err := strconv.Atoi(ttl)
+	require.NoError(t, err, ttl)
+	assert.True(t, ms > 0 && ms <= max, "unexpected budget %d", ms)
+}
+
+func TestEndpointTimeout(t *testing.T) {
+	gateway, ttls := createSlowBarGateway(t, map[string]interface{}{
+		"http.endpointTimeout": 100,
+	})
+	defer gateway.Close()
+
+	start := time.Now()
+	res, err := gateway.MakeRequest("GET", "/bar/hello", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assertBudget(t, <-ttls, 100)
+}
+
+func TestContextTTLHeader(t *testing.T) {
+	gateway, ttls := createSlowBarGateway(t, map[string]interface{}{})
+	defer gateway.Close()
+
+	res, err := gateway.MakeRequest("GET", "/bar/hello", map[string]string{
+		zanzibar.ContextTTLHeader: "100",
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+	assertBudget(t, <-ttls, 100)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gateway_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exampleGateway "github.com/uber/zanzibar/examples/example-gateway/build/services/example-gateway"
+	benchGateway "github.com/uber/zanzibar/test/lib/bench_gateway"
+	testGateway "github.com/uber/zanzibar/test/lib/test_gateway"
+	"github.com/uber/zanzibar/test/lib/util"
+)
+
+func TestShutdownDrain(t *testing.T) {
+	gw, err := benchGateway.CreateGateway(
+		map[string]interface{}{
+			"shutdown.drainDelay": 300,
+			"shutdown.timeout":    100,
+		},
+		&testGateway.Options{
+			TestBinary:        util.DefaultMainFile("example-gateway"),
+			ConfigFiles:       util.DefaultConfigFiles("example-gateway"),
+			KnownHTTPBackends: []string{"bar", "contacts", "google-now"},
+		},
+		exampleGateway.CreateGateway,
+	)
+	require.NoError(t, err)
+	gateway := gw.(*benchGateway.BenchGateway)
+
+	release := make(chan struct{})
+	gateway.HTTPBackends()["bar"].HandleFunc(
+		"GET", "/bar/hello",
+		func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.WriteHeader(200)
+			_, _ = w.Write([]byte(`"hello"`))
+		},
+	)
+
+	slow := make(chan *http.Response, 1)
+	go func() {
+		res, err := gateway.MakeRequest("GET", "/bar/hello", nil, nil)
+		assert.NoError(t, err)
+		slow <- res
+	}()
+	require.Eventually(t, func() bool {
+		return gateway.ActualGateway.InflightRequests()["GET /bar/hello"] == 1
+	}, time.Second, 5*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		gateway.ActualGateway.Shutdown()
+		close(done)
+	}()
+
+	// health fails while requests are still served during the drain delay
+	require.Eventually(t, func() bool {
+		res, err := gateway.MakeRequest("GET", "/health", nil, nil)
+		return err == nil && res.StatusCode == 503
+	}, time.Second, 5*time.Millisecond)
+	res, err := gateway.MakeRequest("GET", "/health/ready", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 503, res.StatusCode)
+	res, err = gateway.MakeRequest("GET", "/health/live", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not finish")
+	}
+	close(release)
+	assert.Equal(t, 200, (<-slow).StatusCode)
+
+	logs := gateway.Logs("warn", "Requests still in flight at shutdown timeout")
+	require.Len(t, logs, 1)
+	assert.Equal(t, float64(1), logs[0]["inflight"])
+	assert.Equal(t, map[string]interface{}{"GET /bar/hello": float64(1)}, logs[0]["endpoints"])
+	assert.Len(t, gateway.Logs("info", "Draining before shutting down the servers"), 1)
+}
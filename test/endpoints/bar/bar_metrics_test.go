@@ -98,16 +98,17 @@ func TestCallMetrics(t *testing.T) {
 	assert.Equal(t, numMetrics+4, len(metrics))
 
 	endpointTags := map[string]string{
-		"env":            "test",
-		"service":        "test-gateway",
-		"endpointid":     "bar",
-		"handlerid":      "normal",
-		"regionname":     "san_francisco",
-		"device":         "ios",
-		"deviceversion":  "carbon",
-		"dc":             "unknown",
-		"protocol":       "HTTP",
-		"apienvironment": "production",
+		"env":             "test",
+		"service":         "test-gateway",
+		"endpointid":      "bar",
+		"handlerid":       "normal",
+		"regionname":      "san_francisco",
+		"device":          "ios",
+		"deviceversion":   "carbon",
+		"dc":              "unknown",
+		"protocol":        "HTTP",
+		"protocolversion": "1.1",
+		"apienvironment":  "production",
 	}
 	statusTags := map[string]string{
 		"status":     "200",
@@ -147,19 +148,20 @@ func TestCallMetrics(t *testing.T) {
 	assert.Equal(t, int64(1), value, "expected counter to be 1")
 
 	httpClientTags := map[string]string{
-		"env":            "test",
-		"service":        "test-gateway",
-		"clientid":       "bar",
-		"clientmethod":   "Normal",
-		"targetendpoint": "Bar--normal",
-		"dc":             "unknown",
-		"endpointid":     "bar",
-		"handlerid":      "normal",
-		"regionname":     "san_francisco",
-		"device":         "ios",
-		"deviceversion":  "carbon",
-		"protocol":       "HTTP",
-		"apienvironment": "production",
+		"env":             "test",
+		"service":         "test-gateway",
+		"clientid":        "bar",
+		"clientmethod":    "Normal",
+		"targetendpoint":  "Bar--normal",
+		"dc":              "unknown",
+		"endpointid":      "bar",
+		"handlerid":       "normal",
+		"regionname":      "san_francisco",
+		"device":          "ios",
+		"deviceversion":   "carbon",
+		"protocol":        "HTTP",
+		"protocolversion": "1.1",
+		"apienvironment":  "production",
 	}
 	cStatusTags := map[string]string{
 		"status": "200",
@@ -116,16 +116,17 @@ func TestCallMetrics(t *testing.T) {
 	assert.Equal(t, numMetrics+5, len(metrics)) // magic number here because there are histogram entries
 
 	endpointTags := map[string]string{
-		"env":            "test",
-		"service":        "test-gateway",
-		"endpointid":     "baz",
-		"handlerid":      "call",
-		"regionname":     "san_francisco",
-		"device":         "ios",
-		"deviceversion":  "carbon",
-		"dc":             "unknown",
-		"protocol":       "HTTP",
-		"apienvironment": "production",
+		"env":             "test",
+		"service":         "test-gateway",
+		"endpointid":      "baz",
+		"handlerid":       "call",
+		"regionname":      "san_francisco",
+		"device":          "ios",
+		"deviceversion":   "carbon",
+		"dc":              "unknown",
+		"protocol":        "HTTP",
+		"protocolversion": "1.1",
+		"apienvironment":  "production",
 	}
 	statusTags := map[string]string{
 		"status":     "204",
@@ -202,20 +203,21 @@ func TestCallMetrics(t *testing.T) {
 		"client.success",
 	}
 	clientTags := map[string]string{
-		"env":            "test",
-		"service":        "test-gateway",
-		"clientid":       "baz",
-		"clientmethod":   "call",
-		"targetservice":  "bazService",
-		"targetendpoint": "SimpleService__call",
-		"dc":             "unknown",
-		"endpointid":     "baz",
-		"handlerid":      "call",
-		"regionname":     "san_francisco",
-		"device":         "ios",
-		"deviceversion":  "carbon",
-		"protocol":       "HTTP",
-		"apienvironment": "production",
+		"env":             "test",
+		"service":         "test-gateway",
+		"clientid":        "baz",
+		"clientmethod":    "call",
+		"targetservice":   "bazService",
+		"targetendpoint":  "SimpleService__call",
+		"dc":              "unknown",
+		"endpointid":      "baz",
+		"handlerid":       "call",
+		"regionname":      "san_francisco",
+		"device":          "ios",
+		"deviceversion":   "carbon",
+		"protocol":        "HTTP",
+		"protocolversion": "1.1",
+		"apienvironment":  "production",
 	}
 	for _, name := range clientNames {
 		key := tally.KeyForPrefixedStringMap(name, clientTags)
@@ -21,12 +21,16 @@
 package gateway_test
 
 import (
+	"context"
+	"encoding/json"
 	"io"
+	"net/http"
 	"testing"
 
 	"github.com/uber-go/tally/m3"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/uber-go/tally"
 	exampleGateway "github.com/uber/zanzibar/examples/example-gateway/build/services/example-gateway"
 	zanzibar "github.com/uber/zanzibar/runtime"
@@ -132,16 +136,17 @@ func TestHealthMetrics(t *testing.T) {
 	metrics := cgateway.M3Service.GetMetrics()
 	assert.Equal(t, numMetrics, len(metrics))
 	tags := map[string]string{
-		"env":            "test",
-		"service":        "test-gateway",
-		"endpointid":     "health",
-		"handlerid":      "health",
-		"regionname":     "san_francisco",
-		"device":         "ios",
-		"deviceversion":  "carbon",
-		"dc":             "unknown",
-		"protocol":       "HTTP",
-		"apienvironment": "production",
+		"env":             "test",
+		"service":         "test-gateway",
+		"endpointid":      "health",
+		"handlerid":       "health",
+		"regionname":      "san_francisco",
+		"device":          "ios",
+		"deviceversion":   "carbon",
+		"dc":              "unknown",
+		"protocol":        "HTTP",
+		"protocolversion": "1.1",
+		"apienvironment":  "production",
 	}
 	statusTags := map[string]string{
 		"status":     "200",
@@ -250,3 +255,82 @@ func TestRuntimeMetrics(t *testing.T) {
 	}
 	assert.Contains(t, metrics, tally.KeyForPrefixedStringMap(histogramName, histogramTags))
 }
+
+type healthReport struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+	Checks  []struct {
+		Name     string `json:"name"`
+		Status   string `json:"status"`
+		Critical bool   `json:"critical"`
+		Error    string `json:"error"`
+	} `json:"checks"`
+}
+
+func getHealth(t *testing.T, gateway *benchGateway.BenchGateway, path string) (int, healthReport) {
+	res, err := gateway.MakeRequest("GET", path, nil, nil)
+	require.NoError(t, err)
+	defer func() { _ = res.Body.Close() }()
+	bytes, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	var report healthReport
+	require.NoError(t, json.Unmarshal(bytes, &report), string(bytes))
+	return res.StatusCode, report
+}
+
+func TestHealthLiveAndReady(t *testing.T) {
+	gateway := createAdminGateway(t, map[string]interface{}{})
+	defer gateway.Close()
+
+	status, report := getHealth(t, gateway, "/health/live")
+	assert.Equal(t, 200, status)
+	assert.True(t, report.OK)
+
+	status, report = getHealth(t, gateway, "/health/ready")
+	assert.Equal(t, 200, status)
+	assert.True(t, report.OK)
+	assert.Empty(t, report.Checks)
+}
+
+func TestHealthReadyWithCriticalClient(t *testing.T) {
+	gw, err := benchGateway.CreateGateway(
+		map[string]interface{}{
+			"clients.bar.healthCheck.enabled":  true,
+			"clients.bar.healthCheck.critical": true,
+			"clients.bar.healthCheck.path":     "/status",
+		},
+		&testGateway.Options{
+			TestBinary:        util.DefaultMainFile("example-gateway"),
+			ConfigFiles:       util.DefaultConfigFiles("example-gateway"),
+			KnownHTTPBackends: []string{"bar", "contacts", "google-now"},
+		},
+		exampleGateway.CreateGateway,
+	)
+	require.NoError(t, err)
+	gateway := gw.(*benchGateway.BenchGateway)
+	defer gateway.Close()
+
+	// the bar backend does not serve the health path yet
+	gateway.ActualGateway.HealthChecker.Refresh(context.Background())
+	status, report := getHealth(t, gateway, "/health/ready")
+	assert.Equal(t, 503, status)
+	assert.False(t, report.OK)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "bar", report.Checks[0].Name)
+	assert.Equal(t, "unhealthy", report.Checks[0].Status)
+	assert.True(t, report.Checks[0].Critical)
+	assert.Contains(t, report.Checks[0].Error, "404")
+
+	// liveness does not depend on downstreams
+	status, _ = getHealth(t, gateway, "/health/live")
+	assert.Equal(t, 200, status)
+
+	gateway.HTTPBackends()["bar"].HandleFunc("GET", "/status", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	gateway.ActualGateway.HealthChecker.Refresh(context.Background())
+	status, report = getHealth(t, gateway, "/health/ready")
+	assert.Equal(t, 200, status)
+	assert.True(t, report.OK)
+	assert.Equal(t, "healthy", report.Checks[0].Status)
+}
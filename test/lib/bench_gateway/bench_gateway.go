@@ -113,13 +113,20 @@ func CreateGateway(
 	seedConfig["metrics.serviceName"] = "bench-gateway"
 	seedConfig["metrics.m3.includeHost"] = true
 
-	benchGateway := &BenchGateway{
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	var benchGateway *BenchGateway
+	benchGateway = &BenchGateway{
 		httpClient: &http.Client{
 			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   30 * time.Second,
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					if socketPath := benchGateway.socketPath(); socketPath != "" {
+						return dialer.DialContext(ctx, "unix", socketPath)
+					}
+					return dialer.DialContext(ctx, network, addr)
+				},
 				DisableKeepAlives:     false,
 				MaxIdleConns:          50000,
 				MaxIdleConnsPerHost:   50000,
@@ -262,13 +269,30 @@ func (gateway *BenchGateway) TChannelBackends() map[string]*testBackend.TestTCha
 	return gateway.backendsTChannel
 }
 
+// socketPath returns the Unix domain socket the gateway listens on, if any.
+func (gateway *BenchGateway) socketPath() string {
+	if gateway.ActualGateway == nil || gateway.ActualGateway.RealHTTPPort != 0 {
+		return ""
+	}
+	return gateway.ActualGateway.RealHTTPAddr
+}
+
+// baseURL returns the URL of the gateway, the host is only a placeholder
+// when requests are sent over a Unix domain socket.
+func (gateway *BenchGateway) baseURL() string {
+	if gateway.socketPath() != "" {
+		return "http://unix"
+	}
+	return "http://" + gateway.ActualGateway.RealHTTPAddr
+}
+
 // MakeRequest helper
 func (gateway *BenchGateway) MakeRequest(
 	method string, url string, headers map[string]string, body io.Reader,
 ) (*http.Response, error) {
 	client := gateway.httpClient
 
-	fullURL := "http://" + gateway.ActualGateway.RealHTTPAddr + url
+	fullURL := gateway.baseURL() + url
 
 	req, err := http.NewRequest(method, fullURL, body)
 	if err != nil {
@@ -288,7 +312,7 @@ func (gateway *BenchGateway) MakeRequestWithHeaderValues(
 ) (*http.Response, error) {
 	client := gateway.httpClient
 
-	fullURL := "http://" + gateway.ActualGateway.RealHTTPAddr + url
+	fullURL := gateway.baseURL() + url
 
 	req, err := http.NewRequest(method, fullURL, body)
 	if err != nil {
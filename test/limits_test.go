@@ -0,0 +1,88 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gateway_test
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exampleGateway "github.com/uber/zanzibar/examples/example-gateway/build/services/example-gateway"
+	benchGateway "github.com/uber/zanzibar/test/lib/bench_gateway"
+	testGateway "github.com/uber/zanzibar/test/lib/test_gateway"
+	"github.com/uber/zanzibar/test/lib/util"
+)
+
+func createLimitsGateway(t *testing.T, config map[string]interface{}) *benchGateway.BenchGateway {
+	gw, err := benchGateway.CreateGateway(
+		config,
+		&testGateway.Options{
+			TestBinary:        util.DefaultMainFile("example-gateway"),
+			ConfigFiles:       util.DefaultConfigFiles("example-gateway"),
+			KnownHTTPBackends: []string{"bar", "contacts", "google-now"},
+		},
+		exampleGateway.CreateGateway,
+	)
+	require.NoError(t, err)
+	return gw.(*benchGateway.BenchGateway)
+}
+
+func TestEndpointMaxBodyBytes(t *testing.T) {
+	gateway := createLimitsGateway(t, map[string]interface{}{})
+	defer gateway.Close()
+
+	var called bool
+	gateway.HTTPBackends()["bar"].HandleFunc(
+		"POST", "/bar/argWithHeaders",
+		func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			w.WriteHeader(200)
+		},
+	)
+
+	body := `{"name": "` + strings.Repeat("a", 2048) + `"}`
+	res, err := gateway.MakeRequest("POST", "/bar/argWithHeaders", map[string]string{
+		"x-uuid": "a-uuid",
+	}, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+	assert.False(t, called)
+}
+
+func TestMaxHeaderBytes(t *testing.T) {
+	gateway := createLimitsGateway(t, map[string]interface{}{
+		"http.maxHeaderBytes": 1024,
+	})
+	defer gateway.Close()
+
+	res, err := gateway.MakeRequest("GET", "/health", map[string]string{
+		"X-Large": strings.Repeat("a", 2048),
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, res.StatusCode)
+
+	res, err = gateway.MakeRequest("GET", "/health", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2023 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package gateway_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	endpointsBaz "github.com/uber/zanzibar/examples/example-gateway/build/gen-code/endpoints-idl/endpoints/tchannel/baz/baz"
+	exampleGateway "github.com/uber/zanzibar/examples/example-gateway/build/services/example-gateway"
+	zanzibar "github.com/uber/zanzibar/runtime"
+	benchGateway "github.com/uber/zanzibar/test/lib/bench_gateway"
+	testGateway "github.com/uber/zanzibar/test/lib/test_gateway"
+	"github.com/uber/zanzibar/test/lib/util"
+)
+
+func createRateLimitedGateway(t *testing.T) *benchGateway.BenchGateway {
+	gw, err := benchGateway.CreateGateway(
+		map[string]interface{}{
+			"clients.baz.serviceName": "baz",
+			"rateLimit.enabled":       true,
+			"rateLimit.rps":           1,
+			"rateLimit.burst":         1,
+		},
+		&testGateway.Options{
+			TestBinary:        util.DefaultMainFile("example-gateway"),
+			ConfigFiles:       util.DefaultConfigFiles("example-gateway"),
+			KnownHTTPBackends: []string{"bar", "contacts", "google-now"},
+		},
+		exampleGateway.CreateGateway,
+	)
+	require.NoError(t, err)
+	return gw.(*benchGateway.BenchGateway)
+}
+
+func TestHTTPRateLimit(t *testing.T) {
+	gateway := createRateLimitedGateway(t)
+	defer gateway.Close()
+
+	gateway.HTTPBackends()["bar"].HandleFunc(
+		"GET", "/bar/hello",
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+			_, _ = w.Write([]byte(`"hello"`))
+		},
+	)
+
+	res, err := gateway.MakeRequest("GET", "/bar/hello", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = gateway.MakeRequest("GET", "/bar/hello", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal(t, "1", res.Header.Get("Retry-After"))
+
+	// endpoints are limited separately
+	res, err = gateway.MakeRequest("GET", "/health", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestHTTPRateLimitSkipsHealthEndpoints(t *testing.T) {
+	gateway := createRateLimitedGateway(t)
+	defer gateway.Close()
+
+	// load balancer probes must not be rejected however often they come
+	for i := 0; i < 5; i++ {
+		for _, path := range []string{"/health", "/health/live", "/health/ready"} {
+			res, err := gateway.MakeRequest("GET", path, nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.StatusCode, path)
+		}
+	}
+}
+
+func TestTChannelRateLimit(t *testing.T) {
+	gateway := createRateLimitedGateway(t)
+	defer gateway.Close()
+
+	echo := func() error {
+		var result endpointsBaz.SimpleService_Echo_Result
+		_, _, err := gateway.MakeTChannelRequest(
+			context.Background(), "SimpleService", "Echo", nil,
+			&endpointsBaz.SimpleService_Echo_Args{Msg: "hello"}, &result,
+			&zanzibar.TimeoutAndRetryOptions{
+				OverallTimeoutInMs:           time.Second,
+				RequestTimeoutPerAttemptInMs: time.Second,
+				MaxAttempts:                  0,
+				BackOffTimeAcrossRetriesInMs: zanzibar.DefaultBackOffTimeAcrossRetries,
+			},
+		)
+		return err
+	}
+
+	require.NoError(t, echo())
+	err := echo()
+	require.Error(t, err)
+	assert.Equal(t, tchannel.ErrCodeBusy, tchannel.GetSystemErrorCode(errors.Cause(err)))
+}